@@ -0,0 +1,204 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+// batchSize is the number of keys for which AddMany and HasMany
+// make their first probes before the other probes.
+const batchSize = 16
+
+// A probeState holds a key's block and the hash values for its next probe.
+type probeState struct {
+	b          *block
+	h1, h2, h3 uint32
+}
+
+// addBatch adds the keys with hash values hashes to f. For filters larger
+// than the CPU caches, it is faster than a loop around add, because it makes
+// the first probe for a batch of keys before making the other probes.
+// The first probes access independent blocks, so the CPU fetches these
+// blocks from memory in parallel, and the other probes hit the cache.
+func (f *Filter) addBatch(hashes []uint64) {
+	var s [batchSize]probeState
+	for len(hashes) > 0 {
+		batch := hashes
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		hashes = hashes[len(batch):]
+
+		for i, h := range batch {
+			h = mix(h, f.probeSeed)
+			b := getblock(f.b, uint32(h))
+			h3 := thirdhash(f.probing, uint32(h>>32))
+			h1, h2 := probehash(uint32(h>>32), uint32(h), h3, 1)
+			b.setbit(h1)
+			s[i] = probeState{b, h1, h2, h3}
+		}
+		for i := range batch {
+			p := &s[i]
+			for j := 2; j < f.k; j++ {
+				p.h1, p.h2 = probehash(p.h1, p.h2, p.h3, j)
+				p.b.setbit(p.h1)
+			}
+		}
+	}
+}
+
+// HasMany sets result[i] to f.Has(hashes[i]) for each i. It panics if
+// result is shorter than hashes.
+//
+// HasMany uses the same batching as AddMany, which makes it faster
+// than a loop around Has for filters larger than the CPU caches.
+func (f *Filter) HasMany(hashes []uint64, result []bool) {
+	checkResult(hashes, result)
+
+	var s [batchSize]probeState
+	for len(hashes) > 0 {
+		batch := hashes
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		hashes = hashes[len(batch):]
+		res := result[:len(batch)]
+		result = result[len(batch):]
+
+		for i, h := range batch {
+			h = mix(h, f.probeSeed)
+			b := getblock(f.b, uint32(h))
+			h3 := thirdhash(f.probing, uint32(h>>32))
+			h1, h2 := probehash(uint32(h>>32), uint32(h), h3, 1)
+			res[i] = b.getbit(h1)
+			s[i] = probeState{b, h1, h2, h3}
+		}
+		for i := range batch {
+			p := &s[i]
+			for j := 2; res[i] && j < f.k; j++ {
+				p.h1, p.h2 = probehash(p.h1, p.h2, p.h3, j)
+				res[i] = p.b.getbit(p.h1)
+			}
+		}
+
+		if f.rec != nil {
+			for i, h := range batch {
+				f.rec.RecordHas(h, res[i])
+			}
+		}
+	}
+}
+
+// addBatch is like Filter.addBatch.
+func (f *SyncFilter) addBatch(hashes []uint64) {
+	var s [batchSize]probeState
+	for len(hashes) > 0 {
+		batch := hashes
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		hashes = hashes[len(batch):]
+
+		for i, h := range batch {
+			h = mix(h, f.probeSeed)
+			b := getblock(f.b, uint32(h))
+			h3 := thirdhash(f.probing, uint32(h>>32))
+			h1, h2 := probehash(uint32(h>>32), uint32(h), h3, 1)
+			setbitAtomic(b, h1)
+			s[i] = probeState{b, h1, h2, h3}
+		}
+		for i := range batch {
+			p := &s[i]
+			for j := 2; j < f.k; j++ {
+				p.h1, p.h2 = probehash(p.h1, p.h2, p.h3, j)
+				setbitAtomic(p.b, p.h1)
+			}
+		}
+	}
+}
+
+// HasMany sets result[i] to f.Has(hashes[i]) for each i. It panics if
+// result is shorter than hashes.
+//
+// HasMany uses the same batching as AddMany, which makes it faster
+// than a loop around Has for filters larger than the CPU caches.
+func (f *SyncFilter) HasMany(hashes []uint64, result []bool) {
+	checkResult(hashes, result)
+
+	var s [batchSize]probeState
+	for len(hashes) > 0 {
+		batch := hashes
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		hashes = hashes[len(batch):]
+		res := result[:len(batch)]
+		result = result[len(batch):]
+
+		for i, h := range batch {
+			h = mix(h, f.probeSeed)
+			b := getblock(f.b, uint32(h))
+			h3 := thirdhash(f.probing, uint32(h>>32))
+			h1, h2 := probehash(uint32(h>>32), uint32(h), h3, 1)
+			res[i] = getbitAtomic(b, h1)
+			s[i] = probeState{b, h1, h2, h3}
+		}
+		for i := range batch {
+			p := &s[i]
+			for j := 2; res[i] && j < f.k; j++ {
+				p.h1, p.h2 = probehash(p.h1, p.h2, p.h3, j)
+				res[i] = getbitAtomic(p.b, p.h1)
+			}
+		}
+
+		if f.rec != nil {
+			for i, h := range batch {
+				f.rec.RecordHas(h, res[i])
+			}
+		}
+	}
+}
+
+// Has64 reports which of 64 keys have been added, as a bitmask: bit i of
+// the result is set if f.Has(hashes[i]) would return true.
+//
+// Has64 makes its probes like HasMany, without requiring the caller to
+// allocate a result slice, and suits pipelines that process keys in
+// fixed-size vectors, such as column scans.
+func (f *Filter) Has64(hashes *[64]uint64) uint64 {
+	var result [64]bool
+	f.HasMany(hashes[:], result[:])
+	return bitmask(&result)
+}
+
+// Has64 reports which of 64 keys have been added, as a bitmask.
+// See Filter.Has64 for details.
+func (f *SyncFilter) Has64(hashes *[64]uint64) uint64 {
+	var result [64]bool
+	f.HasMany(hashes[:], result[:])
+	return bitmask(&result)
+}
+
+func bitmask(b *[64]bool) (mask uint64) {
+	for i, x := range b {
+		if x {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
+func checkResult(hashes []uint64, result []bool) {
+	if len(result) < len(hashes) {
+		panic("result slice shorter than hashes")
+	}
+}
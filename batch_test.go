@@ -0,0 +1,62 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddManyHasMany(t *testing.T) {
+	const nbits, nhashes = 1 << 16, 6
+
+	keys := randomU64(3*batchSize+5, 0xba7c4)
+	f, g := New(nbits, nhashes), New(nbits, nhashes)
+	s := NewSync(nbits, nhashes)
+
+	for _, h := range keys {
+		f.Add(h)
+	}
+	g.AddMany(keys)
+	s.AddMany(keys)
+	assert.True(t, f.Equals(g))
+	for i := range f.b {
+		assert.Equal(t, f.b[i], s.b[i])
+	}
+
+	queries := append(randomU64(1000, 0xba7c5), keys...)
+	res := make([]bool, len(queries))
+	resSync := make([]bool, len(queries))
+	g.HasMany(queries, res)
+	s.HasMany(queries, resSync)
+	for i, h := range queries {
+		assert.Equal(t, f.Has(h), res[i])
+		assert.Equal(t, f.Has(h), resSync[i])
+	}
+
+	var vec [64]uint64
+	copy(vec[:], queries[len(queries)-32:])
+	copy(vec[32:], queries)
+	mask, maskSync := g.Has64(&vec), s.Has64(&vec)
+	for i, h := range vec {
+		assert.Equal(t, f.Has(h), mask&(1<<uint(i)) != 0)
+		assert.Equal(t, f.Has(h), maskSync&(1<<uint(i)) != 0)
+	}
+	assert.Equal(t, uint64(0xffffffff), mask&0xffffffff)
+
+	g.HasMany(nil, nil)
+	assert.Panics(t, func() { g.HasMany(keys, res[:len(keys)-1]) })
+}
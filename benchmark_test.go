@@ -73,6 +73,28 @@ func BenchmarkAddSync128kB(b *testing.B) { benchmarkAddSync(b, 1<<20) }
 func BenchmarkAddSync1MB(b *testing.B)   { benchmarkAddSync(b, 1<<23) }
 func BenchmarkAddSync16MB(b *testing.B)  { benchmarkAddSync(b, 1<<27) }
 
+func benchmarkAddSharded(b *testing.B, nbits uint64) {
+	b.Helper()
+
+	const nhashes = 22 // Large number of hashes to create collisions.
+
+	f := NewSharded(nbits, nhashes, 64)
+	var seed uint32
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(int64(atomic.AddUint32(&seed, 1))))
+		for pb.Next() {
+			f.Add(r.Uint64())
+		}
+	})
+}
+
+func BenchmarkAddSharded128kB(b *testing.B) { benchmarkAddSharded(b, 1<<20) }
+func BenchmarkAddSharded1MB(b *testing.B)   { benchmarkAddSharded(b, 1<<23) }
+func BenchmarkAddSharded16MB(b *testing.B)  { benchmarkAddSharded(b, 1<<27) }
+
 func BenchmarkCardinalityDense(b *testing.B) {
 	f := New(1<<20, 2)
 	for i := range f.b {
@@ -150,3 +172,31 @@ func BenchmarkUnion(b *testing.B) {
 		f.Union(g)
 	}
 }
+
+func benchmarkHasMany(b *testing.B, batch bool) {
+	b.Helper()
+
+	const nbits, nhashes = 1 << 30, 8 // 128MiB.
+
+	// Enough keys to defeat the CPU caches. All keys are present,
+	// so every lookup makes all probes.
+	f := New(nbits, nhashes)
+	hashes := randomU64(1<<20, 0x6a5)
+	f.AddMany(hashes)
+	result := make([]bool, len(hashes))
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if batch {
+			f.HasMany(hashes, result)
+			continue
+		}
+		for j, h := range hashes {
+			result[j] = f.Has(h)
+		}
+	}
+}
+
+func BenchmarkHasLoop(b *testing.B) { benchmarkHasMany(b, false) }
+func BenchmarkHasMany(b *testing.B) { benchmarkHasMany(b, true) }
@@ -0,0 +1,36 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cuckoo
+// +build cuckoo
+
+package benchmarks
+
+import cuckoo "github.com/seiflotfy/cuckoofilter"
+
+// Cuckoo filters have a fixed false positive rate, determined by
+// their 8-bit fingerprints, so fpr is ignored.
+type bloomFilter cuckoo.Filter
+
+func (f *bloomFilter) Add(hash []byte) {
+	((*cuckoo.Filter)(f)).Insert(hash)
+}
+
+func (f *bloomFilter) Has(hash []byte) bool {
+	return ((*cuckoo.Filter)(f)).Lookup(hash)
+}
+
+func newBF(capacity int, fpr float64) *bloomFilter {
+	return (*bloomFilter)(cuckoo.NewFilter(uint(capacity)))
+}
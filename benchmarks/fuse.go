@@ -0,0 +1,56 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build fuse
+// +build fuse
+
+package benchmarks
+
+import (
+	"encoding/binary"
+
+	"github.com/FastFilter/xorfilter"
+)
+
+// Binary fuse filters are static: they are built from a complete set of
+// keys. This adapter collects the keys passed to Add and builds the filter
+// on the first call to Has, after which it cannot be modified. Like cuckoo
+// filters, these filters have a fixed false positive rate.
+type bloomFilter struct {
+	keys []uint64
+	f    *xorfilter.BinaryFuse8
+}
+
+func (f *bloomFilter) Add(hash []byte) {
+	if f.f != nil {
+		panic("xorfilter: Add after Has")
+	}
+	f.keys = append(f.keys, binary.BigEndian.Uint64(hash[:8]))
+}
+
+func (f *bloomFilter) Has(hash []byte) bool {
+	if f.f == nil {
+		var err error
+		f.f, err = xorfilter.PopulateBinaryFuse8(f.keys)
+		if err != nil {
+			panic(err)
+		}
+		f.keys = nil
+	}
+	return f.f.Contains(binary.BigEndian.Uint64(hash[:8]))
+}
+
+func newBF(capacity int, fpr float64) *bloomFilter {
+	return &bloomFilter{keys: make([]uint64, 0, capacity)}
+}
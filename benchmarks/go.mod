@@ -3,14 +3,17 @@ module github.com/greatroar/blobloom/benchmarks
 go 1.16
 
 require (
+	github.com/FastFilter/xorfilter v0.1.3
 	github.com/bits-and-blooms/bitset v1.7.0 // indirect
 	github.com/bits-and-blooms/bloom/v3 v3.3.1
 	github.com/cespare/xxhash/v2 v2.2.0
 	github.com/d4l3k/messagediff v1.2.1 // indirect
 	github.com/devopsfaith/bloomfilter v1.4.0
+	github.com/dgryski/go-metro v0.0.0-20211217172704-adc40b04c140 // indirect
 	github.com/greatroar/blobloom v0.7.2
 	github.com/ipfs/bbloom v0.0.4
 	github.com/klauspost/cpuid/v2 v2.2.4 // indirect
+	github.com/seiflotfy/cuckoofilter v0.0.0-20220411075957-e3b120b3f5fb
 	github.com/tannerryan/ring v1.1.2
 	github.com/tmthrgd/atomics v0.0.0-20190904060638-dc7a5fcc7e0d // indirect
 	github.com/tmthrgd/go-bitset v0.0.0-20190904054048-394d9a556c05 // indirect
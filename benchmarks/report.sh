@@ -0,0 +1,15 @@
+#!/bin/sh
+#
+# Produces a Markdown table comparing false positive rate, memory use
+# (in bits per key) and latency (in nanoseconds per operation) of the
+# filters supported by this module.
+
+set -e
+
+echo '| Filter      | Keys     | Target | FPR       | Bits/key | Add ns  | Has ns  |'
+echo '| ----------- | -------- | ------ | --------- | -------- | ------- | ------- |'
+
+for tag in "" sync xxhash xxh3 bbloom boom cuckoo devopsfaith fuse ring willf; do
+	go test -tags="$tag" -run=TestReport -count=1 -report="${tag:-blobloom}" |
+		grep '^|'
+done
@@ -0,0 +1,89 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package benchmarks
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+var reportName = flag.String("report", "",
+	"print a row of the comparison report, labeled with the given name")
+
+// TestReport measures the false positive rate, memory use and latency of the
+// filter selected by the build tags and prints them as rows of a Markdown
+// table. See report.sh for how to produce the full table.
+func TestReport(t *testing.T) {
+	if *reportName == "" {
+		t.Skip("no -report flag given")
+	}
+
+	for _, c := range []struct {
+		capacity int
+		fpr      float64
+	}{
+		{1e5, 1e-2}, {1e5, 1e-3},
+		{1e6, 1e-2}, {1e6, 1e-3},
+		{1e7, 1e-2}, {1e7, 1e-3},
+	} {
+		fmt.Println(reportRow(*reportName, c.capacity, c.fpr))
+	}
+}
+
+func reportRow(name string, capacity int, fpr float64) string {
+	const ntest = 1 << 20
+	keys := makehashes(capacity, 0x7e5027)
+	tests := makehashes(ntest, 0x7e5028)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	f := newBF(capacity, fpr)
+	start := time.Now()
+	for i := 0; i < capacity; i++ {
+		f.Add(keys[i*hashSize : (i+1)*hashSize])
+	}
+	addTime := time.Since(start)
+
+	// Static filters are built on the first lookup,
+	// which is why memory is measured afterwards.
+	fp := 0
+	start = time.Now()
+	for i := 0; i < ntest; i++ {
+		if f.Has(tests[i*hashSize : (i+1)*hashSize]) {
+			fp++
+		}
+	}
+	hasTime := time.Since(start)
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(f)
+	runtime.KeepAlive(keys)
+	runtime.KeepAlive(tests)
+
+	mem := float64(after.HeapAlloc) - float64(before.HeapAlloc)
+	return fmt.Sprintf("| %-11s | %8d | %6g | %8.4f%% | %8.2f | %7.1f | %7.1f |",
+		name, capacity, fpr,
+		100*float64(fp)/ntest,
+		8*mem/float64(capacity),
+		float64(addTime.Nanoseconds())/float64(capacity),
+		float64(hasTime.Nanoseconds())/ntest,
+	)
+}
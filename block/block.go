@@ -0,0 +1,187 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package block exposes the primitives that blobloom uses on its blocks:
+// setting and probing bits, population counts and set operations.
+//
+// A Block uses the same bit layout and probe sequence as the blocks of
+// a blobloom.Filter, so blocks can be moved between the two through
+// Filter.AppendBlock and Filter.UnionBlock, and probing a Block with Has
+// gives the same answer as the filter. Data structures built on blocks,
+// such as counting or partitioned variants, can use this package instead
+// of reimplementing the probes.
+//
+// On amd64 and arm64, the population count and set operations work on
+// 64-bit words, as in blobloom. Building with the nounsafe tag selects
+// the portable implementations.
+package block
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"sync/atomic"
+)
+
+const (
+	Bits  = 512       // Number of bits per block. Equal to blobloom.BlockBits.
+	Bytes = Bits / 8  // Size of the encoding of a block.
+	Words = Bits / 32 // Number of 32-bit words per block.
+)
+
+// A Block is a fixed-size Bloom filter. Bit i is bit i%32 of word i/32.
+type Block [Words]uint32
+
+// Index returns the index of the block that a key with hash value h maps to
+// in an array of nblocks blocks. It matches blobloom.Filter.BlockIndex.
+func Index(h uint64, nblocks int) int {
+	return int((uint64(uint32(h)) * uint64(uint32(nblocks))) >> 32)
+}
+
+// Add sets the bits for a key with hash value h, using nhashes hash
+// functions, as blobloom.Filter.Add does in the key's block.
+func (b *Block) Add(h uint64, nhashes int) {
+	h1, h2 := uint32(h>>32), uint32(h)
+	for i := 1; i < nhashes; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		b.SetBit(h1)
+	}
+}
+
+// AddAtomic is like Add, but may run concurrently with other atomic
+// operations on b.
+func (b *Block) AddAtomic(h uint64, nhashes int) {
+	h1, h2 := uint32(h>>32), uint32(h)
+	for i := 1; i < nhashes; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		b.SetBitAtomic(h1)
+	}
+}
+
+// Has reports whether all bits for a key with hash value h are set.
+func (b *Block) Has(h uint64, nhashes int) bool {
+	h1, h2 := uint32(h>>32), uint32(h)
+	for i := 1; i < nhashes; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		if !b.GetBit(h1) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAtomic is like Has, but may run concurrently with other atomic
+// operations on b.
+func (b *Block) HasAtomic(h uint64, nhashes int) bool {
+	h1, h2 := uint32(h>>32), uint32(h)
+	for i := 1; i < nhashes; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		if !b.GetBitAtomic(h1) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetBit reports whether bit (i modulo Bits) of b is set.
+func (b *Block) GetBit(i uint32) bool {
+	return b[(i/32)%Words]&(1<<(i%32)) != 0
+}
+
+// GetBitAtomic is like GetBit, but loads the word atomically.
+func (b *Block) GetBitAtomic(i uint32) bool {
+	return atomic.LoadUint32(&b[(i/32)%Words])&(1<<(i%32)) != 0
+}
+
+// SetBit sets bit (i modulo Bits) of b.
+func (b *Block) SetBit(i uint32) {
+	b[(i/32)%Words] |= 1 << (i % 32)
+}
+
+// SetBitAtomic is like SetBit, but sets the bit atomically.
+func (b *Block) SetBitAtomic(i uint32) {
+	bit := uint32(1) << (i % 32)
+	p := &b[(i/32)%Words]
+	for {
+		old := atomic.LoadUint32(p)
+		if old&bit != 0 || atomic.CompareAndSwapUint32(p, old, old|bit) {
+			return
+		}
+	}
+}
+
+// OnesCountAtomic is like OnesCount, but loads the words of b atomically.
+func (b *Block) OnesCountAtomic() (n int) {
+	// Blocks need not be 64-bit aligned, so this uses 32-bit loads.
+	for i := range b {
+		n += bits.OnesCount32(atomic.LoadUint32(&b[i]))
+	}
+	return n
+}
+
+// Append appends the encoding of b to p and returns the extended slice.
+// The encoding is that of blobloom.Filter.AppendBlock.
+func (b *Block) Append(p []byte) []byte {
+	var buf [Bytes]byte
+	for j := range b {
+		binary.LittleEndian.PutUint32(buf[4*j:], b[j])
+	}
+	return append(p, buf[:]...)
+}
+
+// Decode sets b to the block encoded in the first Bytes bytes of p.
+func (b *Block) Decode(p []byte) {
+	_ = p[Bytes-1]
+	for j := range b {
+		b[j] = binary.LittleEndian.Uint32(p[4*j:])
+	}
+}
+
+// Union sets each block in dst to its union with the corresponding block
+// in src. It panics if the slices differ in length.
+func Union(dst, src []Block) {
+	checkLen(dst, src)
+	for i := range dst {
+		dst[i].Union(&src[i])
+	}
+}
+
+// Intersect sets each block in dst to its intersection with the
+// corresponding block in src. It panics if the slices differ in length.
+func Intersect(dst, src []Block) {
+	checkLen(dst, src)
+	for i := range dst {
+		dst[i].Intersect(&src[i])
+	}
+}
+
+// OnesCount returns the total number of bits set in the blocks of b.
+func OnesCount(b []Block) (n int) {
+	for i := range b {
+		n += b[i].OnesCount()
+	}
+	return n
+}
+
+func checkLen(dst, src []Block) {
+	if len(dst) != len(src) {
+		panic("block: slices do not have the same length")
+	}
+}
+
+// doublehash matches its counterpart in package blobloom.
+func doublehash(h1, h2 uint32, i int) (uint32, uint32) {
+	h1 = h1 + h2
+	h2 = h2 + uint32(i)
+	return h1, h2
+}
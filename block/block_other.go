@@ -0,0 +1,44 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (!amd64 && !arm64) || nounsafe
+// +build !amd64,!arm64 nounsafe
+
+package block
+
+import (
+	"math/bits"
+)
+
+// Union sets b to the union of b and c.
+func (b *Block) Union(c *Block) {
+	for i := range b {
+		b[i] |= c[i]
+	}
+}
+
+// Intersect sets b to the intersection of b and c.
+func (b *Block) Intersect(c *Block) {
+	for i := range b {
+		b[i] &= c[i]
+	}
+}
+
+// OnesCount returns the number of bits set in b.
+func (b *Block) OnesCount() (n int) {
+	for _, x := range b {
+		n += bits.OnesCount32(x)
+	}
+	return n
+}
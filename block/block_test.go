@@ -0,0 +1,118 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package block_test
+
+import (
+	"math/bits"
+	"math/rand"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/block"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesFilter(t *testing.T) {
+	const nblocks, nhashes = 13, 7
+
+	r := rand.New(rand.NewSource(0xb10c))
+	f := blobloom.New(nblocks*block.Bits, nhashes)
+	b := make([]block.Block, nblocks)
+
+	keys := make([]uint64, 300)
+	for i := range keys {
+		h := r.Uint64()
+		keys[i] = h
+		f.Add(h)
+
+		j := block.Index(h, nblocks)
+		assert.Equal(t, f.BlockIndex(h), j)
+		if i%2 == 0 {
+			b[j].Add(h, nhashes)
+		} else {
+			b[j].AddAtomic(h, nhashes)
+		}
+	}
+
+	var p []byte
+	for i := range b {
+		p = b[i].Append(p[:0])
+		assert.Equal(t, f.AppendBlock(nil, i), p)
+
+		var c block.Block
+		c.Decode(p)
+		assert.Equal(t, b[i], c)
+	}
+
+	for i := 0; i < 1000; i++ {
+		h := r.Uint64()
+		if i%2 == 0 {
+			h = keys[i%len(keys)]
+		}
+		j := block.Index(h, nblocks)
+		assert.Equal(t, f.Has(h), b[j].Has(h, nhashes))
+		assert.Equal(t, f.Has(h), b[j].HasAtomic(h, nhashes))
+	}
+
+	ones := block.OnesCount(b)
+	assert.InDelta(t, f.FillRatio(), float64(ones)/float64(f.NumBits()), 1e-12)
+}
+
+func TestSetOps(t *testing.T) {
+	r := rand.New(rand.NewSource(0x5e7))
+	random := func() []block.Block {
+		b := make([]block.Block, 3)
+		for i := range b {
+			for j := range b[i] {
+				b[i][j] = r.Uint32()
+			}
+		}
+		return b
+	}
+
+	a, b := random(), random()
+	and := append([]block.Block(nil), a...)
+	or := append([]block.Block(nil), a...)
+	block.Intersect(and, b)
+	block.Union(or, b)
+
+	for i := range a {
+		n := 0
+		for j := range a[i] {
+			assert.Equal(t, a[i][j]&b[i][j], and[i][j])
+			assert.Equal(t, a[i][j]|b[i][j], or[i][j])
+			n += bits.OnesCount32(a[i][j])
+		}
+		assert.Equal(t, n, a[i].OnesCount())
+		assert.Equal(t, n, a[i].OnesCountAtomic())
+	}
+
+	assert.Panics(t, func() { block.Union(a, b[:1]) })
+	assert.Panics(t, func() { block.Intersect(a[:2], b) })
+}
+
+func TestBits(t *testing.T) {
+	var b block.Block
+	for i := uint32(0); i < 2*block.Bits; i += 37 {
+		assert.False(t, b.GetBit(i))
+		if i%2 == 0 {
+			b.SetBit(i)
+		} else {
+			b.SetBitAtomic(i)
+		}
+		assert.True(t, b.GetBit(i))
+		assert.True(t, b.GetBitAtomic(i%block.Bits))
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "container/list"
+
+// A BlockCache is a BlockStorage that keeps the most recently used blocks
+// of another BlockStorage in memory.
+//
+// Writes are buffered in the cache and written back to the underlying
+// storage when a block is evicted or on Flush. An error from writing back
+// an evicted block is returned by the ReadBlock or WriteBlock call that
+// caused the eviction; the block then stays in the cache.
+//
+// A BlockCache is not safe for concurrent use.
+type BlockCache struct {
+	st     BlockStorage
+	max    int
+	lru    list.List // Of *cachedBlock, most recently used first.
+	blocks map[int]*list.Element
+
+	hits, misses uint64
+}
+
+type cachedBlock struct {
+	i     int
+	dirty bool
+	data  [BlockBytes]byte
+}
+
+// NewBlockCache returns a BlockCache that holds up to nblocks blocks of st
+// in memory, using about nblocks*BlockBytes bytes. nblocks is silently
+// increased to one if a lower value is given.
+func NewBlockCache(st BlockStorage, nblocks int) *BlockCache {
+	if nblocks < 1 {
+		nblocks = 1
+	}
+	return &BlockCache{
+		st:     st,
+		max:    nblocks,
+		blocks: make(map[int]*list.Element),
+	}
+}
+
+// ReadBlock reads block i into p, from the cache if possible.
+func (c *BlockCache) ReadBlock(i int, p []byte) error {
+	b, err := c.get(i, true)
+	if err == nil {
+		copy(p, b.data[:])
+	}
+	return err
+}
+
+// WriteBlock sets block i to the contents of p in the cache.
+func (c *BlockCache) WriteBlock(i int, p []byte) error {
+	b, err := c.get(i, false)
+	if err == nil {
+		copy(b.data[:], p)
+		b.dirty = true
+	}
+	return err
+}
+
+// Flush writes all modified blocks back to the underlying storage,
+// then calls its Flush method.
+func (c *BlockCache) Flush() error {
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		if err := c.writeBack(e.Value.(*cachedBlock)); err != nil {
+			return err
+		}
+	}
+	return c.st.Flush()
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *BlockCache) Stats() (hits, misses uint64) { return c.hits, c.misses }
+
+// get returns the cached block i, reading it from the underlying storage
+// if necessary and load is true.
+func (c *BlockCache) get(i int, load bool) (*cachedBlock, error) {
+	if e, ok := c.blocks[i]; ok {
+		c.hits++
+		c.lru.MoveToFront(e)
+		return e.Value.(*cachedBlock), nil
+	}
+	c.misses++
+
+	var b *cachedBlock
+	if c.lru.Len() < c.max {
+		b = new(cachedBlock)
+	} else {
+		e := c.lru.Back()
+		b = e.Value.(*cachedBlock)
+		if err := c.writeBack(b); err != nil {
+			return nil, err
+		}
+		c.lru.Remove(e)
+		delete(c.blocks, b.i)
+	}
+
+	*b = cachedBlock{i: i}
+	if load {
+		if err := c.st.ReadBlock(i, b.data[:]); err != nil {
+			return nil, err
+		}
+	}
+	c.blocks[i] = c.lru.PushFront(b)
+	return b, nil
+}
+
+func (c *BlockCache) writeBack(b *cachedBlock) error {
+	if !b.dirty {
+		return nil
+	}
+	if err := c.st.WriteBlock(b.i, b.data[:]); err != nil {
+		return err
+	}
+	b.dirty = false
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+)
+
+// BlockBytes is the size of a block in bytes, as used by the block-level
+// methods AppendBlock and UnionBlock.
+const BlockBytes = BlockBits / 8
+
+// The block-level methods in this file allow filters to be copied or
+// replicated piecemeal. Blocks are encoded as in the format produced by Dump:
+// sixteen little-endian 32-bit words.
+
+// BlockIndex returns the index of the block that a key with hash value h
+// maps to. All bits set by Add(h) are in that block.
+func (f *Filter) BlockIndex(h uint64) int {
+	return int(reducerange(uint32(mix(h, f.probeSeed)), uint32(len(f.b))))
+}
+
+// AppendBlock appends the contents of block i of f to p and returns the
+// extended slice.
+func (f *Filter) AppendBlock(p []byte, i int) []byte {
+	return appendBlock(p, &f.b[i])
+}
+
+// UnionBlock sets block i of f to the union of that block and the first
+// BlockBytes bytes of p, which must hold a block encoded by AppendBlock.
+func (f *Filter) UnionBlock(i int, p []byte) {
+	b := &f.b[i]
+	_ = p[BlockBytes-1]
+	for j := range b {
+		b[j] |= binary.LittleEndian.Uint32(p[4*j:])
+	}
+}
+
+// BlockIndex returns the index of the block that a key with hash value h
+// maps to. All bits set by Add(h) are in that block.
+func (f *SyncFilter) BlockIndex(h uint64) int {
+	return int(reducerange(uint32(mix(h, f.probeSeed)), uint32(len(f.b))))
+}
+
+// AppendBlock appends the contents of block i of f to p and returns the
+// extended slice.
+//
+// If other goroutines are concurrently adding keys to the block,
+// AppendBlock may or may not reflect their additions.
+func (f *SyncFilter) AppendBlock(p []byte, i int) []byte {
+	return appendBlock(p, &f.b[i])
+}
+
+// UnionBlock sets block i of f to the union of that block and the first
+// BlockBytes bytes of p, which must hold a block encoded by AppendBlock.
+// UnionBlock may run concurrently with other modifications to f.
+func (f *SyncFilter) UnionBlock(i int, p []byte) {
+	b := &f.b[i]
+	_ = p[BlockBytes-1]
+	for j := range b {
+		x := binary.LittleEndian.Uint32(p[4*j:])
+		for {
+			old := atomic.LoadUint32(&b[j])
+			if old|x == old || atomic.CompareAndSwapUint32(&b[j], old, old|x) {
+				break
+			}
+		}
+	}
+}
+
+func appendBlock(p []byte, b *block) []byte {
+	var buf [BlockBytes]byte
+	for j := range b {
+		binary.LittleEndian.PutUint32(buf[4*j:], atomic.LoadUint32(&b[j]))
+	}
+	return append(p, buf[:]...)
+}
@@ -0,0 +1,52 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockIO(t *testing.T) {
+	r := rand.New(rand.NewSource(0x5eed))
+	f := New(20*BlockBits, 5)
+	s := NewSync(20*BlockBits, 5)
+	g := New(20*BlockBits, 5)
+	gs := NewSync(20*BlockBits, 5)
+
+	touched := make(map[int]bool)
+	for i := 0; i < 50; i++ {
+		h := r.Uint64()
+		f.Add(h)
+		s.Add(h)
+		assert.Equal(t, f.BlockIndex(h), s.BlockIndex(h))
+		touched[f.BlockIndex(h)] = true
+	}
+
+	var p []byte
+	for i := range touched {
+		p = f.AppendBlock(p[:0], i)
+		assert.Len(t, p, BlockBytes)
+		assert.Equal(t, p, s.AppendBlock(nil, i))
+		g.UnionBlock(i, p)
+		gs.UnionBlock(i, p)
+	}
+	assert.True(t, f.Equals(g))
+	assert.True(t, s.Equals(gs))
+
+	assert.Panics(t, func() { g.UnionBlock(0, p[:BlockBytes-1]) })
+}
@@ -0,0 +1,189 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blocklist builds compact DNS blocklists from hosts files and
+// Adblock-style domain lists.
+//
+// A List is backed by a Bloom filter, so it may block names that are not
+// on the list, at the configured false positive rate. Resolvers that cannot
+// tolerate this should confirm positive answers against the full list.
+package blocklist
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/internal/keyhash"
+)
+
+// An Entry is a domain on a blocklist.
+type Entry struct {
+	Domain     string // Lowercase, without trailing dot.
+	Subdomains bool   // Whether subdomains are blocked too.
+}
+
+// Parse reads the entries from a blocklist. It accepts the following
+// formats, which may be mixed:
+//
+//   - hosts files ("0.0.0.0 ads.example.com"), whose entries block only
+//     the names given;
+//   - Adblock-style rules ("||example.com^"), which block a domain and
+//     its subdomains;
+//   - plain lists of domains, one per line, which also block subdomains.
+//
+// Comments, starting with "#" or "!", are ignored, as are Adblock exception
+// rules, rules with options, rules with wildcards and localhost entries.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "", line[0] == '!', line[0] == '[':
+			// Empty line, Adblock comment or header.
+
+		case strings.HasPrefix(line, "||"):
+			d := strings.TrimPrefix(line, "||")
+			if !strings.HasSuffix(d, "^") {
+				continue
+			}
+			entries = appendEntry(entries, strings.TrimSuffix(d, "^"), true)
+
+		default:
+			fields := strings.Fields(line)
+			if len(fields) == 1 {
+				entries = appendEntry(entries, fields[0], true)
+				continue
+			}
+			if net.ParseIP(fields[0]) == nil {
+				continue
+			}
+			for _, name := range fields[1:] {
+				entries = appendEntry(entries, name, false)
+			}
+		}
+	}
+	return entries, s.Err()
+}
+
+func appendEntry(entries []Entry, domain string, subdomains bool) []Entry {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	if domain == "" || localhost[domain] || strings.ContainsAny(domain, "*/$^|@ ") {
+		return entries
+	}
+	return append(entries, Entry{Domain: domain, Subdomains: subdomains})
+}
+
+var localhost = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+	"0.0.0.0":               true,
+}
+
+// A List is a compact blocklist.
+type List struct {
+	f *blobloom.Filter
+}
+
+// New constructs a List from entries, with the given false positive rate
+// per lookup of a name or one of its parent domains.
+func New(entries []Entry, fpRate float64) *List {
+	l := &List{f: blobloom.NewOptimized(blobloom.Config{
+		Capacity: uint64(len(entries)),
+		FPRate:   fpRate,
+	})}
+	for _, e := range entries {
+		l.f.Add(hash(e.Domain, e.Subdomains))
+	}
+	return l
+}
+
+// Load is shorthand for Parse followed by New.
+func Load(r io.Reader, fpRate float64) (*List, error) {
+	entries, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return New(entries, fpRate), nil
+}
+
+// Blocked reports whether name is blocked by l, either because it is on
+// the list or because one of its parent domains is on the list with
+// its subdomains.
+func (l *List) Blocked(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if l.f.Has(hash(name, false)) {
+		return true
+	}
+	for {
+		if l.f.Has(hash(name, true)) {
+			return true
+		}
+		i := strings.IndexByte(name, '.')
+		if i == -1 {
+			return false
+		}
+		name = name[i+1:]
+	}
+}
+
+// Entries that block subdomains are distinguished by a leading dot.
+func hash(domain string, subdomains bool) uint64 {
+	if subdomains {
+		domain = "." + domain
+	}
+	return keyhash.Sum64([]byte(domain))
+}
+
+const dumpComment = "blocklist"
+
+// Dump writes l to w, in the format of blobloom.Dump.
+func (l *List) Dump(w io.Writer) (int64, error) {
+	return blobloom.Dump(w, l.f, dumpComment)
+}
+
+// Read reads a List written by Dump from r.
+func Read(r io.Reader) (*List, error) {
+	ld, err := blobloom.NewLoader(r)
+	if err != nil {
+		return nil, err
+	}
+	if ld.Comment != dumpComment {
+		return nil, errors.New("blocklist: not a blocklist dump")
+	}
+	f, err := ld.Load(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &List{f: f}, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blocklist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const input = `# hosts file
+127.0.0.1 localhost
+0.0.0.0 ads.example.com tracker.example.net # trailing comment
+::1 ip6-localhost
+
+[Adblock Plus 2.0]
+! comment
+||Doubleclick.NET^
+||third-party.example^$third-party
+@@||allowed.example^
+||*.wildcard.example^
+
+malware.example.
+`
+
+func TestParse(t *testing.T) {
+	entries, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{"ads.example.com", false},
+		{"tracker.example.net", false},
+		{"doubleclick.net", true},
+		{"malware.example", true},
+	}, entries)
+}
+
+func TestList(t *testing.T) {
+	l, err := Load(strings.NewReader(input), 1e-6)
+	require.NoError(t, err)
+
+	check := func(l *List) {
+		for _, name := range []string{
+			"ads.example.com",
+			"ADS.example.com.",
+			"doubleclick.net",
+			"ad.doubleclick.net",
+			"a.b.doubleclick.net",
+			"malware.example",
+		} {
+			assert.True(t, l.Blocked(name), name)
+		}
+		for _, name := range []string{
+			"example.com",
+			"sub.ads.example.com",
+			"net",
+			"notdoubleclick.net",
+			"localhost",
+			"allowed.example",
+			"example",
+		} {
+			assert.False(t, l.Blocked(name), name)
+		}
+	}
+	check(l)
+
+	var buf bytes.Buffer
+	_, err = l.Dump(&buf)
+	require.NoError(t, err)
+	p := append([]byte(nil), buf.Bytes()...)
+	l, err = Read(&buf)
+	require.NoError(t, err)
+	check(l)
+
+	// A truncated dump that claims a huge number of blocks.
+	binary.LittleEndian.PutUint32(p[12:], 1<<30)
+	_, err = Read(bytes.NewReader(p))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
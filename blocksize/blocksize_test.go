@@ -0,0 +1,101 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blocksize
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+)
+
+func hashes(n int, seed int64) []uint64 {
+	r := rand.New(rand.NewSource(seed))
+	h := make([]uint64, n)
+	for i := range h {
+		h[i] = r.Uint64()
+	}
+	return h
+}
+
+func TestBlockSizes(t *testing.T) {
+	const (
+		nbits, nhashes = 1 << 17, 7
+		nkeys, nprobes = 12000, 200000
+	)
+
+	keys := hashes(nkeys+nprobes, 0xb5)
+	probes := keys[nkeys:]
+
+	var measured []float64
+	for _, bs := range []int{Bits256, Bits512, Bits1024} {
+		f := New(nbits, nhashes, bs)
+		assert.Equal(t, bs, f.BlockBits())
+		assert.EqualValues(t, nbits, f.NumBits())
+		assert.Equal(t, nhashes, f.NumHashes())
+
+		for _, h := range keys[:nkeys] {
+			f.Add(h)
+		}
+		for _, h := range keys[:nkeys] {
+			assert.True(t, f.Has(h))
+		}
+		assert.Greater(t, f.FillRatio(), 0.)
+
+		fp := 0
+		for _, h := range probes {
+			if f.Has(h) {
+				fp++
+			}
+		}
+		fpr := float64(fp) / nprobes
+		assert.InEpsilon(t, f.FPRate(nkeys), fpr, .2, "%d-bit blocks", bs)
+		measured = append(measured, fpr)
+
+		f.Clear()
+		assert.Zero(t, f.FillRatio())
+	}
+
+	// Larger blocks spread keys more evenly.
+	assert.Greater(t, measured[0], measured[1])
+	assert.Greater(t, measured[1], measured[2])
+}
+
+func TestMatchesBlobloom(t *testing.T) {
+	const nbits, nhashes = 40 * Bits512, 6
+
+	f := New(nbits, nhashes, Bits512)
+	for _, h := range hashes(1000, 0x512) {
+		f.Add(h)
+		for _, pos := range blobloom.Positions(nbits, nhashes, h) {
+			assert.NotZero(t, f.w[pos/32]&(1<<(pos%32)))
+		}
+	}
+
+	for _, nkeys := range []uint64{1, 100, 1000, 1e4} {
+		assert.InEpsilon(t, blobloom.FPRate(nkeys, nbits, nhashes),
+			FPRate(nkeys, nbits, nhashes, Bits512), 1e-12)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	assert.Panics(t, func() { New(1000, 3, 128) })
+	assert.Panics(t, func() { New(1000, 3, 2048) })
+
+	f := New(0, 0, Bits256)
+	assert.EqualValues(t, Bits256, f.NumBits())
+	assert.Equal(t, 2, f.NumHashes())
+}
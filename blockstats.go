@@ -0,0 +1,62 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+// BlockStats describes how evenly keys are spread over the blocks
+// of a filter.
+//
+// A blocked Bloom filter's false positive rate is dominated by its fullest
+// blocks. When keys are spread evenly, Min and Max stay close to Mean. A Max
+// close to BlockBits, or a non-zero Full, indicates that some blocks are
+// saturated, either because the filter is over capacity or because the hash
+// function does not distribute keys evenly.
+type BlockStats struct {
+	Min, Max int     // Least and greatest number of bits set in a block.
+	Mean     float64 // Mean number of bits set per block.
+	Full     int     // Number of blocks with all bits set.
+}
+
+// BlockStats returns statistics on the number of bits set per block of f.
+func (f *Filter) BlockStats() BlockStats {
+	return blockStats(f.b, onescount)
+}
+
+// BlockStats returns statistics on the number of bits set per block of f.
+//
+// If other goroutines are concurrently adding keys,
+// the result may lag behind the actual state of f.
+func (f *SyncFilter) BlockStats() BlockStats {
+	return blockStats(f.b, onescountAtomic)
+}
+
+func blockStats(b []block, onescount func(*block) int) BlockStats {
+	s := BlockStats{Min: BlockBits}
+	var total uint64
+	for i := range b {
+		n := onescount(&b[i])
+		if n < s.Min {
+			s.Min = n
+		}
+		if n > s.Max {
+			s.Max = n
+		}
+		if n == BlockBits {
+			s.Full++
+		}
+		total += uint64(n)
+	}
+	s.Mean = float64(total) / float64(len(b))
+	return s
+}
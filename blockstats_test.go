@@ -0,0 +1,55 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockStats(t *testing.T) {
+	f := New(16*BlockBits, 4)
+	assert.Equal(t, BlockStats{}, f.BlockStats())
+
+	for _, h := range randomU64(800, 0xb10c5) {
+		f.Add(h)
+	}
+	s := f.BlockStats()
+	assert.InDelta(t, f.FillRatio()*BlockBits, s.Mean, 1e-9)
+	assert.Less(t, 0, s.Min)
+	assert.LessOrEqual(t, float64(s.Min), s.Mean)
+	assert.LessOrEqual(t, s.Mean, float64(s.Max))
+	assert.Less(t, s.Max, BlockBits)
+	assert.Zero(t, s.Full)
+
+	g := NewSync(16*BlockBits, 4)
+	for _, h := range randomU64(800, 0xb10c5) {
+		g.Add(h)
+	}
+	assert.Equal(t, s, g.BlockStats())
+
+	f.b[3] = block{}
+	for i := range f.b[7] {
+		f.b[7][i] = ^uint32(0)
+	}
+	s = f.BlockStats()
+	assert.Equal(t, 0, s.Min)
+	assert.Equal(t, BlockBits, s.Max)
+	assert.Equal(t, 1, s.Full)
+
+	f.Fill()
+	assert.Equal(t, BlockStats{BlockBits, BlockBits, BlockBits, 16}, f.BlockStats())
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bloomarrow converts Bloom filters to and from Apache Arrow data.
+//
+// The bit array of a filter is laid out in memory as Arrow lays out
+// bitmaps: bit i of the filter is bit i%8 of byte i/8. Buffer exports it
+// as an Arrow buffer with a single copy, Bits wraps that buffer in a boolean
+// array and FromBuffer constructs a filter from such a buffer. Has and Add
+// query and update a filter with whole arrays of hash values.
+//
+// This package lives in its own module, so that the blobloom package itself
+// remains free of dependencies.
+package bloomarrow
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v13/arrow/array"
+	"github.com/apache/arrow/go/v13/arrow/memory"
+	"github.com/greatroar/blobloom"
+)
+
+// A BlockFilter is a Bloom filter with block-level access.
+// *blobloom.Filter and *blobloom.SyncFilter implement this interface.
+type BlockFilter interface {
+	NumBits() uint64
+	AppendBlock(p []byte, i int) []byte
+}
+
+// Buffer returns a copy of the bit array of f as an Arrow buffer
+// of f.NumBits()/8 bytes.
+func Buffer(f BlockFilter) *memory.Buffer {
+	nbytes := f.NumBits() / 8
+	p := make([]byte, 0, nbytes)
+	for i := 0; i < int(nbytes/blobloom.BlockBytes); i++ {
+		p = f.AppendBlock(p, i)
+	}
+	return memory.NewBufferBytes(p)
+}
+
+// Bits returns a copy of the bit array of f as an Arrow boolean array
+// of length f.NumBits(), without nulls.
+func Bits(f BlockFilter) *array.Boolean {
+	return array.NewBoolean(int(f.NumBits()), Buffer(f), nil, 0)
+}
+
+// FromBuffer constructs a Filter with the given number of hashes from the
+// bit array in buf, which must have been produced by Buffer or have the
+// same layout. The length of buf must be a multiple of blobloom.BlockBytes.
+func FromBuffer(buf *memory.Buffer, nhashes int) (*blobloom.Filter, error) {
+	p := buf.Bytes()
+	if len(p) == 0 || len(p)%blobloom.BlockBytes != 0 {
+		return nil, fmt.Errorf("bloomarrow: buffer length %d is not a positive multiple of %d",
+			len(p), blobloom.BlockBytes)
+	}
+
+	f := blobloom.New(uint64(len(p))*8, nhashes)
+	for i := 0; i < len(p)/blobloom.BlockBytes; i++ {
+		f.UnionBlock(i, p[i*blobloom.BlockBytes:])
+	}
+	return f, nil
+}
+
+// Has looks up each of hashes in f and returns the results as a boolean
+// array allocated from mem. Null hash values produce nulls.
+func Has(f interface{ Has(uint64) bool }, hashes *array.Uint64, mem memory.Allocator) *array.Boolean {
+	b := array.NewBooleanBuilder(mem)
+	defer b.Release()
+
+	n := hashes.Len()
+	b.Reserve(n)
+	values := hashes.Uint64Values()
+	hasNulls := hashes.NullN() > 0
+	for i := 0; i < n; i++ {
+		if hasNulls && hashes.IsNull(i) {
+			b.AppendNull()
+			continue
+		}
+		b.Append(f.Has(values[i]))
+	}
+	return b.NewBooleanArray()
+}
+
+// Add adds the non-null values in hashes to f.
+func Add(f interface{ Add(uint64) }, hashes *array.Uint64) {
+	values := hashes.Uint64Values()
+	hasNulls := hashes.NullN() > 0
+	for i, h := range values {
+		if hasNulls && hashes.IsNull(i) {
+			continue
+		}
+		f.Add(h)
+	}
+}
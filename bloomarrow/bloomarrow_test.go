@@ -0,0 +1,80 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomarrow_test
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v13/arrow/array"
+	"github.com/apache/arrow/go/v13/arrow/memory"
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/bloomarrow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundtrip(t *testing.T) {
+	mem := memory.NewGoAllocator()
+
+	b := array.NewUint64Builder(mem)
+	defer b.Release()
+	b.Append(1)
+	b.AppendNull()
+	b.Append(0x0123456789abcdef)
+	hashes := b.NewUint64Array()
+	defer hashes.Release()
+
+	f := blobloom.NewSync(1<<12, 4)
+	bloomarrow.Add(f, hashes)
+
+	g, err := bloomarrow.FromBuffer(bloomarrow.Buffer(f), f.NumHashes())
+	require.NoError(t, err)
+	assert.EqualValues(t, f.NumBits(), g.NumBits())
+
+	res := bloomarrow.Has(g, hashes, mem)
+	defer res.Release()
+	require.Equal(t, 3, res.Len())
+	assert.True(t, res.Value(0))
+	assert.True(t, res.IsNull(1))
+	assert.True(t, res.Value(2))
+	assert.False(t, g.Has(0))
+
+	_, err = bloomarrow.FromBuffer(memory.NewBufferBytes(make([]byte, 100)), 4)
+	assert.Error(t, err)
+}
+
+func TestBits(t *testing.T) {
+	f := blobloom.New(1024, 3)
+	f.Add(0xfedcba9876543210)
+
+	bits := bloomarrow.Bits(f)
+	defer bits.Release()
+	require.Equal(t, 1024, bits.Len())
+
+	var buf []byte
+	buf = f.AppendBlock(buf, f.BlockIndex(0xfedcba9876543210))
+	offset := blobloom.BlockBits * f.BlockIndex(0xfedcba9876543210)
+	nset := 0
+	for i := 0; i < bits.Len(); i++ {
+		if !bits.Value(i) {
+			continue
+		}
+		nset++
+		j := i - offset
+		require.True(t, j >= 0 && j < blobloom.BlockBits)
+		assert.NotZero(t, buf[j/8]&(1<<(j%8)))
+	}
+	assert.NotZero(t, nset)
+}
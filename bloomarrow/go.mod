@@ -0,0 +1,25 @@
+module github.com/greatroar/blobloom/bloomarrow
+
+go 1.20
+
+require (
+	github.com/apache/arrow/go/v13 v13.0.0
+	github.com/greatroar/blobloom v0.7.2
+	github.com/stretchr/testify v1.8.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/goccy/go-json v0.10.0 // indirect
+	github.com/google/flatbuffers v23.1.21+incompatible // indirect
+	github.com/klauspost/cpuid/v2 v2.2.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/zeebo/xxh3 v1.0.2 // indirect
+	golang.org/x/mod v0.8.0 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+	golang.org/x/tools v0.6.0 // indirect
+	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/greatroar/blobloom => ../
@@ -0,0 +1,108 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomd_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/greatroar/blobloom/bloomd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientServer(t *testing.T) {
+	var s bloomd.Server
+	defer s.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+
+	c, err := bloomd.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.Create("foo", 1000, 1e-3))
+	assert.Equal(t, bloomd.Error("Exists"), c.Create("foo", 0, 0))
+	require.NoError(t, c.Create("foobar", 0, 0))
+	assert.Error(t, c.Create("bad/name", 0, 0))
+
+	names, err := c.List("foo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo", "foobar"}, names)
+
+	added, err := c.Set("foo", "hello")
+	require.NoError(t, err)
+	assert.True(t, added)
+	added, err = c.Set("foo", "hello")
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	r, err := c.Bulk("foo", "a", "b", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, true, false}, r)
+
+	ok, err := c.Check("foo", "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	r, err = c.Multi("foo", "a", "nope", "hello")
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, true}, r)
+
+	_, err = c.Check("nonexistent", "a")
+	assert.Equal(t, bloomd.Error("Filter does not exist"), err)
+	_, err = c.Check("foo", "two words")
+	assert.Error(t, err)
+
+	info, err := c.Info("foo")
+	require.NoError(t, err)
+	assert.Equal(t, "1000", info["capacity"])
+	assert.Equal(t, "3", info["size"])
+	assert.Equal(t, "4", info["checks"])
+	assert.Equal(t, "3", info["check_hits"])
+	assert.Equal(t, "5", info["sets"])
+	assert.Equal(t, "3", info["set_hits"])
+
+	require.NoError(t, c.Drop("foo"))
+	assert.Error(t, c.Drop("foo"))
+	names, err = c.List("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foobar"}, names)
+}
+
+func TestMaxBits(t *testing.T) {
+	s := bloomd.Server{MaxBits: 1 << 20}
+	defer s.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+
+	c, err := bloomd.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	assert.Equal(t, bloomd.Error("Internal Error"), c.Create("huge", 1<<40, 1e-9))
+
+	// 50000 keys at 1e-3 take about 700,000 bits, so only one fits.
+	require.NoError(t, c.Create("a", 50000, 1e-3))
+	assert.Equal(t, bloomd.Error("Internal Error"), c.Create("b", 50000, 1e-3))
+
+	// Dropping a filter frees its bits.
+	require.NoError(t, c.Drop("a"))
+	require.NoError(t, c.Create("b", 50000, 1e-3))
+}
@@ -0,0 +1,228 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Client is a client for a bloomd server, which may be a Server from
+// this package or the original bloomd.
+//
+// A Client may be used by multiple goroutines concurrently.
+// Their commands are executed sequentially.
+type Client struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// An Error is an error reply from a bloomd server.
+type Error string
+
+func (e Error) Error() string { return "bloomd: " + string(e) }
+
+// Dial connects to the bloomd server at addr.
+func Dial(network, addr string) (*Client, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a Client that communicates over conn.
+func NewClient(conn net.Conn) *Client {
+	return &Client{
+		conn: conn,
+		r:    bufio.NewReaderSize(conn, maxLineLen),
+		w:    bufio.NewWriter(conn),
+	}
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Create creates a filter with the given name, capacity and false positive
+// probability. Zero values of capacity and prob select the server's defaults.
+// It returns an Error if the filter already exists.
+func (c *Client) Create(name string, capacity uint64, prob float64) error {
+	cmd := "create " + name
+	if capacity != 0 {
+		cmd += " capacity=" + strconv.FormatUint(capacity, 10)
+	}
+	if prob != 0 {
+		cmd += " prob=" + strconv.FormatFloat(prob, 'g', -1, 64)
+	}
+	return c.expectDone(cmd)
+}
+
+// Drop deletes the filter with the given name.
+func (c *Client) Drop(name string) error {
+	return c.expectDone("drop " + name)
+}
+
+// Check reports whether the filter with the given name has key.
+func (c *Client) Check(name, key string) (bool, error) {
+	r, err := c.bools("check", name, key)
+	if err != nil {
+		return false, err
+	}
+	return r[0], nil
+}
+
+// Multi reports, for each of keys, whether the filter with the given name
+// has that key.
+func (c *Client) Multi(name string, keys ...string) ([]bool, error) {
+	return c.bools("multi", name, keys...)
+}
+
+// Set adds key to the filter with the given name.
+// It reports whether key was new to the filter.
+func (c *Client) Set(name, key string) (bool, error) {
+	r, err := c.bools("set", name, key)
+	if err != nil {
+		return false, err
+	}
+	return r[0], nil
+}
+
+// Bulk adds keys to the filter with the given name.
+// It reports, for each key, whether that key was new to the filter.
+func (c *Client) Bulk(name string, keys ...string) ([]bool, error) {
+	return c.bools("bulk", name, keys...)
+}
+
+// Info returns information about the filter with the given name,
+// as key-value pairs.
+func (c *Client) Info(name string) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lines, err := c.block("info " + name)
+	if err != nil {
+		return nil, err
+	}
+	info := make(map[string]string)
+	for _, line := range lines {
+		kv := strings.SplitN(line, " ", 2)
+		if len(kv) == 2 {
+			info[kv[0]] = kv[1]
+		}
+	}
+	return info, nil
+}
+
+// List returns the names of the filters whose names start with prefix.
+func (c *Client) List(prefix string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lines, err := c.block(strings.TrimSpace("list " + prefix))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(lines))
+	for i, line := range lines {
+		names[i] = strings.SplitN(line, " ", 2)[0]
+	}
+	return names, nil
+}
+
+func (c *Client) expectDone(cmd string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reply, err := c.roundTrip(cmd)
+	if err == nil && reply != replyDone {
+		err = Error(reply)
+	}
+	return err
+}
+
+func (c *Client) bools(cmd, name string, keys ...string) ([]bool, error) {
+	for _, k := range keys {
+		if k == "" || strings.ContainsAny(k, " \t\r\n") {
+			return nil, fmt.Errorf("bloomd: invalid key %q", k)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reply, err := c.roundTrip(cmd + " " + name + " " + strings.Join(keys, " "))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(reply)
+	if len(fields) != len(keys) {
+		return nil, Error(reply)
+	}
+	r := make([]bool, len(fields))
+	for i, f := range fields {
+		switch f {
+		case "Yes":
+			r[i] = true
+		case "No":
+		default:
+			return nil, Error(reply)
+		}
+	}
+	return r, nil
+}
+
+// block executes a command whose reply is a START ... END block
+// and returns the lines in between.
+func (c *Client) block(cmd string) ([]string, error) {
+	reply, err := c.roundTrip(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if reply != "START" {
+		return nil, Error(reply)
+	}
+
+	var lines []string
+	for {
+		line, err := readLine(c.r)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(line, []byte("END")) {
+			return lines, nil
+		}
+		lines = append(lines, string(line))
+	}
+}
+
+func (c *Client) roundTrip(cmd string) (string, error) {
+	c.w.WriteString(cmd)
+	c.w.WriteByte('\n')
+	if err := c.w.Flush(); err != nil {
+		return "", err
+	}
+	line, err := readLine(c.r)
+	return string(line), err
+}
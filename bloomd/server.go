@@ -0,0 +1,477 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bloomd implements a client and server for the bloomd protocol.
+//
+// Bloomd (https://github.com/armon/bloomd) is a network daemon for Bloom
+// filters with a simple line-based protocol. The Server in this package
+// understands the commands
+//
+//	create <filter> [capacity=<n>] [prob=<p>] [in_memory=<0|1>]
+//	list [<prefix>]
+//	drop <filter>
+//	close <filter>
+//	clear <filter>
+//	check|c <filter> <key>
+//	multi|m <filter> <key> [<key> ...]
+//	set|s <filter> <key>
+//	bulk|b <filter> <key> [<key> ...]
+//	info <filter>
+//	flush [<filter>]
+//
+// Filters are stored in memory only, so close, clear and drop all remove
+// a filter and flush does nothing. Unlike bloomd's scalable filters,
+// filters do not grow when their capacity is exceeded; their false
+// positive rate increases instead.
+//
+// Since any client can create filters, the total size of filters is
+// limited; see Server.MaxBits.
+package bloomd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/internal/keyhash"
+)
+
+// Defaults for create. These match bloomd's defaults.
+const (
+	DefaultCapacity    = 100000
+	DefaultProbability = 1e-4
+)
+
+// DefaultMaxBits is the default limit on the total size of a Server's
+// filters, in bits. It amounts to 1GiB.
+const DefaultMaxBits = 1 << 33
+
+// Replies defined by the protocol.
+const (
+	replyDone       = "Done"
+	replyExists     = "Exists"
+	replyNotExist   = "Filter does not exist"
+	replyBadArgs    = "Client Error: Bad arguments"
+	replyBadCommand = "Client Error: Command not supported"
+	replyNoMemory   = "Internal Error" // Sent by bloomd when allocation fails.
+)
+
+// Maximum length of a line, including the newline.
+const maxLineLen = 64 << 10
+
+// A Server serves Bloom filters over the bloomd protocol.
+// A Server must not be copied after first use.
+type Server struct {
+	// Hash computes the hash value of a key.
+	// If nil, Hash is used.
+	Hash func(key []byte) uint64
+
+	// MaxBits limits the total size of all filters, in bits.
+	// A create that would exceed it fails. If zero, DefaultMaxBits is used.
+	MaxBits uint64
+
+	mu      sync.RWMutex
+	filters map[string]*filter
+	nbits   uint64                 // Total size of filters.
+	active  map[io.Closer]struct{} // Listeners and connections.
+	closed  bool
+}
+
+type filter struct {
+	// Accessed atomically.
+	checks, checkHits, sets, setHits uint64
+
+	*blobloom.SyncFilter
+	capacity uint64
+	prob     float64
+}
+
+// ErrServerClosed is returned by Serve after a call to Close.
+var ErrServerClosed = errors.New("bloomd: server closed")
+
+// Hash is the default hash function: 64-bit FNV-1a,
+// followed by a finalizer that mixes the bits.
+func Hash(key []byte) uint64 {
+	return keyhash.Sum64(key)
+}
+
+// Serve accepts connections on l and serves each in a new goroutine.
+// It returns when l.Accept fails or the Server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l) {
+		return ErrServerClosed
+	}
+	defer s.untrack(l)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves a single connection. It closes conn when done.
+func (s *Server) ServeConn(conn net.Conn) {
+	defer conn.Close()
+	if !s.track(conn) {
+		return
+	}
+	defer s.untrack(conn)
+
+	r := bufio.NewReaderSize(conn, maxLineLen)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return
+		}
+		if fields := bytes.Fields(line); len(fields) > 0 {
+			s.exec(w, fields)
+		}
+
+		// Pipelined commands are answered in a single write.
+		if r.Buffered() == 0 && w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// Close closes all listeners and connections of s.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	var err error
+	for c := range s.active {
+		if e := c.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.closed
+}
+
+// track adds c to the set of active listeners and connections.
+// It reports false if the server is closed.
+func (s *Server) track(c io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if s.active == nil {
+		s.active = make(map[io.Closer]struct{})
+	}
+	s.active[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, c)
+}
+
+func (s *Server) exec(w *bufio.Writer, args [][]byte) {
+	cmd := string(args[0])
+	args = args[1:]
+
+	switch cmd {
+	case "create":
+		if len(args) < 1 {
+			writeLine(w, replyBadArgs)
+			return
+		}
+		writeLine(w, s.create(string(args[0]), args[1:]))
+
+	case "list":
+		if len(args) > 1 {
+			writeLine(w, replyBadArgs)
+			return
+		}
+		var prefix string
+		if len(args) == 1 {
+			prefix = string(args[0])
+		}
+		s.list(w, prefix)
+
+	case "drop", "close", "clear":
+		if len(args) != 1 {
+			writeLine(w, replyBadArgs)
+			return
+		}
+		s.mu.Lock()
+		f, ok := s.filters[string(args[0])]
+		if ok {
+			delete(s.filters, string(args[0]))
+			s.nbits -= f.NumBits()
+		}
+		s.mu.Unlock()
+		if ok {
+			writeLine(w, replyDone)
+		} else {
+			writeLine(w, replyNotExist)
+		}
+
+	case "check", "c", "multi", "m", "set", "s", "bulk", "b":
+		single := cmd == "check" || cmd == "c" || cmd == "set" || cmd == "s"
+		if len(args) < 2 || single && len(args) != 2 {
+			writeLine(w, replyBadArgs)
+			return
+		}
+		f := s.filter(string(args[0]))
+		if f == nil {
+			writeLine(w, replyNotExist)
+			return
+		}
+		set := cmd[0] == 's' || cmd[0] == 'b'
+		for i, key := range args[1:] {
+			if i > 0 {
+				w.WriteByte(' ')
+			}
+			writeBool(w, s.checkOrSet(f, key, set))
+		}
+		w.WriteByte('\n')
+
+	case "info":
+		if len(args) != 1 {
+			writeLine(w, replyBadArgs)
+			return
+		}
+		f := s.filter(string(args[0]))
+		if f == nil {
+			writeLine(w, replyNotExist)
+			return
+		}
+		writeInfo(w, f)
+
+	case "flush":
+		if len(args) > 1 {
+			writeLine(w, replyBadArgs)
+			return
+		}
+		if len(args) == 1 && s.filter(string(args[0])) == nil {
+			writeLine(w, replyNotExist)
+			return
+		}
+		writeLine(w, replyDone)
+
+	default:
+		writeLine(w, replyBadCommand)
+	}
+}
+
+func (s *Server) create(name string, opts [][]byte) string {
+	if !validName(name) {
+		return replyBadArgs
+	}
+
+	capacity, prob := uint64(DefaultCapacity), DefaultProbability
+	for _, opt := range opts {
+		kv := strings.SplitN(string(opt), "=", 2)
+		if len(kv) != 2 {
+			return replyBadArgs
+		}
+
+		var err error
+		switch kv[0] {
+		case "capacity":
+			capacity, err = strconv.ParseUint(kv[1], 10, 64)
+			if capacity == 0 {
+				err = errors.New("zero capacity")
+			}
+		case "prob":
+			prob, err = strconv.ParseFloat(kv[1], 64)
+			if !(prob > 0 && prob < 1) {
+				err = errors.New("invalid probability")
+			}
+		case "in_memory":
+			// All filters are in memory.
+			_, err = strconv.ParseBool(kv[1])
+		default:
+			err = errors.New("unknown option")
+		}
+		if err != nil {
+			return replyBadArgs
+		}
+	}
+
+	// Check the size before allocating, so clients can't exhaust memory.
+	nbits, nhashes := blobloom.Optimize(blobloom.Config{
+		Capacity: capacity,
+		FPRate:   prob,
+	})
+	maxBits := s.MaxBits
+	if maxBits == 0 {
+		maxBits = DefaultMaxBits
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.filters[name]; ok {
+		return replyExists
+	}
+	if nbits > maxBits || s.nbits > maxBits-nbits {
+		return replyNoMemory
+	}
+	if s.filters == nil {
+		s.filters = make(map[string]*filter)
+	}
+	f := blobloom.NewSync(nbits, nhashes)
+	s.nbits += f.NumBits()
+	s.filters[name] = &filter{
+		SyncFilter: f,
+		capacity:   capacity,
+		prob:       prob,
+	}
+	return replyDone
+}
+
+func (s *Server) list(w *bufio.Writer, prefix string) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.filters))
+	filters := make(map[string]*filter)
+	for name, f := range s.filters {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+			filters[name] = f
+		}
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+
+	writeLine(w, "START")
+	for _, name := range names {
+		f := filters[name]
+		fmt.Fprintf(w, "%s %g %d %d %d\n",
+			name, f.prob, f.NumBits()/8, f.capacity, size(f))
+	}
+	writeLine(w, "END")
+}
+
+func (s *Server) filter(name string) *filter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filters[name]
+}
+
+// checkOrSet checks for key in f or sets it. In the latter case,
+// it reports whether the key was new.
+func (s *Server) checkOrSet(f *filter, key []byte, set bool) bool {
+	var h uint64
+	if s.Hash != nil {
+		h = s.Hash(key)
+	} else {
+		h = Hash(key)
+	}
+
+	found := f.Has(h)
+	if !set {
+		atomic.AddUint64(&f.checks, 1)
+		if found {
+			atomic.AddUint64(&f.checkHits, 1)
+		}
+		return found
+	}
+
+	atomic.AddUint64(&f.sets, 1)
+	if found {
+		return false
+	}
+	f.Add(h)
+	atomic.AddUint64(&f.setHits, 1)
+	return true
+}
+
+func writeInfo(w *bufio.Writer, f *filter) {
+	checks := atomic.LoadUint64(&f.checks)
+	checkHits := atomic.LoadUint64(&f.checkHits)
+	sets := atomic.LoadUint64(&f.sets)
+	setHits := atomic.LoadUint64(&f.setHits)
+
+	writeLine(w, "START")
+	fmt.Fprintf(w, "capacity %d\n", f.capacity)
+	fmt.Fprintf(w, "checks %d\n", checks)
+	fmt.Fprintf(w, "check_hits %d\n", checkHits)
+	fmt.Fprintf(w, "check_misses %d\n", checks-checkHits)
+	fmt.Fprintf(w, "page_ins 0\npage_outs 0\n")
+	fmt.Fprintf(w, "probability %g\n", f.prob)
+	fmt.Fprintf(w, "sets %d\n", sets)
+	fmt.Fprintf(w, "set_hits %d\n", setHits)
+	fmt.Fprintf(w, "set_misses %d\n", sets-setHits)
+	fmt.Fprintf(w, "size %d\n", size(f))
+	fmt.Fprintf(w, "storage %d\n", f.NumBits()/8)
+	writeLine(w, "END")
+}
+
+// size estimates the number of keys in f.
+func size(f *filter) uint64 {
+	n := f.Cardinality()
+	if math.IsInf(n, 0) || n >= math.MaxUint64 {
+		return math.MaxUint64
+	}
+	return uint64(math.Round(n))
+}
+
+// validName reports whether name is a valid filter name.
+// Bloomd uses names as file names, so we follow its restrictions.
+func validName(name string) bool {
+	return len(name) <= 200 && !strings.ContainsAny(name, "/.\x00")
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, errors.New("bloomd: line too long")
+	} else if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(line[:len(line)-1], []byte("\r")), nil
+}
+
+func writeLine(w *bufio.Writer, s string) {
+	w.WriteString(s)
+	w.WriteByte('\n')
+}
+
+func writeBool(w *bufio.Writer, b bool) {
+	if b {
+		w.WriteString("Yes")
+	} else {
+		w.WriteString("No")
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bloomexpvar publishes statistics about Bloom filters
+// through the standard library's expvar package.
+package bloomexpvar
+
+import (
+	"expvar"
+	"math"
+
+	"github.com/greatroar/blobloom"
+)
+
+// A Filter is a Bloom filter whose statistics can be published.
+// *blobloom.Filter and *blobloom.SyncFilter implement this interface.
+type Filter interface {
+	Cardinality() float64
+	FillRatio() float64
+	FPRate(nkeys uint64) float64
+	NumBits() uint64
+}
+
+// A statser counts operations, like *blobloom.Filter and SyncFilter.
+type statser interface {
+	Stats() blobloom.Stats
+}
+
+// Publish registers the statistics of f as an expvar.Var with the given name.
+// Like expvar.Publish, it panics if name is already registered.
+//
+// The statistics are recomputed every time the variable is read,
+// which takes time linear in the size of f.
+func Publish(name string, f Filter) {
+	expvar.Publish(name, Var(f))
+}
+
+// Var returns an expvar.Var that reports the statistics of f as a JSON object
+// with the fields
+//
+//	bits            size of f in bits
+//	fill_ratio      fraction of bits set
+//	estimated_keys  estimated number of distinct keys; omitted when infinite
+//	estimated_fpr   estimated false positive rate
+//
+// If f has a method Stats() blobloom.Stats, as do *blobloom.Filter and
+// SyncFilter, the object also has the fields adds, lookups, hits, batches,
+// batch_adds, verified and false_positives. *blobloom.Filter and SyncFilter
+// only maintain these counts when a *blobloom.Counters is set on them;
+// see its Verify field for the last two.
+func Var(f Filter) expvar.Var {
+	return expvar.Func(func() interface{} { return stats(f) })
+}
+
+func stats(f Filter) map[string]interface{} {
+	m := map[string]interface{}{
+		"bits":       f.NumBits(),
+		"fill_ratio": f.FillRatio(),
+	}
+
+	// JSON can't represent infinity, which Cardinality returns when
+	// one of the blocks is full.
+	nkeys := f.Cardinality()
+	if math.IsInf(nkeys, 0) || nkeys >= math.MaxUint64 {
+		m["estimated_fpr"] = 1.0
+	} else {
+		m["estimated_keys"] = nkeys
+		m["estimated_fpr"] = f.FPRate(uint64(math.Round(nkeys)))
+	}
+
+	if sf, ok := f.(statser); ok {
+		stats := sf.Stats()
+		m["adds"] = stats.Adds
+		m["lookups"] = stats.Lookups
+		m["hits"] = stats.Hits
+		m["batches"] = stats.Batches
+		m["batch_adds"] = stats.BatchAdds
+		m["verified"] = stats.Verified
+		m["false_positives"] = stats.FalsePositives
+	}
+	return m
+}
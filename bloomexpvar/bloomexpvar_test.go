@@ -0,0 +1,62 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublish(t *testing.T) {
+	f := blobloom.New(1024, 4)
+	f.SetRecorder(&blobloom.Counters{Verify: func(h uint64) bool { return false }})
+	for i := uint64(0); i < 10; i++ {
+		f.Add(0x9e3779b97f4a7c15 * i)
+	}
+	f.AddMany([]uint64{1, 2})
+	f.Has(1)
+	Publish("bloomexpvar_test_f", f)
+
+	var m map[string]interface{}
+	err := json.Unmarshal([]byte(expvar.Get("bloomexpvar_test_f").String()), &m)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 1024, m["bits"])
+	assert.Equal(t, f.FillRatio(), m["fill_ratio"])
+	assert.InDelta(t, 12, m["estimated_keys"], 1)
+	assert.Greater(t, m["estimated_fpr"], 0.0)
+	assert.EqualValues(t, 12, m["adds"])
+	assert.EqualValues(t, 1, m["lookups"])
+	assert.EqualValues(t, 1, m["hits"])
+	assert.EqualValues(t, 1, m["batches"])
+	assert.EqualValues(t, 2, m["batch_adds"])
+	assert.EqualValues(t, 1, m["verified"])
+	assert.EqualValues(t, 1, m["false_positives"])
+
+	g := blobloom.New(512, 2)
+	g.Fill()
+	m = nil
+	err = json.Unmarshal([]byte(Var(g).String()), &m)
+	require.NoError(t, err)
+
+	assert.NotContains(t, m, "estimated_keys")
+	assert.EqualValues(t, 1, m["estimated_fpr"])
+	assert.EqualValues(t, 0, m["adds"])
+}
@@ -39,6 +39,7 @@ package blobloom
 
 import (
 	"encoding/binary"
+	"hash/maphash"
 	"io"
 	"math"
 )
@@ -55,8 +56,14 @@ const MaxBits = BlockBits << 32 // 256GiB.
 
 // A Filter is a blocked Bloom filter.
 type Filter struct {
-	b []block // Shards.
-	k int     // Number of hash functions required.
+	b   []block  // Shards.
+	k   int      // Number of hash functions required.
+	rec Recorder // Optional.
+
+	seed      maphash.Seed // For AddString and friends.
+	probeSeed uint64       // Mixed into hash values, see SetSeed.
+	probing   Probing
+	sat       *saturation // Optional, see SetSaturationFunc.
 }
 
 // New constructs a Bloom filter with given numbers of bits and hash functions.
@@ -71,8 +78,9 @@ func New(nbits uint64, nhashes int) *Filter {
 	nbits, nhashes = fixBitsAndHashes(nbits, nhashes)
 
 	return &Filter{
-		b: make([]block, nbits/BlockBits),
-		k: nhashes,
+		b:    make([]block, nbits/BlockBits),
+		k:    nhashes,
+		seed: maphash.MakeSeed(),
 	}
 }
 
@@ -104,8 +112,9 @@ func Read(stream io.Reader) (*Filter, error) {
 		b = append(b, block)
 	}
 	return &Filter{
-		k: int(k),
-		b: b,
+		k:    int(k),
+		b:    b,
+		seed: maphash.MakeSeed(),
 	}, nil
 }
 
@@ -130,11 +139,22 @@ func fixBitsAndHashes(nbits uint64, nhashes int) (uint64, int) {
 
 // Add insert a key with hash value h into f.
 func (f *Filter) Add(h uint64) {
+	f.add(mix(h, f.probeSeed))
+	if f.rec != nil {
+		f.rec.RecordAdd(h)
+	}
+	if f.sat != nil {
+		f.checkSaturation(1)
+	}
+}
+
+func (f *Filter) add(h uint64) {
 	h1, h2 := uint32(h>>32), uint32(h)
 	b := getblock(f.b, h2)
+	h3 := thirdhash(f.probing, h1)
 
 	for i := 1; i < f.k; i++ {
-		h1, h2 = doublehash(h1, h2, i)
+		h1, h2 = probehash(h1, h2, h3, i)
 		b.setbit(h1)
 	}
 }
@@ -178,11 +198,39 @@ func cardinality(nhashes int, b []block, onescount func(*block) int) float64 {
 	return n * logProb0Inv
 }
 
+// FillRatio returns the fraction of bits in f that are set.
+func (f *Filter) FillRatio() float64 {
+	return fillRatio(f.b, onescount)
+}
+
+func fillRatio(b []block, onescount func(*block) int) float64 {
+	var n uint64
+	for i := range b {
+		n += uint64(onescount(&b[i]))
+	}
+	return float64(n) / (BlockBits * float64(len(b)))
+}
+
 // Clear resets f to its empty state.
+//
+// Clear zeroes f's memory in place, so a Filter can be recycled, e.g.,
+// between batches of keys, without allocating a new one.
 func (f *Filter) Clear() {
 	for i := 0; i < len(f.b); i++ {
 		f.b[i] = block{}
 	}
+	if f.sat != nil {
+		f.sat.reset()
+	}
+}
+
+// Clone returns a deep copy of f, e.g., to take a snapshot before adding
+// a batch of keys that may need to be rolled back. The copy has no
+// Recorder or saturation function set.
+func (f *Filter) Clone() *Filter {
+	b := make([]block, len(f.b))
+	copy(b, f.b)
+	return &Filter{b: b, k: f.k, seed: f.seed, probeSeed: f.probeSeed, probing: f.probing}
 }
 
 // Empty reports whether f contains no keys.
@@ -198,7 +246,7 @@ func (f *Filter) Empty() bool {
 // Equals returns true if f and g contain the same keys (in terms of Has)
 // when used with the same hash function.
 func (f *Filter) Equals(g *Filter) bool {
-	if g.k != f.k || len(g.b) != len(f.b) {
+	if g.k != f.k || len(g.b) != len(f.b) || g.probeSeed != f.probeSeed || g.probing != f.probing {
 		return false
 	}
 	for i := range g.b {
@@ -222,11 +270,23 @@ func (f *Filter) Fill() {
 // Has reports whether a key with hash value h has been added.
 // It may return a false positive.
 func (f *Filter) Has(h uint64) bool {
+	found := f.has(mix(h, f.probeSeed))
+	if f.rec != nil {
+		f.rec.RecordHas(h, found)
+	}
+	return found
+}
+
+func (f *Filter) has(h uint64) bool {
 	h1, h2 := uint32(h>>32), uint32(h)
 	b := getblock(f.b, h2)
 
+	if useVector(f.k) && f.probing == DoubleHashing {
+		return hasVector(b, h1, h2, f.k)
+	}
+	h3 := thirdhash(f.probing, h1)
 	for i := 1; i < f.k; i++ {
-		h1, h2 = doublehash(h1, h2, i)
+		h1, h2 = probehash(h1, h2, h3, i)
 		if !b.getbit(h1) {
 			return false
 		}
@@ -248,6 +308,9 @@ func (f *Filter) NumBits() uint64 {
 	return BlockBits * uint64(len(f.b))
 }
 
+// NumHashes returns the number of hash functions of f.
+func (f *Filter) NumHashes() int { return f.k }
+
 func checkBinop(f, g *Filter) {
 	if len(f.b) != len(g.b) {
 		panic("Bloom filters do not have the same number of bits")
@@ -255,19 +318,30 @@ func checkBinop(f, g *Filter) {
 	if f.k != g.k {
 		panic("Bloom filters do not have the same number of hash functions")
 	}
+	if f.probeSeed != g.probeSeed {
+		panic("Bloom filters do not have the same seed")
+	}
+	if f.probing != g.probing {
+		panic("Bloom filters do not have the same probing scheme")
+	}
 }
 
 // Intersect sets f to the intersection of f and g.
 //
-// Intersect panics when f and g do not have the same number of bits and
-// hash functions. Both Filters must be using the same hash function(s),
-// but Intersect cannot check this.
+// Intersect panics when f and g do not have the same number of bits,
+// hash functions and seed. Both Filters must be using the same hash
+// function(s), but Intersect cannot check this.
 //
 // Since Bloom filters may return false positives, Has may return true for
-// a key that was not in both f and g.
+// a key that was not in both f and g. The intersection is therefore best
+// used as a "possibly in both" pre-filter, e.g., for the probe side of a
+// join, with exact checks on the keys it passes.
 //
 // After Intersect, the estimates from f.Cardinality and f.FPRate should be
-// considered unreliable.
+// considered unreliable. f.CurrentFPRate estimates the false positive rate
+// for keys that were in neither f nor g. For keys in only one of them,
+// the false positive rate is that of the other filter before the
+// intersection, which is higher.
 func (f *Filter) Intersect(g *Filter) {
 	checkBinop(f, g)
 	f.intersect(g)
@@ -275,9 +349,10 @@ func (f *Filter) Intersect(g *Filter) {
 
 // Union sets f to the union of f and g.
 //
-// Union panics when f and g do not have the same number of bits and
-// hash functions. Both Filters must be using the same hash function(s),
-// but Union cannot check this.
+// Union panics when f and g do not have the same number of bits,
+// hash functions and seed. Both Filters must be using the same hash
+// function(s), but Union cannot check this. To merge filters of different
+// sizes, see UnionFold.
 func (f *Filter) Union(g *Filter) {
 	checkBinop(f, g)
 	f.union(g)
@@ -337,16 +412,3 @@ func (f *Filter) Write(stream io.Writer) error {
 	}
 	return nil
 }
-
-// Equals - compares two filters and returns true if they are equal
-func (f *Filter) Equals(f1 *Filter) bool {
-	if f1.k != f.k || len(f1.b) != len(f.b) {
-		return false
-	}
-	for index, val := range f1.b {
-		if val != f.b[index] {
-			return false
-		}
-	}
-	return true
-}
@@ -57,14 +57,20 @@ func TestSimple(t *testing.T) {
 			assert.True(t, f.Has(k))
 		}
 
+		fill := f.FillRatio()
+		assert.Greater(t, fill, 0.0)
+		assert.LessOrEqual(t, fill, 1.0)
+
 		f.Clear()
 		assert.True(t, f.Empty())
+		assert.EqualValues(t, 0, f.FillRatio())
 		for _, k := range keys {
 			assert.False(t, f.Has(k))
 		}
 
 		f.Fill()
 		assert.False(t, f.Empty())
+		assert.EqualValues(t, 1, f.FillRatio())
 		for _, k := range keys {
 			assert.True(t, f.Has(k))
 		}
@@ -283,14 +289,14 @@ func TestUnion(t *testing.T) {
 		u.Add(h)
 	}
 
-	assert.NotEqual(t, f, g)
+	assert.NotEqual(t, f.b, g.b)
 
 	f.Union(g)
-	assert.Equal(t, u, f)
-	assert.NotEqual(t, u, g)
+	assert.Equal(t, u.b, f.b)
+	assert.NotEqual(t, u.b, g.b)
 
 	g.Union(f)
-	assert.Equal(t, u, g)
+	assert.Equal(t, u.b, g.b)
 
 	assert.Panics(t, func() { f.Union(New(n, 4)) })
 	assert.Panics(t, func() { f.Union(New(n+BlockBits, 5)) })
@@ -415,3 +421,23 @@ func TestReadWritePattern(t *testing.T) {
 	assert.Nil(t, err)
 	assert.True(t, f.Equals(f1))
 }
+
+func TestClone(t *testing.T) {
+	keys := randomU64(2000, 0xc104e)
+
+	f := New(1<<14, 5)
+	for _, h := range keys[:1000] {
+		f.Add(h)
+	}
+	f.SetRecorder(new(Counters))
+
+	g := f.Clone()
+	assert.True(t, f.Equals(g))
+	assert.Nil(t, g.rec)
+
+	// Modifying the clone does not affect the original.
+	for _, h := range keys[1000:] {
+		g.Add(h)
+	}
+	assert.False(t, f.Equals(g))
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomgrpc_test
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/bloomgrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func setup(t *testing.T, f *blobloom.SyncFilter) *bloomgrpc.Client {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	bloomgrpc.Register(s, bloomgrpc.NewServer(f, "test"))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	cc, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { cc.Close() })
+
+	return bloomgrpc.NewClient(cc)
+}
+
+func TestClientServer(t *testing.T) {
+	ctx := context.Background()
+	f := blobloom.NewSync(1<<22, 5)
+	c := setup(t, f)
+
+	r := rand.New(rand.NewSource(0x9c9c))
+	hashes := make([]uint64, 1000)
+	for i := range hashes {
+		hashes[i] = r.Uint64()
+	}
+
+	require.NoError(t, c.Add(ctx, hashes[:500]...))
+
+	ok, err := c.Has(ctx, hashes[0])
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	present, err := c.BatchHas(ctx, hashes)
+	require.NoError(t, err)
+	for i, p := range present {
+		if i < 500 {
+			assert.True(t, p)
+		} else {
+			assert.Equal(t, f.Has(hashes[i]), p)
+		}
+	}
+
+	replica, err := c.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, f.NumBits(), replica.NumBits())
+	for _, h := range hashes[:500] {
+		assert.True(t, replica.Has(h))
+	}
+
+	// Add the remaining hashes, then update the replica.
+	require.NoError(t, c.Add(ctx, hashes[500:]...))
+	require.NoError(t, c.Update(ctx, replica))
+
+	expect, err := c.Load(ctx)
+	require.NoError(t, err)
+	assert.True(t, expect.Equals(replica))
+
+	// A replica of the wrong shape is rejected.
+	assert.Error(t, c.Update(ctx, blobloom.New(1<<20, 5)))
+	assert.Error(t, c.Update(ctx, blobloom.New(1<<22, 4)))
+}
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	f := blobloom.NewSync(1<<20, 5)
+	c := setup(t, f)
+
+	r := rand.New(rand.NewSource(0x3e76e))
+	hashes := make([]uint64, 2000)
+	for i := range hashes {
+		hashes[i] = r.Uint64()
+	}
+
+	for _, h := range hashes[:1000] {
+		f.Add(h)
+	}
+	local := blobloom.New(1<<20, 5)
+	for _, h := range hashes[1000:] {
+		local.Add(h)
+	}
+	require.NoError(t, c.Merge(ctx, local))
+
+	for _, h := range hashes {
+		assert.True(t, f.Has(h))
+	}
+
+	// A filter of the wrong shape is rejected.
+	assert.Error(t, c.Merge(ctx, blobloom.New(1<<22, 5)))
+	assert.Error(t, c.Merge(ctx, blobloom.New(1<<20, 4)))
+}
+
+func TestGetDeltaChunkBlocks(t *testing.T) {
+	f := blobloom.NewSync(1<<20, 5)
+	srv := bloomgrpc.NewServer(f, "")
+	nblocks := f.NumBits() / blobloom.BlockBits
+
+	for _, chunkBlocks := range []uint32{0, 1025, 1 << 31, 1<<32 - 1} {
+		err := srv.GetDelta(&bloomgrpc.DeltaRequest{
+			NumBlocks:   nblocks,
+			NumHashes:   5,
+			ChunkBlocks: chunkBlocks,
+			Checksums:   []uint32{0},
+		}, nil)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), chunkBlocks)
+	}
+}
+
+func TestClientServerSeeded(t *testing.T) {
+	ctx := context.Background()
+	f := blobloom.NewSyncOptimized(blobloom.Config{
+		Capacity: 10000, FPRate: 1e-4, Seed: 0x9c5d, Probing: blobloom.TripleHashing,
+	})
+	c := setup(t, f)
+
+	r := rand.New(rand.NewSource(0x9c5d))
+	hashes := make([]uint64, 2000)
+	for i := range hashes {
+		hashes[i] = r.Uint64()
+	}
+	require.NoError(t, c.Add(ctx, hashes[:1000]...))
+
+	replica, err := c.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, f.Seed(), replica.Seed())
+	assert.Equal(t, f.Probing(), replica.Probing())
+	for _, h := range hashes[:1000] {
+		assert.True(t, replica.Has(h))
+	}
+
+	require.NoError(t, c.Add(ctx, hashes[1000:]...))
+	require.NoError(t, c.Update(ctx, replica))
+	for _, h := range hashes {
+		assert.True(t, replica.Has(h))
+	}
+
+	require.NoError(t, c.Merge(ctx, replica))
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomgrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/greatroar/blobloom"
+	"google.golang.org/grpc"
+)
+
+// Number of blocks covered by each checksum in requests made by Update.
+const chunkBlocks = 1024
+
+// A Client is a client of the BloomFilter service.
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewClient returns a Client that makes requests through cc.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+// Add adds the keys with the given hashes to the remote filter.
+func (c *Client) Add(ctx context.Context, hashes ...uint64) error {
+	return c.cc.Invoke(ctx, "/"+serviceName+"/Add",
+		&AddRequest{Hashes: hashes}, new(AddResponse))
+}
+
+// Has reports whether the remote filter has a key with hash value h.
+func (c *Client) Has(ctx context.Context, h uint64) (bool, error) {
+	resp := new(HasResponse)
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/Has", &HasRequest{Hash: h}, resp)
+	return resp.Present, err
+}
+
+// BatchHas reports, for each of hashes, whether the remote filter has a key
+// with that hash value.
+func (c *Client) BatchHas(ctx context.Context, hashes []uint64) ([]bool, error) {
+	resp := new(BatchHasResponse)
+	err := c.cc.Invoke(ctx, "/"+serviceName+"/BatchHas",
+		&BatchHasRequest{Hashes: hashes}, resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Present) != len(hashes) {
+		return nil, fmt.Errorf("bloomgrpc: expected %d results, got %d",
+			len(hashes), len(resp.Present))
+	}
+	return resp.Present, nil
+}
+
+// Dump writes a dump of the remote filter to w,
+// in the format written by blobloom.Dump.
+func (c *Client) Dump(ctx context.Context, w io.Writer) error {
+	r, err := c.dumpReader(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// Load returns a copy of the remote filter.
+func (c *Client) Load(ctx context.Context) (*blobloom.Filter, error) {
+	r, err := c.dumpReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l, err := blobloom.NewLoader(r)
+	if err != nil {
+		return nil, err
+	}
+	return l.Load(nil)
+}
+
+func (c *Client) dumpReader(ctx context.Context) (*dumpReader, error) {
+	stream, err := c.newStream(ctx, 0, "GetDump", &DumpRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return &dumpReader{stream: stream}, nil
+}
+
+// Update brings the replica f up to date with the remote filter.
+// Only the parts of the remote filter that differ from f are transferred.
+//
+// f must have the same number of bits and hashes as the remote filter.
+// Since Update adds the differing parts to f, it assumes that the remote
+// filter has only been added to since f was obtained from it.
+// If the remote filter may have been cleared, use Load instead.
+func (c *Client) Update(ctx context.Context, f *blobloom.Filter) error {
+	sums := &checksummer{headerSize: int(f.DumpSize() - f.NumBits()/8)}
+	if _, err := blobloom.Dump(sums, f, ""); err != nil {
+		return err
+	}
+	sums.flush()
+
+	nblocks := f.NumBits() / blobloom.BlockBits
+	stream, err := c.newStream(ctx, 1, "GetDelta", &DeltaRequest{
+		NumBlocks:   nblocks,
+		NumHashes:   binary.LittleEndian.Uint32(sums.header[16:]),
+		ChunkBlocks: chunkBlocks,
+		Checksums:   sums.sums,
+	})
+	if err != nil {
+		return err
+	}
+
+	l, err := blobloom.NewLoader(&deltaReader{
+		stream:  stream,
+		header:  sums.header,
+		nblocks: nblocks,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = l.Load(f)
+	return err
+}
+
+// Merge sets the remote filter to the union of itself and f.
+// f must have the same number of bits and hashes as the remote filter.
+func (c *Client) Merge(ctx context.Context, f *blobloom.Filter) error {
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[2], "/"+serviceName+"/Merge")
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriterSize(writerFunc(func(p []byte) (int, error) {
+		return len(p), stream.SendMsg(&DumpChunk{Data: p})
+	}), maxChunkSize)
+	_, err = blobloom.Dump(w, f, "")
+	if err == nil {
+		err = w.Flush()
+	}
+	// SendMsg returns io.EOF when the server has ended the call.
+	// The actual error is then returned by RecvMsg.
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if err = stream.CloseSend(); err != nil {
+		return err
+	}
+	return stream.RecvMsg(new(MergeResponse))
+}
+
+func (c *Client) newStream(ctx context.Context, i int, method string,
+	req interface{}) (grpc.ClientStream, error) {
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[i],
+		"/"+serviceName+"/"+method)
+	if err != nil {
+		return nil, err
+	}
+	if err = stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// A checksummer computes the checksums for a DeltaRequest from a dump.
+type checksummer struct {
+	headerSize int
+	header     []byte
+	chunk      []byte
+	sums       []uint32
+}
+
+func (s *checksummer) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if len(s.header) < s.headerSize {
+		k := s.headerSize - len(s.header)
+		if k > len(p) {
+			k = len(p)
+		}
+		s.header = append(s.header, p[:k]...)
+		p = p[k:]
+	}
+
+	for len(p) > 0 {
+		k := blockBytes*chunkBlocks - len(s.chunk)
+		if k > len(p) {
+			k = len(p)
+		}
+		s.chunk = append(s.chunk, p[:k]...)
+		p = p[k:]
+
+		if len(s.chunk) == blockBytes*chunkBlocks {
+			s.flush()
+		}
+	}
+	return n, nil
+}
+
+func (s *checksummer) flush() {
+	if len(s.chunk) > 0 {
+		s.sums = append(s.sums, crc32.Checksum(s.chunk, castagnoli))
+		s.chunk = s.chunk[:0]
+	}
+}
+
+// A dumpReader reads the data from a stream of DumpChunks:
+// the response to GetDump or the request of Merge.
+type dumpReader struct {
+	stream interface{ RecvMsg(m interface{}) error }
+	buf    []byte
+}
+
+func (r *dumpReader) Read(p []byte) (n int, err error) {
+	for len(r.buf) == 0 {
+		chunk := new(DumpChunk)
+		if err = r.stream.RecvMsg(chunk); err != nil {
+			return 0, err
+		}
+		r.buf = chunk.Data
+	}
+	n = copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// A deltaReader reconstructs a dump from a GetDelta stream,
+// filling in zero blocks between the chunks. Loading the result
+// into the replica updates it.
+type deltaReader struct {
+	stream  grpc.ClientStream
+	header  []byte
+	nblocks uint64
+
+	pos   uint64      // Block at which the next Read starts.
+	chunk *DeltaChunk // Current chunk, or nil.
+	done  bool        // Stream has ended.
+}
+
+var zeros [blockBytes]byte
+
+func (r *deltaReader) Read(p []byte) (n int, err error) {
+	if len(r.header) > 0 {
+		n = copy(p, r.header)
+		r.header = r.header[n:]
+		return n, nil
+	}
+	if r.pos >= r.nblocks {
+		return 0, io.EOF
+	}
+	if len(p) < blockBytes {
+		return 0, io.ErrShortBuffer
+	}
+
+	if r.chunk == nil && !r.done {
+		chunk := new(DeltaChunk)
+		err = r.stream.RecvMsg(chunk)
+		switch {
+		case err == io.EOF:
+			r.done = true
+		case err != nil:
+			return 0, err
+		case chunk.FirstBlock < r.pos || len(chunk.Data)%blockBytes != 0 ||
+			chunk.FirstBlock+uint64(len(chunk.Data)/blockBytes) > r.nblocks:
+			return 0, fmt.Errorf("bloomgrpc: invalid DeltaChunk at block %d", chunk.FirstBlock)
+		default:
+			r.chunk = chunk
+		}
+	}
+
+	if r.chunk == nil || r.chunk.FirstBlock > r.pos {
+		n = copy(p, zeros[:])
+		r.pos++
+		return n, nil
+	}
+
+	n = copy(p, r.chunk.Data)
+	n -= n % blockBytes
+	r.chunk.Data = r.chunk.Data[n:]
+	r.pos += uint64(n / blockBytes)
+	r.chunk.FirstBlock = r.pos
+	if len(r.chunk.Data) == 0 {
+		r.chunk = nil
+	}
+	return n, nil
+}
@@ -0,0 +1,23 @@
+module github.com/greatroar/blobloom/bloomgrpc
+
+go 1.19
+
+require (
+	github.com/greatroar/blobloom v0.7.2
+	github.com/stretchr/testify v1.8.0
+	google.golang.org/grpc v1.56.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.9.0 // indirect
+	golang.org/x/sys v0.7.0 // indirect
+	golang.org/x/text v0.9.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
+	google.golang.org/protobuf v1.30.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/greatroar/blobloom => ../
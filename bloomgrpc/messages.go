@@ -0,0 +1,122 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomgrpc
+
+import "fmt"
+
+// The message types in this file mirror those in bloomgrpc.proto.
+// Instead of being generated by protoc, they are described by struct tags,
+// which the protobuf runtime understands. Keep both files in sync.
+
+// AddRequest is the request message of Add.
+type AddRequest struct {
+	Hashes []uint64 `protobuf:"fixed64,1,rep,packed,name=hashes,proto3"`
+}
+
+// AddResponse is the response message of Add.
+type AddResponse struct{}
+
+// HasRequest is the request message of Has.
+type HasRequest struct {
+	Hash uint64 `protobuf:"fixed64,1,opt,name=hash,proto3"`
+}
+
+// HasResponse is the response message of Has.
+type HasResponse struct {
+	Present bool `protobuf:"varint,1,opt,name=present,proto3"`
+}
+
+// BatchHasRequest is the request message of BatchHas.
+type BatchHasRequest struct {
+	Hashes []uint64 `protobuf:"fixed64,1,rep,packed,name=hashes,proto3"`
+}
+
+// BatchHasResponse is the response message of BatchHas.
+type BatchHasResponse struct {
+	Present []bool `protobuf:"varint,1,rep,packed,name=present,proto3"`
+}
+
+// DumpRequest is the request message of GetDump.
+type DumpRequest struct{}
+
+// DumpChunk is a response message of GetDump and a request message of Merge.
+type DumpChunk struct {
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3"`
+}
+
+// MergeResponse is the response message of Merge.
+type MergeResponse struct{}
+
+// DeltaRequest is the request message of GetDelta.
+type DeltaRequest struct {
+	NumBlocks   uint64   `protobuf:"varint,1,opt,name=num_blocks,json=numBlocks,proto3"`
+	NumHashes   uint32   `protobuf:"varint,2,opt,name=num_hashes,json=numHashes,proto3"`
+	ChunkBlocks uint32   `protobuf:"varint,3,opt,name=chunk_blocks,json=chunkBlocks,proto3"`
+	Checksums   []uint32 `protobuf:"fixed32,4,rep,packed,name=checksums,proto3"`
+}
+
+// DeltaChunk is a response message of GetDelta.
+type DeltaChunk struct {
+	FirstBlock uint64 `protobuf:"varint,1,opt,name=first_block,json=firstBlock,proto3"`
+	Data       []byte `protobuf:"bytes,2,opt,name=data,proto3"`
+}
+
+// Methods required by the protobuf runtime.
+
+func (m *AddRequest) Reset()         { *m = AddRequest{} }
+func (m *AddRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*AddRequest) ProtoMessage()    {}
+
+func (m *AddResponse) Reset()         { *m = AddResponse{} }
+func (m *AddResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*AddResponse) ProtoMessage()    {}
+
+func (m *HasRequest) Reset()         { *m = HasRequest{} }
+func (m *HasRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*HasRequest) ProtoMessage()    {}
+
+func (m *HasResponse) Reset()         { *m = HasResponse{} }
+func (m *HasResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*HasResponse) ProtoMessage()    {}
+
+func (m *BatchHasRequest) Reset()         { *m = BatchHasRequest{} }
+func (m *BatchHasRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*BatchHasRequest) ProtoMessage()    {}
+
+func (m *BatchHasResponse) Reset()         { *m = BatchHasResponse{} }
+func (m *BatchHasResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*BatchHasResponse) ProtoMessage()    {}
+
+func (m *DumpRequest) Reset()         { *m = DumpRequest{} }
+func (m *DumpRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*DumpRequest) ProtoMessage()    {}
+
+func (m *DumpChunk) Reset()         { *m = DumpChunk{} }
+func (m *DumpChunk) String() string { return fmt.Sprintf("DumpChunk{%d bytes}", len(m.Data)) }
+func (*DumpChunk) ProtoMessage()    {}
+
+func (m *MergeResponse) Reset()         { *m = MergeResponse{} }
+func (m *MergeResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*MergeResponse) ProtoMessage()    {}
+
+func (m *DeltaRequest) Reset()         { *m = DeltaRequest{} }
+func (m *DeltaRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*DeltaRequest) ProtoMessage()    {}
+
+func (m *DeltaChunk) Reset() { *m = DeltaChunk{} }
+func (m *DeltaChunk) String() string {
+	return fmt.Sprintf("DeltaChunk{FirstBlock:%d %d bytes}", m.FirstBlock, len(m.Data))
+}
+func (*DeltaChunk) ProtoMessage() {}
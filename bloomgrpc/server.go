@@ -0,0 +1,342 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bloomgrpc implements a gRPC service for accessing a remote
+// Bloom filter.
+//
+// The service is defined in bloomgrpc.proto. It lets clients that cannot
+// hold a large filter in memory consult it remotely, lets clients that
+// can hold it keep a replica up to date by fetching dumps and deltas,
+// and lets clients merge filters that they built locally into it.
+//
+// This package lives in its own module, so that the blobloom package itself
+// remains free of dependencies.
+package bloomgrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/greatroar/blobloom"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const serviceName = "blobloom.v1.BloomFilter"
+
+// Maximum number of bytes in a DumpChunk or DeltaChunk sent by a Server.
+const maxChunkSize = 1 << 16
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// A Server serves a SyncFilter through the BloomFilter service.
+type Server struct {
+	f       *blobloom.SyncFilter
+	comment string
+}
+
+// NewServer returns a Server for f. The comment is included in dumps;
+// it must satisfy the requirements of blobloom.Dump.
+func NewServer(f *blobloom.SyncFilter, comment string) *Server {
+	return &Server{f: f, comment: comment}
+}
+
+// Register registers srv with the gRPC server s.
+func Register(s grpc.ServiceRegistrar, srv *Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+// Add implements the Add method of the BloomFilter service.
+func (s *Server) Add(ctx context.Context, req *AddRequest) (*AddResponse, error) {
+	for _, h := range req.Hashes {
+		s.f.Add(h)
+	}
+	return &AddResponse{}, nil
+}
+
+// Has implements the Has method of the BloomFilter service.
+func (s *Server) Has(ctx context.Context, req *HasRequest) (*HasResponse, error) {
+	return &HasResponse{Present: s.f.Has(req.Hash)}, nil
+}
+
+// BatchHas implements the BatchHas method of the BloomFilter service.
+func (s *Server) BatchHas(ctx context.Context, req *BatchHasRequest) (*BatchHasResponse, error) {
+	present := make([]bool, len(req.Hashes))
+	for i, h := range req.Hashes {
+		present[i] = s.f.Has(h)
+	}
+	return &BatchHasResponse{Present: present}, nil
+}
+
+// GetDump implements the GetDump method of the BloomFilter service.
+func (s *Server) GetDump(req *DumpRequest, stream grpc.ServerStream) error {
+	w := bufio.NewWriterSize(writerFunc(func(p []byte) (int, error) {
+		return len(p), stream.SendMsg(&DumpChunk{Data: p})
+	}), maxChunkSize)
+
+	if _, err := blobloom.DumpSync(w, s.f, s.comment); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// GetDelta implements the GetDelta method of the BloomFilter service.
+func (s *Server) GetDelta(req *DeltaRequest, stream grpc.ServerStream) error {
+	nblocks := s.f.NumBits() / blobloom.BlockBits
+
+	switch {
+	case req.ChunkBlocks == 0:
+		return status.Error(codes.InvalidArgument, "chunk_blocks must be positive")
+	case uint64(req.ChunkBlocks)*blockBytes > maxChunkSize:
+		return status.Errorf(codes.InvalidArgument,
+			"chunk_blocks must be at most %d", maxChunkSize/blockBytes)
+	case req.NumBlocks != nblocks:
+		return status.Errorf(codes.FailedPrecondition,
+			"replica has %d blocks, filter has %d", req.NumBlocks, nblocks)
+	case uint64(len(req.Checksums)) != numChunks(nblocks, req.ChunkBlocks):
+		return status.Errorf(codes.InvalidArgument,
+			"expected %d checksums, got %d",
+			numChunks(nblocks, req.ChunkBlocks), len(req.Checksums))
+	}
+
+	d := &deltaWriter{
+		headerSize: int(s.f.DumpSize() - s.f.NumBits()/8),
+		chunkSize:  blockBytes * int(req.ChunkBlocks),
+		nhashes:    req.NumHashes,
+		checksums:  req.Checksums,
+		send: func(first uint64, data []byte) error {
+			return stream.SendMsg(&DeltaChunk{FirstBlock: first, Data: data})
+		},
+	}
+	if _, err := blobloom.DumpSync(d, s.f, ""); err != nil {
+		return err
+	}
+	return d.flush()
+}
+
+// Merge implements the Merge method of the BloomFilter service.
+//
+// If the stream breaks off, the filter may have been partially merged.
+func (s *Server) Merge(stream grpc.ServerStream) error {
+	l, err := blobloom.NewLoader(&dumpReader{stream: stream})
+	if err == nil {
+		_, err = l.LoadSync(s.f)
+	}
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Error(codes.InvalidArgument, err.Error())
+		}
+		return err
+	}
+	return stream.SendMsg(&MergeResponse{})
+}
+
+const blockBytes = blobloom.BlockBits / 8
+
+func numChunks(nblocks uint64, chunkBlocks uint32) uint64 {
+	return (nblocks + uint64(chunkBlocks) - 1) / uint64(chunkBlocks)
+}
+
+// A deltaWriter consumes a dump and sends the chunks whose checksums
+// do not match the expected ones. Consecutive mismatching chunks are
+// coalesced into messages of up to maxChunkSize bytes.
+type deltaWriter struct {
+	headerSize int // Size of the dump header, which is not checksummed.
+	chunkSize  int
+	nhashes    uint32
+	checksums  []uint32
+	send       func(firstBlock uint64, data []byte) error
+
+	header  []byte
+	chunk   []byte // Current chunk.
+	nchunks int    // Number of completed chunks.
+
+	pending      []byte // Data of mismatching chunks not yet sent.
+	pendingFirst uint64 // First block in pending.
+}
+
+func (d *deltaWriter) Write(p []byte) (n int, err error) {
+	n = len(p)
+
+	if len(d.header) < d.headerSize {
+		k := d.headerSize - len(d.header)
+		if k > len(p) {
+			k = len(p)
+		}
+		d.header = append(d.header, p[:k]...)
+		p = p[k:]
+
+		if len(d.header) == d.headerSize {
+			nhashes := binary.LittleEndian.Uint32(d.header[16:])
+			if nhashes != d.nhashes {
+				return 0, status.Errorf(codes.FailedPrecondition,
+					"replica has %d hashes, filter has %d", d.nhashes, nhashes)
+			}
+		}
+	}
+
+	for len(p) > 0 {
+		k := d.chunkSize - len(d.chunk)
+		if k > len(p) {
+			k = len(p)
+		}
+		d.chunk = append(d.chunk, p[:k]...)
+		p = p[k:]
+
+		if len(d.chunk) == d.chunkSize {
+			if err = d.endChunk(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (d *deltaWriter) endChunk() error {
+	i := d.nchunks
+	d.nchunks++
+	chunk := d.chunk
+	d.chunk = d.chunk[:0]
+
+	if crc32.Checksum(chunk, castagnoli) == d.checksums[i] {
+		return d.sendPending()
+	}
+
+	first := uint64(i) * uint64(d.chunkSize/blockBytes)
+	if len(d.pending) == 0 {
+		d.pendingFirst = first
+	}
+	d.pending = append(d.pending, chunk...)
+	if len(d.pending) >= maxChunkSize {
+		return d.sendPending()
+	}
+	return nil
+}
+
+func (d *deltaWriter) sendPending() error {
+	if len(d.pending) == 0 {
+		return nil
+	}
+	err := d.send(d.pendingFirst, d.pending)
+	d.pending = d.pending[:0]
+	return err
+}
+
+func (d *deltaWriter) flush() error {
+	if len(d.chunk) > 0 {
+		if err := d.endChunk(); err != nil {
+			return err
+		}
+	}
+	return d.sendPending()
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// service is the BloomFilter service, as implemented by Server.
+// grpc.ServiceDesc requires an interface type for the handler.
+type service interface {
+	Add(context.Context, *AddRequest) (*AddResponse, error)
+	Has(context.Context, *HasRequest) (*HasResponse, error)
+	BatchHas(context.Context, *BatchHasRequest) (*BatchHasResponse, error)
+	GetDump(*DumpRequest, grpc.ServerStream) error
+	GetDelta(*DeltaRequest, grpc.ServerStream) error
+	Merge(grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*service)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Add", Handler: addHandler},
+		{MethodName: "Has", Handler: hasHandler},
+		{MethodName: "BatchHas", Handler: batchHasHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "GetDump", Handler: getDumpHandler, ServerStreams: true},
+		{StreamName: "GetDelta", Handler: getDeltaHandler, ServerStreams: true},
+		{StreamName: "Merge", Handler: mergeHandler, ClientStreams: true},
+	},
+	Metadata: "bloomgrpc.proto",
+}
+
+func addHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(AddRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).Add(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/Add"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*Server).Add(ctx, req.(*AddRequest))
+	})
+}
+
+func hasHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(HasRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).Has(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/Has"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*Server).Has(ctx, req.(*HasRequest))
+	})
+}
+
+func batchHasHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(BatchHasRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).BatchHas(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/BatchHas"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*Server).BatchHas(ctx, req.(*BatchHasRequest))
+	})
+}
+
+func getDumpHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(DumpRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(*Server).GetDump(req, stream)
+}
+
+func getDeltaHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(DeltaRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(*Server).GetDelta(req, stream)
+}
+
+func mergeHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(*Server).Merge(stream)
+}
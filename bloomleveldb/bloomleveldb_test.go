@@ -0,0 +1,61 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomleveldb_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/greatroar/blobloom/bloomleveldb"
+	"github.com/stretchr/testify/assert"
+)
+
+type buffer struct{ bytes.Buffer }
+
+func (b *buffer) Alloc(n int) []byte {
+	b.Grow(n)
+	off := b.Len()
+	b.Write(make([]byte, n))
+	return b.Bytes()[off:]
+}
+
+func TestFilter(t *testing.T) {
+	f := bloomleveldb.NewFilter(10)
+	assert.Equal(t, bloomleveldb.Name, f.Name())
+
+	g := f.NewGenerator()
+
+	// Generators are reused for successive data blocks.
+	for round := 0; round < 2; round++ {
+		const n = 1000
+		for i := 0; i < n; i++ {
+			g.Add([]byte(fmt.Sprint(round, i)))
+		}
+		var b buffer
+		g.Generate(&b)
+		p := b.Bytes()
+		assert.Equal(t, 20*64+1, len(p))
+
+		fp := 0
+		for i := 0; i < n; i++ {
+			assert.True(t, f.Contains(p, []byte(fmt.Sprint(round, i))))
+			if f.Contains(p, []byte(fmt.Sprint(round, i, "x"))) {
+				fp++
+			}
+		}
+		assert.Less(t, fp, n/20)
+	}
+}
@@ -0,0 +1,17 @@
+module github.com/greatroar/blobloom/bloomleveldb
+
+go 1.17
+
+require (
+	github.com/greatroar/blobloom v0.7.2
+	github.com/stretchr/testify v1.8.0
+	github.com/syndtr/goleveldb v1.0.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/greatroar/blobloom => ../
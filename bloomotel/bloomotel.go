@@ -0,0 +1,246 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bloomotel instruments Bloom filters with OpenTelemetry metrics.
+//
+// This package lives in its own module, so that the blobloom package itself
+// remains free of dependencies.
+package bloomotel
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/greatroar/blobloom"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// A Filter is a Bloom filter that can be instrumented.
+// *blobloom.Filter and *blobloom.SyncFilter implement this interface.
+type Filter interface {
+	Add(h uint64)
+	Has(h uint64) bool
+
+	Cardinality() float64
+	FillRatio() float64
+	FPRate(nkeys uint64) float64
+	NumBits() uint64
+}
+
+// A Config holds parameters for New.
+type Config struct {
+	// Meter creates the instruments. If nil, a Meter is obtained from
+	// the global MeterProvider.
+	Meter metric.Meter
+
+	// Attributes are attached to every measurement. Use these to
+	// distinguish between multiple instrumented filters.
+	Attributes []attribute.KeyValue
+}
+
+// An Instrumented wraps a Filter and records metrics about its use.
+//
+// The metrics are the counters blobloom.adds, blobloom.lookups and
+// blobloom.hits, the histograms blobloom.add.duration and
+// blobloom.has.duration, and the gauges blobloom.fill_ratio and
+// blobloom.estimated_fpr, which describe the saturation of the filter.
+//
+// An Instrumented may be used concurrently by multiple goroutines
+// if the underlying Filter allows that.
+type Instrumented struct {
+	Filter
+
+	adds, lookups, hits metric.Int64Counter
+	addTime, hasTime    metric.Float64Histogram
+	opts                metric.MeasurementOption
+	reg                 metric.Registration
+}
+
+// New returns an Instrumented that wraps f.
+func New(f Filter, config Config) (*Instrumented, error) {
+	meter := config.meter()
+
+	inst := &Instrumented{
+		Filter: f,
+		opts:   metric.WithAttributes(config.Attributes...),
+	}
+
+	var err error
+	counter := func(name, desc string) metric.Int64Counter {
+		if err != nil {
+			return nil
+		}
+		var c metric.Int64Counter
+		c, err = meter.Int64Counter(name, metric.WithDescription(desc))
+		return c
+	}
+	histogram := func(name, desc string) metric.Float64Histogram {
+		if err != nil {
+			return nil
+		}
+		var h metric.Float64Histogram
+		h, err = meter.Float64Histogram(name,
+			metric.WithDescription(desc), metric.WithUnit("s"))
+		return h
+	}
+	gauge := func(name, desc string) metric.Float64ObservableGauge {
+		if err != nil {
+			return nil
+		}
+		var g metric.Float64ObservableGauge
+		g, err = meter.Float64ObservableGauge(name, metric.WithDescription(desc))
+		return g
+	}
+
+	inst.adds = counter("blobloom.adds", "Number of calls to Add.")
+	inst.lookups = counter("blobloom.lookups", "Number of calls to Has.")
+	inst.hits = counter("blobloom.hits", "Number of calls to Has that returned true.")
+	inst.addTime = histogram("blobloom.add.duration", "Duration of calls to Add.")
+	inst.hasTime = histogram("blobloom.has.duration", "Duration of calls to Has.")
+	fill := gauge("blobloom.fill_ratio", "Fraction of bits set in the Bloom filter.")
+	fpr := gauge("blobloom.estimated_fpr",
+		"Estimated false positive rate of the Bloom filter.")
+	if err != nil {
+		return nil, err
+	}
+
+	inst.reg, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveFloat64(fill, f.FillRatio(), inst.opts)
+		o.ObserveFloat64(fpr, estimateFPR(f), inst.opts)
+		return nil
+	}, fill, fpr)
+	if err != nil {
+		return nil, err
+	}
+
+	return inst, nil
+}
+
+func (config *Config) meter() metric.Meter {
+	if config.Meter != nil {
+		return config.Meter
+	}
+	return otel.GetMeterProvider().Meter("github.com/greatroar/blobloom/bloomotel")
+}
+
+// A StatsFilter is a Filter that counts its operations, such as
+// a *blobloom.Filter or SyncFilter with a *blobloom.Counters set on it.
+type StatsFilter interface {
+	Filter
+	Stats() blobloom.Stats
+}
+
+// Observe registers asynchronous instruments that report the metrics of an
+// Instrumented for f, except the histograms, plus the counters
+// blobloom.batches, blobloom.verified and blobloom.false_positives.
+// The counters are read from f.Stats, so f does not need to be wrapped
+// and its operations are not counted twice. See blobloom.Counters.Verify
+// for the verification of hits.
+//
+// Unregister the returned Registration to stop reporting.
+func Observe(f StatsFilter, config Config) (metric.Registration, error) {
+	meter := config.meter()
+	opts := metric.WithAttributes(config.Attributes...)
+
+	var err error
+	counter := func(name, desc string) metric.Int64ObservableCounter {
+		if err != nil {
+			return nil
+		}
+		var c metric.Int64ObservableCounter
+		c, err = meter.Int64ObservableCounter(name, metric.WithDescription(desc))
+		return c
+	}
+	gauge := func(name, desc string) metric.Float64ObservableGauge {
+		if err != nil {
+			return nil
+		}
+		var g metric.Float64ObservableGauge
+		g, err = meter.Float64ObservableGauge(name, metric.WithDescription(desc))
+		return g
+	}
+
+	adds := counter("blobloom.adds", "Number of keys added.")
+	lookups := counter("blobloom.lookups", "Number of calls to Has.")
+	hits := counter("blobloom.hits", "Number of calls to Has that returned true.")
+	batches := counter("blobloom.batches", "Number of calls to AddMany.")
+	verified := counter("blobloom.verified",
+		"Number of calls to Has that returned true and were verified.")
+	falsePos := counter("blobloom.false_positives",
+		"Number of verified calls to Has that were false positives.")
+	fill := gauge("blobloom.fill_ratio", "Fraction of bits set in the Bloom filter.")
+	fpr := gauge("blobloom.estimated_fpr",
+		"Estimated false positive rate of the Bloom filter.")
+	if err != nil {
+		return nil, err
+	}
+
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := f.Stats()
+		o.ObserveInt64(adds, int64(stats.Adds), opts)
+		o.ObserveInt64(lookups, int64(stats.Lookups), opts)
+		o.ObserveInt64(hits, int64(stats.Hits), opts)
+		o.ObserveInt64(batches, int64(stats.Batches), opts)
+		o.ObserveInt64(verified, int64(stats.Verified), opts)
+		o.ObserveInt64(falsePos, int64(stats.FalsePositives), opts)
+		o.ObserveFloat64(fill, f.FillRatio(), opts)
+		o.ObserveFloat64(fpr, estimateFPR(f), opts)
+		return nil
+	}, adds, lookups, hits, batches, verified, falsePos, fill, fpr)
+}
+
+// Close stops the reporting of the gauges for f.
+// The counters and histograms are unaffected.
+func (f *Instrumented) Close() error {
+	return f.reg.Unregister()
+}
+
+// Add inserts a key with hash value h into the underlying filter.
+func (f *Instrumented) Add(h uint64) {
+	start := time.Now()
+	f.Filter.Add(h)
+	elapsed := time.Since(start)
+
+	ctx := context.Background()
+	f.adds.Add(ctx, 1, f.opts)
+	f.addTime.Record(ctx, elapsed.Seconds(), f.opts)
+}
+
+// Has reports whether a key with hash value h has been added to the
+// underlying filter.
+func (f *Instrumented) Has(h uint64) bool {
+	start := time.Now()
+	found := f.Filter.Has(h)
+	elapsed := time.Since(start)
+
+	ctx := context.Background()
+	f.lookups.Add(ctx, 1, f.opts)
+	if found {
+		f.hits.Add(ctx, 1, f.opts)
+	}
+	f.hasTime.Record(ctx, elapsed.Seconds(), f.opts)
+	return found
+}
+
+func estimateFPR(f Filter) float64 {
+	nkeys := f.Cardinality()
+	if math.IsInf(nkeys, 0) || nkeys >= math.MaxUint64 {
+		// At least one block is full.
+		return 1
+	}
+	return f.FPRate(uint64(math.Round(nkeys)))
+}
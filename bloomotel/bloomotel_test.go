@@ -0,0 +1,107 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomotel_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/bloomotel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestInstrumented(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	f, err := bloomotel.New(blobloom.New(1024, 3), bloomotel.Config{
+		Meter:      provider.Meter("test"),
+		Attributes: []attribute.KeyValue{attribute.String("filter", "f")},
+	})
+	require.NoError(t, err)
+
+	f.Add(1)
+	f.Add(2)
+	assert.True(t, f.Has(2))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	got := make(map[string]metricdata.Aggregation)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		got[m.Name] = m.Data
+	}
+
+	adds := got["blobloom.adds"].(metricdata.Sum[int64])
+	require.Len(t, adds.DataPoints, 1)
+	assert.EqualValues(t, 2, adds.DataPoints[0].Value)
+	v, ok := adds.DataPoints[0].Attributes.Value("filter")
+	assert.True(t, ok)
+	assert.Equal(t, "f", v.AsString())
+
+	hasTime := got["blobloom.has.duration"].(metricdata.Histogram[float64])
+	require.Len(t, hasTime.DataPoints, 1)
+	assert.EqualValues(t, 1, hasTime.DataPoints[0].Count)
+
+	fill := got["blobloom.fill_ratio"].(metricdata.Gauge[float64])
+	require.Len(t, fill.DataPoints, 1)
+	assert.Equal(t, f.FillRatio(), fill.DataPoints[0].Value)
+
+	require.NoError(t, f.Close())
+}
+
+func TestObserve(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	f := blobloom.NewSync(1024, 3)
+	f.SetRecorder(new(blobloom.Counters))
+	reg, err := bloomotel.Observe(f, bloomotel.Config{Meter: provider.Meter("test")})
+	require.NoError(t, err)
+
+	f.Add(1)
+	f.AddMany([]uint64{2, 3})
+	assert.True(t, f.Has(2))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	got := make(map[string]metricdata.Aggregation)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		got[m.Name] = m.Data
+	}
+
+	for name, want := range map[string]int64{
+		"blobloom.adds":     3,
+		"blobloom.lookups":  1,
+		"blobloom.hits":     1,
+		"blobloom.batches":  1,
+		"blobloom.verified": 0,
+	} {
+		sum := got[name].(metricdata.Sum[int64])
+		require.Len(t, sum.DataPoints, 1, name)
+		assert.Equal(t, want, sum.DataPoints[0].Value, name)
+	}
+	assert.NotContains(t, got, "blobloom.add.duration")
+
+	require.NoError(t, reg.Unregister())
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bloompebble provides a Pebble filter policy based on blocked
+// Bloom filters.
+//
+// Blocked Bloom filters answer lookups with a single cache miss, at the cost
+// of a slightly higher false positive rate than Pebble's bloom package
+// for the same number of bits per key. Set
+//
+//	pebble.LevelOptions{FilterPolicy: bloompebble.FilterPolicy(10)}
+//
+// for each level and add the policy to Options.Filters so that existing
+// tables can use it. Pebble stores each table's filter as a full-table
+// filter block, found in the metaindex under the policy's name.
+package bloompebble
+
+import (
+	"github.com/cockroachdb/pebble"
+	"github.com/greatroar/blobloom/internal/keyhash"
+	"github.com/greatroar/blobloom/internal/sstfilter"
+)
+
+// Name is the name under which filters are recorded in tables.
+const Name = "blobloom.BlockFilter"
+
+// FilterPolicy implements pebble.FilterPolicy with the given number of bits
+// per key. Ten bits per key yields a false positive rate of about 1%.
+//
+// Only table filters are supported.
+type FilterPolicy int
+
+var _ pebble.FilterPolicy = FilterPolicy(0)
+
+// Name returns Name.
+func (p FilterPolicy) Name() string { return Name }
+
+// MayContain reports whether a filter written by p may contain key.
+func (p FilterPolicy) MayContain(ftype pebble.FilterType, filter, key []byte) bool {
+	if ftype != pebble.TableFilter {
+		return true
+	}
+	return sstfilter.Contains(filter, keyhash.Sum64(key))
+}
+
+// NewWriter returns a new pebble.FilterWriter.
+// It panics if ftype is not pebble.TableFilter.
+func (p FilterPolicy) NewWriter(ftype pebble.FilterType) pebble.FilterWriter {
+	if ftype != pebble.TableFilter {
+		panic("bloompebble: unsupported filter type")
+	}
+	return &writer{bitsPerKey: int(p)}
+}
+
+type writer struct {
+	bitsPerKey int
+	hashes     []uint64
+}
+
+func (w *writer) AddKey(key []byte) {
+	w.hashes = append(w.hashes, keyhash.Sum64(key))
+}
+
+// Finish appends the filter to buf and resets w.
+func (w *writer) Finish(buf []byte) []byte {
+	buf = sstfilter.Append(buf, w.hashes, w.bitsPerKey)
+	w.hashes = w.hashes[:0]
+	return buf
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloompebble_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/greatroar/blobloom/bloompebble"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterPolicy(t *testing.T) {
+	p := bloompebble.FilterPolicy(10)
+	assert.Equal(t, bloompebble.Name, p.Name())
+
+	w := p.NewWriter(pebble.TableFilter)
+
+	// Writers are reused for successive tables.
+	for round := 0; round < 2; round++ {
+		const n = 1000
+		for i := 0; i < n; i++ {
+			w.AddKey([]byte(fmt.Sprint(round, i)))
+		}
+		prefix := []byte("prefix")
+		buf := w.Finish(prefix)
+		assert.Equal(t, "prefix", string(buf[:len(prefix)]))
+		filter := buf[len(prefix):]
+		assert.Equal(t, 20*64+1, len(filter))
+
+		fp := 0
+		for i := 0; i < n; i++ {
+			key := []byte(fmt.Sprint(round, i))
+			assert.True(t, p.MayContain(pebble.TableFilter, filter, key))
+			if p.MayContain(pebble.TableFilter, filter, append(key, 'x')) {
+				fp++
+			}
+		}
+		assert.Less(t, fp, n/20)
+	}
+
+	assert.Panics(t, func() { p.NewWriter(pebble.TableFilter + 1) })
+	assert.True(t, p.MayContain(pebble.TableFilter+1, nil, []byte("x")))
+}
@@ -0,0 +1,228 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bloomprom exports statistics about Bloom filters to Prometheus.
+//
+// This package lives in its own module, so that the blobloom package itself
+// remains free of dependencies.
+package bloomprom
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/greatroar/blobloom"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// A Filter is a Bloom filter that can be monitored by a Collector.
+// *blobloom.Filter and *blobloom.SyncFilter implement this interface.
+type Filter interface {
+	Add(h uint64)
+	Has(h uint64) bool
+
+	Cardinality() float64
+	FillRatio() float64
+	FPRate(nkeys uint64) float64
+	NumBits() uint64
+}
+
+// A statser is a Filter that counts operations,
+// like *blobloom.Filter and *blobloom.SyncFilter.
+type statser interface {
+	Stats() blobloom.Stats
+}
+
+// A CountingFilter wraps a Filter and counts the calls made to its Add and
+// Has methods. A Collector exports these counts, so that the rate of
+// additions and hits can be graphed.
+//
+// A CountingFilter may be used concurrently by multiple goroutines
+// if the underlying Filter allows that.
+//
+// Deprecated: set a *blobloom.Counters on the filter with SetRecorder
+// instead. The Collector exports the filter's Stats directly.
+type CountingFilter struct {
+	adds, lookups, hits uint64 // Accessed atomically.
+
+	Filter
+}
+
+// Counting returns a CountingFilter that wraps f.
+func Counting(f Filter) *CountingFilter {
+	return &CountingFilter{Filter: f}
+}
+
+// Add inserts a key with hash value h into the underlying filter.
+func (f *CountingFilter) Add(h uint64) {
+	atomic.AddUint64(&f.adds, 1)
+	f.Filter.Add(h)
+}
+
+// Has reports whether a key with hash value h has been added to the
+// underlying filter.
+func (f *CountingFilter) Has(h uint64) bool {
+	atomic.AddUint64(&f.lookups, 1)
+	found := f.Filter.Has(h)
+	if found {
+		atomic.AddUint64(&f.hits, 1)
+	}
+	return found
+}
+
+// Counts returns the number of calls to Add and Has made on f,
+// and the number of those calls to Has that returned true.
+func (f *CountingFilter) Counts() (adds, lookups, hits uint64) {
+	return atomic.LoadUint64(&f.adds), atomic.LoadUint64(&f.lookups),
+		atomic.LoadUint64(&f.hits)
+}
+
+// Stats returns the counts of f as a blobloom.Stats.
+func (f *CountingFilter) Stats() blobloom.Stats {
+	adds, lookups, hits := f.Counts()
+	return blobloom.Stats{Adds: adds, Lookups: lookups, Hits: hits}
+}
+
+// A Collector is a prometheus.Collector for a set of named Bloom filters.
+//
+// For each filter, it exports the number of bits, the fill ratio,
+// the estimated number of keys and the estimated false positive rate,
+// labeled with the filter's name. For filters that have a method
+// Stats() blobloom.Stats, it also exports counters of the keys added,
+// the calls to Has, the number of hits, the number of batches added,
+// and the number of hits that were verified and of those that turned out
+// to be false positives. These counters are only maintained by
+// *blobloom.Filter and SyncFilter when a *blobloom.Counters is set on them;
+// see its Verify field for the verification of hits.
+//
+// The fill ratio and estimates are computed by scanning the entire filter,
+// so Collect takes time linear in the total size of the filters.
+type Collector struct {
+	mu      sync.Mutex
+	filters map[string]Filter
+
+	bits, fill, keys, fpr *prometheus.Desc
+	adds, lookups, hits   *prometheus.Desc
+	batches               *prometheus.Desc
+	verified, falsePos    *prometheus.Desc
+}
+
+// NewCollector returns a Collector with no filters.
+// The names of the exported metrics are prefixed with namespace.
+func NewCollector(namespace string) *Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		name = prometheus.BuildFQName(namespace, "bloomfilter", name)
+		return prometheus.NewDesc(name, help, []string{"filter"}, nil)
+	}
+
+	return &Collector{
+		filters: make(map[string]Filter),
+
+		bits: desc("bits", "Size of the Bloom filter in bits."),
+		fill: desc("fill_ratio", "Fraction of bits set in the Bloom filter."),
+		keys: desc("estimated_keys",
+			"Estimated number of distinct keys in the Bloom filter."),
+		fpr: desc("estimated_fpr",
+			"Estimated false positive rate of the Bloom filter."),
+		adds:    desc("adds_total", "Number of keys added."),
+		lookups: desc("lookups_total", "Number of calls to Has."),
+		hits:    desc("hits_total", "Number of calls to Has that returned true."),
+		batches: desc("batches_total", "Number of calls to AddMany."),
+		verified: desc("verified_total",
+			"Number of calls to Has that returned true and were verified."),
+		falsePos: desc("false_positives_total",
+			"Number of verified calls to Has that were false positives."),
+	}
+}
+
+// Add adds the filter f to c under the given name,
+// replacing any filter previously added under that name.
+func (c *Collector) Add(name string, f Filter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filters[name] = f
+}
+
+// Remove removes the filter with the given name from c.
+func (c *Collector) Remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.filters, name)
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.bits
+	ch <- c.fill
+	ch <- c.keys
+	ch <- c.fpr
+	ch <- c.adds
+	ch <- c.lookups
+	ch <- c.hits
+	ch <- c.batches
+	ch <- c.verified
+	ch <- c.falsePos
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.filters))
+	filters := make([]Filter, 0, len(c.filters))
+	for name := range c.filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		filters = append(filters, c.filters[name])
+	}
+	c.mu.Unlock()
+
+	gauge := func(d *prometheus.Desc, v float64, name string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, name)
+	}
+	counter := func(d *prometheus.Desc, v uint64, name string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v), name)
+	}
+
+	for i, f := range filters {
+		name := names[i]
+
+		nkeys := f.Cardinality()
+		gauge(c.bits, float64(f.NumBits()), name)
+		gauge(c.fill, f.FillRatio(), name)
+		gauge(c.keys, nkeys, name)
+		gauge(c.fpr, estimateFPR(f, nkeys), name)
+
+		if sf, ok := f.(statser); ok {
+			stats := sf.Stats()
+			counter(c.adds, stats.Adds, name)
+			counter(c.lookups, stats.Lookups, name)
+			counter(c.hits, stats.Hits, name)
+			counter(c.batches, stats.Batches, name)
+			counter(c.verified, stats.Verified, name)
+			counter(c.falsePos, stats.FalsePositives, name)
+		}
+	}
+}
+
+func estimateFPR(f Filter, nkeys float64) float64 {
+	if math.IsInf(nkeys, 0) || nkeys >= math.MaxUint64 {
+		// At least one block is full.
+		return 1
+	}
+	return f.FPRate(uint64(math.Round(nkeys)))
+}
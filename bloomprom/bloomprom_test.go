@@ -0,0 +1,74 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomprom_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/bloomprom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	f := bloomprom.Counting(blobloom.New(1024, 3))
+	g := blobloom.NewSync(512, 2)
+	g.SetRecorder(new(blobloom.Counters))
+
+	c := bloomprom.NewCollector("test")
+	c.Add("f", f)
+	c.Add("g", g)
+
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(c))
+
+	f.Add(1)
+	f.Add(2)
+	assert.True(t, f.Has(1))
+	f.Has(0xfffffffff)
+	g.AddMany([]uint64{1, 2, 3})
+
+	expect := `
+		# HELP test_bloomfilter_adds_total Number of keys added.
+		# TYPE test_bloomfilter_adds_total counter
+		test_bloomfilter_adds_total{filter="f"} 2
+		test_bloomfilter_adds_total{filter="g"} 3
+		# HELP test_bloomfilter_bits Size of the Bloom filter in bits.
+		# TYPE test_bloomfilter_bits gauge
+		test_bloomfilter_bits{filter="f"} 1024
+		test_bloomfilter_bits{filter="g"} 512
+		# HELP test_bloomfilter_lookups_total Number of calls to Has.
+		# TYPE test_bloomfilter_lookups_total counter
+		test_bloomfilter_lookups_total{filter="f"} 2
+		test_bloomfilter_lookups_total{filter="g"} 0
+	`
+	err := testutil.GatherAndCompare(reg, strings.NewReader(expect),
+		"test_bloomfilter_adds_total", "test_bloomfilter_bits",
+		"test_bloomfilter_lookups_total")
+	assert.NoError(t, err)
+
+	n, err := testutil.GatherAndCount(reg)
+	require.NoError(t, err)
+	assert.Equal(t, 10*2, n)
+
+	c.Remove("g")
+	n, err = testutil.GatherAndCount(reg, "test_bloomfilter_fill_ratio")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
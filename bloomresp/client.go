@@ -0,0 +1,278 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomresp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/greatroar/blobloom"
+)
+
+// A Client sends Bloom filter commands to a server that speaks the Redis
+// protocol, such as a Server from this package or Redis with RedisBloom.
+//
+// A Client may be used by multiple goroutines concurrently.
+// Their commands are executed sequentially.
+type Client struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// Dial connects to the server at addr.
+func Dial(network, addr string) (*Client, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a Client that communicates over conn.
+func NewClient(conn net.Conn) *Client {
+	return &Client{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Add adds item to the filter stored under key, with BF.ADD.
+// It reports whether item was new to the filter.
+func (c *Client) Add(key string, item []byte) (bool, error) {
+	return c.boolCommand("BF.ADD", []byte(key), item)
+}
+
+// Exists reports whether the filter stored under key has item,
+// using BF.EXISTS.
+func (c *Client) Exists(key string, item []byte) (bool, error) {
+	return c.boolCommand("BF.EXISTS", []byte(key), item)
+}
+
+// Do sends a command to the server and returns its reply.
+//
+// Simple strings are returned as string, integers as int64, bulk strings
+// as []byte and arrays as []interface{}. Null bulk strings and arrays are
+// nil. Error replies are returned as an Error.
+func (c *Client) Do(args ...[]byte) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeCommand(c.w, args...)
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	v, err := readValue(c.r)
+	if e, ok := v.(Error); ok && err == nil {
+		return nil, e
+	}
+	return v, err
+}
+
+func (c *Client) boolCommand(cmd string, args ...[]byte) (bool, error) {
+	v, err := c.Do(append([][]byte{[]byte(cmd)}, args...)...)
+	if err != nil {
+		return false, err
+	}
+	i, ok := v.(int64)
+	if !ok {
+		return false, fmt.Errorf("bloomresp: unexpected reply %v to %s", v, cmd)
+	}
+	return i == 1, nil
+}
+
+// scandump fetches the dump of the filter stored under key with
+// BF.SCANDUMP and writes it to w.
+func (c *Client) scandump(key string, w io.Writer) error {
+	var iter int64
+	for {
+		v, err := c.Do([]byte("BF.SCANDUMP"), []byte(key),
+			[]byte(strconv.FormatInt(iter, 10)))
+		if err != nil {
+			return err
+		}
+
+		a, _ := v.([]interface{})
+		if len(a) != 2 {
+			return fmt.Errorf("bloomresp: unexpected reply %v to BF.SCANDUMP", v)
+		}
+		next, ok1 := a[0].(int64)
+		chunk, ok2 := a[1].([]byte)
+		if !ok1 || !ok2 {
+			return fmt.Errorf("bloomresp: unexpected reply %v to BF.SCANDUMP", v)
+		}
+		if next == 0 {
+			return nil
+		}
+		if _, err = w.Write(chunk); err != nil {
+			return err
+		}
+		iter = next
+	}
+}
+
+// A ReadThrough answers queries for a remote filter from a local replica
+// where possible.
+//
+// A negative answer from the replica is returned without contacting the
+// server. Only positive answers, which may be false positives of the
+// replica, are confirmed by the server. When most queries are for absent
+// items, this saves most of the network round-trips.
+//
+// The replica is fetched with BF.SCANDUMP, in the format produced by
+// the Server in this package. RedisBloom's native dump format is not
+// supported. The replica must be refreshed periodically to pick up items
+// added by other clients; until then, Exists may report such items as
+// absent. Items added through the ReadThrough itself are added to the
+// replica immediately.
+//
+// A ReadThrough may be used by multiple goroutines concurrently.
+type ReadThrough struct {
+	// Hash computes the hash value of an item.
+	// It must match the server's hash function. If nil, Hash is used.
+	Hash func(item []byte) uint64
+
+	c       *Client
+	key     string
+	replica atomic.Value // *blobloom.SyncFilter
+
+	refresh sync.Mutex // Serializes calls to Refresh.
+
+	// Adds that land during a Refresh may be missing from the dump it
+	// fetches, so they are recorded and replayed onto the new replica
+	// before it replaces the old one. mu makes that replacement atomic
+	// with respect to Add.
+	mu        sync.Mutex
+	recording bool
+	pending   []uint64 // Hash values added while recording.
+}
+
+// NewReadThrough returns a ReadThrough for the filter stored under key.
+// It does not fetch a replica: until the first successful call to
+// Refresh, all queries go to the server.
+func NewReadThrough(c *Client, key string) *ReadThrough {
+	return &ReadThrough{c: c, key: key}
+}
+
+// Add adds item to the remote filter and the replica.
+// It reports whether item was new to the remote filter.
+func (rt *ReadThrough) Add(item []byte) (bool, error) {
+	added, err := rt.c.Add(rt.key, item)
+	if err != nil {
+		return added, err
+	}
+
+	h := rt.hash(item)
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if f := rt.local(); f != nil {
+		f.Add(h)
+	}
+	if rt.recording {
+		rt.pending = append(rt.pending, h)
+	}
+	return added, nil
+}
+
+// Exists reports whether the filter has item.
+func (rt *ReadThrough) Exists(item []byte) (bool, error) {
+	if f := rt.local(); f != nil && !f.Has(rt.hash(item)) {
+		return false, nil
+	}
+	return rt.c.Exists(rt.key, item)
+}
+
+// Refresh replaces the replica by a fresh copy of the remote filter.
+// Items added through rt while Refresh runs are kept in the new replica.
+func (rt *ReadThrough) Refresh() error {
+	rt.refresh.Lock()
+	defer rt.refresh.Unlock()
+
+	rt.mu.Lock()
+	rt.recording = true
+	rt.mu.Unlock()
+
+	f, err := rt.fetch()
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if err == nil {
+		for _, h := range rt.pending {
+			f.Add(h)
+		}
+		rt.replica.Store(f)
+	}
+	rt.recording, rt.pending = false, nil
+	return err
+}
+
+// fetch loads a copy of the remote filter.
+func (rt *ReadThrough) fetch() (*blobloom.SyncFilter, error) {
+	var buf bytes.Buffer
+	if err := rt.c.scandump(rt.key, &buf); err != nil {
+		return nil, err
+	}
+
+	l, err := blobloom.NewLoader(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return l.LoadSync(nil)
+}
+
+// RefreshEvery calls Refresh at the given interval until ctx is done
+// or Refresh fails. It returns the error from Refresh or ctx.Err().
+func (rt *ReadThrough) RefreshEvery(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if err := rt.Refresh(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (rt *ReadThrough) local() *blobloom.SyncFilter {
+	f, _ := rt.replica.Load().(*blobloom.SyncFilter)
+	return f
+}
+
+func (rt *ReadThrough) hash(item []byte) uint64 {
+	if rt.Hash != nil {
+		return rt.Hash(item)
+	}
+	return Hash(item)
+}
@@ -0,0 +1,167 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomresp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadThrough(t *testing.T) {
+	var s Server
+	defer s.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+
+	c, err := Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	// Large enough to need multiple chunks.
+	_, err = c.Do([]byte("BF.RESERVE"), []byte("f"), []byte("1e-6"), []byte("5000000"))
+	require.NoError(t, err)
+
+	rt := NewReadThrough(c, "f")
+	added, err := rt.Add([]byte("foo"))
+	require.NoError(t, err)
+	assert.True(t, added)
+	require.Nil(t, rt.local())
+
+	for i := 0; i < 1000; i++ {
+		_, err = c.Add("f", []byte(fmt.Sprint(i)))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, rt.Refresh())
+	f := rt.local()
+	require.NotNil(t, f)
+	assert.Greater(t, f.NumBits(), uint64(scandumpChunkBlocks*blobloom.BlockBits))
+	assert.True(t, f.Has(Hash([]byte("foo"))))
+	assert.True(t, f.Has(Hash([]byte("999"))))
+
+	ok, err := rt.Exists([]byte("foo"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	added, err = rt.Add([]byte("bar"))
+	require.NoError(t, err)
+	assert.True(t, added)
+	assert.True(t, f.Has(Hash([]byte("bar"))))
+
+	// Answered locally, even though the server has the item.
+	_, err = c.Add("f", []byte("baz"))
+	require.NoError(t, err)
+	ok, err = rt.Exists([]byte("baz"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = c.Do([]byte("BF.SCANDUMP"), []byte("nonexistent"), []byte("0"))
+	assert.IsType(t, Error(""), err)
+}
+
+func TestReadThroughConcurrentRefresh(t *testing.T) {
+	var s Server
+	defer s.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+
+	c, err := Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Do([]byte("BF.RESERVE"), []byte("f"), []byte("1e-6"), []byte("5000000"))
+	require.NoError(t, err)
+
+	rt := NewReadThrough(c, "f")
+	require.NoError(t, rt.Refresh())
+
+	// Adds interleave with the chunks fetched by Refresh.
+	// None of them may be lost when the new replica is swapped in.
+	const n = 500
+	var added uint32
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			_, err := rt.Add([]byte(fmt.Sprint(i)))
+			assert.NoError(t, err)
+			atomic.AddUint32(&added, 1)
+		}
+	}()
+	for atomic.LoadUint32(&added) < n {
+		require.NoError(t, rt.Refresh())
+
+		f := rt.local()
+		for i := 0; i < int(atomic.LoadUint32(&added)); i++ {
+			require.True(t, f.Has(Hash([]byte(fmt.Sprint(i)))), i)
+		}
+	}
+}
+
+func TestScandumpChunks(t *testing.T) {
+	var s Server
+	defer s.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+
+	c, err := Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Do([]byte("BF.RESERVE"), []byte("f"), []byte("1e-6"), []byte("5000000"))
+	require.NoError(t, err)
+	f := s.filter("f", false)
+	nblocks := int(f.NumBits() / blobloom.BlockBits)
+
+	scan := func(iter int) (int64, []byte) {
+		v, err := c.Do([]byte("BF.SCANDUMP"), []byte("f"), []byte(fmt.Sprint(iter)))
+		require.NoError(t, err)
+		a := v.([]interface{})
+		return a[0].(int64), a[1].([]byte)
+	}
+
+	next, chunk := scan(0)
+	assert.EqualValues(t, 1, next)
+	assert.Len(t, chunk, 64)
+
+	// Chunks can be fetched in any order.
+	last := (nblocks + scandumpChunkBlocks - 1) / scandumpChunkBlocks
+	next, chunk = scan(last)
+	assert.EqualValues(t, last+1, next)
+	assert.Len(t, chunk, (nblocks-(last-1)*scandumpChunkBlocks)*blobloom.BlockBytes)
+
+	next, chunk = scan(1)
+	assert.EqualValues(t, 2, next)
+	assert.Len(t, chunk, scandumpChunkBlocks*blobloom.BlockBytes)
+
+	next, chunk = scan(last + 1)
+	assert.EqualValues(t, 0, next)
+	assert.Empty(t, chunk)
+}
@@ -0,0 +1,218 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomresp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Limits on incoming data, to protect against malicious input.
+// Memory for arrays and bulk strings is allocated as their contents
+// arrive, so claiming a large length costs a peer about as much as
+// sending that much data.
+const (
+	maxArrayLen = 1 << 20
+	maxBulkLen  = 8 << 20 // Client reads BF.SCANDUMP chunks.
+	maxDepth    = 4       // Nesting depth of arrays.
+
+	readChunk = 64 << 10 // Initial allocation for large values.
+)
+
+var errProtocol = errors.New("bloomresp: protocol error")
+
+// An Error is an error reply.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// readValue reads a RESP value from r. Simple strings are returned as
+// string, errors as Error, integers as int64, bulk strings as []byte
+// and arrays as []interface{}. Null bulk strings and arrays are nil.
+func readValue(r *bufio.Reader) (interface{}, error) {
+	return readNested(r, 0)
+}
+
+// readNested is readValue for a value nested in depth arrays.
+func readNested(r *bufio.Reader, depth int) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errProtocol
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := parseLen(line[1:], maxBulkLen)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		p, err := readFull(r, n+2)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.HasSuffix(p, []byte("\r\n")) {
+			return nil, errProtocol
+		}
+		return p[:n], nil
+	case '*':
+		n, err := parseLen(line[1:], maxArrayLen)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		if depth >= maxDepth {
+			return nil, errProtocol
+		}
+		a := make([]interface{}, 0, min(n, readChunk/16))
+		for i := 0; i < n; i++ {
+			v, err := readNested(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	}
+	return nil, errProtocol
+}
+
+// readCommand reads a command, either as an array of bulk strings
+// or as an inline command separated by spaces.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if b[0] != '*' {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Fields(line), nil
+	}
+
+	v, err := readValue(r)
+	if err != nil {
+		return nil, err
+	}
+	a, _ := v.([]interface{})
+	args := make([][]byte, len(a))
+	for i, x := range a {
+		arg, ok := x.([]byte)
+		if !ok {
+			return nil, errProtocol
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// readFull reads n bytes from r. It allocates memory in proportion to
+// the data received, not to n.
+func readFull(r io.Reader, n int) ([]byte, error) {
+	p := make([]byte, 0, min(n, readChunk))
+	for len(p) < n {
+		if len(p) == cap(p) {
+			p = append(p, 0)[:len(p)] // Grow by doubling.
+		}
+		k, err := io.ReadFull(r, p[len(p):min(n, cap(p))])
+		p = p[:len(p)+k]
+		if err == io.EOF && len(p) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	switch {
+	case err == bufio.ErrBufferFull:
+		return nil, errProtocol
+	case err != nil:
+		return nil, err
+	}
+	return bytes.TrimSuffix(line[:len(line)-1], []byte("\r")), nil
+}
+
+func parseLen(p []byte, max int) (int, error) {
+	n, err := strconv.Atoi(string(p))
+	if err != nil || n < -1 || n > max {
+		return 0, errProtocol
+	}
+	return n, nil
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	w.WriteByte('+')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func writeError(w *bufio.Writer, s string) {
+	w.WriteByte('-')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func writeInt(w *bufio.Writer, i int64) {
+	w.WriteByte(':')
+	w.WriteString(strconv.FormatInt(i, 10))
+	w.WriteString("\r\n")
+}
+
+func writeBulk(w *bufio.Writer, p []byte) {
+	if p == nil {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	fmt.Fprintf(w, "$%d\r\n", len(p))
+	w.Write(p)
+	w.WriteString("\r\n")
+}
+
+func writeArrayLen(w *bufio.Writer, n int) {
+	fmt.Fprintf(w, "*%d\r\n", n)
+}
+
+func writeCommand(w *bufio.Writer, args ...[]byte) {
+	writeArrayLen(w, len(args))
+	for _, a := range args {
+		writeBulk(w, a)
+	}
+}
@@ -0,0 +1,445 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bloomresp implements a server for a subset of the RedisBloom
+// commands, backed by blobloom filters.
+//
+// The server speaks RESP, the Redis protocol, so existing Redis clients in
+// any language can talk to it. It supports the following commands:
+//
+//	BF.RESERVE key error_rate capacity
+//	BF.ADD key item
+//	BF.MADD key item [item ...]
+//	BF.EXISTS key item
+//	BF.MEXISTS key item [item ...]
+//	BF.SCANDUMP key iterator
+//	PING [message]
+//	QUIT
+//
+// Like RedisBloom, BF.ADD and BF.MADD create a filter with default
+// parameters when the key does not exist. Unlike RedisBloom, filters
+// do not grow when their capacity is exceeded; their false positive
+// rate increases instead.
+//
+// BF.SCANDUMP returns chunks of a dump in the format of blobloom.Dump,
+// not in RedisBloom's format: the first chunk is the header of the dump
+// and the others hold its blocks. The ReadThrough in this package uses
+// these to maintain a local replica of a filter, so it only works with
+// this package's Server. The other methods of Client also work with Redis
+// with RedisBloom.
+//
+// Filters are stored in memory only. Since any client can create filters,
+// their total size is limited; see Server.MaxBits.
+package bloomresp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/internal/keyhash"
+)
+
+// Defaults for filters created implicitly by BF.ADD and BF.MADD.
+// These match RedisBloom's defaults.
+const (
+	DefaultCapacity  = 100
+	DefaultErrorRate = 0.01
+)
+
+// DefaultMaxBits is the default limit on the total size of a Server's
+// filters, in bits. It amounts to 1GiB.
+const DefaultMaxBits = 1 << 33
+
+// Reply to commands that would exceed the Server's MaxBits.
+const errOOM = "OOM filters would exceed the server's size limit"
+
+// A Server serves Bloom filters over the Redis protocol.
+// A Server must not be copied after first use.
+type Server struct {
+	// Hash computes the hash value of an item.
+	// If nil, Hash is used.
+	Hash func(item []byte) uint64
+
+	// MaxBits limits the total size of all filters, in bits. Commands
+	// that would exceed it fail. If zero, DefaultMaxBits is used.
+	MaxBits uint64
+
+	mu      sync.RWMutex
+	filters map[string]*blobloom.SyncFilter
+	nbits   uint64                 // Total size of filters.
+	active  map[io.Closer]struct{} // Listeners and connections.
+	closed  bool
+}
+
+// ErrServerClosed is returned by Serve after a call to Close.
+var ErrServerClosed = errors.New("bloomresp: server closed")
+
+// Hash is the default hash function: 64-bit FNV-1a,
+// followed by a finalizer that mixes the bits.
+func Hash(item []byte) uint64 {
+	return keyhash.Sum64(item)
+}
+
+// Serve accepts connections on l and serves each in a new goroutine.
+// It returns when l.Accept fails or the Server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l) {
+		return ErrServerClosed
+	}
+	defer s.untrack(l)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves a single connection. It closes conn when done.
+func (s *Server) ServeConn(conn net.Conn) {
+	defer conn.Close()
+	if !s.track(conn) {
+		return
+	}
+	defer s.untrack(conn)
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err == errProtocol {
+			writeError(w, "ERR Protocol error")
+			w.Flush()
+			return
+		} else if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.exec(w, args)
+
+		// Pipelined commands are answered in a single write.
+		if r.Buffered() == 0 || quit {
+			if w.Flush() != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// Close closes all listeners and connections of s.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	var err error
+	for c := range s.active {
+		if e := c.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.closed
+}
+
+// track adds c to the set of active listeners and connections.
+// It reports false if the server is closed.
+func (s *Server) track(c io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	if s.active == nil {
+		s.active = make(map[io.Closer]struct{})
+	}
+	s.active[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, c)
+}
+
+// exec executes a command and writes its reply. It reports whether the
+// connection should be closed.
+func (s *Server) exec(w *bufio.Writer, args [][]byte) (quit bool) {
+	cmd := strings.ToUpper(string(args[0]))
+	args = args[1:]
+
+	switch cmd {
+	case "PING":
+		switch len(args) {
+		case 0:
+			writeSimple(w, "PONG")
+		case 1:
+			writeBulk(w, args[0])
+		default:
+			wrongArgs(w, cmd)
+		}
+
+	case "QUIT":
+		writeSimple(w, "OK")
+		return true
+
+	case "BF.RESERVE":
+		if len(args) != 3 {
+			wrongArgs(w, cmd)
+			break
+		}
+		s.reserve(w, string(args[0]), string(args[1]), string(args[2]))
+
+	case "BF.ADD", "BF.MADD":
+		if len(args) < 2 || cmd == "BF.ADD" && len(args) != 2 {
+			wrongArgs(w, cmd)
+			break
+		}
+		f := s.filter(string(args[0]), true)
+		if f == nil {
+			writeError(w, errOOM)
+			break
+		}
+		if cmd == "BF.MADD" {
+			writeArrayLen(w, len(args)-1)
+		}
+		for _, item := range args[1:] {
+			h := s.hash(item)
+			// Not atomic, but concurrent adds can only make us
+			// report a new item as existing, which RedisBloom's
+			// definition of the return value allows for.
+			var added int64
+			if !f.Has(h) {
+				f.Add(h)
+				added = 1
+			}
+			writeInt(w, added)
+		}
+
+	case "BF.EXISTS", "BF.MEXISTS":
+		if len(args) < 2 || cmd == "BF.EXISTS" && len(args) != 2 {
+			wrongArgs(w, cmd)
+			break
+		}
+		f := s.filter(string(args[0]), false)
+		if cmd == "BF.MEXISTS" {
+			writeArrayLen(w, len(args)-1)
+		}
+		for _, item := range args[1:] {
+			var found int64
+			if f != nil && f.Has(s.hash(item)) {
+				found = 1
+			}
+			writeInt(w, found)
+		}
+
+	case "BF.SCANDUMP":
+		if len(args) != 2 {
+			wrongArgs(w, cmd)
+			break
+		}
+		s.scandump(w, string(args[0]), string(args[1]))
+
+	default:
+		writeError(w, "ERR unknown command '"+cmd+"'")
+	}
+	return false
+}
+
+// Size of the chunks returned by BF.SCANDUMP, in blocks.
+const scandumpChunkBlocks = (4 << 20) / blobloom.BlockBytes
+
+// scandump writes chunk number iter of the dump of a filter,
+// preceded by the next iterator, or an empty chunk and iterator zero
+// if there is no more data. Chunk zero is the header of the dump and the
+// other chunks hold scandumpChunkBlocks blocks each, so every chunk is
+// written without producing the ones before it.
+//
+// Chunks are not taken from a single snapshot, so concurrent additions to
+// the filter may only be partially reflected in the dump. Since these only
+// set bits, the result is still a valid filter.
+func (s *Server) scandump(w *bufio.Writer, key, iter string) {
+	i, err := strconv.ParseUint(iter, 10, 32)
+	if err != nil {
+		writeError(w, "ERR invalid iterator")
+		return
+	}
+	f := s.filter(key, false)
+	if f == nil {
+		writeError(w, "ERR not found")
+		return
+	}
+
+	if i == 0 {
+		hw := &headerWriter{buf: make([]byte, 0, f.DumpSize()-f.NumBits()/8)}
+		_, err = blobloom.DumpSync(hw, f, "")
+		if err != nil && err != errHeaderDone {
+			writeError(w, "ERR "+err.Error())
+			return
+		}
+		writeArrayLen(w, 2)
+		writeInt(w, 1)
+		writeBulk(w, hw.buf)
+		return
+	}
+
+	nblocks := f.NumBits() / blobloom.BlockBits
+	start := (i - 1) * scandumpChunkBlocks
+	if start >= nblocks {
+		writeArrayLen(w, 2)
+		writeInt(w, 0)
+		writeBulk(w, []byte{})
+		return
+	}
+	end := start + scandumpChunkBlocks
+	if end > nblocks {
+		end = nblocks
+	}
+
+	// The chunk is written to w block by block, through a single
+	// block-sized buffer, instead of being assembled in memory first.
+	writeArrayLen(w, 2)
+	writeInt(w, int64(i)+1)
+	fmt.Fprintf(w, "$%d\r\n", (end-start)*blobloom.BlockBytes)
+	var buf [blobloom.BlockBytes]byte
+	for j := start; j < end; j++ {
+		w.Write(f.AppendBlock(buf[:0], int(j)))
+	}
+	w.WriteString("\r\n")
+}
+
+var errHeaderDone = errors.New("header done")
+
+// A headerWriter captures the header of a dump,
+// then stops the writer by returning errHeaderDone.
+type headerWriter struct {
+	buf []byte // Has the capacity of the header.
+}
+
+func (w *headerWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	k := cap(w.buf) - len(w.buf)
+	if k > len(p) {
+		k = len(p)
+	}
+	w.buf = append(w.buf, p[:k]...)
+	if len(w.buf) == cap(w.buf) {
+		return n, errHeaderDone
+	}
+	return n, nil
+}
+
+func (s *Server) reserve(w *bufio.Writer, key, errorRate, capacity string) {
+	fpr, err := strconv.ParseFloat(errorRate, 64)
+	if err != nil || fpr <= 0 || fpr >= 1 {
+		writeError(w, "ERR (0 < error rate range < 1)")
+		return
+	}
+	n, err := strconv.ParseUint(capacity, 10, 64)
+	if err != nil || n == 0 {
+		writeError(w, "ERR (capacity should be larger than 0)")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.filters[key]; ok {
+		writeError(w, "ERR item exists")
+		return
+	}
+	if s.create(key, blobloom.Config{Capacity: n, FPRate: fpr}) == nil {
+		writeError(w, errOOM)
+		return
+	}
+	writeSimple(w, "OK")
+}
+
+// filter returns the filter stored under key. If there is none,
+// it creates one if create is true and it fits in s.MaxBits,
+// else returns nil.
+func (s *Server) filter(key string, create bool) *blobloom.SyncFilter {
+	s.mu.RLock()
+	f := s.filters[key]
+	s.mu.RUnlock()
+	if f != nil || !create {
+		return f
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f = s.filters[key]; f == nil {
+		f = s.create(key, blobloom.Config{
+			Capacity: DefaultCapacity,
+			FPRate:   DefaultErrorRate,
+		})
+	}
+	return f
+}
+
+// create stores a new filter for config under key and returns it,
+// or returns nil if it does not fit in s.MaxBits. The size is checked
+// before allocating, so clients can't exhaust memory.
+// The caller must hold s.mu.
+func (s *Server) create(key string, config blobloom.Config) *blobloom.SyncFilter {
+	nbits, nhashes := blobloom.Optimize(config)
+	maxBits := s.MaxBits
+	if maxBits == 0 {
+		maxBits = DefaultMaxBits
+	}
+	if nbits > maxBits || s.nbits > maxBits-nbits {
+		return nil
+	}
+
+	if s.filters == nil {
+		s.filters = make(map[string]*blobloom.SyncFilter)
+	}
+	f := blobloom.NewSync(nbits, nhashes)
+	s.nbits += f.NumBits()
+	s.filters[key] = f
+	return f
+}
+
+func (s *Server) hash(item []byte) uint64 {
+	if s.Hash != nil {
+		return s.Hash(item)
+	}
+	return Hash(item)
+}
+
+func wrongArgs(w *bufio.Writer, cmd string) {
+	writeError(w, "ERR wrong number of arguments for '"+strings.ToLower(cmd)+"' command")
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloomresp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	s := new(Server)
+	client, server := net.Pipe()
+	go s.ServeConn(server)
+	defer s.Close()
+
+	r := bufio.NewReader(client)
+	w := bufio.NewWriter(client)
+
+	do := func(args ...string) interface{} {
+		t.Helper()
+
+		p := make([][]byte, len(args))
+		for i := range args {
+			p[i] = []byte(args[i])
+		}
+		done := make(chan struct{})
+		go func() {
+			writeCommand(w, p...)
+			w.Flush()
+			close(done)
+		}()
+
+		v, err := readValue(r)
+		require.NoError(t, err)
+		<-done
+		return v
+	}
+
+	assert.Equal(t, "PONG", do("PING"))
+	assert.Equal(t, []byte("hi"), do("ping", "hi"))
+
+	assert.Equal(t, "OK", do("BF.RESERVE", "f", "0.001", "1000"))
+	assert.IsType(t, Error(""), do("BF.RESERVE", "f", "0.001", "1000"))
+	assert.IsType(t, Error(""), do("BF.RESERVE", "g", "2", "1000"))
+
+	assert.EqualValues(t, 1, do("BF.ADD", "f", "foo"))
+	assert.EqualValues(t, 0, do("BF.ADD", "f", "foo"))
+	assert.EqualValues(t, 1, do("BF.EXISTS", "f", "foo"))
+	assert.EqualValues(t, 0, do("BF.EXISTS", "f", "bar"))
+	assert.EqualValues(t, 0, do("BF.EXISTS", "nonexistent", "foo"))
+
+	assert.Equal(t, []interface{}{int64(1), int64(0)},
+		do("BF.MADD", "g", "bar", "bar"))
+	assert.Equal(t, []interface{}{int64(1), int64(0)},
+		do("BF.MEXISTS", "g", "bar", "foo"))
+
+	assert.IsType(t, Error(""), do("BF.ADD", "f"))
+	assert.IsType(t, Error(""), do("NOSUCHCOMMAND"))
+
+	// Inline command.
+	done := make(chan struct{})
+	go func() {
+		w.WriteString("BF.EXISTS f foo\r\n")
+		w.Flush()
+		close(done)
+	}()
+	v, err := readValue(r)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, v)
+	<-done
+
+	assert.Equal(t, "OK", do("QUIT"))
+	_, err = readValue(r)
+	assert.Error(t, err)
+}
+
+func TestServerMaxBits(t *testing.T) {
+	s := &Server{MaxBits: 1 << 16}
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	reply := func(args ...string) string {
+		t.Helper()
+		p := make([][]byte, len(args))
+		for i := range args {
+			p[i] = []byte(args[i])
+		}
+		buf.Reset()
+		s.exec(w, p)
+		w.Flush()
+		return buf.String()
+	}
+
+	assert.Equal(t, "-"+errOOM+"\r\n", reply("BF.RESERVE", "huge", "1e-9", "1000000000000"))
+	assert.Equal(t, "+OK\r\n", reply("BF.RESERVE", "big", "0.01", "5000"))
+
+	// Implicitly created filters count as well.
+	n := 0
+	for strings.HasPrefix(reply("BF.ADD", "key"+strconv.Itoa(n), "item"), ":") {
+		n++
+	}
+	assert.Equal(t, "-"+errOOM+"\r\n", reply("BF.MADD", "another", "item"))
+	assert.Greater(t, n, 0)
+	assert.LessOrEqual(t, s.nbits, s.MaxBits)
+}
+
+func TestReadValueLimits(t *testing.T) {
+	read := func(input string) (interface{}, error) {
+		return readValue(bufio.NewReader(strings.NewReader(input)))
+	}
+
+	// A bulk string longer than the data sent.
+	_, err := read("$8000000\r\nabc")
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = read("$100000000\r\n")
+	assert.Equal(t, errProtocol, err)
+
+	long := strings.Repeat("x", 3*readChunk+5)
+	v, err := read("$" + strconv.Itoa(len(long)) + "\r\n" + long + "\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, []byte(long), v)
+
+	nested := func(depth int) string {
+		return strings.Repeat("*1\r\n", depth) + ":1\r\n"
+	}
+	_, err = read(nested(maxDepth))
+	assert.NoError(t, err)
+	_, err = read(nested(maxDepth + 1))
+	assert.Equal(t, errProtocol, err)
+
+	_, err = read("*1000000\r\n:1\r\n")
+	assert.Equal(t, io.EOF, err)
+}
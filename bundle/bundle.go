@@ -0,0 +1,290 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bundle packs several named Bloom filters into a single file.
+//
+// A bundle holds, for example, one filter per day or per tenant. Shipping
+// the whole set as one artifact means it can be distributed, versioned and
+// swapped in atomically, instead of one file at a time.
+//
+// A bundle starts with an index of the names of its filters and their
+// locations, so single filters can be loaded or extracted without reading
+// the others.
+package bundle
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/internal/atomicfile"
+)
+
+// MaxNameLen is the maximum length of the name of a filter in a bundle.
+const MaxNameLen = 255
+
+// An Entry is a named filter to be written to a bundle.
+type Entry struct {
+	Name    string
+	Filter  *blobloom.Filter
+	Comment string // Stored with the filter, as by blobloom.Dump.
+}
+
+// Wire format of a bundle, all integers little-endian:
+//
+//	magic "BLBN", version byte (0), three zero bytes
+//	number of filters: 32 bits
+//	four zero bytes
+//	for each filter, the index entry:
+//		length of name: 8 bits
+//		name
+//		offset of the filter from the start of the bundle: 64 bits
+//		size of the filter: 64 bits
+//	the filters, in the format written by blobloom.Dump
+const (
+	magic      = "BLBN"
+	headerSize = 16
+)
+
+// Write writes a bundle of the filters in entries to w. The names of
+// the entries must be distinct and non-empty.
+func Write(w io.Writer, entries []Entry) (int64, error) {
+	index, err := makeIndex(entries)
+	if err != nil {
+		return 0, err
+	}
+
+	k, err := w.Write(index)
+	n := int64(k)
+	for i := 0; err == nil && i < len(entries); i++ {
+		var m int64
+		m, err = blobloom.Dump(w, entries[i].Filter, entries[i].Comment)
+		n += m
+	}
+	return n, err
+}
+
+// WriteFile writes a bundle of the filters in entries to the file at path.
+// The file is replaced atomically: readers that open path see either the
+// old bundle or the new one.
+func WriteFile(path string, entries []Entry) error {
+	f, err := atomicfile.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	_, err = Write(w, entries)
+	if err == nil {
+		err = w.Flush()
+	}
+	return atomicfile.Finish(f, err)
+}
+
+// makeIndex returns the header and index of a bundle of entries.
+func makeIndex(entries []Entry) ([]byte, error) {
+	seen := make(map[string]bool, len(entries))
+	size := headerSize
+	for _, e := range entries {
+		switch {
+		case e.Name == "":
+			return nil, errors.New("bundle: empty filter name")
+		case len(e.Name) > MaxNameLen:
+			return nil, fmt.Errorf("bundle: name %q too long", e.Name)
+		case seen[e.Name]:
+			return nil, fmt.Errorf("bundle: duplicate name %q", e.Name)
+		case e.Filter == nil:
+			return nil, fmt.Errorf("bundle: nil filter %q", e.Name)
+		}
+		seen[e.Name] = true
+		size += 1 + len(e.Name) + 16
+	}
+
+	p := make([]byte, headerSize, size)
+	copy(p, magic)
+	binary.LittleEndian.PutUint32(p[8:], uint32(len(entries)))
+
+	offset := uint64(size)
+	for _, e := range entries {
+		dumpSize := e.Filter.DumpSize()
+		p = append(p, byte(len(e.Name)))
+		p = append(p, e.Name...)
+		p = appendUint64(p, offset)
+		p = appendUint64(p, dumpSize)
+		offset += dumpSize
+	}
+	return p, nil
+}
+
+func appendUint64(p []byte, x uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], x)
+	return append(p, buf[:]...)
+}
+
+// A Reader reads filters from a bundle.
+type Reader struct {
+	r       io.ReaderAt
+	entries []indexEntry
+	byName  map[string]int
+}
+
+type indexEntry struct {
+	name         string
+	offset, size int64
+}
+
+// ErrNotFound is returned for names not in a bundle.
+var ErrNotFound = errors.New("bundle: filter not found")
+
+// NewReader reads the index of the bundle of the given size in r.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	sr := bufio.NewReader(io.NewSectionReader(r, 0, size))
+
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(sr, hdr[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	switch {
+	case string(hdr[:4]) != magic:
+		return nil, errors.New("bundle: not a filter bundle")
+	case hdr[4] != 0:
+		return nil, errors.New("bundle: unsupported version")
+	}
+
+	n := binary.LittleEndian.Uint32(hdr[8:])
+	if int64(n) > (size-headerSize)/(1+1+16) {
+		return nil, fmt.Errorf("bundle: index of %d entries too large", n)
+	}
+
+	br := &Reader{
+		r:       r,
+		entries: make([]indexEntry, n),
+		byName:  make(map[string]int, n),
+	}
+	var buf [MaxNameLen + 16]byte
+	for i := range br.entries {
+		namelen, err := sr.ReadByte()
+		if err == nil {
+			_, err = io.ReadFull(sr, buf[:int(namelen)+16])
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		e := indexEntry{
+			name:   string(buf[:namelen]),
+			offset: int64(binary.LittleEndian.Uint64(buf[namelen:])),
+			size:   int64(binary.LittleEndian.Uint64(buf[namelen+8:])),
+		}
+		switch {
+		case e.name == "":
+			return nil, errors.New("bundle: empty filter name")
+		case e.offset < 0 || e.size < 0 || e.offset > size || e.size > size-e.offset:
+			return nil, fmt.Errorf("bundle: filter %q out of bounds", e.name)
+		}
+		if _, dup := br.byName[e.name]; dup {
+			return nil, fmt.Errorf("bundle: duplicate name %q", e.name)
+		}
+		br.entries[i] = e
+		br.byName[e.name] = i
+	}
+	return br, nil
+}
+
+// Names returns the names of the filters in the bundle, in the order
+// in which they were written.
+func (r *Reader) Names() []string {
+	names := make([]string, len(r.entries))
+	for i, e := range r.entries {
+		names[i] = e.name
+	}
+	return names
+}
+
+// Open returns a Loader for the filter with the given name.
+func (r *Reader) Open(name string) (*blobloom.Loader, error) {
+	sr, err := r.section(name)
+	if err != nil {
+		return nil, err
+	}
+	return blobloom.NewLoader(bufio.NewReader(sr))
+}
+
+// Load loads the filter with the given name. It also returns the filter's
+// comment.
+func (r *Reader) Load(name string) (f *blobloom.Filter, comment string, err error) {
+	l, err := r.Open(name)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err = l.Load(nil)
+	return f, l.Comment, err
+}
+
+// Extract copies the filter with the given name to w, in the format written
+// by blobloom.Dump.
+func (r *Reader) Extract(w io.Writer, name string) (int64, error) {
+	sr, err := r.section(name)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(w, sr)
+}
+
+func (r *Reader) section(name string) (*io.SectionReader, error) {
+	i, ok := r.byName[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	e := r.entries[i]
+	return io.NewSectionReader(r.r, e.offset, e.size), nil
+}
+
+// A File is a Reader for a bundle in a file.
+type File struct {
+	*Reader
+	file *os.File
+}
+
+// OpenFile opens the bundle in the file at path.
+func OpenFile(path string) (*File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	var r *Reader
+	if err == nil {
+		r, err = NewReader(file, info.Size())
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &File{Reader: r, file: file}, nil
+}
+
+// Close closes the file.
+func (f *File) Close() error { return f.file.Close() }
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bundle_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/bundle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeEntries() []bundle.Entry {
+	r := rand.New(rand.NewSource(0xb0d1e))
+	entries := []bundle.Entry{
+		{Name: "2023-05-01", Filter: blobloom.New(1<<12, 4), Comment: "day 1"},
+		{Name: "2023-05-02", Filter: blobloom.New(1<<14, 6), Comment: "day 2"},
+		{Name: "seeded", Filter: blobloom.NewOptimized(blobloom.Config{
+			Capacity: 100, FPRate: 1e-3, Seed: 0xb0d1e, Probing: blobloom.TripleHashing,
+		})},
+		{Name: "empty", Filter: blobloom.New(1, 2)},
+	}
+	for _, e := range entries[:3] {
+		for i := 0; i < 100; i++ {
+			e.Filter.Add(r.Uint64())
+		}
+	}
+	return entries
+}
+
+func TestBundle(t *testing.T) {
+	entries := makeEntries()
+
+	var buf bytes.Buffer
+	n, err := bundle.Write(&buf, entries)
+	require.NoError(t, err)
+	assert.EqualValues(t, buf.Len(), n)
+
+	r, err := bundle.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2023-05-01", "2023-05-02", "seeded", "empty"}, r.Names())
+
+	for _, e := range entries {
+		f, comment, err := r.Load(e.Name)
+		require.NoError(t, err)
+		assert.True(t, e.Filter.Equals(f))
+		assert.Equal(t, e.Filter.Seed(), f.Seed())
+		assert.Equal(t, e.Filter.Probing(), f.Probing())
+		assert.Equal(t, e.Comment, comment)
+
+		var want, got bytes.Buffer
+		_, err = blobloom.Dump(&want, e.Filter, e.Comment)
+		require.NoError(t, err)
+		_, err = r.Extract(&got, e.Name)
+		require.NoError(t, err)
+		assert.Equal(t, want.Bytes(), got.Bytes())
+	}
+
+	_, _, err = r.Load("nonexistent")
+	assert.Equal(t, bundle.ErrNotFound, err)
+
+	// Truncated bundles.
+	for _, size := range []int{0, 10, 20, 40} {
+		_, err = bundle.NewReader(bytes.NewReader(buf.Bytes()), int64(size))
+		assert.Error(t, err)
+	}
+	r, err = bundle.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()-1))
+	if err == nil {
+		_, _, err = r.Load("empty")
+	}
+	assert.Error(t, err)
+}
+
+func TestWriteErrors(t *testing.T) {
+	f := blobloom.New(1, 2)
+	for _, entries := range [][]bundle.Entry{
+		{{Name: "", Filter: f}},
+		{{Name: "a", Filter: f}, {Name: "a", Filter: f}},
+		{{Name: "nil"}},
+		{{Name: string(make([]byte, bundle.MaxNameLen+1)), Filter: f}},
+	} {
+		_, err := bundle.Write(ioutil.Discard, entries)
+		assert.Error(t, err)
+	}
+}
+
+func TestLoadTruncatedHuge(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := bundle.Write(&buf, []bundle.Entry{{Name: "f", Filter: blobloom.New(1, 2)}})
+	require.NoError(t, err)
+
+	// Make the dump claim a huge number of blocks.
+	p := buf.Bytes()
+	dump := bytes.Index(p, []byte("blobloom"))
+	require.NotEqual(t, -1, dump)
+	binary.LittleEndian.PutUint32(p[dump+12:], 1<<30)
+
+	r, err := bundle.NewReader(bytes.NewReader(p), int64(len(p)))
+	require.NoError(t, err)
+	_, _, err = r.Load("f")
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobloom")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bundle")
+
+	entries := makeEntries()
+	require.NoError(t, bundle.WriteFile(path, entries[:1]))
+	require.NoError(t, bundle.WriteFile(path, entries))
+
+	f, err := bundle.OpenFile(path)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Len(t, f.Names(), len(entries))
+
+	g, _, err := f.Load("2023-05-02")
+	require.NoError(t, err)
+	assert.True(t, entries[1].Filter.Equals(g))
+
+	// A failed write leaves the old bundle in place.
+	assert.Error(t, bundle.WriteFile(path, []bundle.Entry{{}}))
+	names, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, names, 1)
+}
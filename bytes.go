@@ -0,0 +1,66 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"errors"
+	"fmt"
+	"hash/maphash"
+)
+
+// NewFromBytes constructs a Filter with the given number of hashes that uses
+// buf as its bit array, without copying. buf must hold the blocks of the
+// filter in the format produced by Dump, without the header, so its length
+// must be a non-zero multiple of BlockBytes. The number of hashes is
+// adjusted as by New.
+//
+// The Filter and the caller share buf: modifications to either are visible
+// through the other. buf may come from a memory mapping or an arena, and
+// must remain valid as long as the Filter is in use. NewFromBytes does not
+// clear buf, so an existing filter can be wrapped.
+//
+// buf must be 8-byte aligned. NewFromBytes is only supported on
+// little-endian architectures and not when this package is built with
+// the nounsafe tag.
+func NewFromBytes(buf []byte, nhashes int) (*Filter, error) {
+	switch {
+	case !haveUnsafe:
+		return nil, errors.New("blobloom: NewFromBytes requires package unsafe")
+	case !littleEndian():
+		return nil, errors.New("blobloom: NewFromBytes requires a little-endian CPU")
+	case len(buf) == 0 || len(buf)%BlockBytes != 0 || uint64(len(buf)) > MaxBits/8:
+		return nil, fmt.Errorf("blobloom: invalid buffer size %d", len(buf))
+	case !aligned(buf):
+		return nil, errors.New("blobloom: buffer is not 8-byte aligned")
+	}
+	if nhashes < 2 {
+		nhashes = 2
+	}
+	return &Filter{b: blocksOf(buf), k: nhashes, seed: maphash.MakeSeed()}, nil
+}
+
+// Bytes returns the bit array of f, in the format accepted by NewFromBytes.
+// The result shares memory with f: modifications to either are visible
+// through the other.
+//
+// Bytes returns nil on big-endian architectures and when this package is
+// built with the nounsafe tag, where the memory of f is not in the format
+// produced by Dump. Use AppendBlock to copy the blocks instead.
+func (f *Filter) Bytes() []byte {
+	if !haveUnsafe || !littleEndian() {
+		return nil
+	}
+	return bytesOf(f.b)
+}
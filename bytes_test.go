@@ -0,0 +1,60 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFromBytes(t *testing.T) {
+	if !haveUnsafe {
+		_, err := NewFromBytes(make([]byte, BlockBytes), 2)
+		assert.Error(t, err)
+		t.Skip("built with nounsafe")
+	}
+
+	ref := New(7*BlockBits, 5)
+	keys := randomU64(300, 0xb17e)
+	for _, h := range keys {
+		ref.Add(h)
+	}
+	p, err := ref.MarshalBinary()
+	require.NoError(t, err)
+
+	// Copy the blocks into an aligned buffer.
+	buf := bytesOf(make([]block, 7))
+	copy(buf, p[64:])
+
+	f, err := NewFromBytes(buf, 5)
+	require.NoError(t, err)
+	assert.True(t, ref.Equals(f))
+	assert.Equal(t, buf, f.Bytes())
+
+	// f and buf share memory.
+	f.Clear()
+	assert.Equal(t, make([]byte, len(buf)), buf)
+	copy(buf, p[64:])
+	for _, h := range keys {
+		assert.True(t, f.Has(h))
+	}
+
+	for _, bad := range [][]byte{nil, buf[:BlockBytes-1], buf[:BlockBytes+1], buf[1 : BlockBytes+1]} {
+		_, err = NewFromBytes(bad, 5)
+		assert.Error(t, err)
+	}
+}
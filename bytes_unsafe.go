@@ -0,0 +1,61 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nounsafe
+// +build !nounsafe
+
+package blobloom
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+const haveUnsafe = true
+
+// blocksOf reinterprets p as a slice of blocks. The length of p must be
+// a multiple of BlockBytes and p must be suitably aligned, which is the
+// case for mappings at an offset of 64 bytes.
+func blocksOf(p []byte) (b []block) {
+	if len(p) == 0 {
+		return nil
+	}
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&b))
+	h.Data = uintptr(unsafe.Pointer(&p[0]))
+	h.Len = len(p) / BlockBytes
+	h.Cap = h.Len
+	return b
+}
+
+// bytesOf reinterprets b as a slice of bytes.
+func bytesOf(b []block) (p []byte) {
+	if len(b) == 0 {
+		return nil
+	}
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&p))
+	h.Data = uintptr(unsafe.Pointer(&b[0]))
+	h.Len = len(b) * BlockBytes
+	h.Cap = h.Len
+	return p
+}
+
+// aligned reports whether p starts at an address that is a multiple of 8.
+func aligned(p []byte) bool {
+	return len(p) == 0 || uintptr(unsafe.Pointer(&p[0]))%8 == 0
+}
+
+func littleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
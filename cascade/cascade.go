@@ -0,0 +1,197 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cascade implements Bloom filter cascades, which encode a set
+// exactly with respect to a known universe of keys.
+//
+// A cascade is built from a set of keys to include and the set of all other
+// keys in the universe, to exclude. The first level is a Bloom filter of the
+// included keys. Its false positives among the excluded keys go into the
+// second level, the false positives of that among the included keys into
+// the third, and so on, until a level has no false positives. A lookup of
+// a key from the universe gives the exact answer; for other keys, it gives
+// an arbitrary answer.
+//
+// Cascades are used to distribute certificate revocation lists (CRLite)
+// and allowlists to clients, where the universe of valid certificates or
+// identifiers is known to the party building the cascade.
+//
+// See Larisch et al., CRLite: A Scalable System for Pushing All TLS
+// Revocations to All Browsers, https://doi.org/10.1109/SP.2017.17.
+package cascade
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/greatroar/blobloom"
+)
+
+// A Cascade is a Bloom filter cascade.
+type Cascade struct {
+	levels []*blobloom.Filter
+}
+
+// Build constructs a Cascade that includes the keys with hash values in
+// include and excludes those in exclude. Each level is a Bloom filter
+// built for the false positive rate fpr; CRLite uses .5, which minimizes
+// the total size for large universes.
+//
+// The hash values must be distinct within each set. Build returns an error
+// if the sets intersect or fpr is not strictly between zero and one.
+func Build(include, exclude []uint64, fpr float64) (*Cascade, error) {
+	if fpr <= 0 || fpr >= 1 {
+		return nil, fmt.Errorf("cascade: invalid false positive rate %g", fpr)
+	}
+	if h, ok := intersect(include, exclude); ok {
+		return nil, fmt.Errorf("cascade: hash %#x both included and excluded", h)
+	}
+
+	c := &Cascade{}
+	in, out := include, exclude
+	for len(in) > 0 {
+		level := len(c.levels)
+		f := blobloom.NewOptimized(blobloom.Config{
+			Capacity: uint64(len(in)),
+			FPRate:   fpr,
+		})
+		for _, h := range in {
+			f.Add(rehash(h, level))
+		}
+		c.levels = append(c.levels, f)
+
+		var fps []uint64
+		for _, h := range out {
+			if f.Has(rehash(h, level)) {
+				fps = append(fps, h)
+			}
+		}
+		in, out = fps, in
+	}
+	return c, nil
+}
+
+// Has reports whether the key with hash value h was included. The result is
+// exact for keys that were passed to Build.
+func (c *Cascade) Has(h uint64) bool {
+	for i, f := range c.levels {
+		if !f.Has(rehash(h, i)) {
+			// Misses at even levels (counting from zero) mean excluded.
+			return i%2 == 1
+		}
+	}
+	return len(c.levels)%2 == 1
+}
+
+// NumLevels returns the number of levels in c.
+func (c *Cascade) NumLevels() int { return len(c.levels) }
+
+// NumBits returns the total number of bits in the levels of c.
+func (c *Cascade) NumBits() (n uint64) {
+	for _, f := range c.levels {
+		n += f.NumBits()
+	}
+	return n
+}
+
+// rehash derives the hash for a level from h, so that the false positives
+// of different levels are independent.
+func rehash(h uint64, level int) uint64 {
+	// Finalizer from MurmurHash3. It is a bijection.
+	h ^= uint64(level) * 0x9e3779b97f4a7c15
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// intersect returns a hash value that occurs in both a and b, if any.
+func intersect(a, b []uint64) (uint64, bool) {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	sorted := append([]uint64(nil), a...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for _, h := range b {
+		i := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= h })
+		if i < len(sorted) && sorted[i] == h {
+			return h, true
+		}
+	}
+	return 0, false
+}
+
+// Wire format of a Cascade, all integers little-endian:
+//
+//	magic "BLFC", version byte (0), three zero bytes
+//	number of levels: 32 bits
+//	the levels, in the format written by blobloom.Dump
+const (
+	magic      = "BLFC"
+	headerSize = 8 + 4
+)
+
+// WriteTo writes c to w in a binary format that Read accepts.
+func (c *Cascade) WriteTo(w io.Writer) (int64, error) {
+	var hdr [headerSize]byte
+	copy(hdr[:], magic)
+	binary.LittleEndian.PutUint32(hdr[8:], uint32(len(c.levels)))
+
+	k, err := w.Write(hdr[:])
+	n := int64(k)
+	for i := 0; err == nil && i < len(c.levels); i++ {
+		var m int64
+		m, err = blobloom.Dump(w, c.levels[i], "")
+		n += m
+	}
+	return n, err
+}
+
+// Read reads a Cascade written by WriteTo from r.
+func Read(r io.Reader) (*Cascade, error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	switch {
+	case string(hdr[:4]) != magic:
+		return nil, errors.New("cascade: not a filter cascade")
+	case hdr[4] != 0:
+		return nil, errors.New("cascade: unsupported version")
+	}
+
+	n := binary.LittleEndian.Uint32(hdr[8:])
+	c := &Cascade{}
+	for i := uint32(0); i < n; i++ {
+		l, err := blobloom.NewLoader(r)
+		if err == nil {
+			var f *blobloom.Filter
+			f, err = l.Load(nil)
+			c.levels = append(c.levels, f)
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cascade
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashes(n int, seed int64) []uint64 {
+	r := rand.New(rand.NewSource(seed))
+	hs := make([]uint64, n)
+	for i := range hs {
+		hs[i] = r.Uint64()
+	}
+	return hs
+}
+
+func TestCascade(t *testing.T) {
+	universe := hashes(100000, 0xc45c)
+	revoked, valid := universe[:1000], universe[1000:]
+
+	for _, fpr := range []float64{.5, .01} {
+		c, err := Build(revoked, valid, fpr)
+		require.NoError(t, err)
+		assert.Greater(t, c.NumLevels(), 1)
+
+		for _, h := range revoked {
+			assert.True(t, c.Has(h))
+		}
+		for _, h := range valid {
+			assert.False(t, c.Has(h))
+		}
+
+		var buf bytes.Buffer
+		n, err := c.WriteTo(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, int64(buf.Len()), n)
+		assert.Equal(t, int64(headerSize+64*c.NumLevels())+int64(c.NumBits()/8), n)
+
+		d, err := Read(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, c.NumLevels(), d.NumLevels())
+		for _, h := range universe[:5000] {
+			assert.Equal(t, c.Has(h), d.Has(h))
+		}
+	}
+}
+
+func TestCascadeEmpty(t *testing.T) {
+	c, err := Build(nil, hashes(10, 1), .5)
+	require.NoError(t, err)
+	assert.Zero(t, c.NumLevels())
+	assert.False(t, c.Has(1))
+
+	c, err = Build(hashes(10, 1), nil, .5)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.NumLevels())
+	assert.True(t, c.Has(hashes(10, 1)[3]))
+}
+
+func TestCascadeErrors(t *testing.T) {
+	hs := hashes(100, 2)
+	_, err := Build(hs[:60], hs[50:], .5)
+	assert.Error(t, err)
+	_, err = Build(hs[:50], hs[50:], 1)
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	c, _ := Build(hs[:50], hs[50:], .5)
+	c.WriteTo(&buf)
+	_, err = Read(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.Error(t, err)
+	_, err = Read(bytes.NewReader([]byte("BLXX\x00\x00\x00\x00\x00\x00\x00\x00")))
+	assert.Error(t, err)
+
+	// A truncated level that claims a huge number of blocks.
+	p := buf.Bytes()
+	binary.LittleEndian.PutUint32(p[headerSize+12:], 1<<30)
+	_, err = Read(bytes.NewReader(p))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2023 the Blobloom authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+ * C interface to blobloom Bloom filters.
+ *
+ * Filters are referred to by handles. A handle of zero signals an error.
+ * All functions may be called concurrently from multiple threads.
+ */
+#ifndef BLOBLOOM_H
+#define BLOBLOOM_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+#define BLOBLOOM_ABI_VERSION 1
+
+typedef uint64_t blobloom_filter;
+
+/* Returns the ABI version implemented by the library. */
+int blobloom_abi_version(void);
+
+/*
+ * Creates a filter with the given numbers of bits and hash functions.
+ * See blobloom.New for how these are adjusted.
+ */
+blobloom_filter blobloom_new(uint64_t nbits, int nhashes);
+
+/*
+ * Creates a filter for capacity keys with false positive rate fprate,
+ * which must be > 0 and <= 1.
+ */
+blobloom_filter blobloom_new_optimized(uint64_t capacity, double fprate);
+
+/* Releases a filter. Its handle must not be used afterwards. */
+void blobloom_free(blobloom_filter f);
+
+/*
+ * Adds the key with the given 64-bit hash to f.
+ * Returns 0 on success, -1 for an invalid handle.
+ */
+int blobloom_add(blobloom_filter f, uint64_t hash);
+
+/*
+ * Reports whether f may contain the key with the given hash:
+ * 1 if so, 0 if not, -1 for an invalid handle.
+ */
+int blobloom_has(blobloom_filter f, uint64_t hash);
+
+/*
+ * Hashes a key with the function used by the blobloom servers
+ * (64-bit FNV-1a with a finalizer). Keys longer than INT32_MAX bytes
+ * are not supported and hash to 0.
+ */
+uint64_t blobloom_hash_key(const void *key, size_t length);
+
+/* Returns the number of bits in f, or 0 for an invalid handle. */
+uint64_t blobloom_num_bits(blobloom_filter f);
+
+/*
+ * Serializes f in the format of blobloom.Dump, with an optional comment
+ * (which may be NULL). Returns a buffer that must be released with
+ * blobloom_free_buffer and stores its length in *length,
+ * or returns NULL on error.
+ */
+void *blobloom_dump(blobloom_filter f, const char *comment, size_t *length);
+
+/* Releases a buffer returned by blobloom_dump. */
+void blobloom_free_buffer(void *p);
+
+/*
+ * Creates a filter from a buffer in the format of blobloom.Dump.
+ * Returns 0 if the buffer is invalid or longer than INT32_MAX bytes.
+ */
+blobloom_filter blobloom_load(const void *p, size_t length);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif /* BLOBLOOM_H */
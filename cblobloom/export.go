@@ -0,0 +1,137 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command cblobloom is a C library for Bloom filters produced by blobloom.
+//
+// Build it with
+//
+//	go build -buildmode=c-shared -o libblobloom.so ./cblobloom
+//
+// or with -buildmode=c-archive for a static library, and include the
+// header blobloom.h from this directory, which documents the functions.
+// The ABI is stable: functions are only added, never changed or removed,
+// and blobloom_abi_version reports the version implemented.
+//
+// Filters are safe for concurrent use from multiple threads.
+package main
+
+/*
+#include <stddef.h>
+#include <stdint.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"math"
+	"unsafe"
+
+	"github.com/greatroar/blobloom/internal/keyhash"
+)
+
+// Keep in sync with BLOBLOOM_ABI_VERSION in blobloom.h.
+const abiVersion = 1
+
+func main() {}
+
+//export blobloom_abi_version
+func blobloom_abi_version() C.int { return abiVersion }
+
+//export blobloom_new
+func blobloom_new(nbits C.uint64_t, nhashes C.int) C.uint64_t {
+	return C.uint64_t(newFilter(uint64(nbits), int(nhashes)))
+}
+
+//export blobloom_new_optimized
+func blobloom_new_optimized(capacity C.uint64_t, fprate C.double) C.uint64_t {
+	return C.uint64_t(newOptimized(uint64(capacity), float64(fprate)))
+}
+
+//export blobloom_free
+func blobloom_free(f C.uint64_t) { freeHandle(uint64(f)) }
+
+//export blobloom_add
+func blobloom_add(f C.uint64_t, hash C.uint64_t) C.int {
+	filter := lookup(uint64(f))
+	if filter == nil {
+		return -1
+	}
+	filter.Add(uint64(hash))
+	return 0
+}
+
+//export blobloom_has
+func blobloom_has(f C.uint64_t, hash C.uint64_t) C.int {
+	filter := lookup(uint64(f))
+	if filter == nil {
+		return -1
+	}
+	if filter.Has(uint64(hash)) {
+		return 1
+	}
+	return 0
+}
+
+//export blobloom_hash_key
+func blobloom_hash_key(key unsafe.Pointer, length C.size_t) C.uint64_t {
+	p, ok := goBytes(key, length)
+	if !ok {
+		return 0
+	}
+	return C.uint64_t(keyhash.Sum64(p))
+}
+
+//export blobloom_num_bits
+func blobloom_num_bits(f C.uint64_t) C.uint64_t {
+	filter := lookup(uint64(f))
+	if filter == nil {
+		return 0
+	}
+	return C.uint64_t(filter.NumBits())
+}
+
+//export blobloom_dump
+func blobloom_dump(f C.uint64_t, comment *C.char, length *C.size_t) unsafe.Pointer {
+	var c string
+	if comment != nil {
+		c = C.GoString(comment)
+	}
+	p := dump(uint64(f), c)
+	if p == nil {
+		return nil
+	}
+	*length = C.size_t(len(p))
+	return C.CBytes(p)
+}
+
+//export blobloom_free_buffer
+func blobloom_free_buffer(p unsafe.Pointer) { C.free(p) }
+
+//export blobloom_load
+func blobloom_load(p unsafe.Pointer, length C.size_t) C.uint64_t {
+	buf, ok := goBytes(p, length)
+	if !ok {
+		return 0
+	}
+	return C.uint64_t(load(buf))
+}
+
+// goBytes copies n bytes at p to a Go slice. It reports false if n does not
+// fit in the C.int that C.GoBytes takes, instead of truncating the buffer.
+func goBytes(p unsafe.Pointer, n C.size_t) ([]byte, bool) {
+	if uint64(n) > math.MaxInt32 {
+		return nil, false
+	}
+	return C.GoBytes(p, C.int(n)), true
+}
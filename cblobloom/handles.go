@@ -0,0 +1,92 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/greatroar/blobloom"
+)
+
+// Filters are passed to C as integer handles, since C code may not
+// hold Go pointers. Zero is never a valid handle.
+var handles = struct {
+	sync.RWMutex
+	m    map[uint64]*blobloom.SyncFilter
+	next uint64
+}{m: make(map[uint64]*blobloom.SyncFilter)}
+
+func newHandle(f *blobloom.SyncFilter) uint64 {
+	handles.Lock()
+	defer handles.Unlock()
+
+	handles.next++
+	handles.m[handles.next] = f
+	return handles.next
+}
+
+func lookup(h uint64) *blobloom.SyncFilter {
+	handles.RLock()
+	defer handles.RUnlock()
+	return handles.m[h]
+}
+
+func freeHandle(h uint64) {
+	handles.Lock()
+	defer handles.Unlock()
+	delete(handles.m, h)
+}
+
+func newOptimized(capacity uint64, fpRate float64) uint64 {
+	if !(fpRate > 0 && fpRate <= 1) {
+		return 0
+	}
+	return newHandle(blobloom.NewSyncOptimized(blobloom.Config{
+		Capacity: capacity,
+		FPRate:   fpRate,
+	}))
+}
+
+func newFilter(nbits uint64, nhashes int) uint64 {
+	if nbits > blobloom.MaxBits {
+		return 0
+	}
+	return newHandle(blobloom.NewSync(nbits, nhashes))
+}
+
+func dump(h uint64, comment string) []byte {
+	f := lookup(h)
+	if f == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if _, err := blobloom.DumpSync(&buf, f, comment); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+func load(p []byte) uint64 {
+	l, err := blobloom.NewLoader(bytes.NewReader(p))
+	if err != nil {
+		return 0
+	}
+	f, err := l.LoadSync(nil)
+	if err != nil {
+		return 0
+	}
+	return newHandle(f)
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandles(t *testing.T) {
+	h := newOptimized(100, .01)
+	assert.NotZero(t, h)
+	lookup(h).Add(42)
+
+	p := dump(h, "comment")
+	assert.NotNil(t, p)
+
+	g := load(p)
+	assert.NotZero(t, g)
+	assert.NotEqual(t, h, g)
+	assert.True(t, lookup(g).Has(42))
+	assert.True(t, lookup(h).Equals(lookup(g)))
+
+	freeHandle(h)
+	assert.Nil(t, lookup(h))
+	assert.Nil(t, dump(h, ""))
+
+	assert.Zero(t, newOptimized(100, 0))
+	assert.Zero(t, load(p[:len(p)-1]))
+	assert.NotZero(t, newFilter(1000, 3))
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NewChecked is like New, but returns an error instead of panicking
+// or silently adjusting its arguments when nbits is zero or exceeds
+// MaxBits, or when nhashes is less than one.
+//
+// NewChecked still rounds nbits up to a multiple of BlockBits and
+// increases nhashes to two if it is one, as New does.
+func NewChecked(nbits uint64, nhashes int) (*Filter, error) {
+	if err := checkParams(nbits, nhashes); err != nil {
+		return nil, err
+	}
+	return New(nbits, nhashes), nil
+}
+
+// NewSyncChecked is like NewSync, but checks its arguments as
+// NewChecked does.
+func NewSyncChecked(nbits uint64, nhashes int) (*SyncFilter, error) {
+	if err := checkParams(nbits, nhashes); err != nil {
+		return nil, err
+	}
+	return NewSync(nbits, nhashes), nil
+}
+
+// OptimizeChecked is like Optimize, but returns an error instead of
+// panicking when config.FPRate is invalid.
+func OptimizeChecked(config Config) (nbits uint64, nhashes int, err error) {
+	if !validFPRate(config.FPRate) {
+		return 0, 0, fmt.Errorf("blobloom: false positive rate %g not in (0, 1]", config.FPRate)
+	}
+	nbits, nhashes = Optimize(config)
+	return nbits, nhashes, nil
+}
+
+func checkParams(nbits uint64, nhashes int) error {
+	switch {
+	case nbits == 0:
+		return errors.New("blobloom: number of bits is zero")
+	case nbits > MaxBits:
+		return fmt.Errorf("blobloom: %d bits exceeds MaxBits", nbits)
+	case nhashes < 1:
+		return fmt.Errorf("blobloom: invalid number of hashes %d", nhashes)
+	}
+	return nil
+}
+
+// validFPRate reports whether p is in (0, 1]. It is false for NaN.
+func validFPRate(p float64) bool { return p > 0 && p <= 1 }
@@ -0,0 +1,73 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChecked(t *testing.T) {
+	f, err := NewChecked(1000, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2*BlockBits, f.NumBits())
+	assert.Equal(t, 2, f.NumHashes())
+
+	s, err := NewSyncChecked(BlockBits, 7)
+	require.NoError(t, err)
+	assert.EqualValues(t, BlockBits, s.NumBits())
+
+	for _, c := range []struct {
+		nbits   uint64
+		nhashes int
+	}{
+		{0, 5},
+		{MaxBits + 1, 5},
+		{math.MaxUint64, 5},
+		{1000, 0},
+		{1000, -1},
+	} {
+		f, err := NewChecked(c.nbits, c.nhashes)
+		assert.Error(t, err)
+		assert.Nil(t, f)
+
+		s, err := NewSyncChecked(c.nbits, c.nhashes)
+		assert.Error(t, err)
+		assert.Nil(t, s)
+	}
+}
+
+func TestOptimizeChecked(t *testing.T) {
+	config := Config{Capacity: 1e6, FPRate: 1e-4}
+	nbits, nhashes, err := OptimizeChecked(config)
+	require.NoError(t, err)
+	wantBits, wantHashes := Optimize(config)
+	assert.Equal(t, wantBits, nbits)
+	assert.Equal(t, wantHashes, nhashes)
+
+	for _, p := range []float64{0, -1, 1.5, math.NaN(), math.Inf(1)} {
+		_, _, err := OptimizeChecked(Config{Capacity: 100, FPRate: p})
+		assert.Error(t, err, "%g", p)
+		assert.Panics(t, func() { Optimize(Config{Capacity: 100, FPRate: p}) })
+	}
+
+	// A capacity that overflows the number of bits is capped at MaxBits.
+	nbits, _, err = OptimizeChecked(Config{Capacity: math.MaxUint64, FPRate: 1e-3})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(MaxBits), nbits)
+}
@@ -0,0 +1,199 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package checkpoint saves generation-numbered snapshots of a filter and
+// rolls a live filter back to one of them.
+//
+// Checkpoints allow undoing an ingest bug that poisoned a filter with
+// keys that should not be there, without rebuilding the filter from
+// the source data: roll back to the last good generation, then replay
+// the ingest from that point.
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/internal/atomicfile"
+)
+
+// A Checkpoint describes a saved snapshot.
+type Checkpoint struct {
+	Generation uint64
+	Tag        string
+}
+
+// A Checkpointer saves and restores snapshots of the filter of a Handle.
+//
+// A Checkpointer is safe for concurrent use by multiple goroutines,
+// but the Storage should not be shared with other Checkpointers.
+type Checkpointer struct {
+	mu sync.Mutex // Serializes Save and Prune.
+	h  *blobloom.Handle
+	st Storage
+}
+
+// New returns a Checkpointer for the filter of h, storing checkpoints in st.
+func New(h *blobloom.Handle, st Storage) *Checkpointer {
+	return &Checkpointer{h: h, st: st}
+}
+
+const (
+	namePrefix = "gen-"
+	maxTagLen  = 44 // Maximum length of a blobloom dump comment.
+)
+
+func name(gen uint64) string { return fmt.Sprintf("%s%020d", namePrefix, gen) }
+
+func parseName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, namePrefix) {
+		return 0, false
+	}
+	gen, err := strconv.ParseUint(name[len(namePrefix):], 10, 64)
+	return gen, err == nil
+}
+
+// Save saves a snapshot of the current filter with the given tag and
+// returns its Checkpoint. The generation is one higher than that of the
+// latest checkpoint, or one if there are none, even after a Rollback.
+//
+// The tag is stored as the comment of a blobloom dump, so it must be at
+// most 44 bytes and not contain zero bytes. Keys added concurrently with
+// Save may or may not be in the snapshot.
+func (c *Checkpointer) Save(tag string) (cp Checkpoint, err error) {
+	// Check the tag before creating a file that Dump would leave empty.
+	if len(tag) > maxTagLen || strings.IndexByte(tag, 0) != -1 {
+		return cp, fmt.Errorf("checkpoint: invalid tag %q", tag)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	gens, err := c.generations()
+	if err != nil {
+		return cp, err
+	}
+	cp = Checkpoint{Generation: 1, Tag: tag}
+	if len(gens) > 0 {
+		cp.Generation = gens[len(gens)-1] + 1
+	}
+
+	w, err := c.st.Create(name(cp.Generation))
+	if err != nil {
+		return cp, err
+	}
+	_, err = blobloom.DumpSync(w, c.h.Filter(), tag)
+	return cp, atomicfile.Finish(w, err)
+}
+
+// List returns the checkpoints in storage, oldest first.
+func (c *Checkpointer) List() ([]Checkpoint, error) {
+	gens, err := c.generations()
+	if err != nil {
+		return nil, err
+	}
+
+	cps := make([]Checkpoint, 0, len(gens))
+	for _, gen := range gens {
+		r, err := c.st.Open(name(gen))
+		if err != nil {
+			return nil, err
+		}
+		l, err := blobloom.NewLoader(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("checkpoint: generation %d: %w", gen, err)
+		}
+		cps = append(cps, Checkpoint{Generation: gen, Tag: l.Comment})
+	}
+	return cps, nil
+}
+
+// Load loads the filter of the given generation, without changing
+// the current filter.
+func (c *Checkpointer) Load(gen uint64) (*blobloom.SyncFilter, Checkpoint, error) {
+	cp := Checkpoint{Generation: gen}
+	r, err := c.st.Open(name(gen))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, cp, fmt.Errorf("checkpoint: no generation %d", gen)
+	} else if err != nil {
+		return nil, cp, err
+	}
+	defer r.Close()
+
+	l, err := blobloom.NewLoader(r)
+	if err != nil {
+		return nil, cp, err
+	}
+	cp.Tag = l.Comment
+	f, err := l.LoadSync(nil)
+	return f, cp, err
+}
+
+// Rollback replaces the current filter by the one of the given generation.
+// Checkpoints of later generations are kept, so a Rollback can itself be
+// undone.
+func (c *Checkpointer) Rollback(gen uint64) error {
+	f, _, err := c.Load(gen)
+	if err != nil {
+		return err
+	}
+	c.h.Replace(f)
+	return nil
+}
+
+// Prune removes all but the latest keep checkpoints.
+// It returns an error if keep is negative.
+func (c *Checkpointer) Prune(keep int) error {
+	if keep < 0 {
+		return fmt.Errorf("checkpoint: negative number of checkpoints to keep %d", keep)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	gens, err := c.generations()
+	if err != nil || len(gens) <= keep {
+		return err
+	}
+	for _, gen := range gens[:len(gens)-keep] {
+		err := c.st.Remove(name(gen))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// generations returns the generations in storage, in increasing order.
+func (c *Checkpointer) generations() ([]uint64, error) {
+	names, err := c.st.List()
+	if err != nil {
+		return nil, err
+	}
+	var gens []uint64
+	for _, name := range names {
+		if gen, ok := parseName(name); ok {
+			gens = append(gens, gen)
+		}
+	}
+	sort.Slice(gens, func(i, j int) bool { return gens[i] < gens[j] })
+	return gens, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollback(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	h := blobloom.NewHandle(blobloom.NewSync(1<<12, 4))
+	c := New(h, Dir(tmp))
+
+	h.Add(1)
+	cp, err := c.Save("good")
+	require.NoError(t, err)
+	assert.Equal(t, Checkpoint{1, "good"}, cp)
+
+	h.Add(2) // Poison.
+	cp, err = c.Save("bad")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), cp.Generation)
+
+	cps, err := c.List()
+	require.NoError(t, err)
+	assert.Equal(t, []Checkpoint{{1, "good"}, {2, "bad"}}, cps)
+
+	require.NoError(t, c.Rollback(1))
+	assert.True(t, h.Has(1))
+	assert.False(t, h.Has(2))
+
+	// Saving after a rollback continues the generation numbers.
+	cp, err = c.Save("")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), cp.Generation)
+
+	require.NoError(t, c.Rollback(2))
+	assert.True(t, h.Has(2))
+
+	assert.Error(t, c.Rollback(4))
+
+	assert.Error(t, c.Prune(-1))
+	cps, err = c.List()
+	require.NoError(t, err)
+	assert.Len(t, cps, 3)
+
+	require.NoError(t, c.Prune(1))
+	cps, err = c.List()
+	require.NoError(t, err)
+	assert.Equal(t, []Checkpoint{{3, ""}}, cps)
+
+	f, cp, err := c.Load(3)
+	require.NoError(t, err)
+	assert.Equal(t, Checkpoint{3, ""}, cp)
+	assert.True(t, f.Has(1))
+	assert.False(t, f.Has(2))
+}
+
+func TestSaveLongTag(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	c := New(blobloom.NewHandle(blobloom.NewSync(1<<12, 4)), Dir(tmp))
+	_, err = c.Save(string(make([]byte, 45)))
+	assert.Error(t, err)
+	_, err = c.Save("a\x00b")
+	assert.Error(t, err)
+
+	cps, err := c.List()
+	require.NoError(t, err)
+	assert.Empty(t, cps)
+}
+
+func TestRollbackSeeded(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	h := blobloom.NewHandle(blobloom.NewSyncOptimized(blobloom.Config{
+		Capacity: 1000, FPRate: 1e-4, Seed: 0xc4ec, Probing: blobloom.TripleHashing,
+	}))
+	c := New(h, Dir(tmp))
+
+	for i := uint64(0); i < 1000; i++ {
+		h.Add(i * 0x9e3779b97f4a7c15)
+	}
+	_, err = c.Save("")
+	require.NoError(t, err)
+
+	require.NoError(t, c.Rollback(1))
+	f := h.Filter()
+	assert.EqualValues(t, 0xc4ec, f.Seed())
+	assert.Equal(t, blobloom.TripleHashing, f.Probing())
+	for i := uint64(0); i < 1000; i++ {
+		assert.True(t, h.Has(i*0x9e3779b97f4a7c15))
+	}
+
+	f, _, err = c.Load(1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0xc4ec, f.Seed())
+}
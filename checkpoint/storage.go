@@ -0,0 +1,34 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoint
+
+import (
+	"errors"
+
+	"github.com/greatroar/blobloom/internal/dirstore"
+)
+
+// A Storage persists checkpoints by name. It opens, creates, lists and
+// removes them; Create must replace a checkpoint atomically when its
+// writer is closed.
+type Storage = dirstore.ListStorage
+
+// Dir returns a Storage that stores each checkpoint in a file in the
+// directory path, named after the checkpoint with the suffix ".blobloom".
+func Dir(path string) Storage {
+	return dirstore.Dir{Path: path, Suffix: ".blobloom", ErrInvalidName: errInvalidName}
+}
+
+var errInvalidName = errors.New("checkpoint: invalid checkpoint name")
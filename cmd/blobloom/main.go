@@ -0,0 +1,356 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command blobloom builds, queries, inspects and merges Bloom filter dumps,
+// in the format written by blobloom.Dump.
+//
+// Usage:
+//
+//	blobloom build [-n capacity] [-p fprate] [-raw] [-c comment] output [input]
+//	blobloom query [-raw] dump [input]
+//	blobloom stats dump...
+//	blobloom merge [-c comment] output input...
+//	blobloom fold [-f factor] [-c comment] output input
+//	blobloom compress output input
+//
+// Build and query read one key per line from input, or from standard input
+// if input is omitted. Keys are 64-bit hash values in hexadecimal, or, with
+// -raw, arbitrary strings, which are hashed with the same function as the
+// servers in this module use. Query prints each key followed by "true" if
+// the filter may have it and "false" if it certainly does not.
+//
+// Build sizes the filter for the number of keys in its input, or for
+// capacity if that is larger. Merge writes the union of its inputs, which
+// must all have the same numbers of bits and hashes. Fold divides the size
+// of its input by factor, which must divide its number of blocks, for
+// shipping a smaller approximation of a large filter: the output reports
+// all keys of the input, at a higher false positive rate.
+//
+// Compress writes its input as a blobloom.CompressedFilter, which is smaller
+// than a dump for sparse filters but has no comment. Query, stats, fold and
+// compress accept compressed filters as input.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/internal/atomicfile"
+	"github.com/greatroar/blobloom/internal/keyhash"
+)
+
+const usage = `usage: blobloom command [flags] args...
+
+commands:
+	build [-n capacity] [-p fprate] [-raw] [-c comment] output [input]
+	query [-raw] dump [input]
+	stats dump...
+	merge [-c comment] output input...
+	fold [-f factor] [-c comment] output input
+	compress output input`
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var (
+		cmd   = os.Args[1]
+		flags = flag.NewFlagSet(cmd, flag.ExitOnError)
+		err   error
+	)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	switch cmd {
+	case "build":
+		capacity := flags.Uint64("n", 0, "`capacity` of the filter")
+		fpr := flags.Float64("p", .01, "false positive `rate` at capacity")
+		raw := flags.Bool("raw", false, "input contains keys, not hashes")
+		comment := flags.String("c", "", "`comment` for the output dump")
+		args := parseArgs(flags, 1, 2)
+		err = build(args[0], inputArg(args, 1), *capacity, *fpr, *raw, *comment)
+
+	case "query":
+		raw := flags.Bool("raw", false, "input contains keys, not hashes")
+		args := parseArgs(flags, 1, 2)
+		err = query(args[0], inputArg(args, 1), *raw)
+
+	case "stats":
+		args := parseArgs(flags, 1, -1)
+		for _, name := range args {
+			if err = stats(name, len(args) > 1); err != nil {
+				break
+			}
+		}
+
+	case "merge":
+		comment := flags.String("c", "", "`comment` for the output dump")
+		args := parseArgs(flags, 2, -1)
+		err = merge(args[0], args[1:], *comment)
+
+	case "fold":
+		factor := flags.Uint64("f", 2, "`factor` to divide the size by")
+		comment := flags.String("c", "", "`comment` for the output dump, instead of the input's")
+		args := parseArgs(flags, 2, 2)
+		err = fold(args[0], args[1], *factor, *comment)
+
+	case "compress":
+		args := parseArgs(flags, 2, 2)
+		err = compress(args[0], args[1])
+
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseArgs parses the arguments to a command and checks that there are
+// at least min and at most max of them. A negative max means no limit.
+func parseArgs(flags *flag.FlagSet, min, max int) []string {
+	flags.Parse(os.Args[2:])
+	if n := flags.NArg(); n < min || max >= 0 && n > max {
+		flags.Usage()
+		os.Exit(2)
+	}
+	return flags.Args()
+}
+
+func inputArg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+func build(output, input string, capacity uint64, fpr float64, raw bool, comment string) error {
+	var hashes []uint64
+	err := readKeys(input, raw, func(_ string, h uint64) {
+		hashes = append(hashes, h)
+	})
+	if err != nil {
+		return err
+	}
+
+	if n := uint64(len(hashes)); n > capacity {
+		capacity = n
+	}
+	nbits, nhashes, err := blobloom.OptimizeChecked(blobloom.Config{
+		Capacity: capacity,
+		FPRate:   fpr,
+	})
+	if err != nil {
+		return err
+	}
+
+	f := blobloom.New(nbits, nhashes)
+	f.AddMany(hashes)
+
+	return writeAtomic(output, func(w io.Writer) error {
+		_, err := blobloom.Dump(w, f, comment)
+		return err
+	})
+}
+
+func query(dump, input string, raw bool) error {
+	f, _, err := load(dump)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	err = readKeys(input, raw, func(key string, h uint64) {
+		fmt.Fprintf(w, "%s\t%t\n", key, f.Has(h))
+	})
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func stats(name string, printName bool) error {
+	f, comment, err := load(name)
+	if err != nil {
+		return err
+	}
+
+	if printName {
+		fmt.Printf("%s:\n", name)
+	}
+	fmt.Printf("bits            %d\n", f.NumBits())
+	fmt.Printf("hashes          %d\n", f.NumHashes())
+	fmt.Printf("comment         %q\n", comment)
+	fmt.Printf("fill ratio      %.4f\n", f.FillRatio())
+	if nkeys := f.Cardinality(); !math.IsInf(nkeys, 0) {
+		fmt.Printf("estimated keys  %.0f\n", nkeys)
+	} else {
+		fmt.Printf("estimated keys  too many to estimate\n")
+	}
+	fmt.Printf("estimated FPR   %.4g\n", f.CurrentFPRate())
+	return nil
+}
+
+func merge(output string, inputs []string, comment string) error {
+	rs := make([]io.Reader, len(inputs))
+	for i, name := range inputs {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		rs[i] = bufio.NewReaderSize(f, 64<<10)
+	}
+
+	return writeAtomic(output, func(w io.Writer) error {
+		_, err := blobloom.UnionDumps(w, comment, rs...)
+		return err
+	})
+}
+
+func fold(output, input string, factor uint64, comment string) error {
+	f, inComment, err := load(input)
+	if err != nil {
+		return err
+	}
+	nblocks := f.NumBits() / blobloom.BlockBits
+	if factor == 0 || nblocks%factor != 0 {
+		return fmt.Errorf("%s: factor %d does not divide %d blocks", input, factor, nblocks)
+	}
+	if comment == "" {
+		comment = inComment
+	}
+
+	g := f.Fold(f.NumBits() / factor)
+	return writeAtomic(output, func(w io.Writer) error {
+		_, err := blobloom.Dump(w, g, comment)
+		return err
+	})
+}
+
+func compress(output, input string) error {
+	f, _, err := load(input)
+	if err != nil {
+		return err
+	}
+	p, err := f.Compress().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return writeAtomic(output, func(w io.Writer) error {
+		_, err := w.Write(p)
+		return err
+	})
+}
+
+// load loads a dump or a compressed filter.
+func load(name string) (f *blobloom.Filter, comment string, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	r := bufio.NewReaderSize(file, 64<<10)
+	if magic, _ := r.Peek(10); string(magic) == "blobloomEF" {
+		p, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, "", err
+		}
+		var c blobloom.CompressedFilter
+		if err := c.UnmarshalBinary(p); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", name, err)
+		}
+		return c.Decompress(), "", nil
+	}
+
+	l, err := blobloom.NewLoader(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", name, err)
+	}
+	f, err = l.Load(nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", name, err)
+	}
+	return f, l.Comment, nil
+}
+
+// readKeys calls fn for each non-empty line in the named file,
+// or in standard input if name is empty.
+func readKeys(name string, raw bool, fn func(key string, h uint64)) error {
+	r := os.Stdin
+	if name == "" {
+		name = "stdin"
+	} else {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	sc := bufio.NewScanner(r)
+	for lineno := 1; sc.Scan(); lineno++ {
+		key := sc.Text()
+		if key == "" {
+			continue
+		}
+
+		var h uint64
+		if raw {
+			h = keyhash.Sum64(sc.Bytes())
+		} else {
+			var err error
+			h, err = strconv.ParseUint(key, 16, 64)
+			if err != nil {
+				return fmt.Errorf("%s:%d: invalid hash %q", name, lineno, key)
+			}
+		}
+		fn(key, h)
+	}
+	return sc.Err()
+}
+
+// writeAtomic calls write to fill a temporary file,
+// then renames that to output, so that no partial output is left behind.
+func writeAtomic(output string, write func(io.Writer) error) error {
+	f, err := atomicfile.Create(output)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriterSize(f, 64<<10)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	return atomicfile.Finish(f, err)
+}
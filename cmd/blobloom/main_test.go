@@ -0,0 +1,109 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildQueryMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobloom")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	writeKeys := func(name string, from, to uint64) {
+		var b strings.Builder
+		for h := from; h < to; h++ {
+			fmt.Fprintf(&b, "%x\n", h*0x9e3779b97f4a7c15)
+		}
+		require.NoError(t, ioutil.WriteFile(path(name), []byte(b.String()), 0644))
+	}
+	writeKeys("a.txt", 0, 100)
+	writeKeys("b.txt", 100, 200)
+
+	require.NoError(t, build(path("a"), path("a.txt"), 1000, .01, false, "a"))
+	require.NoError(t, build(path("b"), path("b.txt"), 1000, .01, false, "b"))
+	require.NoError(t, merge(path("ab"), []string{path("a"), path("b")}, "ab"))
+
+	f, comment, err := load(path("ab"))
+	require.NoError(t, err)
+	assert.Equal(t, "ab", comment)
+	for h := uint64(0); h < 200; h++ {
+		assert.True(t, f.Has(h*0x9e3779b97f4a7c15))
+	}
+
+	// Query writes to standard output.
+	out, err := os.Create(path("out"))
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = out
+	err = query(path("ab"), path("b.txt"), false)
+	os.Stdout = stdout
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	p, err := ioutil.ReadFile(path("out"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(p), "\n"), "\n")
+	assert.Len(t, lines, 100)
+	for _, line := range lines {
+		assert.True(t, strings.HasSuffix(line, "\ttrue"), line)
+	}
+
+	// Merging filters of different sizes fails and leaves no output.
+	require.NoError(t, build(path("c"), path("a.txt"), 1e5, .01, false, ""))
+	assert.Error(t, merge(path("ac"), []string{path("a"), path("c")}, ""))
+	_, err = os.Stat(path("ac"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWriteAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobloom")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "output")
+
+	require.NoError(t, writeAtomic(output, func(w io.Writer) error {
+		_, err := io.WriteString(w, "old")
+		return err
+	}))
+
+	errWrite := errors.New("write failed")
+	err = writeAtomic(output, func(w io.Writer) error {
+		io.WriteString(w, "new")
+		return errWrite
+	})
+	assert.Equal(t, errWrite, err)
+
+	p, err := ioutil.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(p))
+
+	// No temporary files are left behind.
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
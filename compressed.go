@@ -0,0 +1,369 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sync/atomic"
+)
+
+// A CompressedFilter is an immutable, compressed representation of a Filter.
+// It is constructed by Filter.Compress and supports Has without being
+// decompressed.
+//
+// A CompressedFilter stores the positions of the bits set in the filter
+// in the Elias-Fano encoding, which takes about 2+log2(NumBits/n) bits for
+// each of the n bits set. This is smaller than the filter itself when the
+// filter is sparse, i.e., when fewer than about a tenth of its bits are
+// set, as is the case for a filter built for a much larger number of keys
+// than it holds or for a low false positive rate with many hashes.
+// Compressed filters are meant for distributing many filters to clients
+// with little memory. Has takes a few times longer than on a Filter.
+//
+// A CompressedFilter is safe for concurrent use.
+type CompressedFilter struct {
+	nbits   uint64
+	k       int
+	seed    uint64 // See Filter.SetSeed.
+	probing Probing
+	n       uint64 // Number of bits set.
+	lbits   uint   // Width of the low parts of positions.
+
+	low   []uint64 // Low parts, packed.
+	high  []uint64 // High parts, in unary.
+	zeros []uint64 // Position in high of zero number zeroSample*i.
+}
+
+// Sampling rate for select0 on the high parts.
+const zeroSample = 256
+
+// Compress returns a compressed copy of f.
+func (f *Filter) Compress() *CompressedFilter {
+	c := compress(f.b, f.k, onescount)
+	c.seed, c.probing = f.probeSeed, f.probing
+	return c
+}
+
+// Compress returns a compressed copy of f.
+//
+// If other goroutines are simultaneously modifying f,
+// their modifications may not be reflected in the result.
+func (f *SyncFilter) Compress() *CompressedFilter {
+	c := compress(f.b, f.k, onescountAtomic)
+	c.seed, c.probing = f.probeSeed, f.probing
+	return c
+}
+
+func compress(b []block, k int, onescount func(*block) int) *CompressedFilter {
+	c := &CompressedFilter{nbits: BlockBits * uint64(len(b)), k: k}
+	for i := range b {
+		c.n += uint64(onescount(&b[i]))
+	}
+	c.init()
+
+	var i uint64
+	for blk := range b {
+		for j := 0; j < blockWords; j++ {
+			w := atomic.LoadUint32(&b[blk][j])
+			base := uint64(blk)*BlockBits + uint64(j)*wordSize
+			for ; w != 0; w &= w - 1 {
+				c.append(i, base+uint64(bits.TrailingZeros32(w)))
+				i++
+			}
+		}
+	}
+	c.sampleZeros()
+	return c
+}
+
+// init sets the width of the low parts and allocates c.low and c.high,
+// given c.nbits and c.n.
+func (c *CompressedFilter) init() {
+	c.lbits = 0
+	if c.n > 0 && c.nbits/c.n > 1 {
+		c.lbits = uint(bits.Len64(c.nbits/c.n) - 1)
+	}
+	c.low = make([]uint64, (c.n*uint64(c.lbits)+63)/64)
+	c.high = make([]uint64, (c.highLen()+63)/64)
+}
+
+// highLen returns the number of bits in the high parts.
+func (c *CompressedFilter) highLen() uint64 {
+	return c.n + c.nbits>>c.lbits + 1
+}
+
+// append stores position x as element i, which must be the largest so far.
+func (c *CompressedFilter) append(i, x uint64) {
+	if c.lbits > 0 {
+		v := x & (1<<c.lbits - 1)
+		pos := i * uint64(c.lbits)
+		w, off := pos/64, pos%64
+		c.low[w] |= v << off
+		if off+uint64(c.lbits) > 64 {
+			c.low[w+1] |= v >> (64 - off)
+		}
+	}
+	p := x>>c.lbits + i
+	c.high[p/64] |= 1 << (p % 64)
+}
+
+func (c *CompressedFilter) sampleZeros() {
+	c.zeros = c.zeros[:0]
+	var nzeros uint64
+	for p := uint64(0); p < c.highLen(); p++ {
+		if c.high[p/64]&(1<<(p%64)) == 0 {
+			if nzeros%zeroSample == 0 {
+				c.zeros = append(c.zeros, p)
+			}
+			nzeros++
+		}
+	}
+}
+
+// lowAt returns the low part of element i.
+func (c *CompressedFilter) lowAt(i uint64) uint64 {
+	if c.lbits == 0 {
+		return 0
+	}
+	pos := i * uint64(c.lbits)
+	w, off := pos/64, pos%64
+	v := c.low[w] >> off
+	if off+uint64(c.lbits) > 64 {
+		v |= c.low[w+1] << (64 - off)
+	}
+	return v & (1<<c.lbits - 1)
+}
+
+// select0 returns the position of zero number j in c.high.
+func (c *CompressedFilter) select0(j uint64) uint64 {
+	pos := c.zeros[j/zeroSample]
+	j %= zeroSample
+
+	w := pos / 64
+	x := ^c.high[w] &^ (1<<(pos%64) - 1)
+	for {
+		n := uint64(bits.OnesCount64(x))
+		if j < n {
+			break
+		}
+		j -= n
+		w++
+		x = ^c.high[w]
+	}
+	for ; j > 0; j-- {
+		x &= x - 1
+	}
+	return w*64 + uint64(bits.TrailingZeros64(x))
+}
+
+// contains reports whether bit x is set.
+func (c *CompressedFilter) contains(x uint64) bool {
+	h := x >> c.lbits
+	var p uint64 // Position in c.high of the first element with high part h.
+	if h > 0 {
+		p = c.select0(h-1) + 1
+	}
+	target := x & (1<<c.lbits - 1)
+
+	for i := p - h; c.high[p/64]&(1<<(p%64)) != 0; i++ {
+		switch v := c.lowAt(i); {
+		case v == target:
+			return true
+		case v > target:
+			return false
+		}
+		p++
+	}
+	return false
+}
+
+// Has reports whether a key with hash value h has been added.
+// It may return a false positive.
+func (c *CompressedFilter) Has(h uint64) bool {
+	h = mix(h, c.seed)
+	h1, h2 := uint32(h>>32), uint32(h)
+	base := uint64(reducerange(h2, uint32(c.nbits/BlockBits))) * BlockBits
+
+	h3 := thirdhash(c.probing, h1)
+	for i := 1; i < c.k; i++ {
+		h1, h2 = probehash(h1, h2, h3, i)
+		if !c.contains(base + uint64(h1%BlockBits)) {
+			return false
+		}
+	}
+	return true
+}
+
+// NumBits returns the number of bits of the filter that c represents.
+func (c *CompressedFilter) NumBits() uint64 { return c.nbits }
+
+// NumHashes returns the number of hash functions of c.
+func (c *CompressedFilter) NumHashes() int { return c.k }
+
+// Seed returns the seed of the filter that c was constructed from.
+func (c *CompressedFilter) Seed() uint64 { return c.seed }
+
+// Probing returns the probing scheme of the filter that c was
+// constructed from.
+func (c *CompressedFilter) Probing() Probing { return c.probing }
+
+// Size returns the size of c's encoding by MarshalBinary, in bytes.
+func (c *CompressedFilter) Size() int {
+	return c.headerSize() + 8*(len(c.low)+len(c.high))
+}
+
+// Decompress returns a Filter equal to the one that c was constructed from.
+func (c *CompressedFilter) Decompress() *Filter {
+	f := New(c.nbits, c.k)
+	f.probeSeed, f.probing = c.seed, c.probing
+	var i uint64
+	for p := uint64(0); i < c.n; p++ {
+		if c.high[p/64]&(1<<(p%64)) == 0 {
+			continue
+		}
+		x := (p-i)<<c.lbits | c.lowAt(i)
+		f.b[x/BlockBits].setbit(uint32(x % BlockBits))
+		i++
+	}
+	return f
+}
+
+const (
+	compressedMagic      = "blobloomEF"
+	compressedHeaderSize = 32
+	// Size of the header of version 1, which adds the seed and probing.
+	compressedHeaderSizeV1 = compressedHeaderSize + 16
+)
+
+// version returns the version of c's encoding.
+func (c *CompressedFilter) version() uint16 {
+	if c.seed != 0 || c.probing != DoubleHashing {
+		return 1
+	}
+	return 0
+}
+
+func (c *CompressedFilter) headerSize() int {
+	if c.version() > 0 {
+		return compressedHeaderSizeV1
+	}
+	return compressedHeaderSize
+}
+
+// MarshalBinary encodes c. The encoding starts with a 32-byte header:
+//   - the string "blobloomEF", in ASCII;
+//   - a two-byte version number;
+//   - the number of bits, as a 64-bit integer;
+//   - the number of hashes, as a 32-bit integer;
+//   - the number of bits set, as a 64-bit integer.
+//
+// The version is zero for filters with the default seed and probing
+// scheme. Otherwise, it is one and the header is followed by the seed,
+// as a 64-bit integer, the probing scheme, as a 32-bit integer,
+// and four zero bytes.
+//
+// After the header come the low parts and the high parts of the Elias-Fano
+// encoding, as 64-bit words. All integers are little-endian.
+func (c *CompressedFilter) MarshalBinary() ([]byte, error) {
+	hsize := c.headerSize()
+	p := make([]byte, hsize, c.Size())
+	copy(p, compressedMagic)
+	binary.LittleEndian.PutUint16(p[10:], c.version())
+	binary.LittleEndian.PutUint64(p[12:], c.nbits)
+	binary.LittleEndian.PutUint32(p[20:], uint32(c.k))
+	binary.LittleEndian.PutUint64(p[24:], c.n)
+	if hsize > compressedHeaderSize {
+		binary.LittleEndian.PutUint64(p[32:], c.seed)
+		binary.LittleEndian.PutUint32(p[40:], uint32(c.probing))
+	}
+
+	p = p[:hsize+8*len(c.low)+8*len(c.high)]
+	q := p[hsize:]
+	for _, w := range c.low {
+		binary.LittleEndian.PutUint64(q, w)
+		q = q[8:]
+	}
+	for _, w := range c.high {
+		binary.LittleEndian.PutUint64(q, w)
+		q = q[8:]
+	}
+	return p, nil
+}
+
+// UnmarshalBinary decodes a CompressedFilter encoded by MarshalBinary.
+func (c *CompressedFilter) UnmarshalBinary(p []byte) error {
+	if len(p) < compressedHeaderSize || string(p[:10]) != compressedMagic {
+		return errors.New("blobloom: not a compressed Bloom filter")
+	}
+
+	nc := CompressedFilter{
+		nbits: binary.LittleEndian.Uint64(p[12:]),
+		k:     int(binary.LittleEndian.Uint32(p[20:])),
+		n:     binary.LittleEndian.Uint64(p[24:]),
+	}
+	switch binary.LittleEndian.Uint16(p[10:]) {
+	case 0:
+	case 1:
+		if len(p) < compressedHeaderSizeV1 || binary.LittleEndian.Uint32(p[44:]) != 0 {
+			return errors.New("blobloom: corrupt compressed filter")
+		}
+		nc.seed = binary.LittleEndian.Uint64(p[32:])
+		switch probing := binary.LittleEndian.Uint32(p[40:]); Probing(probing) {
+		case DoubleHashing, TripleHashing:
+			nc.probing = Probing(probing)
+		default:
+			return errors.New("blobloom: unknown probing scheme in compressed filter")
+		}
+	default:
+		return errors.New("blobloom: unsupported compressed filter version")
+	}
+	switch {
+	case nc.nbits == 0 || nc.nbits%BlockBits != 0 || nc.nbits > MaxBits:
+		return errors.New("blobloom: invalid number of bits in compressed filter")
+	case nc.k < 2:
+		return errors.New("blobloom: invalid number of hashes in compressed filter")
+	case nc.n > nc.nbits:
+		return errors.New("blobloom: compressed filter has too many bits set")
+	}
+	nc.init()
+	if len(p) != nc.Size() {
+		return errors.New("blobloom: compressed filter has wrong length")
+	}
+
+	q := p[nc.headerSize():]
+	for i := range nc.low {
+		nc.low[i] = binary.LittleEndian.Uint64(q)
+		q = q[8:]
+	}
+	var ones int
+	for i := range nc.high {
+		nc.high[i] = binary.LittleEndian.Uint64(q)
+		ones += bits.OnesCount64(nc.high[i])
+		q = q[8:]
+	}
+
+	// Check the invariants that queries rely on: there are n elements,
+	// the high parts end in a zero and no bits are set after it.
+	last := nc.highLen() - 1
+	if nc.high[len(nc.high)-1]>>(last%64) != 0 || uint64(ones) != nc.n {
+		return errors.New("blobloom: corrupt compressed filter")
+	}
+
+	nc.sampleZeros()
+	*c = nc
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressed(t *testing.T) {
+	for _, c := range []struct {
+		nbits   uint64
+		nhashes int
+		nkeys   int
+	}{
+		{BlockBits, 2, 0},
+		{BlockBits, 3, 1},
+		{1 << 16, 5, 100},
+		{1 << 16, 8, 2000},
+		{1 << 20, 12, 3000},
+		{1 << 14, 4, 10000}, // Dense: larger than the filter.
+	} {
+		f := New(c.nbits, c.nhashes)
+		keys := randomU64(c.nkeys, int64(c.nbits)+int64(c.nkeys))
+		for _, h := range keys {
+			f.Add(h)
+		}
+
+		cf := f.Compress()
+		assert.Equal(t, f.NumBits(), cf.NumBits())
+		assert.Equal(t, f.NumHashes(), cf.NumHashes())
+		assert.True(t, f.Equals(cf.Decompress()))
+
+		for _, h := range keys {
+			assert.True(t, cf.Has(h))
+		}
+		for _, h := range randomU64(10000, 42) {
+			assert.Equal(t, f.Has(h), cf.Has(h))
+		}
+
+		p, err := cf.MarshalBinary()
+		require.NoError(t, err)
+		assert.Equal(t, cf.Size(), len(p))
+
+		var cg CompressedFilter
+		require.NoError(t, cg.UnmarshalBinary(p))
+		assert.Equal(t, cf, &cg)
+	}
+}
+
+func TestCompressedSeed(t *testing.T) {
+	f := NewSync(1<<16, 6)
+	f.SetSeed(0x5eed)
+	keys := randomU64(1000, 0x5eed)
+	for _, h := range keys {
+		f.Add(h)
+	}
+
+	cf := f.Compress()
+	assert.EqualValues(t, 0x5eed, cf.Seed())
+	p, err := cf.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, cf.Size(), len(p))
+
+	var cg CompressedFilter
+	require.NoError(t, cg.UnmarshalBinary(p))
+	assert.Equal(t, cf, &cg)
+
+	g := cg.Decompress()
+	assert.EqualValues(t, 0x5eed, g.Seed())
+	for _, h := range keys {
+		assert.True(t, cg.Has(h))
+		assert.True(t, g.Has(h))
+	}
+
+	// Filters without a seed keep the original encoding.
+	f.SetSeed(0)
+	p, err = f.Compress().MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0}, p[10:12])
+}
+
+func TestCompressedProbing(t *testing.T) {
+	f := New(1<<16, 6)
+	f.SetProbing(TripleHashing)
+	keys := randomU64(1000, 0x3)
+	for _, h := range keys {
+		f.Add(h)
+	}
+
+	cf := f.Compress()
+	assert.Equal(t, TripleHashing, cf.Probing())
+	for _, h := range randomU64(10000, 42) {
+		assert.Equal(t, f.Has(h), cf.Has(h))
+	}
+
+	p, err := cf.MarshalBinary()
+	require.NoError(t, err)
+	var cg CompressedFilter
+	require.NoError(t, cg.UnmarshalBinary(p))
+	assert.Equal(t, cf, &cg)
+	assert.True(t, f.Equals(cg.Decompress()))
+	for _, h := range keys {
+		assert.True(t, cg.Has(h))
+	}
+
+	p[40] = 2 // Unknown probing scheme.
+	assert.Error(t, cg.UnmarshalBinary(p))
+}
+
+func TestCompressedSize(t *testing.T) {
+	// A sparse filter: many hashes and an FPR well below the optimum.
+	f := NewSync(1<<20, 10)
+	for _, h := range randomU64(2000, 0xc0) {
+		f.Add(h)
+	}
+
+	cf := f.Compress()
+	assert.Less(t, cf.Size(), int(f.NumBits()/8)/2)
+}
+
+func TestCompressedUnmarshalCorrupt(t *testing.T) {
+	f := New(1<<12, 3)
+	for _, h := range randomU64(50, 0xbad) {
+		f.Add(h)
+	}
+	p, _ := f.Compress().MarshalBinary()
+
+	var cf CompressedFilter
+	assert.Error(t, cf.UnmarshalBinary(p[:len(p)-1]))
+	assert.Error(t, cf.UnmarshalBinary(p[:10]))
+
+	q := append([]byte(nil), p...)
+	q[len(q)-1] |= 0x80
+	assert.Error(t, cf.UnmarshalBinary(q))
+
+	q = append([]byte(nil), p...)
+	q[12] = 1 // Not a multiple of BlockBits.
+	assert.Error(t, cf.UnmarshalBinary(q))
+
+	q = append([]byte(nil), p...)
+	q[10] = 2 // Unknown version.
+	assert.Error(t, cf.UnmarshalBinary(q))
+}
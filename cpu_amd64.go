@@ -0,0 +1,47 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nounsafe
+// +build !nounsafe
+
+package blobloom
+
+// Implemented in cpu_amd64.s.
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx uint32)
+func xgetbv() (eax uint32)
+
+// Vector instruction sets supported by the CPU and the operating system.
+var hasAVX2, hasAVX512 = cpuVectorFeatures()
+
+func cpuVectorFeatures() (avx2, avx512 bool) {
+	maxLeaf, _, _ := cpuid(0, 0)
+	if maxLeaf < 7 {
+		return false, false
+	}
+	const osxsave = 1 << 27
+	if _, _, ecx := cpuid(1, 0); ecx&osxsave == 0 {
+		return false, false
+	}
+
+	// The OS must save the YMM registers, and for AVX-512,
+	// the opmask and ZMM registers.
+	xcr0 := xgetbv()
+	osAVX := xcr0&0x6 == 0x6
+	osAVX512 := osAVX && xcr0&0xe0 == 0xe0
+
+	_, ebx, _ := cpuid(7, 0)
+	avx2 = osAVX && ebx&(1<<5) != 0
+	avx512 = osAVX512 && ebx&(1<<16) != 0
+	return avx2, avx512
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cuckoo implements cuckoo filters, which support deletion.
+//
+// A cuckoo filter stores a 16-bit fingerprint of each key in one of two
+// candidate buckets of four slots (Fan et al., Cuckoo Filter: Practically
+// Better Than Bloom, https://doi.org/10.1145/2674005.2674994). Its false
+// positive rate is about 8/2^16 = 1.2e-4, at 16 bits per slot. Filled to
+// its typical 95% load, that is about 17 bits per key, less than a
+// blocked Bloom filter needs for the same rate.
+//
+// Like package blobloom, this package takes keys as 64-bit hash values
+// supplied by the client.
+//
+// For a cuckoo filter that grows instead of filling up, see package taffy.
+package cuckoo
+
+import (
+	"errors"
+	"math/bits"
+)
+
+const (
+	slotsPerBucket = 4
+	maxKicks       = 500
+
+	// MaxLoad is the fraction of slots that a Filter can typically fill
+	// before Add fails. New sizes filters for this load.
+	MaxLoad = .95
+)
+
+// ErrFull is returned by Add when a Filter has no room for a key.
+var ErrFull = errors.New("cuckoo: filter is full")
+
+// A Filter is a cuckoo filter with a fixed number of buckets.
+//
+// A Filter is not safe for concurrent use.
+type Filter struct {
+	slots []uint16 // Zero means empty.
+	mask  uint32   // Number of buckets minus one.
+	count int
+	rng   uint64
+
+	// Fingerprint that could not be placed in the table. See place.
+	victim       uint16
+	victimBucket uint32
+}
+
+// New constructs a Filter with room for at least capacity keys.
+// The number of buckets is rounded up to a power of two.
+func New(capacity int) *Filter {
+	nbuckets := int(float64(capacity)/(slotsPerBucket*MaxLoad)) + 1
+	logb := uint(bits.Len(uint(nbuckets - 1)))
+	if logb > 30 {
+		panic("cuckoo: capacity too large")
+	}
+
+	return &Filter{
+		slots: make([]uint16, slotsPerBucket<<logb),
+		mask:  uint32(1)<<logb - 1,
+		rng:   0x2545f4914f6cdd1d,
+	}
+}
+
+// Add inserts a key with hash value h into f. Adding a key more than once
+// stores it more than once. Since a key has only eight candidate slots,
+// adding it more than eight times fills f.
+//
+// Add returns ErrFull if f is full. The key is not added in that case.
+func (f *Filter) Add(h uint64) error {
+	if f.victim != 0 {
+		return ErrFull
+	}
+	fp, i := f.split(h)
+	f.place(i, fp)
+	f.count++
+	return nil
+}
+
+// Delete removes one copy of a key with hash value h from f.
+// It reports whether a matching fingerprint was found.
+//
+// Only keys that have been added may be deleted. Deleting other keys
+// may remove the fingerprint of a key that shares it, causing
+// false negatives.
+func (f *Filter) Delete(h uint64) bool {
+	fp, i1 := f.split(h)
+	i2 := f.alt(i1, fp)
+
+	if f.victim == fp && (f.victimBucket == i1 || f.victimBucket == i2) {
+		f.victim = 0
+		f.count--
+		return true
+	}
+	for _, i := range [2]uint32{i1, i2} {
+		b := f.bucket(i)
+		for j := range b {
+			if b[j] == fp {
+				b[j] = 0
+				f.count--
+				f.reinsertVictim()
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Has reports whether a key with hash value h has been added.
+// It may return a false positive.
+func (f *Filter) Has(h uint64) bool {
+	fp, i1 := f.split(h)
+	i2 := f.alt(i1, fp)
+
+	if f.victim == fp && (f.victimBucket == i1 || f.victimBucket == i2) {
+		return true
+	}
+	b1, b2 := f.bucket(i1), f.bucket(i2)
+	return b1[0] == fp || b1[1] == fp || b1[2] == fp || b1[3] == fp ||
+		b2[0] == fp || b2[1] == fp || b2[2] == fp || b2[3] == fp
+}
+
+// Clear removes all keys from f.
+func (f *Filter) Clear() {
+	for i := range f.slots {
+		f.slots[i] = 0
+	}
+	f.count, f.victim = 0, 0
+}
+
+// Len returns the number of keys in f.
+func (f *Filter) Len() int { return f.count }
+
+// LoadFactor returns the fraction of slots in f that are occupied.
+func (f *Filter) LoadFactor() float64 {
+	return float64(f.count) / float64(len(f.slots))
+}
+
+// NumBuckets returns the number of buckets of f. Each bucket has four slots.
+func (f *Filter) NumBuckets() int { return int(f.mask) + 1 }
+
+// Size returns the size of f's table in bytes.
+func (f *Filter) Size() int { return 2 * len(f.slots) }
+
+// place inserts fp into bucket i or its alternate, kicking out other
+// fingerprints as needed. If that doesn't work out, the last fingerprint
+// kicked out becomes the victim: it is stored separately, so Has still
+// finds it, and further Adds fail until a Delete makes room.
+func (f *Filter) place(i uint32, fp uint16) {
+	i2 := f.alt(i, fp)
+	if f.insert(i, fp) || f.insert(i2, fp) {
+		return
+	}
+
+	if f.random()&1 == 1 {
+		i = i2
+	}
+	for n := 0; n < maxKicks; n++ {
+		s := &f.slots[i*slotsPerBucket+f.random()%slotsPerBucket]
+		fp, *s = *s, fp
+		i = f.alt(i, fp)
+		if f.insert(i, fp) {
+			return
+		}
+	}
+	f.victim, f.victimBucket = fp, i
+}
+
+// reinsertVictim tries to move the victim into the table,
+// after a deletion has made room.
+func (f *Filter) reinsertVictim() {
+	if f.victim == 0 {
+		return
+	}
+	fp, i := f.victim, f.victimBucket
+	f.victim = 0
+	f.place(i, fp)
+}
+
+func (f *Filter) bucket(i uint32) []uint16 {
+	return f.slots[i*slotsPerBucket : (i+1)*slotsPerBucket]
+}
+
+// insert puts fp in an empty slot of bucket i, if there is one.
+func (f *Filter) insert(i uint32, fp uint16) bool {
+	b := f.bucket(i)
+	for j := range b {
+		if b[j] == 0 {
+			b[j] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// split returns the fingerprint and primary bucket index for h.
+// The fingerprint is taken from the high bits, the index from the low bits.
+func (f *Filter) split(h uint64) (fp uint16, i uint32) {
+	fp = uint16(h >> 48)
+	if fp == 0 {
+		fp = 1
+	}
+	return fp, uint32(h) & f.mask
+}
+
+// alt returns the alternate bucket index for fingerprint fp in bucket i.
+// alt(alt(i, fp), fp) == i.
+func (f *Filter) alt(i uint32, fp uint16) uint32 {
+	// Multiplying by an odd constant spreads the fingerprint over
+	// all bits of the index.
+	return (i ^ uint32(fp)*0x5bd1e995) & f.mask
+}
+
+// random returns a pseudo-random number (xorshift64*).
+func (f *Filter) random() uint32 {
+	f.rng ^= f.rng >> 12
+	f.rng ^= f.rng << 25
+	f.rng ^= f.rng >> 27
+	return uint32((f.rng * 0x2545f4914f6cdd1d) >> 32)
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cuckoo
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashes(n int, seed int64) []uint64 {
+	r := rand.New(rand.NewSource(seed))
+	hs := make([]uint64, n)
+	for i := range hs {
+		hs[i] = r.Uint64()
+	}
+	return hs
+}
+
+func TestFilter(t *testing.T) {
+	const n = 100000
+
+	f := New(n)
+	assert.GreaterOrEqual(t, f.NumBuckets()*slotsPerBucket, n)
+	assert.Equal(t, 2*slotsPerBucket*f.NumBuckets(), f.Size())
+
+	keys := hashes(n, 0xc0c0)
+	for _, h := range keys {
+		require.NoError(t, f.Add(h))
+	}
+	assert.Equal(t, n, f.Len())
+
+	for _, h := range keys {
+		assert.True(t, f.Has(h))
+	}
+
+	var fp int
+	for _, h := range hashes(n, 0xfa15e) {
+		if f.Has(h) {
+			fp++
+		}
+	}
+	assert.Less(t, fp, 40)
+
+	for _, h := range keys[:n/2] {
+		assert.True(t, f.Delete(h))
+	}
+	assert.Equal(t, n/2, f.Len())
+	for _, h := range keys[n/2:] {
+		assert.True(t, f.Has(h))
+	}
+
+	f.Clear()
+	assert.Equal(t, 0, f.Len())
+	assert.False(t, f.Has(keys[0]))
+}
+
+func TestFull(t *testing.T) {
+	f := New(1000)
+	nslots := f.NumBuckets() * slotsPerBucket
+
+	var keys []uint64
+	var err error
+	for _, h := range hashes(2*nslots, 0xf011) {
+		if err = f.Add(h); err != nil {
+			break
+		}
+		keys = append(keys, h)
+	}
+	assert.Equal(t, ErrFull, err)
+	assert.Equal(t, len(keys), f.Len())
+	assert.Greater(t, f.LoadFactor(), .9)
+
+	// No key was lost when f filled up, including the victim.
+	assert.NotZero(t, f.victim)
+	for _, h := range keys {
+		assert.True(t, f.Has(h))
+	}
+
+	// Deleting makes room for the victim.
+	assert.True(t, f.Delete(keys[0]))
+	assert.Zero(t, f.victim)
+	assert.NoError(t, f.Add(keys[0]))
+	for _, h := range keys {
+		assert.True(t, f.Has(h))
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	f := New(100)
+	h := uint64(0xdeadbeefcafebabe)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, f.Add(h))
+	}
+	for i := 0; i < 3; i++ {
+		assert.True(t, f.Has(h))
+		assert.True(t, f.Delete(h))
+	}
+	assert.False(t, f.Has(h))
+	assert.False(t, f.Delete(h))
+}
+
+func TestAlt(t *testing.T) {
+	f := New(1 << 12)
+	for _, h := range hashes(1000, 0xa17) {
+		fp, i := f.split(h)
+		assert.NotZero(t, fp)
+		assert.Equal(t, i, f.alt(f.alt(i, fp), fp))
+	}
+}
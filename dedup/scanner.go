@@ -0,0 +1,66 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/greatroar/blobloom/internal/keyhash"
+)
+
+// A Scanner reads lines from an io.Reader, skipping lines that it has seen
+// before. It remembers lines in a Window, so its memory use is bounded
+// even for endless input such as logs.
+//
+// Like a bufio.Scanner, a Scanner is not safe for concurrent use.
+type Scanner struct {
+	*bufio.Scanner // Underlying Scanner. Its Split function may be changed.
+
+	// Hash is the hash function for lines.
+	// If nil, a 64-bit FNV-1a hash with a finalizer is used.
+	Hash func([]byte) uint64
+
+	// Number of lines skipped.
+	Skipped int64
+
+	w *Window
+}
+
+// NewScanner returns a Scanner that reads lines from r and remembers them
+// in a Window with the given configuration.
+func NewScanner(r io.Reader, config Config) *Scanner {
+	return &Scanner{
+		Scanner: bufio.NewScanner(r),
+		w:       New(config),
+	}
+}
+
+// Scan advances to the next line that has not been seen.
+// It returns false at the end of the input or when an error occurs.
+func (s *Scanner) Scan() bool {
+	hash := s.Hash
+	if hash == nil {
+		hash = keyhash.Sum64
+	}
+
+	for s.Scanner.Scan() {
+		if !s.w.Seen(hash(s.Bytes())) {
+			return true
+		}
+		s.Skipped++
+	}
+	return false
+}
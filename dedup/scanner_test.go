@@ -0,0 +1,50 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanner(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 10; i++ {
+		for j := 0; j <= i; j++ {
+			fmt.Fprintln(&input, "line", j)
+		}
+	}
+
+	s := NewScanner(strings.NewReader(input.String()), Config{
+		Capacity: 100,
+		FPRate:   1e-6,
+	})
+
+	var lines []string
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	require.NoError(t, s.Err())
+
+	assert.Len(t, lines, 10)
+	for i, line := range lines {
+		assert.Equal(t, fmt.Sprint("line ", i), line)
+	}
+	assert.EqualValues(t, 55-10, s.Skipped)
+}
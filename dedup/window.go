@@ -0,0 +1,238 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dedup provides components for best-effort duplicate suppression
+// on top of Bloom filters.
+//
+// Like Bloom filters, these components have no false negatives: a duplicate
+// is always recognized while it is remembered. False positives cause unique
+// items to be reported as duplicates at a rate determined by their
+// configuration.
+package dedup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/greatroar/blobloom"
+)
+
+// A Config holds parameters for New.
+type Config struct {
+	// Trigger the "contains filtered or unexported fields" message for
+	// forward compatibility and force the caller to use named fields.
+	_ struct{}
+
+	// Capacity is the number of distinct keys per generation.
+	Capacity uint64
+
+	// FPRate is the false positive rate of each generation's filter
+	// when it is filled to capacity.
+	FPRate float64
+
+	// Generations is the number of generations in a Window.
+	// Values less than two mean two.
+	Generations int
+
+	// If not nil, Logger receives events for the construction of a Window
+	// and for rotations.
+	Logger blobloom.Logger
+}
+
+// A Window remembers the keys seen within a sliding window of a stream,
+// such as a Kafka partition.
+//
+// A Window consists of a number of generations, each holding up to Capacity
+// keys in a Bloom filter. When the newest generation is full, the oldest
+// generation is discarded. A Window therefore remembers at least the last
+// (Generations-1)*Capacity keys and at most Generations*Capacity keys.
+// Rotate can be used to rotate generations on a timer instead.
+//
+// The false positive rate of a Window is up to Generations times FPRate.
+//
+// A Window is safe for concurrent use by multiple goroutines.
+type Window struct {
+	mu       sync.Mutex
+	gens     []*blobloom.Filter // Newest first.
+	count    uint64             // Number of keys in gens[0].
+	capacity uint64
+	log      blobloom.Logger
+}
+
+// New constructs a Window with the given configuration.
+// It panics when config.FPRate is invalid.
+func New(config Config) *Window {
+	n := config.Generations
+	if n < 2 {
+		n = 2
+	}
+	nbits, nhashes := blobloom.Optimize(blobloom.Config{
+		Capacity: config.Capacity,
+		FPRate:   config.FPRate,
+	})
+
+	w := &Window{
+		gens:     make([]*blobloom.Filter, n),
+		capacity: config.Capacity,
+		log:      config.Logger,
+	}
+	for i := range w.gens {
+		w.gens[i] = blobloom.New(nbits, nhashes)
+	}
+	if w.log != nil {
+		w.log.Info("dedup: window created", "generations", n,
+			"capacity", config.Capacity, "bits", nbits*uint64(n))
+	}
+	return w
+}
+
+// SetLogger sets the Logger that receives events for rotations of w,
+// e.g., for a Window returned by Restore.
+func (w *Window) SetLogger(l blobloom.Logger) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.log = l
+}
+
+// Seen reports whether the key with hash value h has been seen within
+// the window, then records it in the newest generation.
+//
+// Recording keys that have been seen extends the time they are remembered.
+// This ensures that a burst of duplicates is suppressed as a whole.
+func (w *Window) Seen(h uint64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.gens[0].Has(h) {
+		return true
+	}
+	seen := w.has(h)
+	w.add(h)
+	return seen
+}
+
+// Has reports whether the key with hash value h has been seen within
+// the window, without recording it.
+func (w *Window) Has(h uint64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.has(h)
+}
+
+func (w *Window) has(h uint64) bool {
+	for _, f := range w.gens {
+		if f.Has(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add records the key with hash value h.
+func (w *Window) Add(h uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.add(h)
+}
+
+func (w *Window) add(h uint64) {
+	if w.count >= w.capacity && w.capacity > 0 {
+		w.rotate("full")
+	}
+	w.gens[0].Add(h)
+	w.count++
+}
+
+// Rotate discards the oldest generation and starts a new one.
+func (w *Window) Rotate() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rotate("manual")
+}
+
+func (w *Window) rotate(reason string) {
+	oldest := w.gens[len(w.gens)-1]
+	copy(w.gens[1:], w.gens)
+	oldest.Clear()
+	w.gens[0] = oldest
+	w.count = 0
+
+	if w.log != nil {
+		w.log.Info("dedup: generation rotated", "reason", reason)
+	}
+}
+
+// Snapshot writes the state of w to out, in a format that Restore accepts.
+//
+// To resume deduplication after a consumer restarts or a partition is
+// reassigned, take a Snapshot when committing the consumer's offset and
+// store it alongside the offset, e.g., in the commit metadata or in
+// a compacted topic. A Snapshot reflects all keys recorded before the
+// call, so replaying the stream from the committed offset does not cause
+// false duplicates.
+func (w *Window) Snapshot(out io.Writer) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, f := range w.gens {
+		comment := fmt.Sprintf("dedup %d/%d %d/%d", i, len(w.gens), w.count, w.capacity)
+		if _, err := blobloom.Dump(out, f, comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Maximum number of generations that Restore accepts.
+const maxRestoreGenerations = 1 << 16
+
+// Restore reads a Window from r, which must contain a Snapshot.
+func Restore(r io.Reader) (*Window, error) {
+	w := &Window{}
+
+	var ngens int
+	for i := 0; i == 0 || i < ngens; i++ {
+		l, err := blobloom.NewLoader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		var idx, n int
+		var count, capacity uint64
+		_, err = fmt.Sscanf(l.Comment, "dedup %d/%d %d/%d", &idx, &n, &count, &capacity)
+		if err != nil {
+			return nil, fmt.Errorf("dedup: invalid snapshot header %q", l.Comment)
+		}
+		if i == 0 {
+			if n < 2 || n > maxRestoreGenerations {
+				return nil, fmt.Errorf("dedup: invalid number of generations %d in snapshot", n)
+			}
+			ngens = n
+			w.count, w.capacity = count, capacity
+		}
+		if idx != i || n != ngens || count != w.count || capacity != w.capacity {
+			return nil, errors.New("dedup: inconsistent snapshot")
+		}
+
+		// Grow w.gens as generations are read, rather than trusting n.
+		f, err := l.Load(nil)
+		if err != nil {
+			return nil, err
+		}
+		w.gens = append(w.gens, f)
+	}
+	return w, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func key(i uint64) uint64 { return i * 0x9e3779b97f4a7c15 }
+
+func TestWindow(t *testing.T) {
+	w := New(Config{Capacity: 100, FPRate: 1e-6, Generations: 3})
+
+	for h := uint64(0); h < 300; h++ {
+		assert.False(t, w.Seen(key(h)))
+	}
+	for h := uint64(0); h < 300; h++ {
+		assert.True(t, w.Has(key(h)))
+	}
+
+	// Fourth generation drops the first.
+	w.Add(1)
+	for h := uint64(0); h < 100; h++ {
+		assert.False(t, w.Has(key(h)))
+	}
+	for h := uint64(100); h < 300; h++ {
+		assert.True(t, w.Has(key(h)))
+	}
+
+	// Seen refreshes keys from older generations.
+	assert.True(t, w.Seen(key(100)))
+	w.Rotate()
+	w.Rotate()
+	assert.True(t, w.Has(key(100)))
+	assert.False(t, w.Has(key(101)))
+}
+
+func TestSnapshot(t *testing.T) {
+	r := rand.New(rand.NewSource(0x5eed))
+	w := New(Config{Capacity: 1000, FPRate: 1e-3, Generations: 4})
+
+	hs := make([]uint64, 2500)
+	for i := range hs {
+		hs[i] = r.Uint64()
+		w.Add(hs[i])
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Snapshot(&buf))
+	snapshot := buf.Bytes()
+
+	v, err := Restore(bytes.NewReader(snapshot))
+	require.NoError(t, err)
+	assert.Equal(t, w.count, v.count)
+	assert.Equal(t, w.capacity, v.capacity)
+	require.Len(t, v.gens, len(w.gens))
+	for i := range w.gens {
+		assert.True(t, w.gens[i].Equals(v.gens[i]))
+	}
+	for _, h := range hs {
+		assert.True(t, v.Has(h))
+	}
+
+	// Restored Window rotates at the same point as the original.
+	for i := 0; i < 500; i++ {
+		h := r.Uint64()
+		w.Add(h)
+		v.Add(h)
+	}
+	for i := range w.gens {
+		assert.True(t, w.gens[i].Equals(v.gens[i]))
+	}
+
+	_, err = Restore(bytes.NewReader(snapshot[:len(snapshot)-1]))
+	assert.Error(t, err)
+
+	// Drop the last generation.
+	_, err = Restore(bytes.NewReader(snapshot[:3*len(snapshot)/4]))
+	assert.Error(t, err)
+
+	// A truncated generation that claims a huge number of blocks.
+	binary.LittleEndian.PutUint32(snapshot[12:], 1<<30)
+	_, err = Restore(bytes.NewReader(snapshot))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestRestoreInvalidHeader(t *testing.T) {
+	f := blobloom.New(blobloom.BlockBits, 2)
+	for _, comment := range []string{
+		"dedup 0/0 0/0",
+		"dedup 0/1 0/10",
+		"dedup 0/-3 0/10",
+		"dedup 0/1000000000 0/10",
+		"dedup 1/2 0/10",
+		"not a dedup snapshot",
+	} {
+		var buf bytes.Buffer
+		_, err := blobloom.Dump(&buf, f, comment)
+		require.NoError(t, err)
+
+		_, err = Restore(&buf)
+		assert.Error(t, err, comment)
+	}
+}
+
+// msgLogger records the messages logged to it.
+type msgLogger []string
+
+func (l *msgLogger) Debug(msg string, args ...interface{}) { *l = append(*l, msg) }
+func (l *msgLogger) Info(msg string, args ...interface{})  { *l = append(*l, msg) }
+func (l *msgLogger) Warn(msg string, args ...interface{})  { *l = append(*l, msg) }
+
+func TestWindowLogger(t *testing.T) {
+	var log msgLogger
+	w := New(Config{Capacity: 10, FPRate: 1e-3, Logger: &log})
+	for h := uint64(0); h < 11; h++ {
+		w.Add(key(h))
+	}
+	w.Rotate()
+
+	assert.Equal(t, msgLogger{
+		"dedup: window created",
+		"dedup: generation rotated",
+		"dedup: generation rotated",
+	}, log)
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"io"
+	"math/bits"
+
+	"github.com/greatroar/blobloom/internal/keyhash"
+)
+
+// A Filter is a set of hash values. It is implemented by blobloom.Filter,
+// blobloom.SyncFilter and Window.
+type Filter interface {
+	Add(h uint64)
+	Has(h uint64) bool
+}
+
+// A SplitFunc splits a stream into chunks. It returns the length of the
+// first chunk in p, or zero to request more data. If atEOF is true,
+// p holds the remainder of the stream and a SplitFunc must not return zero.
+type SplitFunc func(p []byte, atEOF bool) int
+
+// FixedSize returns a SplitFunc that splits a stream into chunks of size
+// bytes. The last chunk may be shorter.
+func FixedSize(size int) SplitFunc {
+	if size < 1 {
+		panic("dedup: chunk size must be positive")
+	}
+	return func(p []byte, atEOF bool) int {
+		switch {
+		case len(p) >= size:
+			return size
+		case atEOF:
+			return len(p)
+		}
+		return 0
+	}
+}
+
+// ContentDefined returns a SplitFunc that splits a stream into chunks at
+// positions determined by the content, so that chunk boundaries are
+// preserved when data is inserted or removed elsewhere in the stream.
+//
+// Chunks are at least min and at most max bytes long, except for the last,
+// and about avg bytes on average. The expected length of a chunk is min
+// plus avg-min rounded up to a power of two.
+// The boundaries are found using a Gear rolling hash, as in FastCDC.
+func ContentDefined(min, avg, max int) SplitFunc {
+	if min < 0 || avg <= min || max < avg {
+		panic("dedup: need 0 <= min < avg <= max")
+	}
+	// Boundary when the top nbits bits of the hash are zero.
+	nbits := bits.Len(uint(avg - min - 1))
+	mask := ^uint64(0) << (64 - nbits)
+
+	return func(p []byte, atEOF bool) int {
+		n := len(p)
+		switch {
+		case n > max:
+			n = max
+		case n <= min && atEOF:
+			return n
+		case n <= min:
+			return 0
+		}
+
+		var h uint64
+		for i := min; i < n; i++ {
+			h = h<<1 + gear[p[i]]
+			if h&mask == 0 {
+				return i + 1
+			}
+		}
+		if n == max || atEOF {
+			return n
+		}
+		return 0
+	}
+}
+
+var gear [256]uint64
+
+func init() {
+	// Fixed pseudo-random values, so that boundaries are stable across
+	// processes. Generated by SplitMix64.
+	x := uint64(0x6765617268617368)
+	for i := range gear {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		gear[i] = z ^ z>>31
+	}
+}
+
+// A Writer splits the data written to it into chunks and forwards only
+// the chunks that its filter has not seen. Each forwarded chunk is written
+// to the underlying io.Writer in a single call.
+//
+// Since Bloom filters have false positives, a Writer occasionally skips
+// a chunk that it has not seen before. A backup deduplicator should confirm
+// skipped chunks against an index of stored chunks; the filter spares it
+// the index lookup for most new chunks.
+type Writer struct {
+	// Hash is the hash function for chunks.
+	// If nil, a 64-bit FNV-1a hash with a finalizer is used.
+	Hash func([]byte) uint64
+
+	// Numbers of bytes forwarded and skipped.
+	Forwarded, Skipped int64
+
+	w     io.Writer
+	f     Filter
+	split SplitFunc
+	buf   []byte
+}
+
+// NewWriter returns a Writer that writes to w the chunks, as determined by
+// split, that are not in f, and adds them to f.
+func NewWriter(w io.Writer, f Filter, split SplitFunc) *Writer {
+	return &Writer{w: w, f: f, split: split}
+}
+
+// Write buffers p and forwards the complete chunks that it contains.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	w.buf = append(w.buf, p...)
+	return len(p), w.flush(false)
+}
+
+// Close forwards the last chunk, if it has not been seen.
+// It does not close the underlying io.Writer.
+//
+// After Close, w may be used to write another stream.
+func (w *Writer) Close() error {
+	return w.flush(true)
+}
+
+func (w *Writer) flush(atEOF bool) error {
+	hash := w.Hash
+	if hash == nil {
+		hash = keyhash.Sum64
+	}
+
+	p := w.buf
+	for len(p) > 0 {
+		n := w.split(p, atEOF)
+		if n == 0 {
+			break
+		}
+		chunk := p[:n]
+
+		h := hash(chunk)
+		if w.f.Has(h) {
+			w.Skipped += int64(n)
+		} else {
+			if _, err := w.w.Write(chunk); err != nil {
+				w.buf = append(w.buf[:0], p...)
+				return err
+			}
+			w.f.Add(h)
+			w.Forwarded += int64(n)
+		}
+		p = p[n:]
+	}
+	w.buf = append(w.buf[:0], p...)
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type chunkRecorder struct{ chunks [][]byte }
+
+func (r *chunkRecorder) Write(p []byte) (int, error) {
+	r.chunks = append(r.chunks, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestWriterFixedSize(t *testing.T) {
+	var out chunkRecorder
+	f := blobloom.NewOptimized(blobloom.Config{Capacity: 100, FPRate: 1e-6})
+	w := NewWriter(&out, f, FixedSize(4))
+
+	for _, s := range []string{"abcdab", "cdefgh", "abcdx"} {
+		_, err := w.Write([]byte(s))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	var got []string
+	for _, c := range out.chunks {
+		got = append(got, string(c))
+	}
+	assert.Equal(t, []string{"abcd", "efgh", "x"}, got)
+	assert.EqualValues(t, 9, w.Forwarded)
+	assert.EqualValues(t, 8, w.Skipped)
+}
+
+func TestContentDefined(t *testing.T) {
+	const min, avg, max = 256, 1280, 4096
+	split := ContentDefined(min, avg, max)
+
+	r := rand.New(rand.NewSource(0x5eed))
+	data := make([]byte, 1<<20)
+	r.Read(data)
+
+	chunks := chunk(split, data)
+	var total int
+	for i, c := range chunks {
+		total += len(c)
+		assert.LessOrEqual(t, len(c), max)
+		if i < len(chunks)-1 {
+			assert.Greater(t, len(c), min)
+		}
+	}
+	assert.Equal(t, len(data), total)
+	assert.InDelta(t, avg, len(data)/len(chunks), avg/4)
+
+	// Inserting a byte only changes the chunks around the insertion.
+	shifted := append(append(append([]byte(nil), data[:1000]...), 'x'), data[1000:]...)
+	seen := make(map[string]bool)
+	for _, c := range chunks {
+		seen[string(c)] = true
+	}
+	changed := 0
+	for _, c := range chunk(split, shifted) {
+		if !seen[string(c)] {
+			changed++
+		}
+	}
+	assert.LessOrEqual(t, changed, 2)
+}
+
+func chunk(split SplitFunc, data []byte) (chunks [][]byte) {
+	for len(data) > 0 {
+		n := split(data, true)
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return chunks
+}
+
+func TestWriterContentDefined(t *testing.T) {
+	r := rand.New(rand.NewSource(0x5eed))
+	data := make([]byte, 1<<18)
+	r.Read(data)
+
+	var out bytes.Buffer
+	f := blobloom.NewOptimized(blobloom.Config{Capacity: 1000, FPRate: 1e-6})
+	w := NewWriter(&out, f, ContentDefined(64, 512, 2048))
+
+	// Write the data twice, as separate streams in pieces of varying size.
+	for i := 0; i < 2; i++ {
+		for p := data; len(p) > 0; {
+			n := 1 + r.Intn(3000)
+			if n > len(p) {
+				n = len(p)
+			}
+			_, err := w.Write(p[:n])
+			require.NoError(t, err)
+			p = p[n:]
+		}
+		require.NoError(t, w.Close())
+	}
+
+	assert.Equal(t, data, out.Bytes())
+	assert.EqualValues(t, len(data), w.Forwarded)
+	assert.EqualValues(t, len(data), w.Skipped)
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// The delta format consists of a 64-byte header:
+//   - the string "blobloom", in ASCII;
+//   - a four-byte version number, which is one;
+//   - the number of blocks in the filter, minus one, as a 32-bit integer;
+//   - the number of hashes, as a 32-bit integer;
+//   - the number of blocks in the delta, as a 32-bit integer;
+//   - 40 zero bytes.
+//
+// After the header come the blocks, each as a 32-bit block index followed by
+// the block's contents, as in the dump format. All integers are little-endian.
+// The version number makes Loaders reject deltas.
+const deltaVersion = 1
+
+var errNotTracking = errors.New("blobloom: SyncFilter does not track dirty blocks")
+
+// A dirtySet is a bitmap of blocks modified since the last WriteDelta.
+type dirtySet []uint32
+
+func (d dirtySet) mark(i int) {
+	p, bit := &d[i/32], uint32(1)<<(i%32)
+	for {
+		old := atomic.LoadUint32(p)
+		if old&bit != 0 || atomic.CompareAndSwapUint32(p, old, old|bit) {
+			return
+		}
+	}
+}
+
+// TrackDirty makes f track which of its blocks are modified by Add,
+// AddMany, AddIfNotHas and Add128, so that WriteDelta can write only
+// those blocks. Other methods, such as UnionBlock and LoadSync, are not
+// tracked, and since deltas are applied as unions, they cannot convey
+// Clear. Tracking costs an atomic operation per added key.
+//
+// TrackDirty must not be called concurrently with other methods of f.
+// Blocks modified before the call are not considered dirty: replicas
+// should start from a full Dump taken after TrackDirty, then receive
+// deltas. For a replication protocol that handles this, see package
+// replicate.
+func (f *SyncFilter) TrackDirty() {
+	if f.dirty == nil {
+		f.dirty = make(dirtySet, (len(f.b)+31)/32)
+	}
+}
+
+// markDirty marks the block for the (unmixed) hash value h as dirty.
+func (f *SyncFilter) markDirty(h uint64) { f.dirty.mark(f.BlockIndex(h)) }
+
+// WriteDelta writes the blocks of f that were modified since the previous
+// call to WriteDelta, or since TrackDirty, to w, in a format accepted by
+// ApplyDelta, and marks them as clean. It fails if f does not track dirty
+// blocks.
+//
+// WriteDelta may run concurrently with additions to f: additions that are
+// not reflected in the delta leave their blocks marked dirty. If writing
+// to w fails, the blocks that were to be written remain clean, so the
+// caller should fall back to sending a full dump.
+func (f *SyncFilter) WriteDelta(w io.Writer) (n int64, err error) {
+	if f.dirty == nil {
+		return 0, errNotTracking
+	}
+
+	var blocks []uint32
+	for i := range f.dirty {
+		x := atomic.SwapUint32(&f.dirty[i], 0)
+		for j := 0; x != 0; j, x = j+1, x>>1 {
+			if x&1 != 0 {
+				blocks = append(blocks, uint32(32*i+j))
+			}
+		}
+	}
+
+	var hdr [64]byte
+	copy(hdr[:8], "blobloom")
+	binary.LittleEndian.PutUint32(hdr[8:], deltaVersion)
+	binary.LittleEndian.PutUint32(hdr[12:], uint32(len(f.b)-1))
+	binary.LittleEndian.PutUint32(hdr[16:], uint32(f.k))
+	binary.LittleEndian.PutUint32(hdr[20:], uint32(len(blocks)))
+	k, err := w.Write(hdr[:])
+	n = int64(k)
+
+	buf := make([]byte, 0, streamChunk*(4+BlockBytes))
+	for err == nil && len(blocks) > 0 {
+		m := len(blocks)
+		if m > streamChunk {
+			m = streamChunk
+		}
+		buf = buf[:0]
+		for _, i := range blocks[:m] {
+			buf = append(buf, byte(i), byte(i>>8), byte(i>>16), byte(i>>24))
+			buf = appendBlock(buf, &f.b[i])
+		}
+		blocks = blocks[m:]
+
+		k, err = w.Write(buf)
+		n += int64(k)
+	}
+	return n, err
+}
+
+// ApplyDelta reads a delta written by WriteDelta from r and sets each block
+// in it to the union of the corresponding block of f and the block from
+// the delta. ApplyDelta may run concurrently with other modifications of f.
+//
+// ApplyDelta fails if the delta is for a filter with different numbers of
+// bits or hashes. If an error occurs while reading from r, f may have
+// received part of the delta.
+func (f *SyncFilter) ApplyDelta(r io.Reader) error {
+	return applyDelta(r, len(f.b), f.k, f.UnionBlock)
+}
+
+// ApplyDelta reads a delta written by SyncFilter.WriteDelta from r and
+// sets each block in it to the union of the corresponding block of f and
+// the block from the delta. See SyncFilter.ApplyDelta for details.
+func (f *Filter) ApplyDelta(r io.Reader) error {
+	return applyDelta(r, len(f.b), f.k, f.UnionBlock)
+}
+
+func applyDelta(r io.Reader, nblocks, nhashes int, union func(int, []byte)) error {
+	var buf [4 + BlockBytes]byte
+	if _, err := io.ReadFull(r, buf[:64]); err != nil {
+		return err
+	}
+
+	var (
+		version = binary.LittleEndian.Uint32(buf[8:])
+		dblocks = 1 + uint64(binary.LittleEndian.Uint32(buf[12:]))
+		dhashes = int(binary.LittleEndian.Uint32(buf[16:]))
+		count   = binary.LittleEndian.Uint32(buf[20:])
+	)
+	switch {
+	case string(buf[:8]) != "blobloom" || version != deltaVersion:
+		return errors.New("blobloom: not a Bloom filter delta")
+	case dblocks != uint64(nblocks):
+		return fmt.Errorf("blobloom: Filter has %d blocks, but delta has %d", nblocks, dblocks)
+	case dhashes != nhashes:
+		return fmt.Errorf("blobloom: Filter has %d hashes, but delta has %d", nhashes, dhashes)
+	}
+
+	for ; count > 0; count-- {
+		_, err := io.ReadFull(r, buf[:4+BlockBytes])
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		i := binary.LittleEndian.Uint32(buf[:4])
+		if uint64(i) >= uint64(nblocks) {
+			return fmt.Errorf("blobloom: block index %d out of range in delta", i)
+		}
+		union(int(i), buf[4:])
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelta(t *testing.T) {
+	const nbits, nhashes = 1000 * BlockBits, 5
+
+	f := NewSync(nbits, nhashes)
+	f.SetSeed(0xde17a)
+	f.Add(1)
+
+	var buf bytes.Buffer
+	_, err := f.WriteDelta(&buf)
+	assert.Equal(t, errNotTracking, err)
+
+	f.TrackDirty()
+	replica := f.Snapshot()
+
+	keys := randomU64(200, 0xde17a)
+	f.Add(keys[0])
+	f.AddMany(keys[1:100])
+	for _, h := range keys[100:199] {
+		f.AddIfNotHas(h)
+	}
+	f.Add128(keys[199], keys[0])
+
+	buf.Reset()
+	n, err := f.WriteDelta(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, buf.Len(), n)
+	assert.Less(t, buf.Len(), 201*(4+BlockBytes))
+	assert.Greater(t, buf.Len(), 64+150*(4+BlockBytes))
+
+	require.NoError(t, replica.ApplyDelta(&buf))
+	assert.Equal(t, f.b, replica.b)
+
+	// Everything is clean now.
+	buf.Reset()
+	_, err = f.WriteDelta(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 64, buf.Len())
+	syncReplica := f.Clone()
+	require.NoError(t, syncReplica.ApplyDelta(&buf))
+	assert.Equal(t, f.b, syncReplica.b)
+
+	f.Add(keys[0] + 1)
+	buf.Reset()
+	_, err = f.WriteDelta(&buf)
+	require.NoError(t, err)
+	p := buf.Bytes()
+
+	assert.Equal(t, io.ErrUnexpectedEOF, syncReplica.ApplyDelta(bytes.NewReader(p[:len(p)-1])))
+	assert.Error(t, New(nbits+BlockBits, nhashes).ApplyDelta(bytes.NewReader(p)))
+	assert.Error(t, New(nbits, nhashes+1).ApplyDelta(bytes.NewReader(p)))
+	_, err = NewLoader(bytes.NewReader(p))
+	assert.Error(t, err)
+
+	require.NoError(t, syncReplica.ApplyDelta(bytes.NewReader(p)))
+	assert.Equal(t, f.b, syncReplica.b)
+}
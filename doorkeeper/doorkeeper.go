@@ -0,0 +1,105 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package doorkeeper provides the admission filter of W-TinyLFU caches.
+//
+// A Doorkeeper sits in front of a cache's frequency sketch. It absorbs the
+// first occurrence of each key, so that one-hit wonders never reach the
+// sketch, and only reports a key as admissible from its second occurrence
+// on. It is reset periodically, at the same time as the sketch is aged,
+// so that it only remembers recent keys.
+//
+// See Einziger, Friedman and Manes, TinyLFU: A Highly Efficient Cache
+// Admission Policy, https://arxiv.org/abs/1512.00727.
+package doorkeeper
+
+import (
+	"sync"
+
+	"github.com/greatroar/blobloom"
+)
+
+// DefaultFPRate is the false positive rate used by New.
+// Higher rates admit more one-hit wonders;
+// lower rates make the Doorkeeper larger than necessary.
+const DefaultFPRate = .01
+
+// A Doorkeeper admits keys on their second occurrence within a sample
+// period.
+//
+// A Doorkeeper is safe for concurrent use by multiple goroutines.
+type Doorkeeper struct {
+	mu         sync.Mutex
+	f          *blobloom.Filter
+	n          uint64 // Keys recorded since the last reset.
+	sampleSize uint64
+}
+
+// New constructs a Doorkeeper that resets itself after sampleSize keys have
+// been recorded. The sample size is typically ten times the number of
+// entries in the cache. Zero means it never resets by itself.
+func New(sampleSize uint64) *Doorkeeper {
+	return NewWithConfig(blobloom.Config{
+		Capacity: sampleSize,
+		FPRate:   DefaultFPRate,
+	})
+}
+
+// NewWithConfig is like New, but allows setting the false positive rate
+// and maximum size of the Doorkeeper's filter. The sample size is
+// config.Capacity.
+func NewWithConfig(config blobloom.Config) *Doorkeeper {
+	return &Doorkeeper{
+		f:          blobloom.NewOptimized(config),
+		sampleSize: config.Capacity,
+	}
+}
+
+// Admit reports whether the key with hash value h has occurred before in
+// the current sample period. If not, it records the key and returns false.
+func (d *Doorkeeper) Admit(h uint64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.f.Has(h) {
+		return true
+	}
+	if d.sampleSize > 0 && d.n >= d.sampleSize {
+		d.reset()
+	}
+	d.f.Add(h)
+	d.n++
+	return false
+}
+
+// Has reports whether the key with hash value h has occurred before in
+// the current sample period, without recording it.
+func (d *Doorkeeper) Has(h uint64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.f.Has(h)
+}
+
+// Reset forgets all keys. Caches that age their frequency sketch on their
+// own schedule should call Reset at the same time.
+func (d *Doorkeeper) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reset()
+}
+
+func (d *Doorkeeper) reset() {
+	d.f.Clear()
+	d.n = 0
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doorkeeper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoorkeeper(t *testing.T) {
+	d := New(1000)
+
+	for i := uint64(0); i < 1000; i++ {
+		assert.False(t, d.Admit(key(i)))
+	}
+	admitted := 0
+	for i := uint64(0); i < 1000; i++ {
+		assert.True(t, d.Has(key(i)))
+		if d.Admit(key(i)) {
+			admitted++
+		}
+	}
+	assert.Equal(t, 1000, admitted)
+
+	// The sample is full, so a new key resets d.
+	assert.False(t, d.Admit(key(1000)))
+	assert.True(t, d.Admit(key(1000)))
+	fp := 0
+	for i := uint64(0); i < 1000; i++ {
+		if d.Has(key(i)) {
+			fp++
+		}
+	}
+	assert.Less(t, fp, 5)
+
+	d.Reset()
+	assert.False(t, d.Has(key(1000)))
+}
+
+func key(i uint64) uint64 { return (i + 1) * 0x9e3779b97f4a7c15 }
@@ -136,6 +136,36 @@ func init() {
 	}
 }
 
+func ExampleFilter_Cardinality() {
+	// Cardinality can be used to decide when a long-lived filter has
+	// reached its capacity and should be rotated, without keeping
+	// a separate count of distinct keys.
+	const capacity = 10000
+
+	f := blobloom.NewOptimized(blobloom.Config{
+		Capacity: capacity,
+		FPRate:   1e-4,
+	})
+
+	for i := 1; i <= 3*capacity/2; i++ {
+		// The estimate relies on keys being spread evenly over the blocks,
+		// which requires a good hash function.
+		h := sha256.Sum256([]byte(fmt.Sprint("key", i)))
+		f.Add(binary.LittleEndian.Uint64(h[:]))
+
+		if i%(capacity/2) == 0 {
+			// Cardinality needs a full pass over the filter,
+			// so don't call it after every Add.
+			fmt.Printf("%d keys, rotate: %t\n", i, f.Cardinality() > capacity)
+		}
+	}
+
+	// Output:
+	// 5000 keys, rotate: false
+	// 10000 keys, rotate: false
+	// 15000 keys, rotate: true
+}
+
 func ExampleFilter_Cardinality_infinity() {
 	// To handle the case of Cardinality returning +Inf, track the number of
 	// calls to Add and compute the minimum.
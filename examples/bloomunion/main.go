@@ -0,0 +1,88 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Bloomunion compacts Bloom filter dumps into a single dump of their union.
+//
+// It streams through its inputs, so its memory use is small even for
+// hundreds of large filters.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/greatroar/blobloom"
+)
+
+func main() {
+	comment := flag.String("c", "", "comment for the output `dump`")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: bloomunion [-c comment] output input...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	output, inputs := flag.Arg(0), flag.Args()[1:]
+	if err := union(output, inputs, *comment); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func union(output string, inputs []string, comment string) error {
+	rs := make([]io.Reader, len(inputs))
+	for i, name := range inputs {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		rs[i] = bufio.NewReaderSize(f, 64<<10)
+	}
+
+	// Write to a temporary file, so we don't leave a partial output.
+	tmp, err := ioutil.TempFile(filepath.Dir(output), ".bloomunion*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriterSize(tmp, 64<<10)
+	_, err = blobloom.UnionDumps(w, comment, rs...)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), output)
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "sync/atomic"
+
+// Fold returns a copy of f, folded down to nbits bits.
+//
+// The number of bits is rounded up to a multiple of BlockBits, like in New,
+// and the resulting number of blocks must divide the number of blocks in f.
+// Fold panics otherwise. The folded filter uses the same number of hashes
+// as f and has a higher false positive rate.
+//
+// Keys that were added to f are reported by Has on the folded filter,
+// so Fold can bring a large filter down to the size of a smaller one
+// for the purpose of UnionFold.
+func (f *Filter) Fold(nbits uint64) *Filter {
+	nbits, _ = fixBitsAndHashes(nbits, f.k)
+	nblocks := int(nbits / BlockBits)
+	if len(f.b)%nblocks != 0 {
+		panic("number of bits does not divide that of Bloom filter")
+	}
+
+	g := &Filter{b: make([]block, nblocks), k: f.k, seed: f.seed, probeSeed: f.probeSeed, probing: f.probing}
+	g.unionFolded(f)
+	return g
+}
+
+// Fold returns a copy of f, folded down to nbits bits, as a Filter.
+// See Filter.Fold for details.
+//
+// If other goroutines are concurrently adding keys,
+// their additions may or may not be reflected in the copy.
+func (f *SyncFilter) Fold(nbits uint64) *Filter {
+	nbits, _ = fixBitsAndHashes(nbits, f.k)
+	nblocks := int(nbits / BlockBits)
+	if len(f.b)%nblocks != 0 {
+		panic("number of bits does not divide that of Bloom filter")
+	}
+
+	g := &Filter{b: make([]block, nblocks), k: f.k, seed: f.seed, probeSeed: f.probeSeed, probing: f.probing}
+	m := len(f.b) / nblocks
+	for i := range f.b {
+		p, q := &g.b[i/m], &f.b[i]
+		for j := range p {
+			p[j] |= atomic.LoadUint32(&q[j])
+		}
+	}
+	return g
+}
+
+// Grow replaces the contents of f by a larger filter of newNBits bits,
+// rounded up as in New, with the same number of hashes and seed.
+//
+// A Bloom filter cannot enumerate its keys, so Grow calls reAdd, which
+// must pass the hash of every key that should be in the grown filter to
+// add, e.g., by scanning the backing store whose keys f indexes. Keys are
+// then reported by Has only if reAdd passes them.
+//
+// Grow panics if newNBits is less than the number of bits in f. To shrink
+// a filter without a source of keys, use Fold.
+//
+// Grow is the way out of a saturated filter; see SetSaturationFunc.
+// The saturation function is kept and re-armed.
+func (f *Filter) Grow(newNBits uint64, reAdd func(add func(hash uint64))) {
+	newNBits, _ = fixBitsAndHashes(newNBits, f.k)
+	if newNBits < f.NumBits() {
+		panic("Grow cannot shrink a Bloom filter")
+	}
+
+	g := &Filter{b: make([]block, newNBits/BlockBits), k: f.k, seed: f.seed, probeSeed: f.probeSeed, probing: f.probing}
+	reAdd(g.Add)
+	f.b = g.b
+	if f.sat != nil {
+		f.sat.reset()
+	}
+}
+
+// UnionFold sets f to the union of f and g, where g may be larger than f.
+// It is equivalent to, but faster than, f.Union(g.Fold(f.NumBits())).
+//
+// UnionFold panics when the number of blocks in f does not divide that in g,
+// or when f and g do not have the same number of hash functions and seed. Both
+// Filters must be using the same hash function(s), but UnionFold cannot
+// check this.
+//
+// UnionFold allows merging filters that were created with different
+// capacities, by folding the larger ones into the smallest.
+func (f *Filter) UnionFold(g *Filter) {
+	if len(g.b)%len(f.b) != 0 {
+		panic("number of bits does not divide that of Bloom filter")
+	}
+	if f.k != g.k {
+		panic("Bloom filters do not have the same number of hash functions")
+	}
+	if f.probeSeed != g.probeSeed {
+		panic("Bloom filters do not have the same seed")
+	}
+	if f.probing != g.probing {
+		panic("Bloom filters do not have the same probing scheme")
+	}
+	f.unionFolded(g)
+}
+
+// unionFolded ORs each run of len(g.b)/len(f.b) consecutive blocks of g
+// into a single block of f.
+//
+// This works because getblock uses reducerange: for a filter with n blocks
+// and a larger one with m*n blocks, a hash that selects block i in the
+// larger filter selects block i/m in the smaller, and the bits within
+// a block do not depend on the number of blocks.
+func (f *Filter) unionFolded(g *Filter) {
+	if len(f.b) == len(g.b) {
+		f.union(g)
+		return
+	}
+
+	m := len(g.b) / len(f.b)
+	for i := range g.b {
+		p, q := &f.b[i/m], &g.b[i]
+		for j := range p {
+			p[j] |= q[j]
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFold(t *testing.T) {
+	const nhashes = 6
+
+	keys := randomU64(3000, 0xf01d)
+	big := New(12*BlockBits, nhashes)
+	for _, h := range keys[:2000] {
+		big.Add(h)
+	}
+
+	for _, nblocks := range []uint64{1, 2, 3, 4, 6, 12} {
+		folded := big.Fold(nblocks * BlockBits)
+		assert.EqualValues(t, nblocks*BlockBits, folded.NumBits())
+		assert.Equal(t, nhashes, folded.NumHashes())
+
+		// Folding must give the same filter as adding the keys directly.
+		want := New(nblocks*BlockBits, nhashes)
+		for _, h := range keys[:2000] {
+			want.Add(h)
+		}
+		assert.True(t, want.Equals(folded), "%d blocks", nblocks)
+
+		small := New(nblocks*BlockBits, nhashes)
+		for _, h := range keys[2000:] {
+			small.Add(h)
+			want.Add(h)
+		}
+		small.UnionFold(big)
+		assert.True(t, want.Equals(small), "%d blocks", nblocks)
+		for _, h := range keys {
+			assert.True(t, small.Has(h))
+		}
+	}
+
+	assert.Panics(t, func() { big.Fold(5 * BlockBits) })
+	assert.Panics(t, func() { New(5*BlockBits, nhashes).UnionFold(big) })
+	assert.Panics(t, func() { New(BlockBits, nhashes+1).UnionFold(big) })
+}
+
+func TestGrow(t *testing.T) {
+	const nhashes = 5
+
+	keys := randomU64(2000, 0x960)
+	f := New(2*BlockBits, nhashes)
+	f.SetSeed(42)
+	for _, h := range keys {
+		f.Add(h)
+	}
+
+	var saturated int
+	f.SetSaturationFunc(.5, func() { saturated++ })
+	assert.True(t, f.Saturated(.5))
+
+	f.Grow(100*BlockBits, func(add func(uint64)) {
+		for _, h := range keys {
+			add(h)
+		}
+	})
+	assert.EqualValues(t, 100*BlockBits, f.NumBits())
+	assert.Equal(t, nhashes, f.NumHashes())
+
+	want := New(100*BlockBits, nhashes)
+	want.SetSeed(42)
+	for _, h := range keys {
+		want.Add(h)
+	}
+	assert.True(t, want.Equals(f))
+	assert.Zero(t, saturated)
+	assert.False(t, f.Saturated(.5))
+
+	assert.Panics(t, func() { f.Grow(BlockBits, func(func(uint64)) {}) })
+}
+
+func TestSyncFilterFold(t *testing.T) {
+	keys := randomU64(1000, 0x5f0)
+	f := NewSync(8*BlockBits, 4)
+	for _, h := range keys {
+		f.Add(h)
+	}
+
+	want := New(2*BlockBits, 4)
+	for _, h := range keys {
+		want.Add(h)
+	}
+	assert.True(t, want.Equals(f.Fold(2*BlockBits)))
+	assert.Panics(t, func() { f.Fold(3 * BlockBits) })
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.16
+// +build go1.16
+
+package blobloom
+
+import (
+	"bufio"
+	"io/fs"
+)
+
+// LoadFS loads a Filter from the file name in fsys, which must be in
+// the format produced by Dump. It also returns the dump's comment.
+//
+// LoadFS can read filters from embedded files (embed.FS), zip archives
+// (archive/zip.Reader) and other file systems without temporary files.
+func LoadFS(fsys fs.FS, name string) (f *Filter, comment string, err error) {
+	err = loadFS(fsys, name, func(l *Loader) (err error) {
+		f, err = l.Load(nil)
+		comment = l.Comment
+		return err
+	})
+	return f, comment, err
+}
+
+// LoadSyncFS is like LoadFS, but loads a SyncFilter.
+func LoadSyncFS(fsys fs.FS, name string) (f *SyncFilter, comment string, err error) {
+	err = loadFS(fsys, name, func(l *Loader) (err error) {
+		f, err = l.LoadSync(nil)
+		comment = l.Comment
+		return err
+	})
+	return f, comment, err
+}
+
+func loadFS(fsys fs.FS, name string, load func(*Loader) error) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	l, err := NewLoader(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	return load(l)
+}
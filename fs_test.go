@@ -0,0 +1,58 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.16
+// +build go1.16
+
+package blobloom
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFS(t *testing.T) {
+	f := New(10*BlockBits, 4)
+	for i := uint64(0); i < 100; i++ {
+		f.Add(i * 0x9e3779b97f4a7c15)
+	}
+	var buf bytes.Buffer
+	_, err := Dump(&buf, f, "from fs")
+	require.NoError(t, err)
+
+	fsys := fstest.MapFS{
+		"dir/filter.blobloom": {Data: buf.Bytes()},
+		"garbage":             {Data: []byte("garbage")},
+	}
+
+	g, comment, err := LoadFS(fsys, "dir/filter.blobloom")
+	require.NoError(t, err)
+	assert.Equal(t, "from fs", comment)
+	assert.True(t, f.Equals(g))
+
+	s, comment, err := LoadSyncFS(fsys, "dir/filter.blobloom")
+	require.NoError(t, err)
+	assert.Equal(t, "from fs", comment)
+	assert.Equal(t, f.NumBits(), s.NumBits())
+	assert.True(t, s.Has(0x9e3779b97f4a7c15))
+
+	_, _, err = LoadFS(fsys, "garbage")
+	assert.Error(t, err)
+	_, _, err = LoadFS(fsys, "nonexistent")
+	assert.Error(t, err)
+}
@@ -0,0 +1,377 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fuse implements binary fuse filters, static approximate sets
+// that are much smaller than Bloom filters.
+//
+// A binary fuse filter is built once from the complete set of keys and
+// cannot be modified afterwards. In exchange, it takes about 9 bits per
+// key at a false positive rate of 1/256 = 0.39%, where a blocked Bloom
+// filter needs over 12. Each lookup reads three bytes of memory.
+//
+// See Graf and Lemire, Binary Fuse Filters: Fast and Smaller Than Xor
+// Filters, https://doi.org/10.1145/3510449. The construction here follows
+// their reference implementation of 8-bit filters with three hashes.
+//
+// Building a Filter holds all keys in memory. For key sets that do not fit,
+// a StreamBuilder partitions the keys on disk and builds a Partitioned
+// filter one partition at a time.
+//
+// Like package blobloom, this package takes keys as 64-bit hash values
+// supplied by the client.
+package fuse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	arity      = 3
+	maxRetries = 100
+)
+
+// A Filter is a binary fuse filter with 8-bit fingerprints.
+type Filter struct {
+	seed          uint64
+	n             uint32 // Number of distinct keys.
+	segmentLength uint32
+	segmentCount  uint32
+	fingerprints  []uint8
+}
+
+// A Builder collects the keys for a Filter.
+// The zero value is an empty Builder, ready to use.
+type Builder struct {
+	keys []uint64
+}
+
+// Add adds a key with hash value h to the set of keys of b.
+func (b *Builder) Add(h uint64) { b.keys = append(b.keys, h) }
+
+// Len returns the number of keys added to b, including duplicates.
+func (b *Builder) Len() int { return len(b.keys) }
+
+// Reset removes all keys from b.
+func (b *Builder) Reset() { b.keys = b.keys[:0] }
+
+// Build constructs a Filter of the keys added to b. Duplicate keys are
+// allowed. b can be used again afterwards; its keys are kept, but
+// duplicates are removed.
+//
+// Build fails only for more than 2^32-1 keys or, with negligible
+// probability, when the construction does not succeed for any of
+// a number of random seeds.
+func (b *Builder) Build() (*Filter, error) {
+	b.dedup()
+	size := len(b.keys)
+	if uint64(size) > math.MaxUint32-1 {
+		return nil, fmt.Errorf("fuse: %d keys is too many", size)
+	}
+
+	f := newFilter(uint32(size))
+	capacity := len(f.fingerprints)
+	var (
+		alone        = make([]uint32, capacity)
+		t2count      = make([]uint8, capacity)
+		t2hash       = make([]uint64, capacity)
+		reverseH     = make([]uint8, size)
+		reverseOrder = make([]uint64, size+1)
+
+		blockBits = 1
+	)
+	for 1<<blockBits < f.segmentCount {
+		blockBits++
+	}
+	startPos := make([]uint, 1<<blockBits)
+
+	rng := uint64(0x726b2b9d438b9d4d)
+	var stacksize int
+	for retry := 0; ; retry++ {
+		if retry == maxRetries {
+			return nil, errors.New("fuse: construction failed")
+		}
+		if retry > 0 {
+			for i := range t2count {
+				t2count[i], t2hash[i] = 0, 0
+			}
+			for i := range reverseOrder {
+				reverseOrder[i] = 0
+			}
+		}
+		f.seed = splitmix64(&rng)
+
+		// Sort the hashes roughly by segment, for locality.
+		for i := range startPos {
+			startPos[i] = uint(i) * uint(size) >> blockBits
+		}
+		reverseOrder[size] = 1 // Sentinel.
+		mask := uint64(1)<<blockBits - 1
+		for _, key := range b.keys {
+			h := f.hash(key)
+			s := h >> (64 - blockBits)
+			for reverseOrder[startPos[s]] != 0 {
+				s = (s + 1) & mask
+			}
+			reverseOrder[startPos[s]] = h
+			startPos[s]++
+		}
+
+		failed := false
+		for _, h := range reverseOrder[:size] {
+			i0, i1, i2 := f.positions(h)
+			t2count[i0] += 4
+			t2hash[i0] ^= h
+			t2count[i1] += 4
+			t2count[i1] ^= 1
+			t2hash[i1] ^= h
+			t2count[i2] += 4
+			t2count[i2] ^= 2
+			t2hash[i2] ^= h
+
+			// Counts of 64 or more overflow.
+			if t2count[i0] < 4 || t2count[i1] < 4 || t2count[i2] < 4 {
+				failed = true
+			}
+		}
+		if failed {
+			continue
+		}
+
+		// Peel: repeatedly remove a key that is alone in one of its
+		// positions and record that position.
+		qsize := 0
+		for i := range t2count {
+			alone[qsize] = uint32(i)
+			if t2count[i]>>2 == 1 {
+				qsize++
+			}
+		}
+		stacksize = 0
+		for qsize > 0 {
+			qsize--
+			i := alone[qsize]
+			if t2count[i]>>2 != 1 {
+				continue
+			}
+			h := t2hash[i]
+			found := t2count[i] & 3
+			reverseH[stacksize] = found
+			reverseOrder[stacksize] = h
+			stacksize++
+
+			i0, i1, i2 := f.positions(h)
+			pos := [5]uint32{i0, i1, i2, i0, i1}
+			for j := uint8(1); j <= 2; j++ {
+				other := pos[found+j]
+				alone[qsize] = other
+				if t2count[other]>>2 == 2 {
+					qsize++
+				}
+				t2count[other] -= 4
+				t2count[other] ^= mod3(found + j)
+				t2hash[other] ^= h
+			}
+		}
+		if stacksize == size {
+			break
+		}
+	}
+
+	f.n = uint32(size)
+	for i := stacksize - 1; i >= 0; i-- {
+		h := reverseOrder[i]
+		i0, i1, i2 := f.positions(h)
+		pos := [5]uint32{i0, i1, i2, i0, i1}
+		found := reverseH[i]
+		f.fingerprints[pos[found]] = fingerprint(h) ^
+			f.fingerprints[pos[found+1]] ^ f.fingerprints[pos[found+2]]
+	}
+	return f, nil
+}
+
+// dedup sorts b.keys and removes duplicates.
+func (b *Builder) dedup() {
+	keys := b.keys
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	n := 0
+	for i, h := range keys {
+		if i == 0 || h != keys[n-1] {
+			keys[n] = h
+			n++
+		}
+	}
+	b.keys = keys[:n]
+}
+
+// newFilter returns an empty Filter sized for n keys.
+func newFilter(n uint32) *Filter {
+	segmentLength := uint32(4)
+	if n > 0 {
+		segmentLength = 1 << int(math.Floor(math.Log(float64(n))/math.Log(3.33)+2.25))
+	}
+	if segmentLength > 1<<18 {
+		segmentLength = 1 << 18
+	}
+
+	var capacity uint32
+	if n > 1 {
+		sizeFactor := math.Max(1.125, .875+.25*math.Log(1e6)/math.Log(float64(n)))
+		capacity = uint32(math.Round(float64(n) * sizeFactor))
+	}
+	segmentCount := (capacity + segmentLength - 1) / segmentLength
+	if segmentCount <= arity-1 {
+		segmentCount = 1
+	} else {
+		segmentCount -= arity - 1
+	}
+	length := (segmentCount + arity - 1) * segmentLength
+
+	return &Filter{
+		segmentLength: segmentLength,
+		segmentCount:  segmentCount,
+		fingerprints:  make([]uint8, length),
+	}
+}
+
+// Has reports whether a key with hash value h was in the set that f was
+// built from. It may return a false positive, with probability 1/256.
+func (f *Filter) Has(h uint64) bool {
+	h = f.hash(h)
+	i0, i1, i2 := f.positions(h)
+	fp := fingerprint(h)
+	return fp^f.fingerprints[i0]^f.fingerprints[i1]^f.fingerprints[i2] == 0
+}
+
+// Len returns the number of distinct keys that f was built from.
+func (f *Filter) Len() int { return int(f.n) }
+
+// Size returns the size of f's fingerprint array in bytes.
+func (f *Filter) Size() int { return len(f.fingerprints) }
+
+func (f *Filter) hash(key uint64) uint64 { return murmur64(key + f.seed) }
+
+// positions returns the three positions of the fingerprint for hash h.
+// The positions lie in three consecutive segments.
+func (f *Filter) positions(h uint64) (uint32, uint32, uint32) {
+	hi, _ := bits.Mul64(h, uint64(f.segmentCount*f.segmentLength))
+	mask := f.segmentLength - 1
+	i0 := uint32(hi)
+	i1 := i0 + f.segmentLength
+	i2 := i1 + f.segmentLength
+	i1 ^= uint32(h>>18) & mask
+	i2 ^= uint32(h) & mask
+	return i0, i1, i2
+}
+
+func fingerprint(h uint64) uint8 { return uint8(h ^ h>>32) }
+
+func mod3(x uint8) uint8 {
+	if x > 2 {
+		x -= 3
+	}
+	return x
+}
+
+func murmur64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func splitmix64(seed *uint64) uint64 {
+	*seed += 0x9e3779b97f4a7c15
+	z := *seed
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+// Wire format of a Filter, all integers little-endian:
+//
+//	magic "BLBF", version byte (0), three zero bytes
+//	seed: 64 bits
+//	number of keys: 32 bits
+//	segment length: 32 bits, a power of two
+//	segment count: 32 bits
+//	the fingerprints, (segment count + 2) * segment length bytes
+const (
+	magic      = "BLBF"
+	headerSize = 8 + 8 + 3*4
+)
+
+// WriteTo writes f to w in a binary format that Read accepts.
+func (f *Filter) WriteTo(w io.Writer) (int64, error) {
+	var hdr [headerSize]byte
+	copy(hdr[:], magic)
+	binary.LittleEndian.PutUint64(hdr[8:], f.seed)
+	binary.LittleEndian.PutUint32(hdr[16:], f.n)
+	binary.LittleEndian.PutUint32(hdr[20:], f.segmentLength)
+	binary.LittleEndian.PutUint32(hdr[24:], f.segmentCount)
+
+	k, err := w.Write(hdr[:])
+	n := int64(k)
+	if err == nil {
+		k, err = w.Write(f.fingerprints)
+		n += int64(k)
+	}
+	return n, err
+}
+
+// Read reads a Filter written by WriteTo from r.
+func Read(r io.Reader) (*Filter, error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	f := &Filter{
+		seed:          binary.LittleEndian.Uint64(hdr[8:]),
+		n:             binary.LittleEndian.Uint32(hdr[16:]),
+		segmentLength: binary.LittleEndian.Uint32(hdr[20:]),
+		segmentCount:  binary.LittleEndian.Uint32(hdr[24:]),
+	}
+	length := (uint64(f.segmentCount) + arity - 1) * uint64(f.segmentLength)
+
+	switch {
+	case string(hdr[:4]) != magic:
+		return nil, errors.New("fuse: not a binary fuse filter")
+	case hdr[4] != 0:
+		return nil, errors.New("fuse: unsupported version")
+	case f.segmentLength == 0 || f.segmentLength&(f.segmentLength-1) != 0 ||
+		f.segmentCount == 0 || length > math.MaxUint32:
+		return nil, errors.New("fuse: invalid filter dimensions")
+	}
+
+	// The header is not trusted: the buffer grows as data arrives, so a
+	// corrupt or truncated filter cannot make us allocate much more memory
+	// than it contains.
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	f.fingerprints = buf.Bytes()
+	return f, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashes(n int, seed int64) []uint64 {
+	r := rand.New(rand.NewSource(seed))
+	hs := make([]uint64, n)
+	for i := range hs {
+		hs[i] = r.Uint64()
+	}
+	return hs
+}
+
+func build(t *testing.T, keys []uint64) *Filter {
+	t.Helper()
+	var b Builder
+	for _, h := range keys {
+		b.Add(h)
+	}
+	f, err := b.Build()
+	require.NoError(t, err)
+	return f
+}
+
+func TestFilter(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 10, 1000, 100000} {
+		keys := hashes(n, int64(n))
+		f := build(t, keys)
+		assert.Equal(t, n, f.Len())
+
+		for _, h := range keys {
+			assert.True(t, f.Has(h))
+		}
+
+		if n < 100000 {
+			continue
+		}
+		bitsPerKey := 8 * float64(f.Size()) / float64(n)
+		assert.Less(t, bitsPerKey, 10.)
+
+		var fp int
+		const m = 1000000
+		for _, h := range hashes(m, 0xfa15e) {
+			if f.Has(h) {
+				fp++
+			}
+		}
+		assert.InDelta(t, 1./256, float64(fp)/m, .001)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	keys := hashes(5000, 0xd0b)
+	keys = append(keys, keys[:1000]...)
+	keys = append(keys, keys[:10]...)
+
+	f := build(t, keys)
+	assert.Equal(t, 5000, f.Len())
+	for _, h := range keys {
+		assert.True(t, f.Has(h))
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	keys := hashes(10000, 0x5e71a1)
+	f := build(t, keys)
+
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, headerSize+f.Size(), n)
+	p := buf.Bytes()
+
+	g, err := Read(bytes.NewReader(p))
+	require.NoError(t, err)
+	assert.Equal(t, f, g)
+
+	_, err = Read(bytes.NewReader(p[:len(p)-1]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	bad := append([]byte(nil), p...)
+	bad[20] = 3 // Segment length not a power of two.
+	_, err = Read(bytes.NewReader(bad))
+	assert.Error(t, err)
+
+	// A truncated filter with huge dimensions in its header.
+	huge := append([]byte(nil), p[:headerSize+100]...)
+	binary.LittleEndian.PutUint32(huge[20:], 1<<20) // Segment length.
+	binary.LittleEndian.PutUint32(huge[24:], 1<<11) // Segment count.
+	_, err = Read(bytes.NewReader(huge))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -0,0 +1,278 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/bits"
+	"os"
+)
+
+// A Partitioned is a binary fuse filter split into independent Filters,
+// each for the keys whose hash values fall in one of a number of equal
+// ranges. It is built by a StreamBuilder, from more keys than fit in
+// memory.
+type Partitioned struct {
+	parts []*Filter
+}
+
+// partition returns the index of the partition for hash value h,
+// among n partitions.
+func partition(h uint64, n int) int {
+	hi, _ := bits.Mul64(h, uint64(n))
+	return int(hi)
+}
+
+// Has reports whether a key with hash value h was in the set that p was
+// built from. It may return a false positive, with probability 1/256.
+func (p *Partitioned) Has(h uint64) bool {
+	return p.parts[partition(h, len(p.parts))].Has(h)
+}
+
+// Len returns the number of distinct keys that p was built from.
+func (p *Partitioned) Len() int {
+	n := 0
+	for _, f := range p.parts {
+		n += f.Len()
+	}
+	return n
+}
+
+// NumPartitions returns the number of partitions of p.
+func (p *Partitioned) NumPartitions() int { return len(p.parts) }
+
+// Size returns the total size of p's fingerprint arrays in bytes.
+func (p *Partitioned) Size() int {
+	n := 0
+	for _, f := range p.parts {
+		n += f.Size()
+	}
+	return n
+}
+
+// Wire format of a Partitioned, all integers little-endian:
+//
+//	magic "BLBP", version byte (0), three zero bytes
+//	number of partitions: 32 bits
+//	the partitions, each in the format of Filter.WriteTo
+const (
+	partitionedMagic      = "BLBP"
+	partitionedHeaderSize = 8 + 4
+)
+
+func writePartitionedHeader(w io.Writer, nparts int) (int64, error) {
+	var hdr [partitionedHeaderSize]byte
+	copy(hdr[:], partitionedMagic)
+	binary.LittleEndian.PutUint32(hdr[8:], uint32(nparts))
+	n, err := w.Write(hdr[:])
+	return int64(n), err
+}
+
+// WriteTo writes p to w in a binary format that ReadPartitioned accepts.
+func (p *Partitioned) WriteTo(w io.Writer) (int64, error) {
+	n, err := writePartitionedHeader(w, len(p.parts))
+	for i := 0; err == nil && i < len(p.parts); i++ {
+		var m int64
+		m, err = p.parts[i].WriteTo(w)
+		n += m
+	}
+	return n, err
+}
+
+// ReadPartitioned reads a Partitioned written by WriteTo or
+// StreamBuilder.BuildTo from r.
+func ReadPartitioned(r io.Reader) (*Partitioned, error) {
+	var hdr [partitionedHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(hdr[8:])
+	switch {
+	case string(hdr[:4]) != partitionedMagic:
+		return nil, errors.New("fuse: not a partitioned binary fuse filter")
+	case hdr[4] != 0:
+		return nil, errors.New("fuse: unsupported version")
+	case n == 0:
+		return nil, errors.New("fuse: no partitions")
+	}
+
+	// Grow p.parts as partitions are read, rather than trusting n.
+	p := &Partitioned{}
+	for i := uint32(0); i < n; i++ {
+		f, err := Read(r)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.parts = append(p.parts, f)
+	}
+	return p, nil
+}
+
+// A StreamBuilder builds a Partitioned filter from a set of keys that
+// need not fit in memory. It spreads the keys over temporary files, one per
+// partition, by their hash values. Building reads back one partition at
+// a time and builds a Filter for it, so only the keys of one partition
+// are held in memory.
+//
+// The number of partitions should be chosen so that building a Filter for
+// a single partition fits in memory. This takes about 40 bytes per key.
+// While keys are added, each partition keeps a file open.
+type StreamBuilder struct {
+	files []*os.File
+	bufs  []*bufio.Writer
+}
+
+// NewStreamBuilder returns a StreamBuilder for a filter with npartitions
+// partitions that keeps its temporary files in dir. If dir is empty,
+// the default directory for temporary files is used; see os.TempDir.
+//
+// The temporary files are removed by Close.
+func NewStreamBuilder(dir string, npartitions int) (*StreamBuilder, error) {
+	if npartitions < 1 || uint64(npartitions) > math.MaxUint32 {
+		return nil, fmt.Errorf("fuse: invalid number of partitions %d", npartitions)
+	}
+
+	b := &StreamBuilder{}
+	for i := 0; i < npartitions; i++ {
+		f, err := ioutil.TempFile(dir, "fuse-partition-*")
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+		b.files = append(b.files, f)
+		b.bufs = append(b.bufs, bufio.NewWriter(f))
+	}
+	return b, nil
+}
+
+// Add adds a key with hash value h to the set of keys of b.
+func (b *StreamBuilder) Add(h uint64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], h)
+	_, err := b.bufs[partition(h, len(b.bufs))].Write(buf[:])
+	return err
+}
+
+// ReadFrom adds the keys in r to b. r must hold hash values as 64-bit
+// little-endian integers; ReadFrom reads them until EOF.
+func (b *StreamBuilder) ReadFrom(r io.Reader) (n int64, err error) {
+	br := bufio.NewReader(r)
+	var buf [8]byte
+	for {
+		k, err := io.ReadFull(br, buf[:])
+		n += int64(k)
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		if err = b.Add(binary.LittleEndian.Uint64(buf[:])); err != nil {
+			return n, err
+		}
+	}
+}
+
+// Build constructs a Partitioned filter of the keys added to b. Duplicate
+// keys are allowed. b can be used again afterwards.
+//
+// The Partitioned filter is held in memory in its entirety.
+// To construct filters larger than that, use BuildTo.
+func (b *StreamBuilder) Build() (*Partitioned, error) {
+	p := &Partitioned{parts: make([]*Filter, 0, len(b.files))}
+	err := b.build(func(f *Filter) error {
+		p.parts = append(p.parts, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// BuildTo constructs a Partitioned filter of the keys added to b and writes
+// it to w, in the format of Partitioned.WriteTo. Each partition is written
+// as soon as it is built, so only one is held in memory at a time.
+// b can be used again afterwards.
+func (b *StreamBuilder) BuildTo(w io.Writer) (int64, error) {
+	n, err := writePartitionedHeader(w, len(b.files))
+	if err == nil {
+		err = b.build(func(f *Filter) error {
+			m, err := f.WriteTo(w)
+			n += m
+			return err
+		})
+	}
+	return n, err
+}
+
+// build builds a Filter for each partition in turn and passes it to emit.
+func (b *StreamBuilder) build(emit func(*Filter) error) error {
+	var keys Builder
+	for i, file := range b.files {
+		if err := b.bufs[i].Flush(); err != nil {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		// Reading to the end leaves the file offset where Add continues.
+		keys.Reset()
+		r := bufio.NewReader(file)
+		var buf [8]byte
+		for {
+			_, err := io.ReadFull(r, buf[:])
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			keys.Add(binary.LittleEndian.Uint64(buf[:]))
+		}
+
+		f, err := keys.Build()
+		if err == nil {
+			err = emit(f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close removes the temporary files of b.
+func (b *StreamBuilder) Close() error {
+	var err error
+	for _, f := range b.files {
+		if e := f.Close(); err == nil {
+			err = e
+		}
+		if e := os.Remove(f.Name()); err == nil {
+			err = e
+		}
+	}
+	b.files, b.bufs = nil, nil
+	return err
+}
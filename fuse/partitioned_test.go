@@ -0,0 +1,151 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fuse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamBuilder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuse-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	const n = 100000
+	keys := hashes(n, 51)
+
+	b, err := NewStreamBuilder(dir, 8)
+	require.NoError(t, err)
+	defer b.Close()
+
+	// Half the keys through Add, half through ReadFrom, with a duplicate.
+	for _, h := range keys[:n/2] {
+		require.NoError(t, b.Add(h))
+	}
+	var stream bytes.Buffer
+	for _, h := range keys[n/2-1:] {
+		binary.Write(&stream, binary.LittleEndian, h)
+	}
+	m, err := b.ReadFrom(&stream)
+	require.NoError(t, err)
+	assert.EqualValues(t, 8*(n/2+1), m)
+
+	p, err := b.Build()
+	require.NoError(t, err)
+	assert.Equal(t, 8, p.NumPartitions())
+	assert.Equal(t, n, p.Len())
+	for _, h := range keys {
+		assert.True(t, p.Has(h))
+	}
+
+	fp := 0
+	for _, h := range hashes(n, 52) {
+		if p.Has(h) {
+			fp++
+		}
+	}
+	assert.Less(t, fp, 2*n/256)
+
+	var built, written bytes.Buffer
+	m, err = b.BuildTo(&built)
+	require.NoError(t, err)
+	assert.EqualValues(t, built.Len(), m)
+	m, err = p.WriteTo(&written)
+	require.NoError(t, err)
+	assert.EqualValues(t, written.Len(), m)
+	assert.Equal(t, written.Bytes(), built.Bytes())
+
+	q, err := ReadPartitioned(&built)
+	require.NoError(t, err)
+	assert.Equal(t, p, q)
+
+	// More keys can be added after building.
+	extra := hashes(100, 53)
+	for _, h := range extra {
+		require.NoError(t, b.Add(h))
+	}
+	p, err = b.Build()
+	require.NoError(t, err)
+	assert.Equal(t, n+len(extra), p.Len())
+	for _, h := range append(keys, extra...) {
+		assert.True(t, p.Has(h))
+	}
+
+	require.NoError(t, b.Close())
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestNewStreamBuilderInvalid(t *testing.T) {
+	_, err := NewStreamBuilder("", 0)
+	assert.Error(t, err)
+	_, err = NewStreamBuilder("", -1)
+	assert.Error(t, err)
+}
+
+func TestStreamBuilderReadFromPartialKey(t *testing.T) {
+	b, err := NewStreamBuilder("", 2)
+	require.NoError(t, err)
+	defer b.Close()
+
+	_, err = b.ReadFrom(bytes.NewReader(make([]byte, 12)))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestReadPartitionedInvalid(t *testing.T) {
+	b, err := NewStreamBuilder("", 3)
+	require.NoError(t, err)
+	defer b.Close()
+	for _, h := range hashes(1000, 54) {
+		b.Add(h)
+	}
+	var buf bytes.Buffer
+	_, err = b.BuildTo(&buf)
+	require.NoError(t, err)
+	data := buf.Bytes()
+
+	for _, n := range []int{0, 5, partitionedHeaderSize, partitionedHeaderSize + 1, len(data) - 1} {
+		_, err = ReadPartitioned(bytes.NewReader(data[:n]))
+		assert.Error(t, err, "truncated to %d bytes", n)
+	}
+	_, err = ReadPartitioned(bytes.NewReader(data[:partitionedHeaderSize]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// A huge partition count in a truncated file must not be trusted.
+	huge := append([]byte(nil), data[:partitionedHeaderSize]...)
+	binary.LittleEndian.PutUint32(huge[8:], 1<<31)
+	_, err = ReadPartitioned(bytes.NewReader(huge))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	for _, patch := range []struct{ off, val int }{{0, 'X'}, {4, 1}} {
+		bad := append([]byte(nil), data...)
+		bad[patch.off] = byte(patch.val)
+		_, err = ReadPartitioned(bytes.NewReader(bad))
+		assert.Error(t, err)
+	}
+	bad := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(bad[8:], 0)
+	_, err = ReadPartitioned(bytes.NewReader(bad))
+	assert.Error(t, err)
+}
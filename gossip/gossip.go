@@ -0,0 +1,272 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gossip implements compact deltas between states of a Bloom
+// filter, for peers that exchange updates in a gossip protocol.
+//
+// Peers identify filter states by a Digest. A peer that announces digest X
+// can be sent a Delta from X to the sender's current state, computed as
+// the XOR of the two states. Since filters only grow, the XOR is sparse and
+// holds exactly the bits added since X; it is encoded as gaps between set
+// bits, which is much smaller than a full dump. A History keeps recent
+// states, so a peer can compute deltas from the digests its peers announce.
+package gossip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"math/bits"
+
+	"github.com/greatroar/blobloom"
+)
+
+// A Filter is a Bloom filter with block-level access.
+// It is implemented by *blobloom.Filter and *blobloom.SyncFilter.
+type Filter interface {
+	NumBits() uint64
+	NumHashes() int
+	AppendBlock(p []byte, i int) []byte
+	UnionBlock(i int, p []byte)
+}
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// Digest returns a 64-bit digest of the contents of f.
+func Digest(f Filter) uint64 {
+	var crc uint64
+	var buf []byte
+	for i := 0; i < numBlocks(f); i++ {
+		buf = f.AppendBlock(buf[:0], i)
+		crc = crc64.Update(crc, crcTable, buf)
+	}
+	return crc
+}
+
+func numBlocks(f Filter) int { return int(f.NumBits() / blobloom.BlockBits) }
+
+// A Delta is the XOR of two states of a filter.
+type Delta struct {
+	From, To  uint64 // Digests of the states.
+	NumBits   uint64
+	NumHashes int
+
+	pos []uint64 // Positions of set bits, in increasing order.
+}
+
+// Len returns the number of bits that differ between the states.
+func (d *Delta) Len() int { return len(d.pos) }
+
+var errShape = errors.New("gossip: filters have different shapes")
+
+// Diff returns the Delta from old to cur.
+func Diff(old, cur Filter) (*Delta, error) {
+	if old.NumBits() != cur.NumBits() || old.NumHashes() != cur.NumHashes() {
+		return nil, errShape
+	}
+
+	d := &Delta{
+		From:      Digest(old),
+		To:        Digest(cur),
+		NumBits:   cur.NumBits(),
+		NumHashes: cur.NumHashes(),
+	}
+	var a, b []byte
+	for i := 0; i < numBlocks(cur); i++ {
+		a = old.AppendBlock(a[:0], i)
+		b = cur.AppendBlock(b[:0], i)
+		for j := 0; j < blobloom.BlockBytes; j += 8 {
+			x := binary.LittleEndian.Uint64(a[j:]) ^ binary.LittleEndian.Uint64(b[j:])
+			base := uint64(i)*blobloom.BlockBits + 8*uint64(j)
+			for ; x != 0; x &= x - 1 {
+				d.pos = append(d.pos, base+uint64(bits.TrailingZeros64(x)))
+			}
+		}
+	}
+	return d, nil
+}
+
+// Apply sets the bits of d in f.
+//
+// Since filters only grow, the bits of d are those added between the states
+// d.From and d.To. If f contains the state d.From, possibly with keys added
+// since, Apply makes f contain d.To as well. Apply does not require f to be
+// exactly in the state d.From.
+func (d *Delta) Apply(f Filter) error {
+	if f.NumBits() != d.NumBits || f.NumHashes() != d.NumHashes {
+		return errShape
+	}
+
+	buf := make([]byte, blobloom.BlockBytes)
+	for i := 0; i < len(d.pos); {
+		blk := d.pos[i] / blobloom.BlockBits
+		for j := range buf {
+			buf[j] = 0
+		}
+		for ; i < len(d.pos) && d.pos[i]/blobloom.BlockBits == blk; i++ {
+			bit := d.pos[i] % blobloom.BlockBits
+			buf[bit/8] |= 1 << (bit % 8)
+		}
+		f.UnionBlock(int(blk), buf)
+	}
+	return nil
+}
+
+// Merge returns the Delta from a.From to b.To, where a.To must equal b.From.
+func Merge(a, b *Delta) (*Delta, error) {
+	switch {
+	case a.NumBits != b.NumBits || a.NumHashes != b.NumHashes:
+		return nil, errShape
+	case a.To != b.From:
+		return nil, errors.New("gossip: deltas are not consecutive")
+	}
+
+	// Symmetric difference of the sorted position lists.
+	d := &Delta{From: a.From, To: b.To, NumBits: a.NumBits, NumHashes: a.NumHashes}
+	i, j := 0, 0
+	for i < len(a.pos) && j < len(b.pos) {
+		switch {
+		case a.pos[i] < b.pos[j]:
+			d.pos = append(d.pos, a.pos[i])
+			i++
+		case a.pos[i] > b.pos[j]:
+			d.pos = append(d.pos, b.pos[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	d.pos = append(d.pos, a.pos[i:]...)
+	d.pos = append(d.pos, b.pos[j:]...)
+	return d, nil
+}
+
+const (
+	magic      = "BLXD"
+	headerSize = 8 + 3*8 + 4
+)
+
+// MarshalBinary encodes d as a header followed by the gaps between set
+// bits, as unsigned varints.
+func (d *Delta) MarshalBinary() ([]byte, error) {
+	p := make([]byte, headerSize, headerSize+2*len(d.pos)+binary.MaxVarintLen64)
+	copy(p, magic)
+	binary.LittleEndian.PutUint64(p[8:], d.From)
+	binary.LittleEndian.PutUint64(p[16:], d.To)
+	binary.LittleEndian.PutUint64(p[24:], d.NumBits)
+	binary.LittleEndian.PutUint32(p[32:], uint32(d.NumHashes))
+
+	var tmp [binary.MaxVarintLen64]byte
+	p = append(p, tmp[:binary.PutUvarint(tmp[:], uint64(len(d.pos)))]...)
+	prev := uint64(0)
+	for _, pos := range d.pos {
+		p = append(p, tmp[:binary.PutUvarint(tmp[:], pos-prev)]...)
+		prev = pos + 1
+	}
+	return p, nil
+}
+
+// UnmarshalBinary decodes a Delta encoded by MarshalBinary.
+func (d *Delta) UnmarshalBinary(p []byte) error {
+	if len(p) < headerSize || string(p[:4]) != magic || p[4] != 0 {
+		return errors.New("gossip: not a delta")
+	}
+	nd := Delta{
+		From:      binary.LittleEndian.Uint64(p[8:]),
+		To:        binary.LittleEndian.Uint64(p[16:]),
+		NumBits:   binary.LittleEndian.Uint64(p[24:]),
+		NumHashes: int(binary.LittleEndian.Uint32(p[32:])),
+	}
+	p = p[headerSize:]
+
+	n, k := binary.Uvarint(p)
+	// Each position takes at least one byte.
+	if k <= 0 || n > uint64(len(p)-k) {
+		return errors.New("gossip: corrupt delta")
+	}
+	p = p[k:]
+
+	nd.pos = make([]uint64, 0, n)
+	next := uint64(0)
+	for i := uint64(0); i < n; i++ {
+		gap, k := binary.Uvarint(p)
+		if k <= 0 || gap >= nd.NumBits-next || next+gap < next {
+			return errors.New("gossip: corrupt delta")
+		}
+		p = p[k:]
+		nd.pos = append(nd.pos, next+gap)
+		next += gap + 1
+	}
+	if len(p) != 0 {
+		return fmt.Errorf("gossip: %d bytes of trailing data", len(p))
+	}
+
+	*d = nd
+	return nil
+}
+
+// A History keeps recent states of a filter, by digest.
+type History struct {
+	max    int
+	states []state // Most recent last.
+}
+
+type state struct {
+	digest uint64
+	f      *blobloom.Filter
+}
+
+// NewHistory returns a History that keeps up to max states.
+func NewHistory(max int) *History {
+	return &History{max: max}
+}
+
+// Record stores a copy of the current state of f and returns its digest.
+func (h *History) Record(f Filter) uint64 {
+	c := blobloom.New(f.NumBits(), f.NumHashes())
+	var buf []byte
+	for i := 0; i < numBlocks(f); i++ {
+		buf = f.AppendBlock(buf[:0], i)
+		c.UnionBlock(i, buf)
+	}
+	digest := Digest(c)
+
+	for i, s := range h.states {
+		if s.digest == digest {
+			h.states = append(h.states[:i], h.states[i+1:]...)
+			break
+		}
+	}
+	h.states = append(h.states, state{digest, c})
+	if len(h.states) > h.max {
+		h.states[0] = state{}
+		h.states = h.states[1:]
+	}
+	return digest
+}
+
+// Since returns the Delta from the recorded state with the given digest to
+// the current state of f. It returns false if that state is not recorded,
+// in which case the peer needs a full dump.
+func (h *History) Since(digest uint64, f Filter) (*Delta, bool, error) {
+	for _, s := range h.states {
+		if s.digest == digest {
+			d, err := Diff(s.f, f)
+			return d, err == nil, err
+		}
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gossip
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addRandom(r *rand.Rand, f Filter, n int) {
+	for i := 0; i < n; i++ {
+		h := r.Uint64()
+		switch f := f.(type) {
+		case *blobloom.Filter:
+			f.Add(h)
+		case *blobloom.SyncFilter:
+			f.Add(h)
+		}
+	}
+}
+
+func roundtrip(t *testing.T, d *Delta) *Delta {
+	t.Helper()
+	p, err := d.MarshalBinary()
+	require.NoError(t, err)
+	var out Delta
+	require.NoError(t, out.UnmarshalBinary(p))
+	assert.Equal(t, d, &out)
+	return &out
+}
+
+func TestDiffApply(t *testing.T) {
+	r := rand.New(rand.NewSource(0x6055))
+
+	a := blobloom.NewSync(1<<16, 5)
+	addRandom(r, a, 1000)
+	b := blobloom.New(1<<16, 5)
+	var buf []byte
+	for i := 0; i < (1<<16)/blobloom.BlockBits; i++ {
+		buf = a.AppendBlock(buf[:0], i)
+		b.UnionBlock(i, buf)
+	}
+	require.Equal(t, Digest(a), Digest(b))
+
+	h := NewHistory(2)
+	x := h.Record(a)
+	addRandom(r, a, 100)
+
+	d, ok, err := h.Since(x, a)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, x, d.From)
+	assert.Equal(t, Digest(a), d.To)
+	assert.NotZero(t, d.Len())
+
+	p, _ := d.MarshalBinary()
+	assert.Less(t, len(p), (1<<16)/8/4)
+
+	require.NoError(t, roundtrip(t, d).Apply(b))
+	assert.Equal(t, Digest(a), Digest(b))
+
+	_, ok, err = h.Since(x+1, a)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMerge(t *testing.T) {
+	r := rand.New(rand.NewSource(0x6055))
+
+	f := blobloom.New(1<<14, 4)
+	h := NewHistory(3)
+	x := h.Record(f)
+	addRandom(r, f, 50)
+	y := h.Record(f)
+	addRandom(r, f, 50)
+
+	d1, _, err := h.Since(x, f)
+	require.NoError(t, err)
+	d2, _, err := h.Since(y, f)
+	require.NoError(t, err)
+	assert.Greater(t, d1.Len(), d2.Len())
+
+	_, err = Merge(d1, d2)
+	assert.Error(t, err)
+
+	dxy, err := Diff(h.states[0].f, h.states[1].f)
+	require.NoError(t, err)
+	m, err := Merge(dxy, d2)
+	require.NoError(t, err)
+	assert.Equal(t, d1, m)
+}
+
+func TestUnmarshalCorrupt(t *testing.T) {
+	f := blobloom.New(1<<12, 3)
+	g := blobloom.New(1<<12, 3)
+	g.Add(0x0123456789abcdef)
+	d, err := Diff(f, g)
+	require.NoError(t, err)
+	p, _ := d.MarshalBinary()
+
+	var out Delta
+	assert.Error(t, out.UnmarshalBinary(p[:len(p)-1]))
+	assert.Error(t, out.UnmarshalBinary(append(p, 0)))
+	assert.Error(t, out.UnmarshalBinary(p[:10]))
+
+	_, err = Diff(f, blobloom.New(1<<13, 3))
+	assert.Error(t, err)
+	assert.Error(t, d.Apply(blobloom.New(1<<12, 4)))
+}
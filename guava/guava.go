@@ -0,0 +1,210 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package guava reads and writes Bloom filters in the serialized form of
+// Google Guava's com.google.common.hash.BloomFilter, so that Go programs
+// can query and produce filters shared with JVM services.
+//
+// Guava's filters are standard, unblocked Bloom filters over a bit array,
+// and their layout cannot be converted to that of a blobloom.Filter.
+// This package therefore has its own Filter type, which sets and tests
+// exactly the bits that Guava does.
+//
+// Guava hashes keys with MurmurHash3 (x64, 128 bits, seed zero) over the
+// bytes that a Funnel writes. Add and Has take those bytes: the UTF-8
+// encoding of a string for Funnels.stringFunnel(UTF_8), the eight bytes of
+// a long in little-endian order for Funnels.longFunnel(), and so on. For
+// keys that were already hashed with Sum128, use AddHash and HasHash.
+package guava
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// A Strategy is the method by which a Guava filter derives bit indices
+// from a hash. Its value is the ordinal of Guava's
+// BloomFilterStrategies enum constant.
+type Strategy uint8
+
+const (
+	// Murmur128Mitz32 is MURMUR128_MITZ_32, which uses only the low
+	// 64 bits of the hash. Filters written by Guava versions before 11.0
+	// use this strategy.
+	Murmur128Mitz32 Strategy = 0
+	// Murmur128Mitz64 is MURMUR128_MITZ_64, the strategy of current
+	// Guava versions.
+	Murmur128Mitz64 Strategy = 1
+)
+
+// A Filter is a Bloom filter that is bit-compatible with Guava's BloomFilter.
+type Filter struct {
+	strategy Strategy
+	k        uint8
+	data     []uint64
+}
+
+// New constructs a Filter for the given number of expected insertions
+// and false positive probability fpp, sized as Guava's
+// BloomFilter.create(funnel, expectedInsertions, fpp) does.
+// The filter uses the Murmur128Mitz64 strategy.
+//
+// New panics if fpp is not strictly between zero and one.
+func New(expectedInsertions uint64, fpp float64) *Filter {
+	if !(fpp > 0 && fpp < 1) {
+		panic("false positive probability must be in (0, 1)")
+	}
+	if expectedInsertions == 0 {
+		expectedInsertions = 1
+	}
+
+	n := float64(expectedInsertions)
+	nbits := uint64(-n * math.Log(fpp) / (math.Ln2 * math.Ln2))
+	if nbits == 0 {
+		nbits = 1
+	}
+	k := math.Floor(float64(nbits)/n*math.Ln2 + .5)
+	k = math.Max(1, math.Min(k, math.MaxUint8))
+
+	return &Filter{
+		strategy: Murmur128Mitz64,
+		k:        uint8(k),
+		data:     make([]uint64, (nbits+63)/64),
+	}
+}
+
+// Add inserts the key with serialized form p into f.
+func (f *Filter) Add(p []byte) { f.AddHash(Sum128(p)) }
+
+// AddHash inserts the key with 128-bit hash (lo, hi), as returned by Sum128.
+func (f *Filter) AddHash(lo, hi uint64) {
+	f.positions(lo, hi, func(i uint64) bool {
+		f.data[i/64] |= 1 << (i % 64)
+		return true
+	})
+}
+
+// Has reports whether the key with serialized form p may be in f.
+// It is equivalent to Guava's mightContain.
+func (f *Filter) Has(p []byte) bool { return f.HasHash(Sum128(p)) }
+
+// HasHash reports whether the key with 128-bit hash (lo, hi) may be in f.
+func (f *Filter) HasHash(lo, hi uint64) bool {
+	has := true
+	f.positions(lo, hi, func(i uint64) bool {
+		has = f.data[i/64]&(1<<(i%64)) != 0
+		return has
+	})
+	return has
+}
+
+// NumBits returns the size of f in bits.
+func (f *Filter) NumBits() uint64 { return 64 * uint64(len(f.data)) }
+
+// NumHashes returns the number of hash functions of f.
+func (f *Filter) NumHashes() int { return int(f.k) }
+
+// Strategy returns the strategy of f.
+func (f *Filter) Strategy() Strategy { return f.strategy }
+
+// positions calls fn with the bit indices for (lo, hi),
+// until fn returns false.
+func (f *Filter) positions(lo, hi uint64, fn func(uint64) bool) {
+	nbits := f.NumBits()
+
+	if f.strategy == Murmur128Mitz32 {
+		h1, h2 := int32(lo), int32(lo>>32)
+		for i := int32(1); i <= int32(f.k); i++ {
+			h := h1 + i*h2
+			if h < 0 {
+				h = ^h
+			}
+			if !fn(uint64(h) % nbits) {
+				return
+			}
+		}
+		return
+	}
+
+	h := lo
+	for i := 0; i < int(f.k); i++ {
+		if !fn((h &^ (1 << 63)) % nbits) {
+			return
+		}
+		h += hi
+	}
+}
+
+// Wire format of a Filter, as written by Guava's BloomFilter.writeTo,
+// all integers big-endian:
+//
+//	strategy ordinal: 8 bits
+//	number of hash functions: 8 bits, unsigned
+//	number of 64-bit words in the bit array: 32 bits, signed
+//	the words of the bit array
+const headerSize = 1 + 1 + 4
+
+// WriteTo writes f to w in the format of Guava's BloomFilter.writeTo.
+// Guava's BloomFilter.readFrom accepts the result.
+func (f *Filter) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, headerSize+8*len(f.data))
+	buf[0] = byte(f.strategy)
+	buf[1] = f.k
+	binary.BigEndian.PutUint32(buf[2:], uint32(len(f.data)))
+	for i, x := range f.data {
+		binary.BigEndian.PutUint64(buf[headerSize+8*i:], x)
+	}
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// Read reads a Filter from r, in the format of Guava's BloomFilter.writeTo.
+func Read(r io.Reader) (*Filter, error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	f := &Filter{strategy: Strategy(hdr[0]), k: hdr[1]}
+	length := int32(binary.BigEndian.Uint32(hdr[2:]))
+
+	switch {
+	case f.strategy > Murmur128Mitz64:
+		return nil, errors.New("guava: unknown strategy")
+	case f.k == 0:
+		return nil, errors.New("guava: invalid number of hash functions")
+	case length <= 0 || uint64(length) > uint64(^uint(0)>>1)/8:
+		return nil, errors.New("guava: invalid filter size")
+	}
+
+	// The header is not trusted: the buffer grows as data arrives, so a
+	// corrupt or truncated filter cannot make us allocate much more memory
+	// than it contains.
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, 8*int64(length)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	p := buf.Bytes()
+	f.data = make([]uint64, length)
+	for i := range f.data {
+		f.data[i] = binary.BigEndian.Uint64(p[8*i:])
+	}
+	return f, nil
+}
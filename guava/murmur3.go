@@ -0,0 +1,91 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guava
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Sum128 returns the 128-bit MurmurHash3 (x64 variant, seed zero) of p,
+// as computed by Guava's Hashing.murmur3_128(). Guava's HashCode.asBytes
+// holds lo, then hi, in little-endian order; asLong returns lo.
+func Sum128(p []byte) (lo, hi uint64) {
+	n := len(p)
+	var h1, h2 uint64
+	for ; len(p) >= 16; p = p[16:] {
+		k1 := binary.LittleEndian.Uint64(p)
+		k2 := binary.LittleEndian.Uint64(p[8:])
+
+		h1 ^= mixK1(k1)
+		h1 = bits.RotateLeft64(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+
+		h2 ^= mixK2(k2)
+		h2 = bits.RotateLeft64(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+	}
+
+	var k1, k2 uint64
+	for i := len(p) - 1; i >= 8; i-- {
+		k2 = k2<<8 | uint64(p[i])
+	}
+	tail1 := p
+	if len(tail1) > 8 {
+		tail1 = tail1[:8]
+	}
+	for i := len(tail1) - 1; i >= 0; i-- {
+		k1 = k1<<8 | uint64(tail1[i])
+	}
+	if len(p) > 8 {
+		h2 ^= mixK2(k2)
+	}
+	if len(p) > 0 {
+		h1 ^= mixK1(k1)
+	}
+
+	h1 ^= uint64(n)
+	h2 ^= uint64(n)
+	h1 += h2
+	h2 += h1
+	h1 = fmix64(h1)
+	h2 = fmix64(h2)
+	h1 += h2
+	h2 += h1
+	return h1, h2
+}
+
+func mixK1(k uint64) uint64 {
+	k *= 0x87c37b91114253d5
+	k = bits.RotateLeft64(k, 31)
+	return k * 0x4cf5ad432745937f
+}
+
+func mixK2(k uint64) uint64 {
+	k *= 0x4cf5ad432745937f
+	k = bits.RotateLeft64(k, 33)
+	return k * 0x87c37b91114253d5
+}
+
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// GuavaGolden writes the fixtures for TestGuavaGolden. For each of
+// Guava's strategies, it fills a filter with the strings key0 through
+// key999 and writes it to <strategy>.bin with BloomFilter.writeTo.
+// It also writes <strategy>.txt, which lists mightContain's answer for
+// the strings key0 through key1999, one "key answer" pair per line.
+//
+// Run it in this directory with a Guava jar, e.g.,
+//
+//	java -cp guava-32.1.2-jre.jar GuavaGolden.java
+
+import com.google.common.hash.BloomFilter;
+import com.google.common.hash.Funnel;
+import com.google.common.hash.Funnels;
+import java.io.FileOutputStream;
+import java.io.OutputStream;
+import java.io.PrintWriter;
+import java.lang.reflect.Method;
+import java.nio.charset.StandardCharsets;
+
+public class GuavaGolden {
+  public static void main(String[] args) throws Exception {
+    // BloomFilter.create with an explicit strategy is package-private.
+    Class<?> strategyType = Class.forName("com.google.common.hash.BloomFilter$Strategy");
+    Class<?> strategies = Class.forName("com.google.common.hash.BloomFilterStrategies");
+    Method create = BloomFilter.class.getDeclaredMethod(
+        "create", Funnel.class, long.class, double.class, strategyType);
+    create.setAccessible(true);
+
+    for (Object strategy : strategies.getEnumConstants()) {
+      String name = ((Enum<?>) strategy).name().toLowerCase();
+      @SuppressWarnings("unchecked")
+      BloomFilter<CharSequence> f = (BloomFilter<CharSequence>) create.invoke(
+          null, Funnels.stringFunnel(StandardCharsets.UTF_8), 1000L, 0.03, strategy);
+      for (int i = 0; i < 1000; i++) {
+        f.put("key" + i);
+      }
+
+      try (OutputStream out = new FileOutputStream(name + ".bin")) {
+        f.writeTo(out);
+      }
+      try (PrintWriter out = new PrintWriter(name + ".txt", "UTF-8")) {
+        for (int i = 0; i < 2000; i++) {
+          String key = "key" + i;
+          out.println(key + " " + f.mightContain(key));
+        }
+      }
+    }
+  }
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+// Add128 inserts a key with the 128-bit hash value (lo, hi) into f.
+//
+// Add uses the low 32 bits of a 64-bit hash value both to select a block
+// and to step between bits within the block. Add128 selects the block by
+// lo and derives the bits from hi and the upper bits of lo, so the two
+// are independent and callers with a 128-bit hash function, such as
+// xxh3-128 or a truncated SHA-256, need not discard half of its output.
+//
+// Keys must be consistently added and tested with either the 64-bit
+// or the 128-bit methods. Add128 is not reported to f's Recorder,
+// which only takes 64-bit hash values.
+func (f *Filter) Add128(lo, hi uint64) {
+	b, h1, h2 := split128(f.b, mix(lo, f.probeSeed), mix(hi, f.probeSeed))
+	h3 := thirdhash(f.probing, h1)
+	for i := 1; i < f.k; i++ {
+		h1, h2 = probehash(h1, h2, h3, i)
+		b.setbit(h1)
+	}
+	if f.sat != nil {
+		f.checkSaturation(1)
+	}
+}
+
+// Has128 reports whether a key with the 128-bit hash value (lo, hi) has
+// been added by Add128. It may return a false positive.
+//
+// Has128 is not reported to f's Recorder.
+func (f *Filter) Has128(lo, hi uint64) bool {
+	b, h1, h2 := split128(f.b, mix(lo, f.probeSeed), mix(hi, f.probeSeed))
+	if useVector(f.k) && f.probing == DoubleHashing {
+		return hasVector(b, h1, h2, f.k)
+	}
+	h3 := thirdhash(f.probing, h1)
+	for i := 1; i < f.k; i++ {
+		h1, h2 = probehash(h1, h2, h3, i)
+		if !b.getbit(h1) {
+			return false
+		}
+	}
+	return true
+}
+
+// Add128 inserts a key with the 128-bit hash value (lo, hi) into f.
+// See Filter.Add128 for details.
+func (f *SyncFilter) Add128(lo, hi uint64) {
+	b, h1, h2 := split128(f.b, mix(lo, f.probeSeed), mix(hi, f.probeSeed))
+	h3 := thirdhash(f.probing, h1)
+	for i := 1; i < f.k; i++ {
+		h1, h2 = probehash(h1, h2, h3, i)
+		setbitAtomic(b, h1)
+	}
+	if f.dirty != nil {
+		f.dirty.mark(int(reducerange(uint32(mix(lo, f.probeSeed)), uint32(len(f.b)))))
+	}
+	if f.sat != nil {
+		f.checkSaturation(1)
+	}
+}
+
+// Has128 reports whether a key with the 128-bit hash value (lo, hi) has
+// been added by Add128. It may return a false positive.
+// See Filter.Has128 for details.
+func (f *SyncFilter) Has128(lo, hi uint64) bool {
+	b, h1, h2 := split128(f.b, mix(lo, f.probeSeed), mix(hi, f.probeSeed))
+	h3 := thirdhash(f.probing, h1)
+	for i := 1; i < f.k; i++ {
+		h1, h2 = probehash(h1, h2, h3, i)
+		if !getbitAtomic(b, h1) {
+			return false
+		}
+	}
+	return true
+}
+
+// split128 returns the block selected by a 128-bit hash value and the
+// initial values for probehash.
+func split128(b []block, lo, hi uint64) (*block, uint32, uint32) {
+	return getblock(b, uint32(lo)), uint32(hi>>32) ^ uint32(lo>>32), uint32(hi)
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdd128(t *testing.T) {
+	const (
+		nkeys   = 20000
+		nbits   = 16 * nkeys
+		nhashes = 11
+	)
+
+	keys := randomU64(2*nkeys, 0x128)
+	f := New(nbits, nhashes)
+	sf := NewSync(nbits, nhashes)
+	for i := 0; i < nkeys; i++ {
+		f.Add128(keys[2*i], keys[2*i+1])
+		sf.Add128(keys[2*i], keys[2*i+1])
+	}
+	assert.Equal(t, f.b, sf.b)
+
+	for i := 0; i < nkeys; i++ {
+		assert.True(t, f.Has128(keys[2*i], keys[2*i+1]))
+		assert.True(t, sf.Has128(keys[2*i], keys[2*i+1]))
+	}
+
+	var fp int
+	const ntests = 200000
+	others := randomU64(2*ntests, 0x821)
+	for i := 0; i < ntests; i++ {
+		if f.Has128(others[2*i], others[2*i+1]) {
+			fp++
+		}
+	}
+	want := f.FPRate(nkeys)
+	assert.InDelta(t, want, float64(fp)/ntests, 2*want)
+}
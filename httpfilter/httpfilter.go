@@ -0,0 +1,203 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpfilter serves Bloom filters over HTTP.
+//
+// A Handler responds to the following requests:
+//
+//	GET /has?h=<hash>[&h=<hash>...]
+//	    Reports, for each hash, whether the filter has it, as a line
+//	    containing "true" or "false". Hashes are written in hexadecimal.
+//	POST /add?h=<hash>[&h=<hash>...]
+//	    Adds the hashes to the filter and reports, for each hash, whether
+//	    it was new, as by AddIfNotHas. The hashes may also be sent as a form
+//	    in the request body. Only Handlers returned by NewSync accept this
+//	    request; others respond with 403 Forbidden.
+//	GET /dump[?format=compressed]
+//	    Streams the filter in the format written by blobloom.Dump, or with
+//	    format=compressed, sends it as a blobloom.CompressedFilter encoded
+//	    by MarshalBinary, without the comment. The compressed format is
+//	    smaller for sparse filters: at a fill ratio of 10%, it takes about
+//	    half the size of a dump, at 1% about a tenth.
+//	GET /stats
+//	    Reports statistics about the filter as a JSON object,
+//	    in the format of bloomexpvar.Var.
+//
+// To serve a filter under a path prefix, wrap the Handler in http.StripPrefix.
+// A Handler does not authenticate its clients. When it accepts additions,
+// wrap it in a handler that does.
+package httpfilter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/bloomexpvar"
+)
+
+// A Handler is an http.Handler that serves a Bloom filter.
+type Handler struct {
+	// Comment is included in dumps of the filter.
+	// It must satisfy the requirements of blobloom.Dump.
+	Comment string
+
+	f interface {
+		bloomexpvar.Filter
+		Has(uint64) bool
+		DumpSize() uint64
+	}
+	add      func(uint64) bool // Nil if read-only.
+	dump     func(w io.Writer, comment string) (int64, error)
+	compress func() *blobloom.CompressedFilter
+}
+
+// New returns a Handler that serves f.
+//
+// The Handler only reads from f, but f must not be modified while
+// the Handler is in use. To serve a filter that is being updated,
+// use a SyncFilter.
+func New(f *blobloom.Filter) *Handler {
+	return &Handler{
+		f: f,
+		dump: func(w io.Writer, comment string) (int64, error) {
+			return blobloom.Dump(w, f, comment)
+		},
+		compress: f.Compress,
+	}
+}
+
+// NewSync returns a Handler that serves f and accepts additions to it.
+func NewSync(f *blobloom.SyncFilter) *Handler {
+	return &Handler{
+		f:   f,
+		add: f.AddIfNotHas,
+		dump: func(w io.Writer, comment string) (int64, error) {
+			return blobloom.DumpSync(w, f, comment)
+		},
+		compress: f.Compress,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/has", "/dump", "/stats":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			methodNotAllowed(w, "GET, HEAD")
+			return
+		}
+	case "/add":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, "POST")
+			return
+		}
+	}
+
+	switch r.URL.Path {
+	case "/has":
+		respond(w, r.URL.Query()["h"], h.f.Has)
+	case "/add":
+		if h.add == nil {
+			http.Error(w, "httpfilter: filter is read-only", http.StatusForbidden)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respond(w, r.Form["h"], h.add)
+	case "/dump":
+		switch format := r.URL.Query().Get("format"); format {
+		case "":
+			h.serveDump(w)
+		case "compressed":
+			h.serveCompressed(w)
+		default:
+			http.Error(w, fmt.Sprintf("httpfilter: unknown format %q", format),
+				http.StatusBadRequest)
+		}
+	case "/stats":
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, bloomexpvar.Var(h.f).String())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
+// respond applies op to each of the hashes in params
+// and writes the results, one per line.
+func respond(w http.ResponseWriter, params []string, op func(uint64) bool) {
+	hashes, err := parseHashes(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for _, x := range hashes {
+		bw.WriteString(strconv.FormatBool(op(x)))
+		bw.WriteByte('\n')
+	}
+	bw.Flush()
+}
+
+func (h *Handler) serveDump(w http.ResponseWriter) {
+	size := h.f.DumpSize()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatUint(size, 10))
+
+	bw := bufio.NewWriter(w)
+	if _, err := h.dump(bw, h.Comment); err != nil {
+		// Headers have been sent. Abort the response to signal the error.
+		panic(http.ErrAbortHandler)
+	}
+	bw.Flush()
+}
+
+func (h *Handler) serveCompressed(w http.ResponseWriter) {
+	p, err := h.compress().MarshalBinary()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(p)))
+	w.Write(p)
+}
+
+func parseHashes(params []string) ([]uint64, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("httpfilter: missing parameter h")
+	}
+
+	hashes := make([]uint64, len(params))
+	for i, s := range params {
+		x, err := strconv.ParseUint(s, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("httpfilter: invalid hash %q", s)
+		}
+		hashes[i] = x
+	}
+	return hashes, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpfilter_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/httpfilter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, srv *httptest.Server, path string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get(srv.URL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestHandler(t *testing.T) {
+	f := blobloom.NewSync(2048, 4)
+	f.SetSeed(0x4774) // Dumps get a longer header.
+	f.Add(0xdeadbeef)
+
+	h := httpfilter.NewSync(f)
+	h.Comment = "test"
+	srv := httptest.NewServer(http.StripPrefix("/bloom", h))
+	defer srv.Close()
+
+	code, body := get(t, srv, "/bloom/has?h=deadbeef&h=1234")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "true\nfalse\n", body)
+
+	code, _ = get(t, srv, "/bloom/has?h=xyz")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get(t, srv, "/bloom/has")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get(t, srv, "/bloom/nothing")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, body = get(t, srv, "/bloom/dump")
+	assert.Equal(t, http.StatusOK, code)
+	l, err := blobloom.NewLoader(strings.NewReader(body))
+	require.NoError(t, err)
+	assert.Equal(t, "test", l.Comment)
+	assert.EqualValues(t, f.DumpSize(), len(body))
+	g, err := l.Load(nil)
+	require.NoError(t, err)
+	assert.True(t, g.Has(0xdeadbeef))
+
+	code, body = get(t, srv, "/bloom/dump?format=compressed")
+	assert.Equal(t, http.StatusOK, code)
+	var c blobloom.CompressedFilter
+	require.NoError(t, c.UnmarshalBinary([]byte(body)))
+	assert.True(t, c.Decompress().Equals(g))
+	code, _ = get(t, srv, "/bloom/dump?format=gzip")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = get(t, srv, "/bloom/stats")
+	assert.Equal(t, http.StatusOK, code)
+	var stats map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(body), &stats))
+	assert.EqualValues(t, 2048, stats["bits"])
+
+	resp, err := http.Post(srv.URL+"/bloom/has", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestHandlerAdd(t *testing.T) {
+	f := blobloom.NewSync(2048, 4)
+	srv := httptest.NewServer(httpfilter.NewSync(f))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/add?h=deadbeef&h=1234", "", nil)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true\ntrue\n", string(body))
+	assert.True(t, f.Has(0xdeadbeef))
+	assert.True(t, f.Has(0x1234))
+
+	resp, err = http.PostForm(srv.URL+"/add", url.Values{"h": {"1234", "5678"}})
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "false\ntrue\n", string(body))
+
+	code, body2 := get(t, srv, "/has?h=5678")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "true\n", body2)
+
+	resp, err = http.PostForm(srv.URL+"/add", url.Values{"h": {"xyz"}})
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	code, _ = get(t, srv, "/add?h=1")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	// Handlers for a Filter are read-only.
+	g := blobloom.New(2048, 4)
+	ro := httptest.NewServer(httpfilter.New(g))
+	defer ro.Close()
+
+	resp, err = http.Post(ro.URL+"/add?h=1", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.True(t, g.Empty())
+}
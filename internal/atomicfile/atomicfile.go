@@ -0,0 +1,87 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package atomicfile writes files that appear atomically when closed.
+package atomicfile
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// A File is a temporary file that is renamed into place on Close,
+// or removed on Abort.
+type File struct {
+	*os.File
+	path string
+}
+
+// Create creates a temporary file in the directory of path,
+// which Close renames to path.
+func Create(path string) (*File, error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+name+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	return &File{File: f, path: path}, nil
+}
+
+// Close syncs f, closes it and renames it into place.
+// On error, the temporary file is removed.
+func (f *File) Close() error {
+	err := f.File.Chmod(0644)
+	if serr := f.File.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.File.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.File.Name())
+	}
+	return err
+}
+
+// Abort closes and removes f, leaving the file at its path untouched.
+func (f *File) Abort() error {
+	err := f.File.Close()
+	if rerr := os.Remove(f.File.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Finish ends a write to w that returned err. If err is nil, it closes w
+// and returns the result. Otherwise, it aborts w if w has an Abort method
+// and closes it if not, then returns err.
+func Finish(w io.WriteCloser, err error) error {
+	if err == nil {
+		return w.Close()
+	}
+	if a, ok := w.(interface{ Abort() error }); ok {
+		a.Abort()
+	} else {
+		w.Close()
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package atomicfile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseAbort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomicfile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+
+	write := func(content string, err error) error {
+		f, cerr := Create(path)
+		require.NoError(t, cerr)
+		_, werr := f.WriteString(content)
+		require.NoError(t, werr)
+		return Finish(f, err)
+	}
+
+	require.NoError(t, write("old", nil))
+
+	failed := errors.New("write failed")
+	assert.Equal(t, failed, write("partial", failed))
+
+	p, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(p))
+
+	// No temporary files are left behind.
+	names, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, names, 1)
+
+	require.NoError(t, write("new", nil))
+	p, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(p))
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dirstore stores named files in a directory. It provides the
+// Storage interfaces and directory backends of the packages that persist
+// filters by name.
+package dirstore
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/greatroar/blobloom/internal/atomicfile"
+)
+
+// A Storage persists files by name.
+type Storage interface {
+	// Open opens the named file for reading. If the file does not
+	// exist, the error satisfies errors.Is(err, os.ErrNotExist).
+	Open(name string) (io.ReadCloser, error)
+
+	// Create opens the named file for writing. The new contents
+	// should replace the old atomically when the writer is closed.
+	// If writing fails, the writer's Abort method is called instead of
+	// Close, if it has one, and should discard the new contents.
+	Create(name string) (io.WriteCloser, error)
+}
+
+// A ListStorage is a Storage that can also list and remove files.
+type ListStorage interface {
+	Storage
+
+	// List returns the names of all files.
+	List() ([]string, error)
+
+	// Remove removes the named file. If the file does not exist,
+	// the error satisfies errors.Is(err, os.ErrNotExist).
+	Remove(name string) error
+}
+
+// A Dir is a ListStorage that stores each file in the directory Path,
+// under its name followed by Suffix.
+//
+// Names must consist of ASCII letters, digits, '.', '-' and '_',
+// and must not start with a dot, so they cannot escape the directory
+// or clash with the temporary files of Create. Other names are rejected
+// with ErrInvalidName.
+type Dir struct {
+	Path           string
+	Suffix         string
+	ErrInvalidName error
+}
+
+// validName reports whether name is valid in a Dir.
+func validName(name string) bool {
+	if name == "" || name[0] == '.' {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (d Dir) path(name string) (string, error) {
+	if !validName(name) {
+		return "", d.ErrInvalidName
+	}
+	return filepath.Join(d.Path, name+d.Suffix), nil
+}
+
+// Open implements Storage.
+func (d Dir) Open(name string) (io.ReadCloser, error) {
+	path, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Create implements Storage.
+func (d Dir) Create(name string) (io.WriteCloser, error) {
+	path, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return atomicfile.Create(path)
+}
+
+// Remove implements ListStorage.
+func (d Dir) Remove(name string) error {
+	path, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// List implements ListStorage. It returns the names of the regular files
+// with Suffix whose names are valid.
+func (d Dir) List() ([]string, error) {
+	infos, err := ioutil.ReadDir(d.Path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		name := info.Name()
+		if !info.Mode().IsRegular() || !strings.HasSuffix(name, d.Suffix) {
+			continue
+		}
+		name = strings.TrimSuffix(name, d.Suffix)
+		if validName(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
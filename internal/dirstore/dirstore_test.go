@@ -0,0 +1,70 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirstore
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "dirstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	errInvalid := errors.New("invalid")
+	d := Dir{Path: tmp, Suffix: ".ext", ErrInvalidName: errInvalid}
+
+	w, err := d.Create("foo.bar-1_2")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := d.Open("foo.bar-1_2")
+	require.NoError(t, err)
+	p, err := ioutil.ReadAll(r)
+	r.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(p))
+
+	_, err = d.Open("nonexistent")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// Files without the suffix or with invalid names are not listed.
+	for _, name := range []string{"other", ".hidden.ext", "sp ace.ext"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, name), nil, 0644))
+	}
+	names, err := d.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo.bar-1_2"}, names)
+
+	require.NoError(t, d.Remove("foo.bar-1_2"))
+	assert.True(t, errors.Is(d.Remove("foo.bar-1_2"), os.ErrNotExist))
+
+	for _, name := range []string{"", ".hidden", "a/b", "../x", `a\b`, "sp ace", "é"} {
+		_, err = d.Open(name)
+		assert.Equal(t, errInvalid, err, name)
+		_, err = d.Create(name)
+		assert.Equal(t, errInvalid, err, name)
+		assert.Equal(t, errInvalid, d.Remove(name), name)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keyhash provides the hash function used by the servers and
+// adapters in this repository, which receive keys rather than hashes.
+package keyhash
+
+import "hash/fnv"
+
+// Sum64 returns the 64-bit FNV-1a hash of p,
+// followed by a finalizer that mixes the bits.
+func Sum64(p []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(p)
+	x := h.Sum64()
+
+	// Finalizer from MurmurHash3.
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
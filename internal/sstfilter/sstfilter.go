@@ -0,0 +1,118 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sstfilter implements the serialized filter format used by the
+// adapters for LSM-tree storage engines, which store one small filter per
+// table or data block.
+//
+// A filter consists of the blocks of a blobloom.Filter, as little-endian
+// 32-bit words, followed by a single byte holding the number of hashes.
+// Filters are probed in their serialized form, without decoding.
+package sstfilter
+
+import (
+	"math"
+
+	"github.com/greatroar/blobloom"
+)
+
+const blockBytes = blobloom.BlockBits / 8
+
+// Filters with more hashes than this are reserved for future extensions.
+const maxHashes = 30
+
+// Append appends to dst a filter containing the keys with hash values hs,
+// using bitsPerKey bits per key, and returns the extended buffer.
+func Append(dst []byte, hs []uint64, bitsPerKey int) []byte {
+	if bitsPerKey < 1 {
+		bitsPerKey = 1
+	}
+	// Round down, like LevelDB, to save probing costs.
+	k := int(float64(bitsPerKey) * math.Ln2)
+	if k < 2 {
+		k = 2
+	} else if k > maxHashes {
+		k = maxHashes
+	}
+
+	nbits := uint64(len(hs)) * uint64(bitsPerKey)
+	nblocks := (nbits + blobloom.BlockBits - 1) / blobloom.BlockBits
+	if nblocks == 0 {
+		nblocks = 1
+	}
+	if nblocks > math.MaxUint32 {
+		panic("sstfilter: too many keys")
+	}
+
+	n := len(dst)
+	size := int(nblocks)*blockBytes + 1
+	for cap(dst)-n < size {
+		dst = append(dst[:cap(dst)], 0)
+	}
+	dst = dst[:n+size]
+	f := dst[n:]
+	for i := range f {
+		f[i] = 0
+	}
+	f[len(f)-1] = byte(k)
+
+	for _, h := range hs {
+		h1, h2 := uint32(h>>32), uint32(h)
+		b := getblock(f, h2)
+		for i := 1; i < k; i++ {
+			h1, h2 = doublehash(h1, h2, i)
+			b[(h1%blobloom.BlockBits)/8] |= 1 << (h1 % 8)
+		}
+	}
+	return dst
+}
+
+// Contains reports whether filter may contain the key with hash value h.
+//
+// Malformed filters and filters with an unknown number of hashes
+// report true for all keys.
+func Contains(filter []byte, h uint64) bool {
+	if len(filter) < blockBytes+1 || (len(filter)-1)%blockBytes != 0 {
+		return true
+	}
+	k := int(filter[len(filter)-1])
+	if k > maxHashes {
+		return true
+	}
+
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := getblock(filter, h2)
+	for i := 1; i < k; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		if b[(h1%blobloom.BlockBits)/8]&(1<<(h1%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// getblock and doublehash must match their counterparts in package blobloom.
+// Bit i of a block is bit i%8 of byte i/8 because words are little-endian.
+
+func getblock(filter []byte, h2 uint32) []byte {
+	nblocks := uint32((len(filter) - 1) / blockBytes)
+	i := uint32((uint64(h2) * uint64(nblocks)) >> 32)
+	return filter[i*blockBytes : (i+1)*blockBytes]
+}
+
+func doublehash(h1, h2 uint32, i int) (uint32, uint32) {
+	h1 = h1 + h2
+	h2 = h2 + uint32(i)
+	return h1, h2
+}
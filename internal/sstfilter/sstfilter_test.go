@@ -0,0 +1,86 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sstfilter
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The serialized format must match the blocks written by blobloom.Dump.
+func TestMatchesFilter(t *testing.T) {
+	r := rand.New(rand.NewSource(0x5eed))
+
+	for _, n := range []int{0, 1, 10, 1000} {
+		hs := make([]uint64, n)
+		for i := range hs {
+			hs[i] = r.Uint64()
+		}
+
+		prefix := []byte("prefix")
+		p := Append(prefix, hs, 10)
+		require.Equal(t, "prefix", string(p[:len(prefix)]))
+		p = p[len(prefix):]
+
+		k := int(p[len(p)-1])
+		assert.Equal(t, 6, k)
+
+		f := blobloom.New(uint64(8*(len(p)-1)), k)
+		for _, h := range hs {
+			f.Add(h)
+			assert.True(t, Contains(p, h))
+		}
+
+		var buf bytes.Buffer
+		_, err := blobloom.Dump(&buf, f, "")
+		require.NoError(t, err)
+		assert.Equal(t, buf.Bytes()[64:], p[:len(p)-1])
+
+		for i := 0; i < 1000; i++ {
+			h := r.Uint64()
+			assert.Equal(t, f.Has(h), Contains(p, h))
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	for _, p := range [][]byte{
+		nil,
+		{6},
+		make([]byte, 63),
+		make([]byte, 66),
+		append(make([]byte, 64), maxHashes+1),
+	} {
+		assert.True(t, Contains(p, 0x1234))
+	}
+
+	p := Append(nil, nil, 10)
+	assert.False(t, Contains(p, 0x1234))
+}
+
+func TestReuseBuffer(t *testing.T) {
+	p := Append(nil, []uint64{1, 2, 3}, 100)
+	for i := range p {
+		p[i] = 0xff
+	}
+	q := Append(p[:0], nil, 100)
+	assert.Equal(t, len(p), len(q))
+	assert.False(t, Contains(q, 1))
+}
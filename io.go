@@ -19,6 +19,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/maphash"
 	"io"
 	"strings"
 	"sync/atomic"
@@ -26,14 +27,23 @@ import (
 
 const maxCommentLen = 44
 
+// Versions of the dump format. Version 1 is taken by the delta format.
+const (
+	dumpVersion         = 0
+	dumpVersionExtended = 2 // Header followed by an extension block.
+)
+
 // Dump writes f to w, with an optional comment string, in the binary format
 // that a Loader accepts. It returns the number of bytes written to w.
 //
 // The comment may contain arbitrary data, within the limits layed out by the
 // format description. It can be used to record the hash function to be used
 // with a Filter.
+//
+// The seed and probing scheme of f are recorded in the dump, if they are
+// not the defaults, and a Loader restores them.
 func Dump(w io.Writer, f *Filter, comment string) (int64, error) {
-	return dump(w, f.b, f.k, comment)
+	return dump(w, f.b, f.k, f.probeSeed, f.probing, comment)
 }
 
 // DumpSync is like Dump, but for SyncFilters.
@@ -41,51 +51,99 @@ func Dump(w io.Writer, f *Filter, comment string) (int64, error) {
 // If other goroutines are simultaneously modifying f,
 // their modifications may not be reflected in the dump.
 // Separate synchronization is required to prevent this.
+// The dump does have all keys whose Add returned before DumpSync
+// was called, so a backup of a live filter needs no such
+// synchronization; see Snapshot.
 //
 // The format produced is the same as Dump's. The fact that
 // the argument is a SyncFilter is not encoded in the dump.
 func DumpSync(w io.Writer, f *SyncFilter, comment string) (n int64, err error) {
-	return dump(w, f.b, f.k, comment)
+	return dump(w, f.b, f.k, f.probeSeed, f.probing, comment)
+}
+
+// DumpSize returns the number of bytes that Dump writes for f.
+func (f *Filter) DumpSize() uint64 {
+	return dumpHeaderSize(f.probeSeed, f.probing) + BlockBytes*uint64(len(f.b))
+}
+
+// DumpSize returns the number of bytes that DumpSync writes for f.
+func (f *SyncFilter) DumpSize() uint64 {
+	return dumpHeaderSize(f.probeSeed, f.probing) + BlockBytes*uint64(len(f.b))
+}
+
+func dumpHeaderSize(seed uint64, p Probing) uint64 {
+	if seed != 0 || p != DoubleHashing {
+		return 128
+	}
+	return 64
+}
+
+func dump(w io.Writer, b []block, nhashes int, seed uint64, p Probing, comment string) (n int64, err error) {
+	if len(b) == 0 || nhashes == 0 {
+		return 0, errors.New("blobloom: won't dump uninitialized Filter")
+	}
+
+	var hdr [64]byte
+	k, err := writeHeader(w, &hdr, uint64(len(b)), nhashes, seed, p, comment)
+	n = int64(k)
+
+	// Write the blocks in chunks, to save on calls to w.Write.
+	chunk := len(b)
+	if chunk > streamChunk {
+		chunk = streamChunk
+	}
+	buf := make([]byte, 0, chunk*BlockBytes)
+	for err == nil && len(b) > 0 {
+		m := len(b)
+		if m > chunk {
+			m = chunk
+		}
+		buf = buf[:0]
+		for i := range b[:m] {
+			buf = appendBlock(buf, &b[i])
+		}
+		b = b[m:]
+
+		k, err = w.Write(buf)
+		n += int64(k)
+	}
+
+	return n, err
 }
 
-func dump(w io.Writer, b []block, nhashes int, comment string) (n int64, err error) {
+// writeHeader writes the header of the dump format to w, using buf as
+// scratch space. If seed or p is not the default, the header is followed
+// by an extension block that records them.
+func writeHeader(w io.Writer, buf *[64]byte, nblocks uint64, nhashes int,
+	seed uint64, p Probing, comment string) (int, error) {
 	switch {
-	case len(b) == 0 || nhashes == 0:
-		err = errors.New("blobloom: won't dump uninitialized Filter")
 	case len(comment) > maxCommentLen:
-		err = fmt.Errorf("blobloom: comment of length %d too long", len(comment))
+		return 0, fmt.Errorf("blobloom: comment of length %d too long", len(comment))
 	case strings.IndexByte(comment, 0) != -1:
-		err = fmt.Errorf("blobloom: comment %q contains zero byte", len(comment))
-	}
-	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("blobloom: comment %q contains zero byte", len(comment))
 	}
 
-	var buf [64]byte
+	extended := dumpHeaderSize(seed, p) > 64
+
+	*buf = [64]byte{}
 	copy(buf[:8], "blobloom")
-	binary.LittleEndian.PutUint32(buf[12:], uint32(len(b)-1))
+	if extended {
+		binary.LittleEndian.PutUint32(buf[8:], dumpVersionExtended)
+	}
+	binary.LittleEndian.PutUint32(buf[12:], uint32(nblocks-1))
 	binary.LittleEndian.PutUint32(buf[16:], uint32(nhashes))
 	copy(buf[20:], comment)
 
-	k, err := w.Write(buf[:])
-	n = int64(k)
-	if err != nil {
+	n, err := w.Write(buf[:])
+	if err != nil || !extended {
 		return n, err
 	}
 
-	for i := range b {
-		for j := range b[i] {
-			x := atomic.LoadUint32(&b[i][j])
-			binary.LittleEndian.PutUint32(buf[4*j:], x)
-		}
-		k, err = w.Write(buf[:])
-		n += int64(k)
-		if err != nil {
-			break
-		}
-	}
-
-	return n, err
+	*buf = [64]byte{}
+	binary.LittleEndian.PutUint64(buf[:], seed)
+	binary.LittleEndian.PutUint32(buf[8:], uint32(p))
+	k, err := w.Write(buf[:])
+	return n + k, err
 }
 
 // A Loader reads a Filter or SyncFilter from an io.Reader.
@@ -93,21 +151,30 @@ func dump(w io.Writer, b []block, nhashes int, comment string) (n int64, err err
 // A Loader accepts the binary format produced by Dump. The format starts
 // with a 64-byte header:
 //   - the string "blobloom", in ASCII;
-//   - a four-byte version number, which must be zero;
+//   - a four-byte version number, which must be zero or two;
 //   - the number of Bloom filter blocks, minus one, as a 32-bit integer;
 //   - the number of hashes, as a 32-bit integer;
 //   - a comment of at most 44 non-zero bytes, padded to 44 bytes with zeros.
 //
-// After the header come the 512-bit blocks, divided into sixteen 32-bit limbs.
-// All integers are little-endian.
+// In version two, the header is followed by a 64-byte extension block:
+//   - the seed, as a 64-bit integer (see Filter.SetSeed);
+//   - the probing scheme, as a 32-bit integer (see Filter.SetProbing);
+//   - 52 zero bytes.
+//
+// Dump writes version zero when the seed and probing scheme have their
+// default values. After the header come the 512-bit blocks, divided into
+// sixteen 32-bit limbs. All integers are little-endian.
 type Loader struct {
 	buf [64]byte
 	r   io.Reader
 	err error
 
-	Comment string // Comment field. Filled in by NewLoader.
-	nblocks uint64
-	nhashes int
+	Comment   string // Comment field. Filled in by NewLoader.
+	version   uint32
+	nblocks   uint64
+	nhashes   int
+	probeSeed uint64
+	probing   Probing
 }
 
 // NewLoader parses the format header from r and returns a Loader
@@ -120,7 +187,7 @@ func NewLoader(r io.Reader) (*Loader, error) {
 		return nil, err
 	}
 
-	version := binary.LittleEndian.Uint32(l.buf[8:])
+	l.version = binary.LittleEndian.Uint32(l.buf[8:])
 	l.nblocks = 1 + uint64(binary.LittleEndian.Uint32(l.buf[12:]))
 	l.nhashes = int(binary.LittleEndian.Uint32(l.buf[16:]))
 	comment := l.buf[20:]
@@ -128,7 +195,7 @@ func NewLoader(r io.Reader) (*Loader, error) {
 	switch {
 	case string(l.buf[:8]) != "blobloom":
 		err = errors.New("blobloom: not a Bloom filter dump")
-	case version != 0:
+	case l.version != dumpVersion && l.version != dumpVersionExtended:
 		err = errors.New("blobloom: unsupported dump version")
 	case l.nhashes == 0:
 		err = errors.New("blobloom: zero hashes in Bloom filter dump")
@@ -137,6 +204,9 @@ func NewLoader(r io.Reader) (*Loader, error) {
 		comment, err = checkComment(comment)
 		l.Comment = string(comment)
 	}
+	if err == nil && l.version == dumpVersionExtended {
+		err = l.readExtension()
+	}
 
 	if err != nil {
 		l = nil
@@ -144,19 +214,59 @@ func NewLoader(r io.Reader) (*Loader, error) {
 	return l, err
 }
 
+// readExtension reads the extension block of a version two dump.
+func (l *Loader) readExtension() error {
+	if err := l.fillbuf(); err != nil {
+		return err
+	}
+	l.probeSeed = binary.LittleEndian.Uint64(l.buf[:])
+	l.probing = Probing(binary.LittleEndian.Uint32(l.buf[8:]))
+
+	switch tail := l.buf[12:]; {
+	case l.probing != DoubleHashing && l.probing != TripleHashing:
+		return fmt.Errorf("blobloom: unknown probing scheme %d in Bloom filter dump", l.probing)
+	case !bytes.Equal(tail, make([]byte, len(tail))):
+		return errors.New("blobloom: corrupt Bloom filter dump header")
+	}
+	return nil
+}
+
+// headerSize returns the size of the dump's header, including any extension.
+func (l *Loader) headerSize() uint64 {
+	if l.version == dumpVersionExtended {
+		return 128
+	}
+	return 64
+}
+
+// Seed returns the seed recorded in the dump. See Filter.SetSeed.
+func (l *Loader) Seed() uint64 { return l.probeSeed }
+
+// Probing returns the probing scheme recorded in the dump.
+// See Filter.SetProbing.
+func (l *Loader) Probing() Probing { return l.probing }
+
 // Load sets f to the union of f and the Loader's filter, then returns f.
-// If f is nil, a new Filter of the appropriate size is constructed.
+// If f is nil, a new Filter of the appropriate size, seed and probing
+// scheme is constructed. Otherwise, these must match those of f.
 //
 // If f is not nil and an error occurs while reading from the Loader,
 // f may end up in an inconsistent state.
 func (l *Loader) Load(f *Filter) (*Filter, error) {
 	if f == nil {
-		nbits := BlockBits * l.nblocks
-		if nbits > MaxBits {
-			return nil, fmt.Errorf("blobloom: %d blocks is too large", l.nblocks)
+		b, err := readBlocks(l.r, l.nblocks)
+		if err != nil {
+			return nil, err
 		}
-		f = New(nbits, int(l.nhashes))
-	} else if err := l.checkBitsAndHashes(len(f.b), f.k); err != nil {
+		_, k := fixBitsAndHashes(BlockBits, l.nhashes)
+		return &Filter{
+			b:         b,
+			k:         k,
+			seed:      maphash.MakeSeed(),
+			probeSeed: l.probeSeed,
+			probing:   l.probing,
+		}, nil
+	} else if err := l.checkShape(len(f.b), f.k, f.probeSeed, f.probing); err != nil {
 		return nil, err
 	}
 
@@ -173,20 +283,28 @@ func (l *Loader) Load(f *Filter) (*Filter, error) {
 	return f, nil
 }
 
-// Load sets f to the union of f and the Loader's filter, then returns f.
-// If f is nil, a new SyncFilter of the appropriate size is constructed.
-// Else, LoadSync may run concurrently with other modifications to f.
+// LoadSync sets f to the union of f and the Loader's filter, then returns f.
+// If f is nil, a new SyncFilter of the appropriate size, seed and probing
+// scheme is constructed. Otherwise, these must match those of f, and
+// LoadSync may run concurrently with other modifications to f.
 //
 // If f is not nil and an error occurs while reading from the Loader,
 // f may end up in an inconsistent state.
 func (l *Loader) LoadSync(f *SyncFilter) (*SyncFilter, error) {
 	if f == nil {
-		nbits := BlockBits * l.nblocks
-		if nbits > MaxBits {
-			return nil, fmt.Errorf("blobloom: %d blocks is too large", l.nblocks)
+		b, err := readBlocks(l.r, l.nblocks)
+		if err != nil {
+			return nil, err
 		}
-		f = NewSync(nbits, int(l.nhashes))
-	} else if err := l.checkBitsAndHashes(len(f.b), f.k); err != nil {
+		_, k := fixBitsAndHashes(BlockBits, l.nhashes)
+		return &SyncFilter{
+			b:         b,
+			k:         k,
+			seed:      maphash.MakeSeed(),
+			probeSeed: l.probeSeed,
+			probing:   l.probing,
+		}, nil
+	} else if err := l.checkShape(len(f.b), f.k, f.probeSeed, f.probing); err != nil {
 		return nil, err
 	}
 
@@ -211,16 +329,71 @@ func (l *Loader) LoadSync(f *SyncFilter) (*SyncFilter, error) {
 	return f, nil
 }
 
-func (l *Loader) checkBitsAndHashes(nblocks, nhashes int) error {
+func (l *Loader) checkShape(nblocks, nhashes int, seed uint64, p Probing) error {
 	switch {
 	case nblocks != int(l.nblocks):
 		return fmt.Errorf("blobloom: Filter has %d blocks, but dump has %d", nblocks, l.nblocks)
 	case nhashes != l.nhashes:
 		return fmt.Errorf("blobloom: Filter has %d hashes, but dump has %d", nhashes, l.nhashes)
+	case seed != l.probeSeed:
+		return errors.New("blobloom: Filter and dump have different seeds")
+	case p != l.probing:
+		return fmt.Errorf("blobloom: Filter has probing scheme %d, but dump has %d", p, l.probing)
 	}
 	return nil
 }
 
+// readBlocks reads nblocks blocks in the format written by Dump from r.
+// The count comes from a dump header and is not trusted: the slice grows as
+// data arrives, so a corrupt or truncated dump cannot make us allocate much
+// more memory than it contains.
+func readBlocks(r io.Reader, nblocks uint64) ([]block, error) {
+	n := int(nblocks)
+	if BlockBits*nblocks > MaxBits || uint64(n) != nblocks {
+		return nil, fmt.Errorf("blobloom: %d blocks is too large", nblocks)
+	}
+
+	chunk := n
+	if chunk > streamChunk {
+		chunk = streamChunk
+	}
+	b := make([]block, 0, chunk)
+	buf := make([]byte, chunk*BlockBytes)
+	for len(b) < n {
+		m := n - len(b)
+		if m > chunk {
+			m = chunk
+		}
+		if _, err := io.ReadFull(r, buf[:m*BlockBytes]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		if len(b)+m > cap(b) {
+			// Double the capacity, but never beyond n,
+			// so a complete dump leaves no excess.
+			c := 2 * cap(b)
+			if c > n {
+				c = n
+			}
+			grown := make([]block, len(b), c)
+			copy(grown, b)
+			b = grown
+		}
+		for i := 0; i < m; i++ {
+			p := buf[i*BlockBytes:]
+			var blk block
+			for j := range blk {
+				blk[j] = binary.LittleEndian.Uint32(p[4*j:])
+			}
+			b = append(b, blk)
+		}
+	}
+	return b, nil
+}
+
 func (l *Loader) fillbuf() error {
 	_, err := io.ReadFull(l.r, l.buf[:])
 	if err == io.EOF {
@@ -51,12 +51,8 @@ func FuzzLoader(f *testing.F) {
 			t.Fatal("zero in comment")
 		}
 
-		// Prevent large allocations.
-		const maxMem = 1 << 20
-		if l.nblocks > maxMem/(BlockBits/8) {
-			t.Skip()
-		}
-
+		// Load allocates memory as data arrives, not as the header says,
+		// so large block counts need not be skipped.
 		f, err := l.Load(nil)
 		if err == nil {
 			if f == nil {
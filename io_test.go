@@ -16,8 +16,11 @@ package blobloom
 
 import (
 	"bytes"
+	"encoding/binary"
 	"io"
+	"io/ioutil"
 	"math/rand"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -50,3 +53,140 @@ func TestDumpLoad(t *testing.T) {
 	assert.Nil(t, g2)
 	assert.Equal(t, io.ErrUnexpectedEOF, err)
 }
+
+// A dump that claims a huge number of blocks, but is truncated,
+// must not make the reading functions allocate memory for all of them.
+func TestLoadTruncatedHuge(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := Dump(&buf, New(BlockBits, 3), "")
+	require.NoError(t, err)
+	p := buf.Bytes()
+	binary.LittleEndian.PutUint32(p[12:], 1<<30) // Number of blocks - 1.
+
+	loaders := map[string]func(r io.Reader) error{
+		"Load": func(r io.Reader) error {
+			l, err := NewLoader(r)
+			require.NoError(t, err)
+			_, err = l.Load(nil)
+			return err
+		},
+		"LoadSync": func(r io.Reader) error {
+			l, err := NewLoader(r)
+			require.NoError(t, err)
+			_, err = l.LoadSync(nil)
+			return err
+		},
+		"ReadFrom": func(r io.Reader) error {
+			_, err := new(Filter).ReadFrom(r)
+			return err
+		},
+	}
+	for name, load := range loaders {
+		var err error
+		alloc := allocated(func() { err = load(bytes.NewReader(p)) })
+		assert.Equal(t, io.ErrUnexpectedEOF, err, name)
+		assert.Less(t, alloc, uint64(1<<20), name)
+	}
+}
+
+// allocated returns the number of bytes allocated by f.
+func allocated(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestDumpLoadSeed(t *testing.T) {
+	f := NewOptimized(Config{Capacity: 1000, FPRate: 1e-4, Seed: 0x5eed, Probing: TripleHashing})
+	keys := randomU64(1000, 0x5eed)
+	for _, h := range keys {
+		f.Add(h)
+	}
+	hasAll := func(g interface{ Has(uint64) bool }) {
+		t.Helper()
+		for _, h := range keys {
+			if !g.Has(h) {
+				t.Fatalf("false negative for %x", h)
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	n, err := Dump(buf, f, "seeded")
+	require.NoError(t, err)
+	assert.EqualValues(t, f.DumpSize(), n)
+	assert.EqualValues(t, 128+f.NumBits()/8, n)
+	p := buf.Bytes()
+
+	l, err := NewLoader(bytes.NewReader(p))
+	require.NoError(t, err)
+	assert.Equal(t, "seeded", l.Comment)
+	assert.EqualValues(t, 0x5eed, l.Seed())
+	assert.Equal(t, TripleHashing, l.Probing())
+	g, err := l.Load(nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0x5eed, g.Seed())
+	assert.Equal(t, TripleHashing, g.Probing())
+	hasAll(g)
+
+	l, err = NewLoader(bytes.NewReader(p))
+	require.NoError(t, err)
+	s, err := l.LoadSync(nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0x5eed, s.Seed())
+	hasAll(s)
+
+	// Loading into a filter with another seed or scheme fails.
+	l, err = NewLoader(bytes.NewReader(p))
+	require.NoError(t, err)
+	_, err = l.Load(New(f.NumBits(), f.NumHashes()))
+	assert.Error(t, err)
+	other := New(f.NumBits(), f.NumHashes())
+	other.SetSeed(0x5eed) // But DoubleHashing.
+	_, err = l.Load(other)
+	assert.Error(t, err)
+
+	var m Filter
+	require.NoError(t, m.UnmarshalBinary(p))
+	assert.EqualValues(t, 0x5eed, m.Seed())
+	assert.Equal(t, TripleHashing, m.Probing())
+	hasAll(&m)
+
+	var r Filter
+	_, err = r.ReadFrom(bytes.NewReader(p))
+	require.NoError(t, err)
+	assert.EqualValues(t, 0x5eed, r.Seed())
+	hasAll(&r)
+
+	var u bytes.Buffer
+	_, err = UnionDumps(&u, "", bytes.NewReader(p), bytes.NewReader(p))
+	require.NoError(t, err)
+	assert.Equal(t, p[128:], u.Bytes()[128:])
+	require.NoError(t, m.UnmarshalBinary(u.Bytes()))
+	assert.EqualValues(t, 0x5eed, m.Seed())
+
+	// Filters with the default seed and scheme are dumped in version 0.
+	var plain bytes.Buffer
+	other.SetSeed(0)
+	_, err = Dump(&plain, other, "")
+	require.NoError(t, err)
+	assert.EqualValues(t, 64+other.NumBits()/8, plain.Len())
+	assert.EqualValues(t, other.DumpSize(), plain.Len())
+	assert.Zero(t, plain.Bytes()[8])
+
+	_, err = UnionDumps(ioutil.Discard, "", bytes.NewReader(p), bytes.NewReader(plain.Bytes()))
+	assert.Error(t, err)
+
+	bad := append([]byte(nil), p...)
+	bad[64+8] = 7 // Unknown probing scheme.
+	_, err = NewLoader(bytes.NewReader(bad))
+	assert.Error(t, err)
+	bad = append([]byte(nil), p...)
+	bad[127] = 1
+	_, err = NewLoader(bytes.NewReader(bad))
+	assert.Error(t, err)
+	_, err = NewLoader(bytes.NewReader(p[:100]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
@@ -0,0 +1,285 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package manager manages many named Bloom filters with a memory budget.
+//
+// A Manager loads filters from a Storage on first use, keeps recently used
+// filters in memory, and writes modified filters back to the Storage when
+// it evicts them and when it is synced or closed.
+package manager
+
+import (
+	"container/list"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/internal/atomicfile"
+)
+
+// ErrClosed is returned by operations on a closed Manager.
+var ErrClosed = errors.New("manager: closed")
+
+// A Manager owns a set of named filters.
+//
+// A Manager is safe for concurrent use by multiple goroutines.
+type Manager struct {
+	storage  Storage
+	config   blobloom.Config
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	lru     list.List // Of *entry, most recently used first.
+	size    int64     // Total size of filters in memory, in bytes.
+	closed  bool
+	log     blobloom.Logger
+}
+
+type entry struct {
+	dirty uint32 // Accessed atomically.
+
+	name string
+	f    *blobloom.SyncFilter
+	elem *list.Element
+	refs int // Number of operations in progress. Protected by Manager.mu.
+
+	// Whether the filter holds more keys than the configured capacity.
+	// Protected by Manager.mu.
+	saturated bool
+}
+
+// New constructs a Manager that loads and stores filters in s.
+// Filters that do not exist in s are created with the given config.
+//
+// The Manager evicts the least recently used filters when the filters
+// in memory take up more than maxBytes. Filters that are in use are not
+// evicted, so the budget may be exceeded temporarily.
+func New(s Storage, config blobloom.Config, maxBytes int64) *Manager {
+	return &Manager{
+		storage:  s,
+		config:   config,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*entry),
+	}
+}
+
+// SetLogger sets a Logger that receives events for loading, storing and
+// evicting filters, and a warning when a filter is stored that holds more
+// keys than the configured capacity.
+func (m *Manager) SetLogger(l blobloom.Logger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.log = l
+}
+
+// Add adds the key with hash value h to the named filter.
+func (m *Manager) Add(name string, h uint64) error {
+	return m.Do(name, func(f *blobloom.SyncFilter) bool {
+		f.Add(h)
+		return true
+	})
+}
+
+// Has reports whether the named filter has the key with hash value h.
+func (m *Manager) Has(name string, h uint64) (has bool, err error) {
+	err = m.Do(name, func(f *blobloom.SyncFilter) bool {
+		has = f.Has(h)
+		return false
+	})
+	return has, err
+}
+
+// Do calls fn with the named filter, loading it if necessary.
+// If fn modifies the filter, it must return true so the filter is stored.
+//
+// Multiple calls to fn may run concurrently. The filter must not be used
+// after fn returns, since it may be evicted.
+func (m *Manager) Do(name string, fn func(f *blobloom.SyncFilter) (modified bool)) error {
+	e, err := m.acquire(name)
+	if err != nil {
+		return err
+	}
+	defer m.release(e)
+
+	if fn(e.f) {
+		atomic.StoreUint32(&e.dirty, 1)
+	}
+	return nil
+}
+
+func (m *Manager) acquire(name string) (*entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+	if e := m.entries[name]; e != nil {
+		e.refs++
+		m.lru.MoveToFront(e.elem)
+		return e, nil
+	}
+
+	// Loading under the lock keeps the code simple
+	// and prevents loading a filter twice.
+	f, err := m.load(name)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{name: name, f: f, refs: 1}
+	e.elem = m.lru.PushFront(e)
+	m.entries[name] = e
+	m.size += int64(f.NumBits() / 8)
+	m.evict()
+
+	return e, nil
+}
+
+func (m *Manager) release(e *entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.refs--
+	m.evict()
+}
+
+func (m *Manager) load(name string) (*blobloom.SyncFilter, error) {
+	r, err := m.storage.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		f := blobloom.NewSyncOptimized(m.config)
+		if m.log != nil {
+			m.log.Debug("manager: filter created", "filter", name, "bits", f.NumBits())
+		}
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	l, err := blobloom.NewLoader(r)
+	if err != nil {
+		return nil, err
+	}
+	f, err := l.LoadSync(nil)
+	if err == nil && m.log != nil {
+		m.log.Debug("manager: filter loaded", "filter", name, "bits", f.NumBits())
+	}
+	return f, err
+}
+
+// evict evicts idle filters until the budget is met. Filters that cannot
+// be stored stay in memory, so that their contents are not lost; Sync and
+// Close report the error.
+func (m *Manager) evict() {
+	for elem := m.lru.Back(); elem != nil && m.size > m.maxBytes; {
+		e := elem.Value.(*entry)
+		elem = elem.Prev()
+
+		if e.refs > 0 || m.store(e) != nil {
+			continue
+		}
+		m.lru.Remove(e.elem)
+		delete(m.entries, e.name)
+		m.size -= int64(e.f.NumBits() / 8)
+		if m.log != nil {
+			m.log.Debug("manager: filter evicted", "filter", e.name)
+		}
+	}
+}
+
+// store writes e to storage if it is dirty.
+func (m *Manager) store(e *entry) error {
+	if !atomic.CompareAndSwapUint32(&e.dirty, 1, 0) {
+		return nil
+	}
+
+	w, err := m.storage.Create(e.name)
+	if err == nil {
+		_, err = blobloom.DumpSync(w, e.f, "")
+		err = atomicfile.Finish(w, err)
+	}
+	if err != nil {
+		atomic.StoreUint32(&e.dirty, 1)
+		if m.log != nil {
+			m.log.Warn("manager: storing filter failed", "filter", e.name, "err", err)
+		}
+		return err
+	}
+
+	if m.log != nil {
+		m.log.Debug("manager: filter stored", "filter", e.name)
+		m.checkSaturation(e)
+	}
+	return nil
+}
+
+// checkSaturation logs a warning when e's estimated number of keys
+// first exceeds the configured capacity.
+func (m *Manager) checkSaturation(e *entry) {
+	if e.saturated || m.config.Capacity == 0 {
+		return
+	}
+	if nkeys := e.f.Cardinality(); nkeys > float64(m.config.Capacity) {
+		e.saturated = true
+		m.log.Warn("manager: filter exceeds capacity", "filter", e.name,
+			"estimated_keys", nkeys, "capacity", m.config.Capacity,
+			"fill_ratio", e.f.FillRatio())
+	}
+}
+
+// Sync stores all modified filters. It returns the first error encountered.
+//
+// Modifications made while Sync is running may not be stored.
+func (m *Manager) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sync()
+}
+
+func (m *Manager) sync() (err error) {
+	for elem := m.lru.Front(); elem != nil; elem = elem.Next() {
+		if serr := m.store(elem.Value.(*entry)); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Close stores all modified filters and releases them.
+// After Close, all operations return ErrClosed.
+//
+// If a filter cannot be stored, Close returns the error and leaves m open,
+// so that Close can be retried.
+//
+// Close should not be called while other operations are in progress.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+	if err := m.sync(); err != nil {
+		return err
+	}
+	m.closed = true
+	m.entries = nil
+	m.lru.Init()
+	m.size = 0
+	return nil
+}
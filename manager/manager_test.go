@@ -0,0 +1,234 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStorage records the calls made to it.
+type memStorage struct {
+	files  map[string][]byte
+	opens  []string
+	stores []string
+	fail   bool
+}
+
+func (s *memStorage) Open(name string) (io.ReadCloser, error) {
+	s.opens = append(s.opens, name)
+	p, ok := s.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+	}
+	return ioutil.NopCloser(bytes.NewReader(p)), nil
+}
+
+func (s *memStorage) Create(name string) (io.WriteCloser, error) {
+	if s.fail {
+		return nil, errors.New("storage failure")
+	}
+	return &memFile{s: s, name: name}, nil
+}
+
+type memFile struct {
+	bytes.Buffer
+	s    *memStorage
+	name string
+}
+
+func (f *memFile) Abort() error { return nil }
+
+func (f *memFile) Close() error {
+	f.s.files[f.name] = f.Bytes()
+	f.s.stores = append(f.s.stores, f.name)
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	s := &memStorage{files: make(map[string][]byte)}
+	config := blobloom.Config{Capacity: 1000, FPRate: 1e-3}
+	size := int64(blobloom.NewOptimized(config).NumBits() / 8)
+
+	// Room for two filters.
+	m := New(s, config, 2*size)
+
+	require.NoError(t, m.Add("a", 1))
+	require.NoError(t, m.Add("b", 2))
+	has, err := m.Has("a", 1)
+	require.NoError(t, err)
+	assert.True(t, has)
+	assert.Empty(t, s.stores)
+
+	// Loading c evicts b, the least recently used.
+	has, err = m.Has("c", 3)
+	require.NoError(t, err)
+	assert.False(t, has)
+	assert.Equal(t, []string{"b"}, s.stores)
+
+	// c is clean, so evicting it doesn't store it.
+	_, err = m.Has("a", 1)
+	require.NoError(t, err)
+	has, err = m.Has("b", 2)
+	require.NoError(t, err)
+	assert.True(t, has)
+	assert.Equal(t, []string{"b"}, s.stores)
+	assert.Equal(t, []string{"a", "b", "c", "b"}, s.opens)
+
+	// A filter in use is not evicted.
+	err = m.Do("a", func(f *blobloom.SyncFilter) bool {
+		require.NoError(t, m.Add("d", 4))
+		require.NoError(t, m.Add("e", 5))
+		f.Add(11)
+		return true
+	})
+	require.NoError(t, err)
+	_, ok := m.entries["a"]
+	assert.True(t, ok)
+
+	// Failed stores keep filters in memory.
+	s.fail = true
+	assert.Error(t, m.Close())
+	s.fail = false
+	require.NoError(t, m.Close())
+	assert.Equal(t, ErrClosed, m.Add("a", 1))
+
+	sort.Strings(s.stores)
+	assert.Equal(t, []string{"a", "b", "d", "e"}, s.stores)
+
+	m = New(s, config, 0)
+	for _, c := range []struct {
+		name string
+		h    uint64
+	}{{"a", 1}, {"a", 11}, {"b", 2}, {"d", 4}, {"e", 5}} {
+		has, err := m.Has(c.name, c.h)
+		require.NoError(t, err)
+		assert.True(t, has, c.name)
+	}
+	assert.Empty(t, m.entries)
+}
+
+// failWriter fails every write.
+type failWriter struct{ *memFile }
+
+func (failWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+type failWriteStorage struct{ *memStorage }
+
+func (s failWriteStorage) Create(name string) (io.WriteCloser, error) {
+	w, err := s.memStorage.Create(name)
+	return failWriter{w.(*memFile)}, err
+}
+
+func TestFailedStoreKeepsOld(t *testing.T) {
+	s := &memStorage{files: make(map[string][]byte)}
+	config := blobloom.Config{Capacity: 1000, FPRate: 1e-3}
+
+	m := New(s, config, 0)
+	require.NoError(t, m.Add("a", 1))
+	require.NoError(t, m.Close())
+	old := s.files["a"]
+
+	m = New(failWriteStorage{s}, config, 0)
+	require.NoError(t, m.Add("a", 2))
+	assert.Error(t, m.Close())
+	assert.Equal(t, old, s.files["a"])
+	assert.Equal(t, []string{"a"}, s.stores)
+}
+
+func TestDir(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "blobloom-manager")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	s := Dir(tmp)
+	m := New(s, blobloom.Config{Capacity: 100, FPRate: .01}, 1<<20)
+	require.NoError(t, m.Add("foo.bar-1_2", 42))
+	require.NoError(t, m.Close())
+
+	names, err := filepath.Glob(filepath.Join(tmp, "*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(tmp, "foo.bar-1_2.blobloom")}, names)
+
+	m = New(s, blobloom.Config{Capacity: 100, FPRate: .01}, 1<<20)
+	has, err := m.Has("foo.bar-1_2", 42)
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	for _, name := range []string{"", ".hidden", "a/b", "../x", "sp ace"} {
+		assert.Equal(t, errInvalidName, m.Add(name, 1), name)
+	}
+}
+
+// msgLogger records the messages logged to it.
+type msgLogger []string
+
+func (l *msgLogger) Debug(msg string, args ...interface{}) { *l = append(*l, msg) }
+func (l *msgLogger) Info(msg string, args ...interface{})  { *l = append(*l, msg) }
+func (l *msgLogger) Warn(msg string, args ...interface{})  { *l = append(*l, msg) }
+
+func TestManagerLogger(t *testing.T) {
+	s := &memStorage{files: make(map[string][]byte)}
+	m := New(s, blobloom.Config{Capacity: 100, FPRate: .01}, 1<<20)
+	var log msgLogger
+	m.SetLogger(&log)
+
+	for i := uint64(0); i < 1000; i++ {
+		require.NoError(t, m.Add("a", i*0x9e3779b97f4a7c15))
+	}
+	require.NoError(t, m.Sync())
+	require.NoError(t, m.Add("a", 1))
+	require.NoError(t, m.Sync())
+
+	assert.Equal(t, msgLogger{
+		"manager: filter created",
+		"manager: filter stored",
+		"manager: filter exceeds capacity",
+		"manager: filter stored",
+	}, log)
+}
+
+func TestManagerSeeded(t *testing.T) {
+	s := &memStorage{files: make(map[string][]byte)}
+	config := blobloom.Config{
+		Capacity: 1000, FPRate: 1e-4, Seed: 0x3a9, Probing: blobloom.TripleHashing,
+	}
+	m := New(s, config, 0) // Evicts after every call.
+
+	for i := uint64(0); i < 1000; i++ {
+		require.NoError(t, m.Add("a", i*0x9e3779b97f4a7c15))
+	}
+	for i := uint64(0); i < 1000; i++ {
+		has, err := m.Has("a", i*0x9e3779b97f4a7c15)
+		require.NoError(t, err)
+		assert.True(t, has)
+	}
+	require.NoError(t, m.Do("a", func(f *blobloom.SyncFilter) bool {
+		assert.EqualValues(t, 0x3a9, f.Seed())
+		assert.Equal(t, blobloom.TripleHashing, f.Probing())
+		return false
+	}))
+}
@@ -0,0 +1,38 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"errors"
+
+	"github.com/greatroar/blobloom/internal/dirstore"
+)
+
+// A Storage persists filters by name. Open must report a missing filter
+// with an error that satisfies errors.Is(err, os.ErrNotExist), and the
+// writer returned by Create must replace the filter atomically on Close
+// and discard the new contents on Abort, if it has that method.
+type Storage = dirstore.Storage
+
+// Dir returns a Storage that stores each filter in a file in the
+// directory path, named after the filter with the suffix ".blobloom".
+//
+// Filter names must consist of ASCII letters, digits, '.', '-' and '_',
+// and must not start with a dot.
+func Dir(path string) Storage {
+	return dirstore.Dir{Path: path, Suffix: ".blobloom", ErrInvalidName: errInvalidName}
+}
+
+var errInvalidName = errors.New("manager: invalid filter name")
@@ -0,0 +1,93 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// MarshalBinary encodes f in the format written by Dump, with an empty
+// comment. The encoding records the number of blocks, the number of hashes,
+// the seed, the probing scheme and the contents of the blocks,
+// so UnmarshalBinary restores f exactly.
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	return marshal(f.b, f.k, f.probeSeed, f.probing)
+}
+
+// UnmarshalBinary sets f to the filter encoded in p, which must be in the
+// format written by Dump. The Recorder and the seed for AddString set on f
+// are retained.
+func (f *Filter) UnmarshalBinary(p []byte) error {
+	l, err := newLoaderExact(p)
+	if err != nil {
+		return err
+	}
+	g, err := l.Load(nil)
+	if err == nil {
+		f.b, f.k, f.seed = g.b, g.k, ensureSeed(f.seed)
+		f.probeSeed, f.probing = g.probeSeed, g.probing
+	}
+	return err
+}
+
+// MarshalBinary encodes f in the format written by Dump, with an empty
+// comment.
+//
+// If other goroutines are simultaneously modifying f,
+// their modifications may not be reflected in the encoding.
+func (f *SyncFilter) MarshalBinary() ([]byte, error) {
+	return marshal(f.b, f.k, f.probeSeed, f.probing)
+}
+
+// UnmarshalBinary sets f to the filter encoded in p, which must be in the
+// format written by Dump. The Recorder and the seed for AddString set on f
+// are retained.
+//
+// UnmarshalBinary must not be called concurrently with other methods of f.
+func (f *SyncFilter) UnmarshalBinary(p []byte) error {
+	l, err := newLoaderExact(p)
+	if err != nil {
+		return err
+	}
+	g, err := l.LoadSync(nil)
+	if err == nil {
+		f.b, f.k, f.seed = g.b, g.k, ensureSeed(f.seed)
+		f.probeSeed, f.probing = g.probeSeed, g.probing
+	}
+	return err
+}
+
+func marshal(b []block, nhashes int, seed uint64, p Probing) ([]byte, error) {
+	size := dumpHeaderSize(seed, p) + uint64(len(b))*BlockBytes
+	w := bytes.NewBuffer(make([]byte, 0, size))
+	_, err := dump(w, b, nhashes, seed, p, "")
+	return w.Bytes(), err
+}
+
+// newLoaderExact returns a Loader for p, after checking that the length
+// of p matches the number of blocks in its header.
+func newLoaderExact(p []byte) (*Loader, error) {
+	l, err := NewLoader(bytes.NewReader(p))
+	if err != nil {
+		return nil, err
+	}
+	data := uint64(len(p)) - l.headerSize()
+	if size := data / BlockBytes; data%BlockBytes != 0 || size != l.nblocks {
+		return nil, fmt.Errorf("blobloom: encoding of %d bytes does not hold %d blocks",
+			len(p), l.nblocks)
+	}
+	return l, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"encoding"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*Filter)(nil)
+	_ encoding.BinaryUnmarshaler = (*Filter)(nil)
+	_ encoding.BinaryMarshaler   = (*SyncFilter)(nil)
+	_ encoding.BinaryUnmarshaler = (*SyncFilter)(nil)
+)
+
+func TestMarshalBinary(t *testing.T) {
+	f := New(10*BlockBits, 6)
+	s := NewSync(10*BlockBits, 6)
+	for _, h := range randomU64(200, 0x3a2) {
+		f.Add(h)
+		s.Add(h)
+	}
+
+	p, err := f.MarshalBinary()
+	require.NoError(t, err)
+	assert.Len(t, p, 64+10*BlockBytes)
+	q, err := s.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, p, q)
+
+	g := New(1, 2)
+	require.NoError(t, g.UnmarshalBinary(p))
+	assert.True(t, f.Equals(g))
+
+	gs := NewSync(1, 2)
+	require.NoError(t, gs.UnmarshalBinary(p))
+	assert.True(t, s.Equals(gs))
+
+	for _, bad := range [][]byte{nil, p[:64], p[:len(p)-1], append(p, 0)} {
+		assert.Error(t, g.UnmarshalBinary(bad))
+		assert.Error(t, gs.UnmarshalBinary(bad))
+	}
+	// Failed unmarshaling leaves the filters intact.
+	assert.True(t, f.Equals(g))
+	assert.True(t, s.Equals(gs))
+}
@@ -0,0 +1,297 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// A MappedFilter is a SyncFilter whose blocks live in a memory-mapped file
+// in the format written by Dump, so that large filters can be opened
+// without reading them into memory.
+//
+// A writable MappedFilter persists across restarts without being dumped
+// and loaded. Since Bloom filter bits are only ever set, its file is
+// consistent after a crash: it holds all keys added before the last
+// successful Sync, and possibly some keys added after.
+//
+// Memory mapping is supported on Linux, macOS, the BSDs and Windows, on
+// little-endian architectures. It is not supported when this package is
+// built with the nounsafe tag.
+type MappedFilter struct {
+	*SyncFilter
+
+	Comment string // Comment field of the file.
+
+	file *os.File
+	m    *mapping
+	mode mapMode
+}
+
+type mapMode int
+
+const (
+	mapPrivate mapMode = iota // Copy-on-write.
+	mapShared
+	mapDAX // Shared, with writes made durable by flushing CPU caches.
+)
+
+var (
+	errMapReadOnly    = errors.New("blobloom: MappedFilter is read-only")
+	errMapUnsupported = errors.New("blobloom: memory mapping not supported on this platform")
+)
+
+// OpenMapped maps the filter in the file at path into memory.
+//
+// If writable is true, modifications to the filter are written to the file,
+// eventually or by a call to Sync. Otherwise, the file is mapped
+// copy-on-write: the filter can still be modified, but modifications are
+// private to the process and are lost on Close.
+func OpenMapped(path string, writable bool) (*MappedFilter, error) {
+	mode := mapPrivate
+	if writable {
+		mode = mapShared
+	}
+	return openMappedPath(path, mode)
+}
+
+// Mmap maps the filter in the file at path, as written by Dump, WriteTo
+// or MarshalBinary, into memory for querying. It is shorthand for
+// OpenMapped(path, false).
+//
+// Has is served directly from the mapping: no blocks are copied into
+// the heap, so opening a filter of any size takes constant time, and
+// processes that map the same file share its pages in the page cache.
+// Pages are only copied when the filter is modified.
+func Mmap(path string) (*MappedFilter, error) {
+	return OpenMapped(path, false)
+}
+
+// OpenMappedDAX maps the filter in the file at path into memory for
+// writing, for files on persistent memory (PMEM) accessed through a file
+// system with direct access (DAX).
+//
+// Instead of relying on Sync to write modified pages back to the file,
+// Add makes every modification durable as it happens, by flushing the
+// modified block, which is a single cache line, from the CPU caches.
+// This takes far less time than a Sync, so every Add is durable when
+// it returns.
+//
+// Only Add makes its modifications durable immediately. After other
+// modifications, such as LoadSync or Fill, call Sync, which flushes all
+// blocks from the CPU caches.
+//
+// On Linux, the file is mapped with MAP_SYNC, so OpenMappedDAX fails if
+// the file is not on a DAX file system. On architectures other than amd64,
+// flushing CPU caches is not implemented and Add instead writes back the
+// page containing the modified block, which is much slower.
+func OpenMappedDAX(path string) (*MappedFilter, error) {
+	return openMappedPath(path, mapDAX)
+}
+
+func openMappedPath(path string, mode mapMode) (*MappedFilter, error) {
+	flag := os.O_RDWR
+	if mode == mapPrivate {
+		flag = os.O_RDONLY
+	}
+	file, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := openMapped(file, mode)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// CreateMapped creates a file at path holding an empty filter with the
+// given numbers of bits and hashes and comment, and maps it into memory
+// for writing. The numbers of bits and hashes are adjusted as by NewSync.
+//
+// CreateMapped fails if the file already exists. The file is prepared under
+// a temporary name and only appears at path once it is complete, so a crash
+// does not leave a partially initialized filter behind.
+func CreateMapped(path string, nbits uint64, nhashes int, comment string) (*MappedFilter, error) {
+	nbits, nhashes = fixBitsAndHashes(nbits, nhashes)
+	if nbits > MaxBits {
+		return nil, fmt.Errorf("blobloom: %d bits is too large", nbits)
+	}
+
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := ioutil.TempFile(dir, "."+name+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	var buf [64]byte
+	nblocks := nbits / BlockBits
+	_, err = writeHeader(file, &buf, nblocks, nhashes, 0, DoubleHashing, comment)
+	if err == nil {
+		err = file.Truncate(int64(len(buf)) + int64(nblocks)*BlockBytes)
+	}
+	if err == nil {
+		err = file.Chmod(0644)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// Unlike a rename, a link fails if path exists.
+		err = os.Link(file.Name(), path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := openMappedPath(path, mapShared)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return f, nil
+}
+
+func openMapped(file *os.File, mode mapMode) (*MappedFilter, error) {
+	if !littleEndian() {
+		return nil, errors.New("blobloom: memory mapping requires a little-endian CPU")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size < 64 || size%BlockBytes != 0 || size-64 > MaxBits/8 {
+		return nil, fmt.Errorf("blobloom: %s: invalid size %d for a Bloom filter dump",
+			file.Name(), size)
+	}
+
+	m, err := mapFile(file, int(size), mode)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := NewLoader(bytes.NewReader(m.data))
+	if err == nil && l.version != dumpVersion {
+		err = fmt.Errorf("blobloom: %s: cannot map a filter with a seed or probing scheme",
+			file.Name())
+	} else if err == nil && l.nblocks != uint64(size-64)/BlockBytes {
+		err = fmt.Errorf("blobloom: %s has %d blocks, but its header says %d",
+			file.Name(), (size-64)/BlockBytes, l.nblocks)
+	}
+	if err != nil {
+		m.unmap()
+		return nil, err
+	}
+
+	return &MappedFilter{
+		SyncFilter: &SyncFilter{b: blocksOf(m.data[64:]), k: l.nhashes, seed: maphash.MakeSeed()},
+		Comment:    l.Comment,
+		file:       file,
+		m:          m,
+		mode:       mode,
+	}, nil
+}
+
+// Add inserts a key with hash value h into f. If f was opened by
+// OpenMappedDAX, the modification is durable when Add returns.
+//
+// Calling f.SyncFilter.Add instead skips the flush.
+func (f *MappedFilter) Add(h uint64) {
+	f.SyncFilter.Add(h)
+	if f.mode == mapDAX {
+		// Errors are ignored. They would be reported by the next Sync.
+		f.persist(64+f.BlockIndex(h)*BlockBytes, BlockBytes)
+	}
+}
+
+// persist makes data[off:off+n] of f's mapping durable.
+func (f *MappedFilter) persist(off, n int) error {
+	if persist(f.m.data[off : off+n]) {
+		return nil
+	}
+	return f.m.flushRange(off, n)
+}
+
+// Sync flushes modifications of f to its file and waits for the file
+// to be written to stable storage. Sync fails if f is not writable.
+func (f *MappedFilter) Sync() error {
+	switch f.mode {
+	case mapPrivate:
+		return errMapReadOnly
+	case mapDAX:
+		return f.persist(0, len(f.m.data))
+	}
+	return f.m.flush(f.file)
+}
+
+// SetComment changes the comment in the header of f's file and writes
+// the header to stable storage. The header lies within the first sector
+// of the file, which storage devices write atomically, so after a crash
+// the file has either the old or the new comment.
+//
+// SetComment fails if f is not writable.
+func (f *MappedFilter) SetComment(comment string) error {
+	if f.mode == mapPrivate {
+		return errMapReadOnly
+	}
+
+	var hdr [64]byte
+	_, err := writeHeader(ioutil.Discard, &hdr, uint64(len(f.b)), f.k, 0, DoubleHashing, comment)
+	if err != nil {
+		return err
+	}
+	copy(f.m.data[:len(hdr)], hdr[:])
+
+	if f.mode == mapDAX {
+		err = f.persist(0, len(hdr))
+	} else {
+		err = f.m.flushRange(0, len(hdr))
+	}
+	if err == nil {
+		f.Comment = comment
+	}
+	return err
+}
+
+// Close unmaps f and closes its file. If f is writable, modifications are
+// written to the file, but Close does not wait for them to reach stable
+// storage; call Sync first to ensure that.
+//
+// f must not be used after Close.
+func (f *MappedFilter) Close() error {
+	f.SyncFilter.b = nil
+	err := f.m.unmap()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (darwin || dragonfly || freebsd || netbsd || openbsd) && !nounsafe
+// +build darwin dragonfly freebsd netbsd openbsd
+// +build !nounsafe
+
+package blobloom
+
+import (
+	"os"
+	"syscall"
+)
+
+// Flags for DAX mappings. These systems have no MAP_SYNC, so cache flushes
+// alone only make writes durable if the file system guarantees that by
+// other means.
+const mapSyncFlags = syscall.MAP_SHARED
+
+// These systems have no explicit huge page allocation. FreeBSD promotes
+// large mappings to superpages by itself.
+const mapHugeTLB = 0
+
+func hugePageSize() int            { return os.Getpagesize() }
+func adviseHugePages([]byte) error { return nil }
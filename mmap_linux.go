@@ -0,0 +1,49 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nounsafe
+// +build !nounsafe
+
+package blobloom
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"syscall"
+)
+
+// Flags for DAX mappings: MAP_SHARED_VALIDATE|MAP_SYNC, which guarantees
+// that the file's metadata is durable when the mapping's writes are, so
+// that flushing CPU caches suffices. Mapping fails unless the file is on
+// a file system mounted with DAX.
+const mapSyncFlags = 0x03 | 0x80000
+
+// hugePageSize returns the default size of huge pages.
+func hugePageSize() int {
+	p, err := ioutil.ReadFile("/proc/meminfo")
+	if err == nil {
+		for _, line := range strings.Split(string(p), "\n") {
+			var kb int
+			if _, err := fmt.Sscanf(line, "Hugepagesize: %d kB", &kb); err == nil {
+				return kb << 10
+			}
+		}
+	}
+	return 2 << 20
+}
+
+func adviseHugePages(p []byte) error {
+	return syscall.Madvise(p, syscall.MADV_HUGEPAGE)
+}
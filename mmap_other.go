@@ -0,0 +1,33 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows) || nounsafe
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows nounsafe
+
+package blobloom
+
+import "os"
+
+type mapping struct {
+	data []byte
+}
+
+func mapFile(*os.File, int, mapMode) (*mapping, error) { return nil, errMapUnsupported }
+func mapAnon(int, HugePages) (*mapping, error)         { return nil, errMapUnsupported }
+
+func (m *mapping) flush(*os.File) error      { return errMapUnsupported }
+func (m *mapping) flushRange(int, int) error { return errMapUnsupported }
+func (m *mapping) unmap() error              { return errMapUnsupported }
+
+func persist([]byte) bool { return false }
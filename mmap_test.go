@@ -0,0 +1,181 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobloom")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "filter")
+
+	f, err := CreateMapped(path, 1<<14, 5, "mapped")
+	if err == errMapUnsupported {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	assert.EqualValues(t, 1<<14, f.NumBits())
+	assert.Equal(t, "mapped", f.Comment)
+
+	ref := New(1<<14, 5)
+	var h uint64
+	for i := 0; i < 500; i++ {
+		h += 0x9e3779b97f4a7c15
+		f.Add(h)
+		ref.Add(h)
+	}
+	require.NoError(t, f.Sync())
+	require.NoError(t, f.Close())
+
+	_, err = CreateMapped(path, 1<<14, 5, "")
+	assert.True(t, os.IsExist(err))
+
+	// No temporary files are left behind.
+	names, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, names, 1)
+
+	// The file is a regular dump.
+	r, err := os.Open(path)
+	require.NoError(t, err)
+	l, err := NewLoader(r)
+	require.NoError(t, err)
+	g, err := l.Load(nil)
+	r.Close()
+	require.NoError(t, err)
+	assert.True(t, ref.Equals(g))
+
+	// Read-only mappings are copy-on-write.
+	f, err = OpenMapped(path, false)
+	require.NoError(t, err)
+	assert.True(t, f.Has(h))
+	f.Fill()
+	assert.Equal(t, errMapReadOnly, f.Sync())
+
+	assert.Equal(t, errMapReadOnly, f.SetComment("foo"))
+	assert.Equal(t, "mapped", f.Comment)
+	require.NoError(t, f.Close())
+
+	f, err = OpenMapped(path, true)
+	require.NoError(t, err)
+	assert.Equal(t, ref.Cardinality(), f.Cardinality())
+	require.NoError(t, f.SetComment("updated"))
+	require.NoError(t, f.Close())
+
+	f, err = OpenMapped(path, false)
+	require.NoError(t, err)
+	assert.Equal(t, "updated", f.Comment)
+	assert.True(t, f.Has(h))
+	require.NoError(t, f.Close())
+
+	require.NoError(t, ioutil.WriteFile(path, make([]byte, 100), 0644))
+	_, err = OpenMapped(path, false)
+	assert.Error(t, err)
+}
+
+func TestMmap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobloom")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "filter")
+
+	ref := New(1<<15, 4)
+	keys := randomU64(1000, 0x3a9)
+	for _, h := range keys {
+		ref.Add(h)
+	}
+	p, err := ref.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, p, 0644))
+
+	f, err := Mmap(path)
+	if err == errMapUnsupported {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	defer f.Close()
+
+	assert.Equal(t, ref.NumBits(), f.NumBits())
+	for _, h := range keys {
+		assert.True(t, f.Has(h))
+	}
+	assert.Equal(t, errMapReadOnly, f.Sync())
+}
+
+func TestMappedDAX(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobloom")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "filter")
+
+	f, err := CreateMapped(path, 1<<14, 5, "")
+	if err == errMapUnsupported {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// The temporary directory is most likely not on a DAX file system,
+	// so map the file normally and pretend.
+	f, err = OpenMappedDAX(path)
+	if err != nil {
+		t.Logf("OpenMappedDAX: %v", err)
+		f, err = OpenMapped(path, true)
+		require.NoError(t, err)
+		f.mode = mapDAX
+	}
+
+	f.Add(0x0123456789abcdef)
+	f.Fill()
+	require.NoError(t, f.Sync())
+	require.NoError(t, f.Close())
+
+	f, err = OpenMapped(path, false)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, f.FillRatio())
+	require.NoError(t, f.Close())
+}
+
+func TestMappedSeeded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobloom")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "filter")
+
+	f := New(1<<14, 5)
+	f.SetSeed(0x5eed)
+	p, err := f.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, p, 0644))
+
+	// Mapped and paged filters cannot hold a seed, so they refuse
+	// to open a dump that has one.
+	_, err = OpenPaged(path, 10)
+	assert.Error(t, err)
+	_, err = OpenMapped(path, false)
+	if err == errMapUnsupported {
+		t.Skip(err)
+	}
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (darwin || dragonfly || freebsd || linux || netbsd || openbsd) && !nounsafe
+// +build darwin dragonfly freebsd linux netbsd openbsd
+// +build !nounsafe
+
+package blobloom
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+type mapping struct {
+	data []byte
+}
+
+func mapFile(file *os.File, size int, mode mapMode) (*mapping, error) {
+	prot, flags := syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE
+	switch mode {
+	case mapShared:
+		flags = syscall.MAP_SHARED
+	case mapDAX:
+		flags = mapSyncFlags
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, size, prot, flags)
+	if err != nil {
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	return &mapping{data: data}, nil
+}
+
+// mapAnon maps at least size bytes of zeroed memory that is not backed
+// by a file.
+func mapAnon(size int, huge HugePages) (*mapping, error) {
+	flags := syscall.MAP_PRIVATE | syscall.MAP_ANON
+	if huge == ExplicitHugePages {
+		if mapHugeTLB == 0 {
+			return nil, errHugePagesUnsupported
+		}
+		flags |= mapHugeTLB
+		// The length of a hugetlb mapping must be a multiple of the page size.
+		pagesize := hugePageSize()
+		size = (size + pagesize - 1) &^ (pagesize - 1)
+	}
+
+	data, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, flags)
+	if err != nil {
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	if huge == TransparentHugePages {
+		// Errors are ignored: the advice is only a hint.
+		adviseHugePages(data)
+	}
+	return &mapping{data: data}, nil
+}
+
+func (m *mapping) flush(*os.File) error {
+	return m.flushRange(0, len(m.data))
+}
+
+// flushRange flushes the pages containing data[off:off+n] to the file.
+func (m *mapping) flushRange(off, n int) error {
+	pagesize := os.Getpagesize()
+	start := off &^ (pagesize - 1)
+	n += off - start
+
+	_, _, errno := syscall.Syscall(sysMsync,
+		uintptr(unsafe.Pointer(&m.data[start])), uintptr(n), msSync)
+	if errno != 0 {
+		return os.NewSyscallError("msync", errno)
+	}
+	return nil
+}
+
+func (m *mapping) unmap() error {
+	if err := syscall.Munmap(m.data); err != nil {
+		return os.NewSyscallError("munmap", err)
+	}
+	m.data = nil
+	return nil
+}
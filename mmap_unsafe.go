@@ -0,0 +1,34 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows) && !nounsafe
+// +build darwin dragonfly freebsd linux netbsd openbsd windows
+// +build !nounsafe
+
+package blobloom
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// bytesAt returns a slice of size bytes starting at address addr,
+// which must point to memory not managed by the Go runtime.
+func bytesAt(addr uintptr, size int) (p []byte) {
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&p))
+	h.Data = addr
+	h.Len = size
+	h.Cap = size
+	return p
+}
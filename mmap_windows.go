@@ -0,0 +1,105 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows && !nounsafe
+// +build windows,!nounsafe
+
+package blobloom
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+type mapping struct {
+	data   []byte
+	handle syscall.Handle // File mapping object.
+}
+
+func mapFile(file *os.File, size int, mode mapMode) (*mapping, error) {
+	prot, access := uint32(syscall.PAGE_WRITECOPY), uint32(syscall.FILE_MAP_COPY)
+	if mode != mapPrivate {
+		prot, access = syscall.PAGE_READWRITE, syscall.FILE_MAP_WRITE
+	}
+
+	h, err := syscall.CreateFileMapping(syscall.Handle(file.Fd()), nil, prot, 0, 0, nil)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	addr, err := syscall.MapViewOfFile(h, access, 0, 0, uintptr(size))
+	if err != nil {
+		syscall.CloseHandle(h)
+		return nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+
+	m := &mapping{handle: h}
+	m.data = bytesAt(addr, size)
+	return m, nil
+}
+
+// mapAnon maps size bytes of zeroed memory backed by the paging file.
+// Transparent huge pages are not supported and are ignored.
+func mapAnon(size int, huge HugePages) (*mapping, error) {
+	if huge == ExplicitHugePages {
+		return nil, errHugePagesUnsupported
+	}
+
+	h, err := syscall.CreateFileMapping(syscall.InvalidHandle, nil,
+		syscall.PAGE_READWRITE, uint32(uint64(size)>>32), uint32(size), nil)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	addr, err := syscall.MapViewOfFile(h, syscall.FILE_MAP_WRITE, 0, 0, uintptr(size))
+	if err != nil {
+		syscall.CloseHandle(h)
+		return nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+
+	m := &mapping{handle: h}
+	m.data = bytesAt(addr, size)
+	return m, nil
+}
+
+func (m *mapping) flush(file *os.File) error {
+	if err := m.flushRange(0, len(m.data)); err != nil {
+		return err
+	}
+	// FlushViewOfFile does not wait for the data to reach the disk.
+	if err := syscall.FlushFileBuffers(syscall.Handle(file.Fd())); err != nil {
+		return os.NewSyscallError("FlushFileBuffers", err)
+	}
+	return nil
+}
+
+// flushRange flushes the pages containing data[off:off+n] to the file.
+func (m *mapping) flushRange(off, n int) error {
+	addr := uintptr(unsafe.Pointer(&m.data[off]))
+	if err := syscall.FlushViewOfFile(addr, uintptr(n)); err != nil {
+		return os.NewSyscallError("FlushViewOfFile", err)
+	}
+	return nil
+}
+
+func (m *mapping) unmap() error {
+	err := syscall.UnmapViewOfFile(uintptr(unsafe.Pointer(&m.data[0])))
+	if err != nil {
+		err = os.NewSyscallError("UnmapViewOfFile", err)
+	}
+	if cerr := syscall.CloseHandle(m.handle); err == nil && cerr != nil {
+		err = os.NewSyscallError("CloseHandle", cerr)
+	}
+	m.data = nil
+	return err
+}
@@ -0,0 +1,26 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nounsafe
+// +build !nounsafe
+
+package blobloom
+
+// NetBSD's msync is __msync13, which package syscall does not define.
+// Its number and flags are the same on all architectures, but package
+// syscall lacks MS_SYNC for some; golang.org/x/sys/unix has both.
+const (
+	sysMsync = 277 // SYS___MSYNC13.
+	msSync   = 0x4
+)
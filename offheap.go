@@ -0,0 +1,117 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"errors"
+	"fmt"
+	"hash/maphash"
+)
+
+// An OffHeapFilter is a Filter whose blocks are allocated outside the Go
+// heap, using an anonymous memory mapping.
+//
+// The blocks of a Filter contain no pointers, so the garbage collector
+// does not scan them, but they still count toward the heap size that
+// determines when the next collection starts. A multi-gigabyte Filter
+// makes the collector run less often, letting garbage grow by about
+// as much, while an OffHeapFilter does not affect the collector at all.
+//
+// An OffHeapFilter must be released by calling Close. Its memory is not
+// released when it becomes unreachable.
+type OffHeapFilter struct {
+	*Filter
+
+	m *mapping
+}
+
+// HugePages selects whether an OffHeapFilter is backed by huge pages.
+//
+// A filter with many blocks takes a TLB miss on nearly every Add or Has,
+// since each key touches a random block. With huge pages, the TLB covers
+// far more memory and these misses become rare.
+type HugePages int
+
+const (
+	// NoHugePages uses the system's default page size.
+	NoHugePages HugePages = iota
+
+	// TransparentHugePages advises the kernel to back the filter by
+	// huge pages (MADV_HUGEPAGE) when it can. This only has an effect
+	// on Linux with transparent huge pages enabled, and is ignored on
+	// other platforms.
+	TransparentHugePages
+
+	// ExplicitHugePages allocates the filter from the pool of huge pages
+	// reserved by the administrator (MAP_HUGETLB). Allocation fails if
+	// the pool has too few free pages, or on platforms other than Linux.
+	ExplicitHugePages
+)
+
+// OffHeapOptions holds optional parameters for NewOffHeapWithOptions.
+type OffHeapOptions struct {
+	// Trigger the "contains filtered or unexported fields" message for
+	// forward compatibility and force the caller to use named fields.
+	_ struct{}
+
+	// HugePages selects the use of huge pages for filters of at least
+	// HugePagesMinBits bits. Smaller filters use the default page size.
+	HugePages        HugePages
+	HugePagesMinBits uint64
+}
+
+var errHugePagesUnsupported = errors.New("blobloom: huge pages not supported on this platform")
+
+// NewOffHeap constructs an OffHeapFilter with the given numbers of bits
+// and hash functions. These are adjusted as by New.
+//
+// Off-heap allocation is supported on the same platforms as memory-mapped
+// files; see MappedFilter.
+func NewOffHeap(nbits uint64, nhashes int) (*OffHeapFilter, error) {
+	return NewOffHeapWithOptions(nbits, nhashes, OffHeapOptions{})
+}
+
+// NewOffHeapWithOptions is like NewOffHeap, but takes additional options.
+func NewOffHeapWithOptions(nbits uint64, nhashes int, opts OffHeapOptions) (*OffHeapFilter, error) {
+	if !littleEndian() {
+		return nil, errors.New("blobloom: memory mapping requires a little-endian CPU")
+	}
+	nbits, nhashes = fixBitsAndHashes(nbits, nhashes)
+	size := int(nbits / 8)
+	if uint64(size) != nbits/8 {
+		return nil, fmt.Errorf("blobloom: %d bits is too large for this platform", nbits)
+	}
+
+	huge := opts.HugePages
+	if nbits < opts.HugePagesMinBits {
+		huge = NoHugePages
+	}
+	m, err := mapAnon(size, huge)
+	if err != nil {
+		return nil, err
+	}
+	return &OffHeapFilter{
+		// The mapping may be rounded up to a multiple of the page size.
+		Filter: &Filter{b: blocksOf(m.data[:size]), k: nhashes, seed: maphash.MakeSeed()},
+		m:      m,
+	}, nil
+}
+
+// Close releases the memory held by f. The Filter must not be used
+// after Close.
+func (f *OffHeapFilter) Close() error {
+	f.Filter.b = nil
+	return f.m.unmap()
+}
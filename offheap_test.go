@@ -0,0 +1,71 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffHeap(t *testing.T) {
+	f, err := NewOffHeap(1<<16, 4)
+	if err == errMapUnsupported {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	assert.EqualValues(t, 1<<16, f.NumBits())
+	assert.Zero(t, f.Cardinality())
+
+	ref := New(1<<16, 4)
+	for _, h := range randomU64(2000, 0x6f1) {
+		f.Add(h)
+		ref.Add(h)
+	}
+	assert.True(t, ref.Equals(f.Filter))
+
+	g := f.Clone()
+	require.NoError(t, f.Close())
+	assert.True(t, ref.Equals(g))
+}
+
+func TestOffHeapHugePages(t *testing.T) {
+	for _, huge := range []HugePages{TransparentHugePages, ExplicitHugePages} {
+		f, err := NewOffHeapWithOptions(1<<20, 4, OffHeapOptions{
+			HugePages:        huge,
+			HugePagesMinBits: 1 << 20,
+		})
+		if err == errMapUnsupported {
+			t.Skip(err)
+		}
+		if huge == ExplicitHugePages && err != nil {
+			// The huge page pool is usually empty.
+			t.Log(err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.EqualValues(t, 1<<20, f.NumBits())
+
+		keys := randomU64(1000, 0x8c2)
+		for _, h := range keys {
+			f.Add(h)
+		}
+		for _, h := range keys {
+			assert.True(t, f.Has(h))
+		}
+		require.NoError(t, f.Close())
+	}
+}
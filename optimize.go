@@ -35,16 +35,53 @@ type Config struct {
 	// Maximum size of the Bloom filter in bits. Zero means the global
 	// MaxBits constant. A value less than BlockBits means BlockBits.
 	MaxBits uint64
+
+	// SingleProbe makes Optimize size the filter for a single probe per key,
+	// so that each Add or Has sets or tests one bit. This is the fastest
+	// configuration, for latency-critical checks, but it requires much more
+	// memory to achieve the same FPRate: about 1.6 times as much as the
+	// optimal configuration at an FPRate of 10%, 9 times at 1% and 60 times
+	// at 0.1%. The number of hashes returned is two, since one hash selects
+	// the block.
+	SingleProbe bool
+
+	// Seed, if not zero, is set as the seed of the filters returned by
+	// NewOptimized and NewSyncOptimized. See Filter.SetSeed for details.
+	// Optimize ignores it.
+	Seed uint64
+
+	// OnSaturation, if not nil, is set as the saturation function of the
+	// filters returned by NewOptimized and NewSyncOptimized, to be called
+	// when their estimated false positive rate exceeds FPRate.
+	// See Filter.SetSaturationFunc for details. Optimize ignores it.
+	OnSaturation func()
+
+	// Probing is set as the probing scheme of the filters returned by
+	// NewOptimized and NewSyncOptimized. See Filter.SetProbing for details.
+	// Optimize ignores it.
+	Probing Probing
 }
 
-// NewOptimized is shorthand for New(Optimize(config)).
+// NewOptimized is shorthand for New(Optimize(config)),
+// followed by SetSeed(config.Seed), SetProbing(config.Probing) and
+// SetSaturationFunc(config.FPRate, config.OnSaturation).
 func NewOptimized(config Config) *Filter {
-	return New(Optimize(config))
+	f := New(Optimize(config))
+	f.probeSeed = config.Seed
+	f.probing = config.Probing
+	f.sat = newSaturation(config.FPRate, config.OnSaturation)
+	return f
 }
 
-// NewSyncOptimized is shorthand for New(Optimize(config)).
+// NewSyncOptimized is shorthand for NewSync(Optimize(config)),
+// followed by SetSeed(config.Seed), SetProbing(config.Probing) and
+// SetSaturationFunc(config.FPRate, config.OnSaturation).
 func NewSyncOptimized(config Config) *SyncFilter {
-	return NewSync(Optimize(config))
+	f := NewSync(Optimize(config))
+	f.probeSeed = config.Seed
+	f.probing = config.Probing
+	f.sat = newSaturation(config.FPRate, config.OnSaturation)
+	return f
 }
 
 // Optimize returns numbers of keys and hash functions that achieve the
@@ -52,13 +89,19 @@ func NewSyncOptimized(config Config) *SyncFilter {
 //
 // Optimize panics when config.FPRate is invalid.
 //
-// The estimated number of bits is imprecise for false positives rates below
-// ca. 1e-15.
+// The number of bits is computed from the false positive rate model of
+// Putze et al. That model assumes independent hash functions, while a
+// filter derives all its bit positions from a single 64-bit hash.
+// Below a false positive rate of about 1e-5, the actual rate is therefore
+// higher than config.FPRate.
+//
+// If config.MaxBits limits the size of the filter, the desired false positive
+// rate may not be achievable. Use OptimizeForBits to find out the actual rate.
 func Optimize(config Config) (nbits uint64, nhashes int) {
 	n := float64(config.Capacity)
 	p := config.FPRate
 
-	if p <= 0 || p > 1 {
+	if !validFPRate(p) {
 		panic("false positive rate for a Bloom filter must be > 0, <= 1")
 	}
 	if n == 0 {
@@ -66,28 +109,27 @@ func Optimize(config Config) (nbits uint64, nhashes int) {
 		n = 1
 	}
 
+	maxbits := maxBits(config)
+	if config.SingleProbe {
+		return singleProbeBits(n, p, maxbits), 2
+	}
+
 	// The optimal nbits/n is c = -log2(p) / ln(2) for a vanilla Bloom filter.
+	// A blocked Bloom filter needs more bits to achieve the same FPR.
 	c := math.Ceil(-math.Log2(p) / math.Ln2)
-	if c < float64(len(correctC)) {
-		c = float64(correctC[int(c)])
+	c = correctC(math.Max(1, c))
+	if c*n >= float64(maxbits) {
+		// Avoid overflow in the conversion and rounding below.
+		nbits = maxbits
 	} else {
-		// We can't achieve the desired FPR. Just triple the number of bits.
-		c *= 3
+		nbits = uint64(c * n)
 	}
-	nbits = uint64(c * n)
 
 	// Round up to a multiple of BlockBits.
 	if nbits%BlockBits != 0 {
 		nbits += BlockBits - nbits%BlockBits
 	}
 
-	var maxbits uint64 = MaxBits
-	if config.MaxBits != 0 && config.MaxBits < maxbits {
-		maxbits = config.MaxBits
-		if maxbits < BlockBits {
-			maxbits = BlockBits
-		}
-	}
 	if nbits > maxbits {
 		nbits = maxbits
 		// Round down to a multiple of BlockBits.
@@ -97,17 +139,13 @@ func Optimize(config Config) (nbits uint64, nhashes int) {
 	// The corresponding optimal number of hash functions is k = c * log(2).
 	// Try rounding up and down to see which rounding is better.
 	c = float64(nbits) / n
-	k := c * math.Ln2
+	k := math.Min(c*math.Ln2, maxHashes)
 	if k < 1 {
 		nhashes = 1
 		return nbits, nhashes
 	}
 
 	ceilK, floorK := math.Floor(k), math.Ceil(k)
-	if ceilK == floorK {
-		return nbits, int(ceilK)
-	}
-
 	fprCeil, _ := fpRate(c, math.Ceil(k))
 	fprFloor, _ := fpRate(c, math.Floor(k))
 	if fprFloor < fprCeil {
@@ -116,18 +154,187 @@ func Optimize(config Config) (nbits uint64, nhashes int) {
 		k = ceilK
 	}
 
+	// With many bits per key, c * log(2) hashes fill up the blocks
+	// and may not achieve the desired FPR.
+	if math.Min(fprCeil, fprFloor) > p {
+		k, _ = optimalHashes(c)
+	}
+
 	return nbits, int(k)
 }
 
+// OptimizeForBits is like Optimize, but also returns the false positive
+// rate that the filter can be expected to have when filled to
+// config.Capacity.
+//
+// When config.MaxBits or the global MaxBits limit the size of the filter,
+// the false positive rate is higher than config.FPRate. Optimize then
+// returns the best filter within the limit. OptimizeForBits lets callers
+// detect this, e.g., to report the problem or to lower the Capacity.
+func OptimizeForBits(config Config) (nbits uint64, nhashes int, fpr float64) {
+	nbits, nhashes = Optimize(config)
+	if config.SingleProbe {
+		// See singleProbeBits.
+		fpr = -math.Expm1(-float64(config.Capacity) / float64(nbits))
+	} else {
+		fpr = FPRate(config.Capacity, nbits, nhashes)
+	}
+	return nbits, nhashes, fpr
+}
+
+// CapacityFor returns the number of distinct keys that can be added to
+// a filter of nbits bits before its false positive rate exceeds fpr, assuming
+// the optimal number of hashes for that many keys. The number of bits is
+// adjusted as by New. To get the number of hashes, call Optimize with the
+// result as the Capacity and nbits as the MaxBits.
+//
+// CapacityFor panics when fpr is invalid. It returns math.MaxUint64
+// when fpr is one.
+func CapacityFor(nbits uint64, fpr float64) uint64 {
+	switch {
+	case !validFPRate(fpr):
+		panic("false positive rate for a Bloom filter must be > 0, <= 1")
+	case fpr == 1:
+		return math.MaxUint64
+	}
+	nbits, _ = fixBitsAndHashes(nbits, 2)
+
+	// bestFPRate increases with the number of keys,
+	// so we can do an exponential search, then a binary search.
+	// The FPR tends to one as the number of keys grows,
+	// so the first loop terminates.
+	lo, hi := uint64(0), uint64(1)
+	for bestFPRate(hi, nbits) <= fpr {
+		lo, hi = hi, 2*hi
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if bestFPRate(mid, nbits) <= fpr {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// bestFPRate returns the lowest false positive rate that nkeys keys
+// can have in a filter of nbits bits, over the number of hashes.
+func bestFPRate(nkeys, nbits uint64) float64 {
+	_, p := optimalHashes(float64(nbits) / float64(nkeys))
+	return p
+}
+
 // correctC maps c = m/n for a vanilla Bloom filter to the c' for a
-// blocked Bloom filter.
+// blocked Bloom filter that has the same false positive rate.
 //
-// This is Putze et al.'s Table I, extended down to zero.
-// For c > 34, the values become huge and are hard to compute.
-var correctC = []byte{
-	1, 1, 2, 4, 5,
-	6, 7, 8, 9, 10, 11, 12, 13, 14, 16, 17, 18, 20, 21, 23,
-	25, 26, 28, 30, 32, 35, 38, 40, 44, 48, 51, 58, 64, 74, 90,
+// This computes Putze et al.'s Table I for arbitrary c: c' is the least
+// integer at which the blocked filter, with k = c'*ln(2) hashes, matches the
+// vanilla filter's FPR. Beyond c' ≈ 100, that many hashes fill up the blocks
+// and the FPR no longer decreases. From there on, correctC uses the optimal
+// number of hashes instead.
+func correctC(c float64) float64 {
+	p := math.Exp(logFprBlock(c, c*math.Ln2))
+
+	prev := math.Inf(1)
+	for cprime := c; ; cprime++ {
+		fpr, _ := fpRate(cprime, cprime*math.Ln2)
+		switch {
+		case fpr <= p:
+			return cprime
+		case fpr >= prev:
+			return math.Ceil(bitsPerKey(p, cprime-1))
+		}
+		prev = fpr
+	}
+}
+
+// bitsPerKey returns the least number of bits per key, at least lo, that
+// achieves a false positive rate of p with the optimal number of hashes.
+// It uses bisection, since the FPR decreases as the number of bits grows.
+func bitsPerKey(p, lo float64) float64 {
+	hi := math.Max(lo, 1)
+	for {
+		if _, fpr := optimalHashes(hi); fpr <= p {
+			break
+		}
+		lo, hi = hi, 2*hi
+	}
+
+	for hi-lo > 1e-3*hi {
+		mid := lo + (hi-lo)/2
+		if _, fpr := optimalHashes(mid); fpr <= p {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi
+}
+
+// More hashes than this are never optimal: they would set more than half
+// the bits in a block holding a single key.
+const maxHashes = BlockBits * math.Ln2
+
+// optimalHashes returns the number of hash functions that minimizes the
+// false positive rate at c bits per key, and that rate.
+//
+// For a vanilla Bloom filter, the optimum is k = c*ln(2). For a blocked
+// Bloom filter, that is too many when c is large, since the keys in a block
+// then set most of its bits. optimalHashes starts from c*ln(2), capped at
+// maxHashes, and moves in the direction in which the rate decreases.
+func optimalHashes(c float64) (k, p float64) {
+	k = math.Max(1, math.Min(math.Round(c*math.Ln2), math.Floor(maxHashes)))
+	p, _ = fpRate(c, k)
+
+	for k > 1 {
+		q, _ := fpRate(c, k-1)
+		if q >= p {
+			break
+		}
+		k, p = k-1, q
+	}
+	for k+1 <= maxHashes {
+		q, _ := fpRate(c, k+1)
+		if q >= p {
+			break
+		}
+		k, p = k+1, q
+	}
+	return k, p
+}
+
+func maxBits(config Config) uint64 {
+	var maxbits uint64 = MaxBits
+	if config.MaxBits != 0 && config.MaxBits < maxbits {
+		maxbits = config.MaxBits
+		if maxbits < BlockBits {
+			maxbits = BlockBits
+		}
+	}
+	return maxbits
+}
+
+// singleProbeBits returns the number of bits for n keys at FPR p with
+// one probe per key. Such a filter behaves as a bit array with a single
+// hash function, whatever the block size, so its FPR is 1 - exp(-n/nbits).
+func singleProbeBits(n, p float64, maxbits uint64) uint64 {
+	m := math.Ceil(n / -math.Log1p(-p))
+	if m >= float64(maxbits) {
+		return maxbits - maxbits%BlockBits
+	}
+
+	nbits := uint64(m)
+	if nbits < BlockBits {
+		nbits = BlockBits
+	}
+	if nbits%BlockBits != 0 {
+		nbits += BlockBits - nbits%BlockBits
+	}
+	if nbits > maxbits {
+		nbits = maxbits - maxbits%BlockBits
+	}
+	return nbits
 }
 
 // FPRate computes an estimate of the false positive rate of a Bloom filter
@@ -189,6 +396,27 @@ func (f *Filter) FPRate(nkeys uint64) float64 {
 	return FPRate(nkeys, f.NumBits(), f.k)
 }
 
+// CurrentFPRate estimates f's false positive rate from its current contents:
+// the probability that Has returns true for a key that was not added.
+//
+// Unlike FPRate, which predicts the rate from the number of keys,
+// CurrentFPRate measures how full each block is. It remains meaningful
+// after Intersect, Union and Fold, which make the number of keys unknown.
+func (f *Filter) CurrentFPRate() float64 {
+	return currentFPRate(f.b, f.k, onescount)
+}
+
+func currentFPRate(b []block, nhashes int, onescount func(*block) int) float64 {
+	// Has tests nhashes-1 bits within a single block.
+	k := float64(nhashes - 1)
+
+	var p float64
+	for i := range b {
+		p += math.Pow(float64(onescount(&b[i]))/BlockBits, k)
+	}
+	return p / float64(len(b))
+}
+
 // Log of the FPR of a single block, FPR = (1 - exp(-k/c))^k.
 func logFprBlock(c, k float64) float64 {
 	return k * math.Log1p(-math.Exp(-k/c))
@@ -44,6 +44,48 @@ func TestFPRate(t *testing.T) {
 	assert.InDelta(t, 1.94e-4, FPRate(1, 20, 14), 3e-5)
 }
 
+func TestCurrentFPRate(t *testing.T) {
+	t.Parallel()
+
+	const (
+		nbits, nhashes = 1 << 16, 5
+		nkeys, nprobes = 8000, 100000
+	)
+
+	keys := randomU64(nkeys+nprobes, 0xfb12a7e)
+	probes := keys[nkeys:]
+
+	f, g := New(nbits, nhashes), NewSync(nbits, nhashes)
+	assert.Zero(t, f.CurrentFPRate())
+
+	for _, h := range keys[:nkeys] {
+		f.Add(h)
+		g.Add(h)
+	}
+	assert.InEpsilon(t, f.FPRate(nkeys), f.CurrentFPRate(), .1)
+	assert.Equal(t, f.CurrentFPRate(), g.CurrentFPRate())
+
+	// After intersecting with a filter that shares half the keys,
+	// CurrentFPRate predicts the rate for keys in neither filter.
+	other := New(nbits, nhashes)
+	for _, h := range keys[nkeys/2 : 3*nkeys/2] {
+		other.Add(h)
+	}
+	f.Intersect(other)
+
+	var fp int
+	for _, h := range probes[nkeys:] {
+		if f.Has(h) {
+			fp++
+		}
+	}
+	measured := float64(fp) / float64(len(probes)-nkeys)
+	assert.InEpsilon(t, measured, f.CurrentFPRate(), .2)
+
+	f.Fill()
+	assert.EqualValues(t, 1, f.CurrentFPRate())
+}
+
 func TestFPRateConvergence(t *testing.T) {
 	for _, c := range []struct {
 		c, k float64
@@ -58,6 +100,7 @@ func TestFPRateConvergence(t *testing.T) {
 		{20, 14, 100},
 		{30, 20, 100},
 	} {
+		c := c
 		t.Run(fmt.Sprintf("c=%f,k=%d", c.c, int(c.k)), func(t *testing.T) {
 			t.Parallel()
 
@@ -71,22 +114,42 @@ func TestFPRateConvergence(t *testing.T) {
 func TestFPRateCorrectC(t *testing.T) {
 	t.Parallel()
 
-	// Try to reconstruct the correction table. We may be one bit off.
-	for i, expect := range correctC[1:] {
-		c := float64(i + 1)
-		k := float64(c) * math.Ln2
-		fprBlock := math.Exp(logFprBlock(c, k))
-
-		cprime := c
-		for {
-			if p, _ := fpRate(cprime, k); p <= fprBlock {
-				break
-			}
-			cprime++
-			k = cprime * math.Ln2
-		}
+	// Putze et al.'s Table I, extended down to zero.
+	// We may be one bit off.
+	table := []float64{
+		1, 1, 2, 4, 5,
+		6, 7, 8, 9, 10, 11, 12, 13, 14, 16, 17, 18, 20, 21, 23,
+		25, 26, 28, 30, 32, 35, 38, 40, 44, 48, 51, 58, 64, 74, 90,
+	}
+	for c := 1; c < len(table); c++ {
+		assert.InDelta(t, table[c], correctC(float64(c)), 1, "c = %d", c)
+	}
 
-		assert.InDelta(t, float64(expect), cprime, 1)
+	// Beyond the table, the correction should keep growing and still
+	// achieve the vanilla Bloom filter's FPR.
+	prev := correctC(float64(len(table) - 1))
+	for c := float64(len(table)); c <= 1000; c *= 1.5 {
+		cprime := correctC(c)
+		assert.GreaterOrEqual(t, cprime, prev, "c = %f", c)
+		prev = cprime
+
+		p := math.Exp(logFprBlock(c, c*math.Ln2))
+		k, fpr := optimalHashes(cprime)
+		assert.LessOrEqual(t, fpr, p, "c = %f", c)
+		assert.LessOrEqual(t, k, maxHashes)
+	}
+}
+
+func TestOptimizeLowFPRate(t *testing.T) {
+	t.Parallel()
+
+	const nkeys = 1e6
+	var prev uint64
+	for _, p := range []float64{1e-8, 1e-10, 1e-12, 1e-15, 1e-20, 1e-30} {
+		nbits, nhashes := Optimize(Config{Capacity: nkeys, FPRate: p})
+		assert.LessOrEqual(t, FPRate(nkeys, nbits, nhashes), p, "p = %g", p)
+		assert.GreaterOrEqual(t, nbits, prev, "p = %g", p)
+		prev = nbits
 	}
 }
 
@@ -156,9 +219,106 @@ func TestOptimizeFewBits(t *testing.T) {
 	}
 }
 
+func TestOptimizeForBits(t *testing.T) {
+	t.Parallel()
+
+	// Without a limit, the requested FPR is achieved.
+	config := Config{Capacity: 1e6, FPRate: 1e-3}
+	nbits, nhashes, fpr := OptimizeForBits(config)
+	wantBits, wantHashes := Optimize(config)
+	assert.Equal(t, wantBits, nbits)
+	assert.Equal(t, wantHashes, nhashes)
+	assert.LessOrEqual(t, fpr, config.FPRate)
+
+	// With a limit, the FPR gets worse.
+	config.MaxBits = nbits / 4
+	nbits, _, fpr = OptimizeForBits(config)
+	assert.LessOrEqual(t, nbits, config.MaxBits)
+	assert.Greater(t, fpr, 10*config.FPRate)
+	assert.Less(t, fpr, 1.)
+
+	config = Config{Capacity: 1e4, FPRate: .01, SingleProbe: true}
+	_, _, fpr = OptimizeForBits(config)
+	assert.InDelta(t, .01, fpr, 1e-4)
+	config.MaxBits = 1e5
+	_, _, fpr = OptimizeForBits(config)
+	assert.InDelta(t, -math.Expm1(-.1), fpr, .01)
+}
+
+func TestCapacityFor(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		nbits      uint64
+		fpr        float64
+		minC, maxC float64 // Expected range of bits per key.
+	}{
+		{1 << 20, .01, 9, 12},
+		{1 << 20, 1e-6, 30, 45},
+		{1 << 30, 1e-3, 13, 18},
+		{BlockBits, .5, 1, 2},
+	} {
+		n := CapacityFor(c.nbits, c.fpr)
+		assert.LessOrEqual(t, bestFPRate(n, c.nbits), c.fpr)
+		assert.Greater(t, bestFPRate(n+1, c.nbits), c.fpr)
+
+		bitsPerKey := float64(c.nbits) / float64(n)
+		assert.GreaterOrEqual(t, bitsPerKey, c.minC)
+		assert.LessOrEqual(t, bitsPerKey, c.maxC)
+
+		// A filter sized by Optimize for n keys should fit in nbits
+		// and get close to the desired FPR. Optimize's choice of the
+		// number of hashes is approximate, so the FPR may be higher.
+		nbits, _, fpr := OptimizeForBits(Config{Capacity: n, FPRate: c.fpr, MaxBits: c.nbits})
+		assert.LessOrEqual(t, nbits, c.nbits)
+		assert.Less(t, fpr, 3*c.fpr)
+	}
+
+	// Fewer keys fit at lower FPRs.
+	assert.Less(t, CapacityFor(1<<20, 1e-4), CapacityFor(1<<20, 1e-3))
+
+	// Bits are rounded up to a block.
+	assert.Equal(t, CapacityFor(BlockBits, .1), CapacityFor(1, .1))
+
+	assert.Equal(t, uint64(math.MaxUint64), CapacityFor(1<<20, 1))
+	assert.Panics(t, func() { CapacityFor(1<<20, 0) })
+	assert.Panics(t, func() { CapacityFor(1<<20, math.NaN()) })
+}
+
 func TestOptimizeInvalidFPRate(t *testing.T) {
 	t.Parallel()
 
 	assert.Panics(t, func() { Optimize(Config{FPRate: 0}) })
 	assert.Panics(t, func() { Optimize(Config{FPRate: 1.0000001}) })
 }
+
+func TestOptimizeSingleProbe(t *testing.T) {
+	t.Parallel()
+
+	const n = 10000
+	config := Config{Capacity: n, FPRate: .01, SingleProbe: true}
+	nbits, nhashes := Optimize(config)
+	assert.Equal(t, 2, nhashes)
+
+	config.SingleProbe = false
+	optimal, _ := Optimize(config)
+	assert.InDelta(t, 9, float64(nbits)/float64(optimal), 1)
+
+	f := New(nbits, nhashes)
+	for _, h := range randomU64(n, 0x51) {
+		f.Add(h)
+	}
+	var fp int
+	for _, h := range randomU64(100000, 0x52) {
+		if f.Has(h) {
+			fp++
+		}
+	}
+	assert.InDelta(t, .01, float64(fp)/100000, .002)
+
+	const maxbits = 1000001
+	nbits, _ = Optimize(Config{Capacity: n, FPRate: 1e-9, SingleProbe: true, MaxBits: maxbits})
+	assert.EqualValues(t, maxbits-maxbits%BlockBits, nbits)
+	nbits, _ = Optimize(Config{FPRate: 1, SingleProbe: true})
+	assert.EqualValues(t, BlockBits, nbits)
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// A PagedFilter is a StorageFilter whose blocks live in a file in the format
+// written by Dump, with the most recently used blocks cached in memory.
+//
+// Unlike a MappedFilter, a PagedFilter bounds its memory use by the size
+// of its cache, so it can hold filters several times larger than RAM.
+// Performance degrades gracefully as the working set outgrows the cache.
+//
+// A PagedFilter is not safe for concurrent use.
+type PagedFilter struct {
+	*StorageFilter
+
+	Comment string // Comment field of the file.
+
+	cache *BlockCache
+	file  *os.File
+}
+
+// CreatePaged creates a file at path holding an empty filter with the
+// given numbers of bits and hashes and comment, and opens it as by
+// OpenPaged. The numbers of bits and hashes are adjusted as by New.
+//
+// CreatePaged fails if the file already exists.
+func CreatePaged(path string, nbits uint64, nhashes int, comment string, cacheBlocks int) (*PagedFilter, error) {
+	nbits, nhashes = fixBitsAndHashes(nbits, nhashes)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// The blocks are left as a hole in the file, which reads as zeros.
+	var buf [64]byte
+	nblocks := nbits / BlockBits
+	_, err = writeHeader(file, &buf, nblocks, nhashes, 0, DoubleHashing, comment)
+	if err == nil {
+		err = file.Truncate(int64(len(buf)) + int64(nblocks)*BlockBytes)
+	}
+	var f *PagedFilter
+	if err == nil {
+		f, err = openPaged(file, cacheBlocks)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return f, nil
+}
+
+// OpenPaged opens the filter in the file at path for reading and writing,
+// caching up to cacheBlocks blocks in memory.
+//
+// Modifications are written to the file when blocks are evicted from the
+// cache, and on Flush and Close. Flush also syncs the file.
+func OpenPaged(path string, cacheBlocks int) (*PagedFilter, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	f, err := openPaged(file, cacheBlocks)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func openPaged(file *os.File, cacheBlocks int) (*PagedFilter, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	l, err := NewLoader(io.NewSectionReader(file, 0, size))
+	switch {
+	case err != nil:
+		return nil, err
+	case l.version != dumpVersion:
+		return nil, fmt.Errorf("blobloom: %s: cannot page a filter with a seed or probing scheme",
+			file.Name())
+	case size != 64+int64(l.nblocks)*BlockBytes:
+		return nil, fmt.Errorf("blobloom: %s: invalid size %d for a filter of %d blocks",
+			file.Name(), size, l.nblocks)
+	}
+
+	c := NewBlockCache(fileStorage{file}, cacheBlocks)
+	return &PagedFilter{
+		StorageFilter: &StorageFilter{st: c, nblocks: uint32(l.nblocks), k: l.nhashes},
+		cache:         c,
+		Comment:       l.Comment,
+		file:          file,
+	}, nil
+}
+
+// CacheStats returns the number of block cache hits and misses so far.
+func (f *PagedFilter) CacheStats() (hits, misses uint64) { return f.cache.Stats() }
+
+// Close flushes f and closes its file.
+func (f *PagedFilter) Close() error {
+	err := f.Flush()
+	if errc := f.file.Close(); err == nil {
+		err = errc
+	}
+	return err
+}
+
+// fileStorage stores blocks in a file in the format written by Dump.
+type fileStorage struct {
+	file *os.File
+}
+
+func (s fileStorage) ReadBlock(i int, p []byte) error {
+	_, err := s.file.ReadAt(p[:BlockBytes], 64+int64(i)*BlockBytes)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (s fileStorage) WriteBlock(i int, p []byte) error {
+	_, err := s.file.WriteAt(p[:BlockBytes], 64+int64(i)*BlockBytes)
+	return err
+}
+
+func (s fileStorage) Flush() error { return s.file.Sync() }
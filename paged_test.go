@@ -0,0 +1,107 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockCache(t *testing.T) {
+	st := &memStorage{blocks: make(map[int][]byte)}
+	c := NewBlockCache(st, 2)
+
+	p := make([]byte, BlockBytes)
+	for i := 0; i < 3; i++ {
+		p[0] = byte(i + 1)
+		require.NoError(t, c.WriteBlock(i, p))
+	}
+	// Block 0 has been evicted and written back.
+	assert.Equal(t, 1, st.writes)
+	assert.EqualValues(t, 1, st.blocks[0][0])
+
+	for i := 2; i >= 0; i-- {
+		require.NoError(t, c.ReadBlock(i, p))
+		assert.EqualValues(t, i+1, p[0])
+	}
+	hits, misses := c.Stats()
+	assert.EqualValues(t, 2, hits)
+	assert.EqualValues(t, 4, misses)
+
+	require.NoError(t, c.Flush())
+	for i := 0; i < 3; i++ {
+		assert.EqualValues(t, i+1, st.blocks[i][0])
+	}
+	writes := st.writes
+	require.NoError(t, c.Flush())
+	assert.Equal(t, writes, st.writes)
+}
+
+func TestPaged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobloom")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "filter")
+
+	const nbits, nhashes = 1 << 16, 5
+
+	f, err := CreatePaged(path, nbits, nhashes, "paged", 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, nbits, f.NumBits())
+	assert.Equal(t, "paged", f.Comment)
+
+	ref := New(nbits, nhashes)
+	var h uint64
+	for i := 0; i < 2000; i++ {
+		h += 0x9e3779b97f4a7c15
+		require.NoError(t, f.Add(h))
+		ref.Add(h)
+	}
+	_, misses := f.CacheStats()
+	assert.Greater(t, misses, uint64(10))
+	require.NoError(t, f.Close())
+
+	_, err = CreatePaged(path, nbits, nhashes, "", 10)
+	assert.True(t, os.IsExist(err))
+
+	// The file is a regular dump.
+	r, err := os.Open(path)
+	require.NoError(t, err)
+	l, err := NewLoader(r)
+	require.NoError(t, err)
+	g, err := l.Load(nil)
+	r.Close()
+	require.NoError(t, err)
+	assert.True(t, ref.Equals(g))
+
+	f, err = OpenPaged(path, 1)
+	require.NoError(t, err)
+	for i := 0; i < 2000; i++ {
+		found, err := f.Has(h)
+		require.NoError(t, err)
+		assert.True(t, found)
+		h -= 0x9e3779b97f4a7c15
+	}
+	require.NoError(t, f.Close())
+
+	require.NoError(t, ioutil.WriteFile(path, make([]byte, 100), 0644))
+	_, err = OpenPaged(path, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// BuildParallel constructs a Filter as by NewOptimized(config) and adds
+// the keys with the hash values received from keys to it, using up to
+// parallelism goroutines. It returns when keys is closed. A parallelism
+// less than one means runtime.GOMAXPROCS(0).
+//
+// Keys are received in batches, because the cost of a channel operation
+// is many times that of an Add: batches of some thousands of keys keep
+// that cost out of the way. The batches must not be modified until
+// BuildParallel returns.
+//
+// The goroutines share a single set of blocks and set bits atomically,
+// like a SyncFilter, so BuildParallel uses no more memory than a Filter
+// and does not need to merge partial filters at the end.
+func BuildParallel(config Config, keys <-chan []uint64, parallelism int) *Filter {
+	if parallelism < 1 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+
+	f := NewSyncOptimized(config)
+
+	var wg sync.WaitGroup
+	wg.Add(parallelism)
+	for i := 0; i < parallelism; i++ {
+		go func() {
+			defer wg.Done()
+			for batch := range keys {
+				f.AddMany(batch)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return &Filter{
+		b:         f.b,
+		k:         f.k,
+		seed:      f.seed,
+		probeSeed: f.probeSeed,
+		probing:   f.probing,
+		sat:       f.sat,
+	}
+}
+
+// unionChunk is the number of blocks that UnionAll processes at a time.
+// A chunk of the result stays in the CPU cache while the corresponding
+// chunks of all inputs are merged into it.
+const unionChunk = 4096
+
+// UnionAll returns a new Filter that is the union of filters, computed by
+// up to runtime.GOMAXPROCS(0) goroutines that each handle a range of blocks.
+// The result has no Recorder or saturation function set.
+//
+// UnionAll panics when filters is empty or when the filters do not all have
+// the same number of bits, hash functions, seed and probing scheme.
+// The filters must not be modified during the call.
+func UnionAll(filters ...*Filter) *Filter {
+	if len(filters) == 0 {
+		panic("no Bloom filters to take the union of")
+	}
+	f0 := filters[0]
+	for _, g := range filters[1:] {
+		checkBinop(f0, g)
+	}
+
+	f := &Filter{
+		b:         make([]block, len(f0.b)),
+		k:         f0.k,
+		seed:      f0.seed,
+		probeSeed: f0.probeSeed,
+		probing:   f0.probing,
+	}
+
+	nchunks := (len(f.b) + unionChunk - 1) / unionChunk
+	parallelism := runtime.GOMAXPROCS(0)
+	if parallelism > nchunks {
+		parallelism = nchunks
+	}
+
+	var (
+		next uint32
+		wg   sync.WaitGroup
+	)
+	wg.Add(parallelism)
+	for i := 0; i < parallelism; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				c := int(atomic.AddUint32(&next, 1) - 1)
+				if c >= nchunks {
+					return
+				}
+				start, end := c*unionChunk, (c+1)*unionChunk
+				if end > len(f.b) {
+					end = len(f.b)
+				}
+
+				dst := Filter{b: f.b[start:end]}
+				copy(dst.b, f0.b[start:end])
+				for _, g := range filters[1:] {
+					dst.union(&Filter{b: g.b[start:end]})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return f
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildParallel(t *testing.T) {
+	keys := randomU64(100000, 0x9a7)
+	config := Config{Capacity: uint64(len(keys)), FPRate: .001, Seed: 0x9a7}
+
+	ch := make(chan []uint64)
+	go func() {
+		for i := 0; i < len(keys); i += 1000 {
+			ch <- keys[i : i+1000]
+		}
+		close(ch)
+	}()
+	f := BuildParallel(config, ch, 4)
+
+	want := NewOptimized(config)
+	want.AddMany(keys)
+	assert.True(t, want.Equals(f))
+	assert.EqualValues(t, 0x9a7, f.Seed())
+
+	ch = make(chan []uint64)
+	close(ch)
+	f = BuildParallel(config, ch, 0)
+	assert.True(t, f.Empty())
+}
+
+func TestUnionAll(t *testing.T) {
+	const nbits, nhashes = 3*unionChunk*BlockBits + 7*BlockBits, 5
+
+	keys := randomU64(200000, 0xa11)
+	want := New(nbits, nhashes)
+	filters := make([]*Filter, 10)
+	for i := range filters {
+		filters[i] = New(nbits, nhashes)
+	}
+	for i, h := range keys {
+		want.Add(h)
+		filters[i%len(filters)].Add(h)
+	}
+
+	f := UnionAll(filters...)
+	assert.True(t, want.Equals(f))
+	assert.True(t, filters[3].Equals(UnionAll(filters[3])))
+	assert.False(t, filters[3].Equals(f))
+
+	assert.Panics(t, func() { UnionAll() })
+	assert.Panics(t, func() { UnionAll(f, New(nbits, nhashes+1)) })
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows) && !nounsafe
+// +build darwin dragonfly freebsd linux netbsd openbsd windows
+// +build !nounsafe
+
+package blobloom
+
+import "unsafe"
+
+// Cache line flush instructions supported by the CPU.
+var hasCLWB, hasCLFLUSHOPT = cpuFlushFeatures()
+
+func cpuFlushFeatures() (clwb, clflushopt bool) {
+	if maxLeaf, _, _ := cpuid(0, 0); maxLeaf < 7 {
+		return false, false
+	}
+	_, ebx, _ := cpuid(7, 0)
+	return ebx&(1<<24) != 0, ebx&(1<<23) != 0
+}
+
+// Implemented in persist_amd64.s.
+func clwb(addr uintptr)
+func clflushopt(addr uintptr)
+func clflush(addr uintptr)
+func sfence()
+
+// persist writes the cache lines holding p back to memory, then waits for
+// the writes to complete. It reports whether it succeeded, which it
+// always does on amd64.
+func persist(p []byte) bool {
+	const lineSize = 64
+	start := uintptr(unsafe.Pointer(&p[0])) &^ (lineSize - 1)
+	end := uintptr(unsafe.Pointer(&p[0])) + uintptr(len(p))
+
+	switch {
+	case hasCLWB:
+		for addr := start; addr < end; addr += lineSize {
+			clwb(addr)
+		}
+	case hasCLFLUSHOPT:
+		for addr := start; addr < end; addr += lineSize {
+			clflushopt(addr)
+		}
+	default:
+		// CLFLUSH is ordered with respect to other writes,
+		// but the SFENCE doesn't hurt.
+		for addr := start; addr < end; addr += lineSize {
+			clflush(addr)
+		}
+	}
+	sfence()
+	return true
+}
@@ -0,0 +1,42 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows) && !nounsafe
+// +build darwin dragonfly freebsd linux netbsd openbsd windows
+// +build !nounsafe
+
+#include "textflag.h"
+
+// func clwb(addr uintptr)
+TEXT ·clwb(SB), NOSPLIT, $0-8
+	MOVQ addr+0(FP), AX
+	CLWB (AX)
+	RET
+
+// func clflushopt(addr uintptr)
+TEXT ·clflushopt(SB), NOSPLIT, $0-8
+	MOVQ addr+0(FP), AX
+	CLFLUSHOPT (AX)
+	RET
+
+// func clflush(addr uintptr)
+TEXT ·clflush(SB), NOSPLIT, $0-8
+	MOVQ addr+0(FP), AX
+	CLFLUSH (AX)
+	RET
+
+// func sfence()
+TEXT ·sfence(SB), NOSPLIT, $0-0
+	SFENCE
+	RET
@@ -0,0 +1,67 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+// Positions returns the positions of the bits that Add sets for a key with
+// hash value h, in a filter with the given numbers of bits and hashes,
+// in probe order. Positions may contain duplicates. Has(h) returns true
+// if and only if all of these bits are set.
+//
+// A position p refers to bit p%8 of byte p/8 of the block data in the
+// format produced by Dump, or equivalently, bit p%32 of the p/32'th
+// little-endian 32-bit word. Systems that cannot call this package,
+// such as GPU kernels, other languages or SQL functions, can compute
+// the positions themselves with 32-bit unsigned arithmetic that wraps
+// around:
+//
+//	nblocks := nbits / 512
+//	h1, h2 := uint32(h>>32), uint32(h)
+//	base := 512 * ((uint64(h2) * nblocks) >> 32)
+//	for i := 1; i < nhashes; i++ {
+//		h1 += h2
+//		h2 += i
+//		emit(base + h1%512)
+//	}
+//
+// The numbers of bits and hashes are adjusted as by New.
+func Positions(nbits uint64, nhashes int, h uint64) []uint64 {
+	nbits, nhashes = fixBitsAndHashes(nbits, nhashes)
+	return appendPositions(make([]uint64, 0, nhashes-1), nbits, nhashes, DoubleHashing, h)
+}
+
+// Positions returns the positions of the bits that Add(h) sets in f.
+// See the function Positions for details.
+func (f *Filter) Positions(h uint64) []uint64 {
+	return appendPositions(make([]uint64, 0, f.k-1), f.NumBits(), f.k, f.probing, mix(h, f.probeSeed))
+}
+
+// Positions returns the positions of the bits that Add(h) sets in f.
+// See the function Positions for details.
+func (f *SyncFilter) Positions(h uint64) []uint64 {
+	return appendPositions(make([]uint64, 0, f.k-1), f.NumBits(), f.k, f.probing, mix(h, f.probeSeed))
+}
+
+func appendPositions(p []uint64, nbits uint64, nhashes int, probing Probing, h uint64) []uint64 {
+	h1, h2 := uint32(h>>32), uint32(h)
+	base := BlockBits * uint64(reducerange(h2, uint32(nbits/BlockBits)))
+	h3 := thirdhash(probing, h1)
+
+	// Same as Add.
+	for i := 1; i < nhashes; i++ {
+		h1, h2 = probehash(h1, h2, h3, i)
+		p = append(p, base+uint64(h1%BlockBits))
+	}
+	return p
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPositions(t *testing.T) {
+	const nbits, nhashes = 10 * BlockBits, 7
+
+	f := New(nbits, nhashes)
+	g := New(nbits, nhashes)
+	s := NewSync(nbits, nhashes)
+	for _, h := range randomU64(200, 0x905) {
+		f.Add(h)
+
+		p := Positions(nbits, nhashes, h)
+		assert.Len(t, p, nhashes-1)
+		assert.Equal(t, p, f.Positions(h))
+		assert.Equal(t, p, s.Positions(h))
+		for _, i := range p {
+			assert.Equal(t, f.BlockIndex(h), int(i/BlockBits))
+			g.b[i/BlockBits].setbit(uint32(i))
+		}
+	}
+	assert.True(t, f.Equals(g))
+
+	// Positions address bits of the dumped data.
+	var buf bytes.Buffer
+	_, err := Dump(&buf, f, "")
+	require.NoError(t, err)
+	data := buf.Bytes()[64:]
+	for _, i := range f.Positions(0xdeadbeef) {
+		assert.Equal(t, f.b[i/BlockBits].getbit(uint32(i)), data[i/8]&(1<<(i%8)) != 0)
+	}
+}
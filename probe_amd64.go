@@ -0,0 +1,57 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nounsafe
+// +build !nounsafe
+
+package blobloom
+
+// Implemented in probe_amd64.s. They probe up to 16 bits, so they require
+// nhashes <= maxVectorHashes.
+//
+//go:noescape
+func probeAVX2(b *block, h1, h2 uint32, nhashes int) bool
+
+//go:noescape
+func probeAVX512(b *block, h1, h2 uint32, nhashes int) bool
+
+const maxVectorHashes = 17
+
+// minVectorHashes is the smallest number of hashes for which hasVector
+// beats the scalar loop in Filter.has. The scalar loop exits at the first
+// unset bit, so it is faster for lookups of absent keys when nhashes is
+// small, while the vector kernels take constant time. The thresholds are
+// where the kernels break even for a mix of present and absent keys.
+var minVectorHashes = func() int {
+	switch {
+	case hasAVX512:
+		return 8
+	case hasAVX2:
+		return 14
+	}
+	return maxVectorHashes + 1
+}()
+
+// useVector reports whether Filter.has should call hasVector.
+func useVector(nhashes int) bool {
+	return nhashes >= minVectorHashes && nhashes <= maxVectorHashes
+}
+
+// hasVector probes the bits for h1, h2 in b in parallel.
+func hasVector(b *block, h1, h2 uint32, nhashes int) bool {
+	if hasAVX512 {
+		return probeAVX512(b, h1, h2, nhashes)
+	}
+	return probeAVX2(b, h1, h2, nhashes)
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nounsafe
+// +build !nounsafe
+
+#include "textflag.h"
+
+// The kernels in this file compute the positions of up to sixteen probes
+// in parallel, one per 32-bit lane. After i iterations of doublehash,
+//
+//	h1 = h1₀ + i*h2₀ + (i-1)i(i+1)/6,
+//
+// so lane j, for probe i = j+1, computes h1₀ + mul[j]*h2₀ + add[j].
+// The words holding the probed bits are selected from the block by
+// a permutation.
+
+DATA probeMul<>+0x00(SB)/4, $1
+DATA probeMul<>+0x04(SB)/4, $2
+DATA probeMul<>+0x08(SB)/4, $3
+DATA probeMul<>+0x0c(SB)/4, $4
+DATA probeMul<>+0x10(SB)/4, $5
+DATA probeMul<>+0x14(SB)/4, $6
+DATA probeMul<>+0x18(SB)/4, $7
+DATA probeMul<>+0x1c(SB)/4, $8
+DATA probeMul<>+0x20(SB)/4, $9
+DATA probeMul<>+0x24(SB)/4, $10
+DATA probeMul<>+0x28(SB)/4, $11
+DATA probeMul<>+0x2c(SB)/4, $12
+DATA probeMul<>+0x30(SB)/4, $13
+DATA probeMul<>+0x34(SB)/4, $14
+DATA probeMul<>+0x38(SB)/4, $15
+DATA probeMul<>+0x3c(SB)/4, $16
+GLOBL probeMul<>(SB), RODATA|NOPTR, $64
+
+DATA probeAdd<>+0x00(SB)/4, $0
+DATA probeAdd<>+0x04(SB)/4, $1
+DATA probeAdd<>+0x08(SB)/4, $4
+DATA probeAdd<>+0x0c(SB)/4, $10
+DATA probeAdd<>+0x10(SB)/4, $20
+DATA probeAdd<>+0x14(SB)/4, $35
+DATA probeAdd<>+0x18(SB)/4, $56
+DATA probeAdd<>+0x1c(SB)/4, $84
+DATA probeAdd<>+0x20(SB)/4, $120
+DATA probeAdd<>+0x24(SB)/4, $165
+DATA probeAdd<>+0x28(SB)/4, $220
+DATA probeAdd<>+0x2c(SB)/4, $286
+DATA probeAdd<>+0x30(SB)/4, $364
+DATA probeAdd<>+0x34(SB)/4, $455
+DATA probeAdd<>+0x38(SB)/4, $560
+DATA probeAdd<>+0x3c(SB)/4, $680
+GLOBL probeAdd<>(SB), RODATA|NOPTR, $64
+
+// Eight all-ones lanes followed by eight zero lanes. Loading eight lanes
+// at offset 4*(8-n) gives a mask of the first n lanes.
+DATA laneMask<>+0x00(SB)/8, $-1
+DATA laneMask<>+0x08(SB)/8, $-1
+DATA laneMask<>+0x10(SB)/8, $-1
+DATA laneMask<>+0x18(SB)/8, $-1
+DATA laneMask<>+0x20(SB)/8, $0
+DATA laneMask<>+0x28(SB)/8, $0
+DATA laneMask<>+0x30(SB)/8, $0
+DATA laneMask<>+0x38(SB)/8, $0
+GLOBL laneMask<>(SB), RODATA|NOPTR, $64
+
+// func probeAVX512(b *block, h1, h2 uint32, nhashes int) bool
+TEXT ·probeAVX512(SB), NOSPLIT, $0-25
+	MOVQ b+0(FP), AX
+	MOVL h1+8(FP), DX
+	VPBROADCASTD DX, Z1
+	MOVL h2+12(FP), DX
+	VPBROADCASTD DX, Z2
+	MOVQ nhashes+16(FP), CX
+
+	// Z1 = positions.
+	VPMULLD probeMul<>(SB), Z2, Z2
+	VPADDD Z2, Z1, Z1
+	VPADDD probeAdd<>(SB), Z1, Z1
+
+	// Z2 = words holding the positions. VPERMD uses the low four bits
+	// of the word indexes.
+	VPSRLD $5, Z1, Z2
+	VPERMD (AX), Z2, Z2
+
+	// Z1 = bits at the positions within their words.
+	MOVL $31, DX
+	VPBROADCASTD DX, Z3
+	VPANDD Z3, Z1, Z1
+	MOVL $1, DX
+	VPBROADCASTD DX, Z3
+	VPSLLVD Z1, Z3, Z1
+
+	// K2 = first nhashes-1 lanes.
+	DECQ CX
+	MOVL $1, DX
+	SHLL CX, DX
+	DECL DX
+	KMOVW DX, K2
+
+	// K1 = lanes where the bit is not set.
+	VPTESTNMD Z1, Z2, K2, K1
+	KORTESTW K1, K1
+	SETEQ ret+24(FP)
+	VZEROUPPER
+	RET
+
+// func probeAVX2(b *block, h1, h2 uint32, nhashes int) bool
+TEXT ·probeAVX2(SB), NOSPLIT, $0-25
+	MOVQ b+0(FP), AX
+	MOVL h1+8(FP), DX
+	VMOVD DX, X1
+	VPBROADCASTD X1, Y1
+	MOVL h2+12(FP), DX
+	VMOVD DX, X2
+	VPBROADCASTD X2, Y2
+	MOVQ nhashes+16(FP), CX
+	DECQ CX
+
+	VMOVDQU (AX), Y8  // Words 0-7 of the block.
+	VMOVDQU 32(AX), Y9 // Words 8-15.
+	MOVL $31, DX
+	VMOVD DX, X3
+	VPBROADCASTD X3, Y10
+	MOVL $1, DX
+	VMOVD DX, X3
+	VPBROADCASTD X3, Y11
+	VPXOR Y12, Y12, Y12
+	LEAQ probeMul<>(SB), BX
+	LEAQ probeAdd<>(SB), SI
+	LEAQ laneMask<>+32(SB), DI
+
+	// Eight probes per iteration. CX is the number of probes left.
+loop:
+	// Y3 = positions.
+	VPMULLD (BX), Y2, Y3
+	VPADDD Y1, Y3, Y3
+	VPADDD (SI), Y3, Y3
+
+	// Y4 = words holding the positions. VPERMD selects from eight words,
+	// so select from both halves of the block and blend by bit 3 of the
+	// word index, shifted into the sign bit.
+	VPSRLD $5, Y3, Y5
+	VPERMD Y8, Y5, Y4
+	VPERMD Y9, Y5, Y6
+	VPSLLD $28, Y5, Y5
+	VBLENDVPS Y5, Y6, Y4, Y4
+
+	// Y3 = bits at the positions within their words.
+	VPAND Y10, Y3, Y3
+	VPSLLVD Y3, Y11, Y3
+
+	// Y4 = lanes where the bit is not set, among the first min(CX, 8).
+	VPAND Y3, Y4, Y4
+	VPCMPEQD Y12, Y4, Y4
+	MOVQ CX, DX
+	CMPQ DX, $8
+	JLE masklanes
+	MOVQ $8, DX
+
+masklanes:
+	SHLQ $2, DX
+	MOVQ DI, R8
+	SUBQ DX, R8
+	VPAND (R8), Y4, Y4
+	VPTEST Y4, Y4
+	JNZ notfound
+
+	ADDQ $32, BX
+	ADDQ $32, SI
+	SUBQ $8, CX
+	JG loop
+
+	MOVB $1, ret+24(FP)
+	VZEROUPPER
+	RET
+
+notfound:
+	MOVB $0, ret+24(FP)
+	VZEROUPPER
+	RET
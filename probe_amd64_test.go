@@ -0,0 +1,30 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nounsafe
+// +build !nounsafe
+
+package blobloom
+
+// vectorKernels returns the kernels supported by the CPU, by name.
+func vectorKernels() map[string]func(*block, uint32, uint32, int) bool {
+	kernels := map[string]func(*block, uint32, uint32, int) bool{}
+	if hasAVX2 {
+		kernels["AVX2"] = probeAVX2
+	}
+	if hasAVX512 {
+		kernels["AVX512"] = probeAVX512
+	}
+	return kernels
+}
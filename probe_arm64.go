@@ -0,0 +1,43 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nounsafe
+// +build !nounsafe
+
+package blobloom
+
+// Implemented in probe_arm64.s. It probes up to 16 bits, so it requires
+// nhashes <= maxVectorHashes.
+//
+//go:noescape
+func probeNEON(b *block, h1, h2 uint32, nhashes int) bool
+
+const maxVectorHashes = 17
+
+// minVectorHashes is the smallest number of hashes for which hasVector
+// is used; see probe_amd64.go. The NEON kernel, like the AVX-512 one,
+// probes all bits in a single pass. This threshold is that of the AVX2
+// kernel, as it has not been measured on arm64 hardware.
+const minVectorHashes = 14
+
+// useVector reports whether Filter.has should call hasVector.
+func useVector(nhashes int) bool {
+	return nhashes >= minVectorHashes && nhashes <= maxVectorHashes
+}
+
+// hasVector probes the bits for h1, h2 in b in parallel.
+// Every arm64 processor has NEON, so no feature check is needed.
+func hasVector(b *block, h1, h2 uint32, nhashes int) bool {
+	return probeNEON(b, h1, h2, nhashes)
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !nounsafe
+// +build !nounsafe
+
+#include "textflag.h"
+
+// probeNEON computes the positions of up to sixteen probes modulo 2¹⁶,
+// in 16-bit lanes, since only the low nine bits of a position select a bit
+// of the block. As in probe_amd64.s, lane j, for probe i = j+1, computes
+// h1₀ + (j+1)*h2₀ + add[j]. The multiplications are sums of h2₀ shifted
+// by the set bits of j+1. The bytes holding the probed bits are selected
+// from the block by TBL, with byte indexes pos>>3 and bit masks 1<<(pos&7).
+
+// neonMulBits<>+16*b selects the lanes j = 0..7 where bit b of j+1 is set.
+DATA neonMulBits<>+0x00(SB)/8, $0x0000ffff0000ffff
+DATA neonMulBits<>+0x08(SB)/8, $0x0000ffff0000ffff
+DATA neonMulBits<>+0x10(SB)/8, $0x0000ffffffff0000
+DATA neonMulBits<>+0x18(SB)/8, $0x0000ffffffff0000
+DATA neonMulBits<>+0x20(SB)/8, $0xffff000000000000
+DATA neonMulBits<>+0x28(SB)/8, $0x0000ffffffffffff
+DATA neonMulBits<>+0x30(SB)/8, $0x0000000000000000
+DATA neonMulBits<>+0x38(SB)/8, $0xffff000000000000
+GLOBL neonMulBits<>(SB), RODATA|NOPTR, $64
+
+DATA neonAdd<>+0x00(SB)/2, $0
+DATA neonAdd<>+0x02(SB)/2, $1
+DATA neonAdd<>+0x04(SB)/2, $4
+DATA neonAdd<>+0x06(SB)/2, $10
+DATA neonAdd<>+0x08(SB)/2, $20
+DATA neonAdd<>+0x0a(SB)/2, $35
+DATA neonAdd<>+0x0c(SB)/2, $56
+DATA neonAdd<>+0x0e(SB)/2, $84
+DATA neonAdd<>+0x10(SB)/2, $120
+DATA neonAdd<>+0x12(SB)/2, $165
+DATA neonAdd<>+0x14(SB)/2, $220
+DATA neonAdd<>+0x16(SB)/2, $286
+DATA neonAdd<>+0x18(SB)/2, $364
+DATA neonAdd<>+0x1a(SB)/2, $455
+DATA neonAdd<>+0x1c(SB)/2, $560
+DATA neonAdd<>+0x1e(SB)/2, $680
+GLOBL neonAdd<>(SB), RODATA|NOPTR, $32
+
+// Bit masks 1<<i, for i = 0..7.
+DATA neonBits<>+0x00(SB)/8, $0x8040201008040201
+DATA neonBits<>+0x08(SB)/8, $0
+GLOBL neonBits<>(SB), RODATA|NOPTR, $16
+
+// Sixteen zero bytes followed by sixteen all-ones bytes. Loading sixteen
+// bytes at offset 16-n sets all but the first n lanes.
+DATA neonLaneMask<>+0x00(SB)/8, $0
+DATA neonLaneMask<>+0x08(SB)/8, $0
+DATA neonLaneMask<>+0x10(SB)/8, $-1
+DATA neonLaneMask<>+0x18(SB)/8, $-1
+GLOBL neonLaneMask<>(SB), RODATA|NOPTR, $32
+
+// func probeNEON(b *block, h1, h2 uint32, nhashes int) bool
+TEXT ·probeNEON(SB), NOSPLIT, $0-25
+	MOVD b+0(FP), R0
+	MOVWU h1+8(FP), R1
+	MOVWU h2+12(FP), R2
+	MOVD nhashes+16(FP), R3
+
+	VLD1 (R0), [V4.B16, V5.B16, V6.B16, V7.B16]
+	VDUP R1, V1.H8
+	VDUP R2, V2.H8
+
+	// V0 = (j+1)*h2, V3 = 8*h2.
+	MOVD $neonMulBits<>(SB), R4
+	VLD1 (R4), [V16.B16, V17.B16, V18.B16, V19.B16]
+	VAND V16.B16, V2.B16, V0.B16
+	VSHL $1, V2.H8, V3.H8
+	VAND V17.B16, V3.B16, V3.B16
+	VADD V3.H8, V0.H8, V0.H8
+	VSHL $2, V2.H8, V3.H8
+	VAND V18.B16, V3.B16, V3.B16
+	VADD V3.H8, V0.H8, V0.H8
+	VSHL $3, V2.H8, V3.H8
+	VAND V19.B16, V3.B16, V16.B16
+	VADD V16.H8, V0.H8, V0.H8
+
+	// V0 = positions for lanes 0-7, V1 = positions for lanes 8-15.
+	MOVD $neonAdd<>(SB), R4
+	VLD1 (R4), [V16.H8, V17.H8]
+	VADD V1.H8, V0.H8, V0.H8
+	VADD V3.H8, V0.H8, V2.H8
+	VADD V16.H8, V0.H8, V0.H8
+	VADD V17.H8, V2.H8, V1.H8
+
+	// Narrow to bytes: V2 = byte indexes, V3 = bit indexes in the bytes.
+	VUSHR $3, V0.H8, V2.H8
+	VUSHR $3, V1.H8, V3.H8
+	VUZP1 V3.B16, V2.B16, V2.B16
+	VUZP1 V1.B16, V0.B16, V3.B16
+	VMOVI $63, V16.B16
+	VAND V16.B16, V2.B16, V2.B16
+	VMOVI $7, V16.B16
+	VAND V16.B16, V3.B16, V3.B16
+
+	// V2 = bytes holding the probed bits, V3 = masks of the bits.
+	VTBL V2.B16, [V4.B16, V5.B16, V6.B16, V7.B16], V2.B16
+	MOVD $neonBits<>(SB), R4
+	VLD1 (R4), [V16.B16]
+	VTBL V3.B16, [V16.B16], V3.B16
+
+	// V2 = all ones in the lanes where the bit is set
+	// and in the lanes past the first nhashes-1.
+	VAND V3.B16, V2.B16, V2.B16
+	VCMEQ V3.B16, V2.B16, V2.B16
+	MOVD $neonLaneMask<>+17(SB), R4
+	SUB R3, R4, R4
+	VLD1 (R4), [V16.B16]
+	VORR V16.B16, V2.B16, V2.B16
+
+	VMOV V2.D[0], R5
+	VMOV V2.D[1], R6
+	AND R5, R6, R6
+	CMN $1, R6
+	CSET EQ, R7
+	MOVB R7, ret+24(FP)
+	RET
@@ -0,0 +1,73 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (amd64 || arm64) && !nounsafe
+// +build amd64 arm64
+// +build !nounsafe
+
+package blobloom
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeVector(t *testing.T) {
+	kernels := vectorKernels()
+	if len(kernels) == 0 {
+		t.Skip("no vector instructions")
+	}
+
+	r := rand.New(rand.NewSource(0x51d))
+	for nhashes := 2; nhashes <= maxVectorHashes; nhashes++ {
+		for i := 0; i < 1000; i++ {
+			var b block
+			h := r.Uint64()
+			h1, h2 := uint32(h>>32), uint32(h)
+
+			// Half the keys are present.
+			if i%2 == 0 {
+				f := Filter{b: []block{b}, k: nhashes}
+				f.add(h)
+				b = f.b[0]
+			}
+			for j := r.Intn(200); j > 0; j-- {
+				b.setbit(r.Uint32())
+			}
+
+			f := Filter{b: []block{b}, k: nhashes}
+			want := f.hasScalar(h)
+			for name, probe := range kernels {
+				if !assert.Equal(t, want, probe(&b, h1, h2, nhashes), name) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// hasScalar is Filter.has without the vector kernels.
+func (f *Filter) hasScalar(h uint64) bool {
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := getblock(f.b, h2)
+	for i := 1; i < f.k; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		if !b.getbit(h1) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "sync"
+
+// A Probe describes the bits that Add sets for a hash value.
+type Probe struct {
+	Hash  uint64
+	Block int      // Index of the block, as returned by BlockIndex.
+	Bits  []uint16 // Indexes of the bits within the block, in probe order.
+}
+
+// A ProbeLog is a Recorder that records the probe positions of the keys
+// added to a filter, for debugging false positive rates that are higher
+// than expected or keys that are unevenly distributed over the blocks.
+//
+// A ProbeLog keeps the most recent probes in a bounded log and optionally
+// passes every probe to a sink function. It records calls to Add only.
+//
+// A ProbeLog computes probes from the hash values that the filter's Recorder
+// receives, so they are only accurate for filters with a seed of zero.
+//
+// ProbeLog methods are safe for concurrent use, so a ProbeLog can be set
+// on a SyncFilter. Computing and storing the probes is much slower than
+// Add itself; a ProbeLog is meant for debugging, not for production use.
+type ProbeLog struct {
+	// If not nil, Sink is called with every probe.
+	// Calls to Sink are serialized.
+	Sink func(Probe)
+
+	nblocks uint32
+	nhashes int
+
+	mu     sync.Mutex
+	log    []Probe // Ring buffer.
+	next   int     // Position of next write in log.
+	counts map[int]uint64
+}
+
+// NewProbeLog constructs a ProbeLog for a filter with the given numbers
+// of bits and hashes, which keeps the last max probes.
+//
+// The ProbeLog must be set on f with SetRecorder to record anything:
+//
+//	l := blobloom.NewProbeLog(f.NumBits(), f.NumHashes(), 1000)
+//	f.SetRecorder(l)
+func NewProbeLog(nbits uint64, nhashes int, max int) *ProbeLog {
+	nbits, nhashes = fixBitsAndHashes(nbits, nhashes)
+	return &ProbeLog{
+		nblocks: uint32(nbits / BlockBits),
+		nhashes: nhashes,
+		log:     make([]Probe, 0, max),
+		counts:  make(map[int]uint64),
+	}
+}
+
+// Probe returns the probe positions for h.
+func (l *ProbeLog) Probe(h uint64) Probe {
+	h1, h2 := uint32(h>>32), uint32(h)
+	p := Probe{
+		Hash:  h,
+		Block: int(reducerange(h2, l.nblocks)),
+		Bits:  make([]uint16, 0, l.nhashes-1),
+	}
+
+	// Same as Add.
+	for i := 1; i < l.nhashes; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		p.Bits = append(p.Bits, uint16(h1%BlockBits))
+	}
+	return p
+}
+
+// RecordAdd records the probe positions for h.
+func (l *ProbeLog) RecordAdd(h uint64) {
+	p := l.Probe(h)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.counts[p.Block]++
+	switch {
+	case cap(l.log) == 0:
+	case len(l.log) < cap(l.log):
+		l.log = append(l.log, p)
+	default:
+		l.log[l.next] = p
+		l.next = (l.next + 1) % len(l.log)
+	}
+	if l.Sink != nil {
+		l.Sink(p)
+	}
+}
+
+// RecordHas does nothing.
+func (l *ProbeLog) RecordHas(h uint64, found bool) {}
+
+// Probes returns the probes in the log, oldest first.
+func (l *ProbeLog) Probes() []Probe {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ps := make([]Probe, 0, len(l.log))
+	ps = append(ps, l.log[l.next:]...)
+	return append(ps, l.log[:l.next]...)
+}
+
+// BlockCounts returns the number of recorded keys per block, for all keys
+// recorded so far, including those no longer in the log. Blocks that no
+// key was recorded for are omitted.
+func (l *ProbeLog) BlockCounts() map[int]uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counts := make(map[int]uint64, len(l.counts))
+	for i, n := range l.counts {
+		counts[i] = n
+	}
+	return counts
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeLog(t *testing.T) {
+	f := New(1<<14, 6)
+	l := NewProbeLog(f.NumBits(), f.NumHashes(), 3)
+	var sunk int
+	l.Sink = func(Probe) { sunk++ }
+	f.SetRecorder(l)
+
+	r := rand.New(rand.NewSource(0x9e0be))
+	hs := make([]uint64, 5)
+	for i := range hs {
+		hs[i] = r.Uint64()
+		f.Add(hs[i])
+	}
+	assert.Equal(t, 5, sunk)
+
+	ps := l.Probes()
+	assert.Len(t, ps, 3)
+	for i, p := range ps {
+		assert.Equal(t, hs[i+2], p.Hash)
+		assert.Equal(t, f.BlockIndex(p.Hash), p.Block)
+		assert.Len(t, p.Bits, f.NumHashes()-1)
+
+		// The probed bits are exactly those that Add sets.
+		g := New(f.NumBits(), f.NumHashes())
+		g.Add(p.Hash)
+		b := g.b[p.Block]
+		for _, bit := range p.Bits {
+			assert.True(t, b.getbit(uint32(bit)))
+			b[bit/wordSize] &^= 1 << (bit % wordSize)
+		}
+		assert.Equal(t, block{}, b)
+	}
+
+	var total uint64
+	for _, n := range l.BlockCounts() {
+		total += n
+	}
+	assert.EqualValues(t, 5, total)
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+// Probing selects how a Filter derives the bits to set and test within
+// a block from a hash value.
+type Probing int
+
+const (
+	// DoubleHashing derives bit positions by enhanced double hashing of the
+	// upper and lower 32 bits of a hash value. It is the default.
+	//
+	// Positions within a block depend only on the lowest nine bits of
+	// each half, so there are at most 2^18 distinct sets of positions.
+	// A key whose set equals that of another key in its block is always
+	// a false positive, which adds about n/2^18 to the false positive
+	// rate, when blocks hold n keys. At rates below about 1e-4, this term
+	// dominates.
+	DoubleHashing Probing = iota
+
+	// TripleHashing adds a third hash value, taken from bits of the hash
+	// value that double hashing ignores. This raises the number of sets of
+	// positions to 2^27, for a false positive rate that matches FPRate
+	// down to about 1e-6, at the cost of one addition per probe.
+	// Has does not use SIMD instructions with TripleHashing.
+	TripleHashing
+)
+
+// Probing returns the probing scheme of f, as set by SetProbing or
+// Config.Probing.
+func (f *Filter) Probing() Probing { return f.probing }
+
+// SetProbing sets the probing scheme of f.
+//
+// The probing scheme must be set before any keys are added. Like the seed,
+// it is recorded by Dump and restored by a Loader. Union and Intersect
+// panic for filters with different schemes.
+//
+// ProbeLog and the function Positions always use DoubleHashing.
+func (f *Filter) SetProbing(p Probing) { f.probing = p }
+
+// Probing returns the probing scheme of f, as set by SetProbing or
+// Config.Probing.
+func (f *SyncFilter) Probing() Probing { return f.probing }
+
+// SetProbing sets the probing scheme of f.
+// See Filter.SetProbing for details.
+//
+// SetProbing must not be called concurrently with other methods of f.
+func (f *SyncFilter) SetProbing(p Probing) { f.probing = p }
+
+// Probing returns the probing scheme of f, as set by SetProbing.
+func (f *StorageFilter) Probing() Probing { return f.probing }
+
+// SetProbing sets the probing scheme of f.
+// See Filter.SetProbing for details. The scheme is not kept in f's storage.
+func (f *StorageFilter) SetProbing(p Probing) { f.probing = p }
+
+// thirdhash returns the third hash value for probing with p, given
+// the upper 32 bits h1 of a hash value. It is zero for DoubleHashing.
+func thirdhash(p Probing, h1 uint32) uint32 {
+	if p != TripleHashing {
+		return 0
+	}
+	return h1 >> 9
+}
+
+// probehash generates the hash values to use in iteration i of enhanced
+// triple hashing from the values h1, h2 of the previous iteration and h3.
+// It is equal to doublehash for h3 == 0.
+func probehash(h1, h2, h3 uint32, i int) (uint32, uint32) {
+	return h1 + h2, h2 + h3 + uint32(i)
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTripleHashing(t *testing.T) {
+	const (
+		nkeys = 20000
+		nbits = 32 * nkeys
+	)
+	keys := randomU64(nkeys, 0x3ba5)
+
+	f := NewOptimized(Config{Capacity: nkeys, FPRate: 1e-5, Probing: TripleHashing})
+	require.Equal(t, TripleHashing, f.Probing())
+	f.AddMany(keys[:nkeys/2])
+	for _, h := range keys[nkeys/2:] {
+		f.Add(h)
+	}
+
+	g := New(f.NumBits(), f.NumHashes())
+	g.SetProbing(TripleHashing)
+	for _, h := range keys {
+		assert.True(t, g.AddIfNotHas(h))
+	}
+	assert.True(t, f.Equals(g))
+	assert.True(t, f.Equals(f.Clone()))
+
+	sf := NewSync(f.NumBits(), f.NumHashes())
+	sf.SetProbing(TripleHashing)
+	sf.AddMany(keys)
+	assert.Equal(t, f.b, sf.b)
+
+	result := make([]bool, nkeys)
+	f.HasMany(keys, result)
+	for i, h := range keys {
+		assert.True(t, result[i])
+		assert.True(t, f.Has(h))
+		assert.True(t, sf.Has(h))
+
+		for _, pos := range f.Positions(h) {
+			assert.NotZero(t, f.b[pos/BlockBits][pos%BlockBits/32]&(1<<(pos%32)))
+		}
+	}
+
+	d := New(f.NumBits(), f.NumHashes())
+	assert.False(t, d.Equals(f))
+	assert.Panics(t, func() { d.Union(f) })
+
+	// With DoubleHashing, this would be about 6e-5.
+	f = New(nbits, 22)
+	f.SetProbing(TripleHashing)
+	f.AddMany(keys)
+	var fp int
+	others := randomU64(2000000, 0xbad)
+	for _, h := range others {
+		if f.Has(h) {
+			fp++
+		}
+	}
+	want := f.FPRate(nkeys)
+	assert.Less(t, float64(fp)/float64(len(others)), 2*want)
+}
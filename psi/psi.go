@@ -0,0 +1,265 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package psi implements private set intersection (PSI) with Bloom filters.
+//
+// Two parties, a server and a client, each hold a set of keys. The client
+// learns which of its keys the server also holds, or only how many, without
+// either party revealing the keys that are not in the intersection. The
+// protocol is the Diffie-Hellman-based PSI protocol of Meadows (1986) and
+// Huberman, Franklin and Hogg (1999), with the server's encrypted set
+// shipped as a Bloom filter, as in several production PSI libraries:
+//
+//  1. The server, with secret key b, builds a Bloom filter of H(y)^b
+//     for each of its keys y (Server.Filter) and sends it to the client.
+//  2. The client, with secret key a, sends H(x)^a for each of its keys x
+//     (Client.Blind).
+//  3. The server raises every element it receives to the power b
+//     (Server.Process) and returns the results in the same order.
+//  4. The client removes its own key a from the results, leaving H(x)^b,
+//     and looks these up in the filter (Client.Intersection).
+//
+// H hashes keys to the prime-order subgroup of the 2048-bit MODP group
+// of RFC 3526. Each blinded element takes ElementSize bytes on the wire.
+//
+// # Security caveats
+//
+// This package has not been audited. It is meant for estimating overlap
+// between data sets of cooperating organizations, not for adversarial
+// settings. In particular:
+//
+//   - Security holds only against semi-honest (honest-but-curious) parties,
+//     under the decisional Diffie-Hellman assumption. A malicious client
+//     can send arbitrary candidate keys to Process and learn whether the
+//     server holds them. Servers should limit the number of elements they
+//     process per client.
+//   - The client learns the number of keys in the server's set, roughly,
+//     from the size and fill ratio of the filter. The server learns the
+//     number of the client's keys.
+//   - The Bloom filter has false positives, so Intersection may report keys
+//     that the server does not hold. Choose the false positive rate with
+//     the client's set size in mind.
+//   - The arithmetic uses math/big, which is not constant-time. Do not use
+//     this package where an attacker can time the server's computations.
+//   - The protocol is not secure against quantum computers.
+//
+// A Server's or Client's key must be kept secret and may be reused across
+// sessions only if the linkability of sessions is acceptable.
+package psi
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/greatroar/blobloom"
+)
+
+// ElementSize is the size in bytes of a blinded or processed element.
+const ElementSize = 256
+
+// The 2048-bit MODP group from RFC 3526, section 3. p is a safe prime,
+// so the quadratic residues modulo p form a subgroup of prime order q.
+var p, q = func() (*big.Int, *big.Int) {
+	const hex = `
+		FFFFFFFF FFFFFFFF C90FDAA2 2168C234 C4C6628B 80DC1CD1
+		29024E08 8A67CC74 020BBEA6 3B139B22 514A0879 8E3404DD
+		EF9519B3 CD3A431B 302B0A6D F25F1437 4FE1356D 6D51C245
+		E485B576 625E7EC6 F44C42E9 A637ED6B 0BFF5CB6 F406B7ED
+		EE386BFB 5A899FA5 AE9F2411 7C4B1FE6 49286651 ECE45B3D
+		C2007CB8 A163BF05 98DA4836 1C55D39A 69163FA8 FD24CF5F
+		83655D23 DCA3AD96 1C62F356 208552BB 9ED52907 7096966D
+		670C354E 4ABC9804 F1746C08 CA18217C 32905E46 2E36CE3B
+		E39E772C 180E8603 9B2783A2 EC07A28F B5C55DF0 6F4C52C9
+		DE2BCBF6 95581718 3995497C EA956AE5 15D22618 98FA0510
+		15728E5A 8AACAA68 FFFFFFFF FFFFFFFF`
+
+	p, _ := new(big.Int).SetString(strings.Join(strings.Fields(hex), ""), 16)
+	return p, new(big.Int).Rsh(p, 1)
+}()
+
+var errInvalidElement = errors.New("psi: invalid group element")
+
+// A Server holds a set of keys and answers a client's blinded queries.
+type Server struct {
+	key *big.Int
+}
+
+// NewServer constructs a Server with a fresh random key.
+func NewServer() (*Server, error) {
+	key, err := randomExponent()
+	if err != nil {
+		return nil, err
+	}
+	return &Server{key: key}, nil
+}
+
+// Filter returns a Bloom filter of the server's keys, encrypted with its
+// secret key, for sending to clients. The filter is built for the given
+// false positive rate.
+func (s *Server) Filter(keys [][]byte, fpr float64) *blobloom.Filter {
+	f := blobloom.NewOptimized(blobloom.Config{
+		Capacity: uint64(len(keys)),
+		FPRate:   fpr,
+	})
+	for _, k := range keys {
+		x := hashToGroup(k)
+		f.Add(filterHash(x.Exp(x, s.key, p)))
+	}
+	return f
+}
+
+// Process raises each blinded element to the server's key and returns
+// the results in the order of the input.
+func (s *Server) Process(blinded [][]byte) ([][]byte, error) {
+	out := make([][]byte, len(blinded))
+	for i, b := range blinded {
+		x, err := decode(b)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = encode(x.Exp(x, s.key, p))
+	}
+	return out, nil
+}
+
+// ProcessShuffled is like Process, but returns the results in random order.
+// A client can then use Cardinality to learn the size of the intersection,
+// but not which of its keys are in it.
+func (s *Server) ProcessShuffled(blinded [][]byte) ([][]byte, error) {
+	out, err := s.Process(blinded)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(out) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, err
+		}
+		out[i], out[j.Int64()] = out[j.Int64()], out[i]
+	}
+	return out, nil
+}
+
+// A Client holds a set of keys and queries a Server for the intersection.
+type Client struct {
+	key, inv *big.Int
+}
+
+// NewClient constructs a Client with a fresh random key.
+func NewClient() (*Client, error) {
+	key, err := randomExponent()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{key: key, inv: new(big.Int).ModInverse(key, q)}, nil
+}
+
+// Blind returns the client's keys, hashed and encrypted with its secret key,
+// for sending to the server's Process method.
+func (c *Client) Blind(keys [][]byte) [][]byte {
+	out := make([][]byte, len(keys))
+	for i, k := range keys {
+		x := hashToGroup(k)
+		out[i] = encode(x.Exp(x, c.key, p))
+	}
+	return out
+}
+
+// Intersection takes the server's response to Process and the server's
+// filter and returns the indexes of the keys passed to Blind that are
+// in the intersection, in increasing order.
+func (c *Client) Intersection(processed [][]byte, f *blobloom.Filter) ([]int, error) {
+	var idx []int
+	err := c.unblind(processed, f, func(i int) { idx = append(idx, i) })
+	return idx, err
+}
+
+// Cardinality takes the server's response to Process or ProcessShuffled
+// and the server's filter and returns the size of the intersection.
+func (c *Client) Cardinality(processed [][]byte, f *blobloom.Filter) (int, error) {
+	n := 0
+	err := c.unblind(processed, f, func(int) { n++ })
+	return n, err
+}
+
+func (c *Client) unblind(processed [][]byte, f *blobloom.Filter, found func(int)) error {
+	for i, b := range processed {
+		x, err := decode(b)
+		if err != nil {
+			return err
+		}
+		if f.Has(filterHash(x.Exp(x, c.inv, p))) {
+			found(i)
+		}
+	}
+	return nil
+}
+
+// randomExponent returns a random exponent in [1, q).
+func randomExponent() (*big.Int, error) {
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(q, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+// hashToGroup hashes key to an element of the order-q subgroup,
+// by reducing a 2560-bit hash modulo p and squaring the result.
+func hashToGroup(key []byte) *big.Int {
+	var buf [5 * sha512.Size]byte
+	for i := 0; i < 5; i++ {
+		h := sha512.New()
+		h.Write([]byte("blobloom psi v0"))
+		h.Write([]byte{byte(i)})
+		h.Write(key)
+		h.Sum(buf[i*sha512.Size : i*sha512.Size])
+	}
+
+	x := new(big.Int).SetBytes(buf[:])
+	x.Mod(x, p)
+	return x.Mul(x, x).Mod(x, p)
+}
+
+// filterHash returns the hash of x that is stored in the server's filter.
+func filterHash(x *big.Int) uint64 {
+	h := sha256.Sum256(encode(x))
+	return binary.LittleEndian.Uint64(h[:])
+}
+
+func encode(x *big.Int) []byte {
+	b := x.Bytes()
+	out := make([]byte, ElementSize)
+	copy(out[ElementSize-len(b):], b)
+	return out
+}
+
+// decode decodes an element and checks that it is in the order-q subgroup,
+// so a malicious party cannot learn bits of the other's key by sending
+// elements of small order.
+func decode(b []byte) (*big.Int, error) {
+	if len(b) != ElementSize {
+		return nil, errInvalidElement
+	}
+	x := new(big.Int).SetBytes(b)
+	if x.Cmp(big.NewInt(1)) <= 0 || x.Cmp(p) >= 0 || big.Jacobi(x, p) != 1 {
+		return nil, errInvalidElement
+	}
+	return x, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package psi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keys(prefix string, from, to int) [][]byte {
+	var ks [][]byte
+	for i := from; i < to; i++ {
+		ks = append(ks, []byte(fmt.Sprintf("%s%d", prefix, i)))
+	}
+	return ks
+}
+
+func TestPSI(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+	client, err := NewClient()
+	require.NoError(t, err)
+
+	// Keys 30 through 59 are shared.
+	f := server.Filter(keys("user", 30, 100), 1e-6)
+	blinded := client.Blind(keys("user", 0, 60))
+	for _, b := range blinded {
+		assert.Len(t, b, ElementSize)
+	}
+
+	processed, err := server.Process(blinded)
+	require.NoError(t, err)
+	idx, err := client.Intersection(processed, f)
+	require.NoError(t, err)
+
+	want := make([]int, 30)
+	for i := range want {
+		want[i] = 30 + i
+	}
+	assert.Equal(t, want, idx)
+
+	shuffled, err := server.ProcessShuffled(blinded)
+	require.NoError(t, err)
+	n, err := client.Cardinality(shuffled, f)
+	require.NoError(t, err)
+	assert.Equal(t, 30, n)
+}
+
+func TestInvalidElement(t *testing.T) {
+	server, err := NewServer()
+	require.NoError(t, err)
+
+	one := make([]byte, ElementSize)
+	one[ElementSize-1] = 1
+	minusOne := encode(p)
+	minusOne[ElementSize-1]-- // p-1 has order two.
+
+	for _, b := range [][]byte{nil, make([]byte, 10), one, minusOne, encode(p)} {
+		_, err := server.Process([][]byte{b})
+		assert.Equal(t, errInvalidElement, err)
+	}
+}
+
+func TestHashToGroup(t *testing.T) {
+	x := hashToGroup([]byte("foo"))
+	assert.Equal(t, x, hashToGroup([]byte("foo")))
+	assert.NotEqual(t, x, hashToGroup([]byte("bar")))
+
+	_, err := decode(encode(x))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quotient
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Merge returns a new Filter that holds the fingerprints of both a and b.
+//
+// The filters must have the same fingerprint width, qbits+rbits, but may
+// differ in size. The result has enough slots to stay below MaxLoad,
+// taking quotient bits from the remainders if it is larger than both
+// a and b, which keeps the fingerprints intact. Merge returns an error
+// if that would leave no remainder bits.
+func Merge(a, b *Filter) (*Filter, error) {
+	p := a.qbits + a.rbits
+	if b.qbits+b.rbits != p {
+		return nil, fmt.Errorf("quotient: fingerprint widths %d and %d differ",
+			p, b.qbits+b.rbits)
+	}
+
+	qbits := a.qbits
+	if b.qbits > qbits {
+		qbits = b.qbits
+	}
+	for float64(a.count+b.count) > MaxLoad*float64(uint64(1)<<qbits) {
+		qbits++
+	}
+	if qbits >= p {
+		return nil, errors.New("quotient: merged filter would have no remainder bits")
+	}
+
+	f := New(qbits, p-qbits)
+	fa, fb := a.Fingerprints(), b.Fingerprints()
+	for len(fa) > 0 || len(fb) > 0 {
+		var fp uint64
+		switch {
+		case len(fb) == 0 || len(fa) > 0 && fa[0] <= fb[0]:
+			fp, fa = fa[0], fa[1:]
+		default:
+			fp, fb = fb[0], fb[1:]
+		}
+		// Inserting in sorted order only ever appends to runs.
+		if err := f.insert(fp); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Wire format of a Filter, all integers little-endian:
+//
+//	magic "BLQF", version byte (0), qbits byte, rbits byte, zero byte
+//	number of fingerprints: 64 bits
+//	the table, as 64-bit words
+const (
+	magic      = "BLQF"
+	headerSize = 8 + 8
+)
+
+// WriteTo writes f to w in a binary format that ReadFrom accepts.
+func (f *Filter) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, headerSize+8*len(f.table))
+	copy(buf, magic)
+	buf[5] = byte(f.qbits)
+	buf[6] = byte(f.rbits)
+	binary.LittleEndian.PutUint64(buf[8:], f.count)
+	for i, x := range f.table {
+		binary.LittleEndian.PutUint64(buf[headerSize+8*i:], x)
+	}
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadFrom sets f to a Filter read from r, in the format written by WriteTo.
+// If an error occurs, f is left unchanged.
+func (f *Filter) ReadFrom(r io.Reader) (int64, error) {
+	var hdr [headerSize]byte
+	n, err := io.ReadFull(r, hdr[:])
+	if err != nil {
+		return int64(n), err
+	}
+	switch {
+	case string(hdr[:4]) != magic:
+		return int64(n), errors.New("quotient: not a quotient filter")
+	case hdr[4] != 0:
+		return int64(n), errors.New("quotient: unsupported version")
+	}
+
+	qbits, rbits := uint(hdr[5]), uint(hdr[6])
+	if qbits == 0 || rbits == 0 || qbits+rbits > 64 || qbits > 40 {
+		return int64(n), errors.New("quotient: invalid number of bits")
+	}
+	g := New(qbits, rbits)
+	g.count = binary.LittleEndian.Uint64(hdr[8:])
+
+	p := make([]byte, 8*len(g.table))
+	m, err := io.ReadFull(r, p)
+	n += m
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return int64(n), err
+	}
+	for i := range g.table {
+		g.table[i] = binary.LittleEndian.Uint64(p[8*i:])
+	}
+
+	if err := g.check(); err != nil {
+		return int64(n), err
+	}
+	*f = *g
+	return int64(n), nil
+}
+
+// check verifies the invariants that guarantee termination of the loops
+// over the table.
+func (f *Filter) check() error {
+	var nonempty uint64
+	var unshifted, uncontinued bool
+	for i := uint64(0); i < f.NumSlots(); i++ {
+		x := f.get(i)
+		if x&metaMask != 0 {
+			nonempty++
+		}
+		unshifted = unshifted || x&shifted == 0
+		uncontinued = uncontinued || x&continuation == 0
+	}
+	if nonempty != f.count || !unshifted || !uncontinued {
+		return errors.New("quotient: corrupt filter")
+	}
+	return nil
+}
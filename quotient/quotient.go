@@ -0,0 +1,298 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quotient implements quotient filters.
+//
+// A quotient filter stores a p-bit fingerprint of each key in a compact
+// hash table with linear probing. The high q bits of a fingerprint (the
+// quotient) select a slot, and the low r = p-q bits (the remainder) are
+// stored in the table, along with three metadata bits per slot that allow
+// the fingerprints to be reconstructed.
+//
+// Unlike a Bloom filter, a quotient filter can be enumerated, so two filters
+// can be merged into a larger one without access to the original keys and
+// without increasing the false positive rate beyond that of a filter built
+// from all the keys. This makes quotient filters suited to compaction
+// workflows that combine per-segment filters.
+//
+// See Bender et al., Don't Thrash: How to Cache Your Hash on Flash,
+// https://www.vldb.org/pvldb/vol5/p1627_michaelabender_vldb2012.pdf.
+package quotient
+
+import "errors"
+
+// MaxLoad is the maximum fraction of slots in use that Merge produces.
+// Lookups and inserts slow down considerably as the load factor approaches
+// one.
+const MaxLoad = .75
+
+// ErrFull is returned by Add when a filter has no empty slots left.
+var ErrFull = errors.New("quotient: filter is full")
+
+// A Filter is a quotient filter.
+//
+// A Filter is not safe for concurrent use.
+type Filter struct {
+	qbits, rbits uint
+	count        uint64
+	table        []uint64 // Slots of rbits+3 bits each, packed.
+}
+
+// Metadata bits, in the low bits of each slot.
+const (
+	occupied     = 1 << iota // Some key has this slot as its canonical slot.
+	continuation             // Slot is part of the same run as the previous.
+	shifted                  // Slot holds a remainder not in its canonical slot.
+	metaBits     = iota
+	metaMask     = 1<<metaBits - 1
+)
+
+// New constructs a Filter with 2^qbits slots and remainders of rbits bits.
+// Fingerprints are qbits+rbits wide. The false positive rate is about
+// 2^-rbits times the load factor.
+//
+// New panics if qbits or rbits is zero or qbits+rbits exceeds 64.
+func New(qbits, rbits uint) *Filter {
+	if qbits == 0 || rbits == 0 || qbits+rbits > 64 || qbits > 40 {
+		panic("quotient: invalid number of bits")
+	}
+	nslots := uint64(1) << qbits
+	width := uint64(rbits + metaBits)
+	return &Filter{
+		qbits: qbits,
+		rbits: rbits,
+		table: make([]uint64, (nslots*width+63)/64),
+	}
+}
+
+// Add inserts a key with hash value h into f.
+// It returns ErrFull if f has no empty slots left.
+//
+// Keys with equal fingerprints are stored once.
+func (f *Filter) Add(h uint64) error {
+	return f.insert(f.fingerprint(h))
+}
+
+// Has reports whether a key with hash value h has been added.
+// It may return a false positive.
+func (f *Filter) Has(h uint64) bool {
+	fp := f.fingerprint(h)
+	fq, fr := fp>>f.rbits, fp&(1<<f.rbits-1)
+
+	if f.get(fq)&occupied == 0 {
+		return false
+	}
+	s := f.runStart(fq)
+	for {
+		if f.get(s)>>metaBits == fr {
+			return true
+		}
+		s = f.incr(s)
+		if f.get(s)&continuation == 0 {
+			return false
+		}
+	}
+}
+
+// Len returns the number of distinct fingerprints in f.
+func (f *Filter) Len() uint64 { return f.count }
+
+// NumSlots returns the number of slots of f.
+func (f *Filter) NumSlots() uint64 { return 1 << f.qbits }
+
+// QuotientBits returns the number of quotient bits of f.
+func (f *Filter) QuotientBits() uint { return f.qbits }
+
+// RemainderBits returns the number of remainder bits of f.
+func (f *Filter) RemainderBits() uint { return f.rbits }
+
+// fingerprint returns the high qbits+rbits bits of h.
+func (f *Filter) fingerprint(h uint64) uint64 {
+	return h >> (64 - f.qbits - f.rbits)
+}
+
+// Fingerprints returns the fingerprints in f, in increasing order.
+func (f *Filter) Fingerprints() []uint64 {
+	fps := make([]uint64, 0, f.count)
+	f.each(func(fp uint64) { fps = append(fps, fp) })
+
+	// Rotate the fingerprints that wrapped around to the front.
+	for i := 1; i < len(fps); i++ {
+		if fps[i] < fps[i-1] {
+			return append(fps[i:], fps[:i]...)
+		}
+	}
+	return fps
+}
+
+// each calls fn with every fingerprint in f, in slot order starting at
+// the first cluster start. Since runs are sorted by remainder, this is
+// increasing order, except that the fingerprints of the cluster that
+// wraps around the end of the table, if any, may wrap around to zero.
+func (f *Filter) each(fn func(uint64)) {
+	if f.count == 0 {
+		return
+	}
+	n := f.NumSlots()
+
+	var start uint64
+	for start < n && !isClusterStart(f.get(start)) {
+		start++
+	}
+
+	quot := start
+	for i, seen := start, uint64(0); seen < f.count; i = f.incr(i) {
+		x := f.get(i)
+		switch {
+		case isClusterStart(x):
+			quot = i
+		case x&continuation == 0 && x&(occupied|shifted) != 0:
+			// Start of the run for the next occupied quotient.
+			for quot = f.incr(quot); f.get(quot)&occupied == 0; {
+				quot = f.incr(quot)
+			}
+		}
+		if x&metaMask != 0 {
+			fn(quot<<f.rbits | x>>metaBits)
+			seen++
+		}
+	}
+}
+
+func isClusterStart(x uint64) bool {
+	return x&occupied != 0 && x&(continuation|shifted) == 0
+}
+
+func (f *Filter) insert(fp uint64) error {
+	fq, fr := fp>>f.rbits, fp&(1<<f.rbits-1)
+	entry := fr << metaBits
+
+	canonical := f.get(fq)
+	if canonical&metaMask == 0 {
+		f.set(fq, entry|occupied)
+		f.count++
+		return nil
+	}
+	if f.count == f.NumSlots() {
+		return ErrFull
+	}
+	if canonical&occupied == 0 {
+		f.set(fq, canonical|occupied)
+	}
+
+	start := f.runStart(fq)
+	s := start
+	if canonical&occupied != 0 {
+		// Find the position in the existing run, which is sorted.
+		for {
+			rem := f.get(s) >> metaBits
+			if rem == fr {
+				return nil
+			} else if rem > fr {
+				break
+			}
+			s = f.incr(s)
+			if f.get(s)&continuation == 0 {
+				break
+			}
+		}
+
+		if s == start {
+			// The old start of the run becomes a continuation.
+			f.set(start, f.get(start)|continuation)
+		} else {
+			entry |= continuation
+		}
+	}
+	if s != fq {
+		entry |= shifted
+	}
+
+	f.shiftInsert(s, entry)
+	f.count++
+	return nil
+}
+
+// shiftInsert inserts entry at slot s, shifting subsequent entries up to
+// the next empty slot. The occupied bits stay with their slots.
+func (f *Filter) shiftInsert(s, entry uint64) {
+	cur := entry
+	for {
+		prev := f.get(s)
+		empty := prev&metaMask == 0
+		if !empty {
+			prev |= shifted
+			if prev&occupied != 0 {
+				cur |= occupied
+				prev &^= occupied
+			}
+		}
+		f.set(s, cur)
+		if empty {
+			return
+		}
+		cur = prev
+		s = f.incr(s)
+	}
+}
+
+// runStart returns the slot where the run for quotient fq starts.
+func (f *Filter) runStart(fq uint64) uint64 {
+	// Find the start of the cluster.
+	b := fq
+	for f.get(b)&shifted != 0 {
+		b = f.decr(b)
+	}
+
+	// Skip the runs of the occupied quotients in the cluster before fq.
+	s := b
+	for b != fq {
+		for s = f.incr(s); f.get(s)&continuation != 0; {
+			s = f.incr(s)
+		}
+		for b = f.incr(b); f.get(b)&occupied == 0; {
+			b = f.incr(b)
+		}
+	}
+	return s
+}
+
+func (f *Filter) incr(i uint64) uint64 { return (i + 1) & (f.NumSlots() - 1) }
+func (f *Filter) decr(i uint64) uint64 { return (i - 1) & (f.NumSlots() - 1) }
+
+// get returns the contents of slot i.
+func (f *Filter) get(i uint64) uint64 {
+	width := uint64(f.rbits + metaBits)
+	pos := i * width
+	w, off := pos/64, pos%64
+
+	x := f.table[w] >> off
+	if off+width > 64 {
+		x |= f.table[w+1] << (64 - off)
+	}
+	return x & (1<<width - 1)
+}
+
+// set sets the contents of slot i to x.
+func (f *Filter) set(i, x uint64) {
+	width := uint64(f.rbits + metaBits)
+	mask := uint64(1)<<width - 1
+	pos := i * width
+	w, off := pos/64, pos%64
+
+	f.table[w] = f.table[w]&^(mask<<off) | x<<off
+	if off+width > 64 {
+		f.table[w+1] = f.table[w+1]&^(mask>>(64-off)) | x>>(64-off)
+	}
+}
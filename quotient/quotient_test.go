@@ -0,0 +1,148 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quotient
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashes(n int, seed int64) []uint64 {
+	r := rand.New(rand.NewSource(seed))
+	hs := make([]uint64, n)
+	for i := range hs {
+		hs[i] = r.Uint64()
+	}
+	return hs
+}
+
+func TestFilter(t *testing.T) {
+	const n = 6000
+
+	f := New(13, 16)
+	keys := hashes(n, 0x9f)
+	for _, h := range keys {
+		require.NoError(t, f.Add(h))
+	}
+	assert.Equal(t, uint64(n), f.Len()) // Probably no fingerprint collisions.
+
+	for _, h := range keys {
+		assert.True(t, f.Has(h))
+	}
+
+	var fp int
+	for _, h := range hashes(n, 0xfa15e) {
+		if f.Has(h) {
+			fp++
+		}
+	}
+	assert.Less(t, fp, n/200)
+
+	fps := f.Fingerprints()
+	assert.Len(t, fps, n)
+	assert.True(t, sort.SliceIsSorted(fps, func(i, j int) bool { return fps[i] < fps[j] }))
+	for _, h := range keys {
+		i := sort.Search(len(fps), func(i int) bool { return fps[i] >= f.fingerprint(h) })
+		assert.Equal(t, f.fingerprint(h), fps[i])
+	}
+}
+
+func TestFull(t *testing.T) {
+	f := New(4, 8)
+	for _, h := range hashes(100, 1) {
+		if err := f.Add(h); err != nil {
+			assert.Equal(t, ErrFull, err)
+			break
+		}
+	}
+	assert.Equal(t, f.NumSlots(), f.Len())
+	assert.Len(t, f.Fingerprints(), 16)
+}
+
+func TestWraparound(t *testing.T) {
+	// Keys with the highest quotient spill over into the first slots.
+	f := New(4, 4)
+	for i := uint64(0); i < 4; i++ {
+		require.NoError(t, f.Add(0xf<<60|i<<56))
+	}
+	require.NoError(t, f.Add(0))
+	assert.Equal(t, []uint64{0x00, 0xf0, 0xf1, 0xf2, 0xf3}, f.Fingerprints())
+	assert.True(t, f.Has(0xf3<<56))
+	assert.True(t, f.Has(0))
+	assert.False(t, f.Has(0x01<<56))
+}
+
+func TestMerge(t *testing.T) {
+	a, b := New(10, 14), New(12, 12)
+	ka, kb := hashes(700, 2), hashes(3000, 3)
+	for _, h := range ka {
+		require.NoError(t, a.Add(h))
+	}
+	for _, h := range kb {
+		require.NoError(t, b.Add(h))
+	}
+	// Shared keys.
+	for _, h := range ka[:100] {
+		require.NoError(t, b.Add(h))
+	}
+
+	f, err := Merge(a, b)
+	require.NoError(t, err)
+	assert.Equal(t, uint(13), f.QuotientBits())
+	assert.Equal(t, uint(11), f.RemainderBits())
+	assert.Equal(t, uint64(3700), f.Len())
+
+	for _, h := range append(ka, kb...) {
+		assert.True(t, f.Has(h))
+	}
+
+	_, err = Merge(a, New(10, 10))
+	assert.Error(t, err)
+	_, err = Merge(New(2, 1), New(2, 1))
+	assert.NoError(t, err)
+}
+
+func TestWriteTo(t *testing.T) {
+	f := New(9, 7)
+	for _, h := range hashes(300, 4) {
+		require.NoError(t, f.Add(h))
+	}
+
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	data := buf.Bytes()
+
+	var g Filter
+	m, err := g.ReadFrom(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, n, m)
+	assert.Equal(t, f, &g)
+
+	_, err = g.ReadFrom(bytes.NewReader(data[:len(data)-1]))
+	assert.Error(t, err)
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[8]++ // Count.
+	_, err = g.ReadFrom(bytes.NewReader(corrupt))
+	assert.Error(t, err)
+	assert.Equal(t, f, &g)
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"errors"
+	"io"
+)
+
+var errBlockRange = errors.New("blobloom: invalid block range")
+
+// DumpRange is like Dump, but writes only blocks start through end-1 of f,
+// where a block holds BlockBits bits.
+//
+// The result describes a filter of end-start blocks, which is not useful
+// on its own, since the blocks that keys map to depend on the total number
+// of blocks. It should be loaded into a filter of the original size with
+// Loader.LoadRange. Together, these functions allow a filter to be split
+// across multiple files.
+func DumpRange(w io.Writer, f *Filter, start, end int, comment string) (int64, error) {
+	if start < 0 || end > len(f.b) || start >= end {
+		return 0, errBlockRange
+	}
+	return dump(w, f.b[start:end], f.k, f.probeSeed, f.probing, comment)
+}
+
+// LoadRange sets the blocks of f starting at block start to the union of
+// those blocks and the Loader's blocks. The Loader's dump should have been
+// written by DumpRange.
+//
+// If an error occurs while reading from the Loader,
+// f may end up in an inconsistent state.
+func (l *Loader) LoadRange(f *Filter, start int) error {
+	if start < 0 || uint64(start)+l.nblocks > uint64(len(f.b)) {
+		return errBlockRange
+	}
+	sub := &Filter{b: f.b[start : uint64(start)+l.nblocks], k: f.k,
+		probeSeed: f.probeSeed, probing: f.probing}
+	_, err := l.Load(sub)
+	return err
+}
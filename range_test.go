@@ -0,0 +1,58 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpLoadRange(t *testing.T) {
+	f := New(100*BlockBits, 7)
+	r := rand.New(rand.NewSource(0x5eed))
+	for i := 0; i < 5000; i++ {
+		f.Add(r.Uint64())
+	}
+	assert.Equal(t, 7, f.NumHashes())
+
+	g := New(f.NumBits(), f.NumHashes())
+	for _, rg := range [][2]int{{0, 33}, {33, 66}, {66, 100}} {
+		var buf bytes.Buffer
+		_, err := DumpRange(&buf, f, rg[0], rg[1], "range")
+		require.NoError(t, err)
+
+		l, err := NewLoader(&buf)
+		require.NoError(t, err)
+		require.NoError(t, l.LoadRange(g, rg[0]))
+	}
+	assert.True(t, f.Equals(g))
+
+	for _, rg := range [][2]int{{-1, 10}, {10, 10}, {90, 101}} {
+		_, err := DumpRange(new(bytes.Buffer), f, rg[0], rg[1], "")
+		assert.Equal(t, errBlockRange, err)
+	}
+
+	var buf bytes.Buffer
+	_, err := DumpRange(&buf, f, 0, 10, "")
+	require.NoError(t, err)
+	l, err := NewLoader(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, errBlockRange, l.LoadRange(g, 91))
+	assert.Error(t, l.LoadRange(New(f.NumBits(), 6), 0))
+}
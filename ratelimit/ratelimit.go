@@ -0,0 +1,131 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit provides an approximate per-key rate limiter
+// with bounded memory.
+//
+// A Limiter is a blocked counting Bloom filter with decaying counters,
+// a time-decayed variant of the count-min sketch. It never undercounts a key,
+// so it never lets a key exceed its limit, but keys that share counters with
+// busy keys may be throttled early.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	counters = 14 // Counters per block.
+	nprobes  = 4  // Counters per key.
+
+	// Counters per key of capacity. Roughly 2.5% of idle keys share all
+	// their counters with other keys when the Limiter is at capacity.
+	cellsPerKey = 8
+
+	nlocks = 64
+)
+
+// A block fills a cache line. All counters in a block decay together.
+type block struct {
+	c    [counters]float32
+	last int64 // Time of last decay, in nanoseconds.
+}
+
+// A Limiter limits the rate of events per key.
+//
+// A Limiter is safe for concurrent use by multiple goroutines.
+type Limiter struct {
+	locks [nlocks]sync.Mutex
+	b     []block
+
+	now func() time.Time
+}
+
+// New constructs a Limiter for about capacity simultaneously active keys.
+// Memory use is about 37 bytes per key of capacity.
+func New(capacity int) *Limiter {
+	nblocks := (cellsPerKey*capacity + counters - 1) / counters
+	if nblocks < 1 {
+		nblocks = 1
+	}
+	return &Limiter{b: make([]block, nblocks), now: time.Now}
+}
+
+// Allow reports whether an event for the key with hash value h may happen
+// now. If so, the event is counted.
+//
+// Allow permits bursts of up to limit events, after which events are
+// allowed at a rate of about limit per window. Counts decay exponentially,
+// with time constant window. Since keys share blocks of counters,
+// all calls on a Limiter should use the same window.
+func (l *Limiter) Allow(h uint64, limit float64, window time.Duration) bool {
+	h1, h2 := uint32(h>>32), uint32(h)
+	i := reducerange(h2, uint32(len(l.b)))
+	b := &l.b[i]
+
+	mu := &l.locks[i%nlocks]
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := l.now().UnixNano()
+	if dt := now - b.last; dt > 0 {
+		decay := float32(math.Exp(-float64(dt) / float64(window)))
+		for j := range b.c {
+			b.c[j] *= decay
+		}
+		b.last = now
+	}
+
+	var idx [nprobes]uint32
+	est := float32(math.Inf(1))
+	for j := range idx {
+		h1, h2 = doublehash(h1, h2, j)
+		idx[j] = h1 % counters
+		est = min32(est, b.c[idx[j]])
+	}
+
+	if float64(est)+1 > limit {
+		return false
+	}
+
+	// Conservative update: only raise counters to the new estimate.
+	est++
+	for _, j := range idx {
+		if b.c[j] < est {
+			b.c[j] = est
+		}
+	}
+	return true
+}
+
+func min32(a, b float32) float32 {
+	if b < a {
+		return b
+	}
+	return a
+}
+
+// The following match their counterparts in package blobloom.
+
+func doublehash(h1, h2 uint32, i int) (uint32, uint32) {
+	h1 = h1 + h2
+	h2 = h2 + uint32(i)
+	return h1, h2
+}
+
+func reducerange(i, n uint32) uint32 {
+	return uint32((uint64(i) * uint64(n)) >> 32)
+}
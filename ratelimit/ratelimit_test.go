@@ -0,0 +1,70 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1e9, 0)
+	l := New(1000)
+	l.now = func() time.Time { return now }
+
+	const (
+		limit  = 10
+		window = time.Second
+	)
+
+	// Burst up to the limit.
+	for i := 0; i < limit; i++ {
+		assert.True(t, l.Allow(key(0), limit, window))
+	}
+	assert.False(t, l.Allow(key(0), limit, window))
+
+	// Other keys are unaffected.
+	allowed := 0
+	for i := uint64(1); i < 1000; i++ {
+		if l.Allow(key(i), limit, window) {
+			allowed++
+		}
+	}
+	assert.Equal(t, 999, allowed)
+
+	// After a window, the count has decayed to 10/e.
+	now = now.Add(window)
+	allowed = 0
+	for i := 0; i < limit; i++ {
+		if l.Allow(key(0), limit, window) {
+			allowed++
+		}
+	}
+	assert.Equal(t, 6, allowed)
+
+	// Sustained rate converges to limit per window.
+	allowed = 0
+	for i := 0; i < 10000; i++ {
+		now = now.Add(window / 100)
+		if l.Allow(key(0), limit, window) {
+			allowed++
+		}
+	}
+	assert.InDelta(t, 1000, allowed, 1000/limit)
+}
+
+func key(i uint64) uint64 { return (i + 1) * 0x9e3779b97f4a7c15 }
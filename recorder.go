@@ -0,0 +1,62 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+// A Recorder is notified of operations on a Filter or SyncFilter.
+// It can be used for telemetry, sampling or audit logging.
+//
+// Recorder methods are called synchronously, after the operation has
+// completed, so they should be cheap. A Recorder set on a SyncFilter
+// may be called concurrently from multiple goroutines.
+type Recorder interface {
+	// RecordAdd is called by Add(h).
+	RecordAdd(h uint64)
+
+	// RecordHas is called by Has(h), which returned found.
+	RecordHas(h uint64, found bool)
+}
+
+// RecorderFuncs is a Recorder that calls its fields. Nil fields are skipped.
+type RecorderFuncs struct {
+	Add func(h uint64)
+	Has func(h uint64, found bool)
+}
+
+// RecordAdd calls r.Add, if it is not nil.
+func (r RecorderFuncs) RecordAdd(h uint64) {
+	if r.Add != nil {
+		r.Add(h)
+	}
+}
+
+// RecordHas calls r.Has, if it is not nil.
+func (r RecorderFuncs) RecordHas(h uint64, found bool) {
+	if r.Has != nil {
+		r.Has(h, found)
+	}
+}
+
+// SetRecorder sets a Recorder to be notified of calls to f.Add and f.Has.
+// A nil Recorder disables notifications, which is the default.
+//
+// Other methods, such as Union, do not notify the Recorder.
+func (f *Filter) SetRecorder(r Recorder) { f.rec = r }
+
+// SetRecorder sets a Recorder to be notified of calls to f.Add and f.Has.
+// A nil Recorder disables notifications, which is the default.
+//
+// SetRecorder must not be called concurrently with other methods of f.
+// Other methods, such as LoadSync, do not notify the Recorder.
+func (f *SyncFilter) SetRecorder(r Recorder) { f.rec = r }
@@ -0,0 +1,74 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recording struct {
+	adds  []uint64
+	found map[uint64]bool
+}
+
+func (r *recording) RecordAdd(h uint64) { r.adds = append(r.adds, h) }
+
+func (r *recording) RecordHas(h uint64, found bool) {
+	if r.found == nil {
+		r.found = make(map[uint64]bool)
+	}
+	r.found[h] = found
+}
+
+func TestRecorder(t *testing.T) {
+	for _, f := range []interface {
+		Add(uint64)
+		Has(uint64) bool
+		SetRecorder(Recorder)
+	}{
+		New(1<<12, 4), NewSync(1<<12, 4),
+	} {
+		var r recording
+		f.SetRecorder(&r)
+		f.Add(1)
+		f.Add(2)
+		f.Has(1)
+		f.Has(3)
+
+		assert.Equal(t, []uint64{1, 2}, r.adds)
+		assert.Equal(t, map[uint64]bool{1: true, 3: false}, r.found)
+
+		f.SetRecorder(nil)
+		f.Add(4)
+		assert.Len(t, r.adds, 2)
+	}
+}
+
+func TestRecorderFuncs(t *testing.T) {
+	f := New(1<<12, 4)
+
+	var hits int
+	f.SetRecorder(RecorderFuncs{Has: func(h uint64, found bool) {
+		if found {
+			hits++
+		}
+	}})
+	f.Add(1)
+	f.Has(1)
+	f.Has(1)
+	assert.Equal(t, 2, hits)
+}
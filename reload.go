@@ -0,0 +1,158 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// A Handle refers to a SyncFilter that can be replaced while it is in use,
+// e.g., by a filter that an offline job rebuilds periodically.
+//
+// Queries through a Handle go to its current filter. Replacing the filter
+// does not block queries: queries that start before the replacement
+// completes see the old filter, later ones see the new filter.
+//
+// A Handle is safe for concurrent use by multiple goroutines.
+type Handle struct {
+	mu    sync.Mutex   // Serializes replacements.
+	f     atomic.Value // *generation
+	drain func(old *SyncFilter)
+}
+
+// A generation is a filter that is or was current in a Handle.
+type generation struct {
+	// Number of Add and Has calls using f, plus one while f is current.
+	// Only maintained if the Handle has a drain function.
+	// First for alignment on 32-bit.
+	refs    int64
+	drained uint32
+
+	f *SyncFilter
+}
+
+// NewHandle returns a Handle that refers to f.
+func NewHandle(f *SyncFilter) *Handle {
+	h := &Handle{}
+	h.f.Store(&generation{f: f, refs: 1})
+	return h
+}
+
+// SetDrainFunc sets a function to be called with each filter that is
+// replaced in h, once all calls to h.Add and h.Has that may be using it
+// have returned, e.g., to Close the MappedFilter that holds it or to Clear
+// it for reuse. The function is called by Replace or by the last call
+// to use the old filter.
+//
+// Filters returned by the Filter method are not tracked, so they must
+// not be used after they are drained.
+//
+// SetDrainFunc must be called before h is used concurrently. Tracking
+// calls costs two atomic operations per call to Add or Has.
+func (h *Handle) SetDrainFunc(fn func(old *SyncFilter)) { h.drain = fn }
+
+// Filter returns the current filter of h.
+func (h *Handle) Filter() *SyncFilter {
+	return h.f.Load().(*generation).f
+}
+
+// Add inserts a key with hash value x into the current filter of h.
+//
+// Keys added while a replacement is in progress may or may not
+// end up in the new filter.
+func (h *Handle) Add(x uint64) {
+	if h.drain == nil {
+		h.Filter().Add(x)
+		return
+	}
+	g := h.acquire()
+	g.f.Add(x)
+	h.release(g)
+}
+
+// Has reports whether a key with hash value x has been added to the
+// current filter of h. It may return a false positive.
+func (h *Handle) Has(x uint64) bool {
+	if h.drain == nil {
+		return h.Filter().Has(x)
+	}
+	g := h.acquire()
+	found := g.f.Has(x)
+	h.release(g)
+	return found
+}
+
+// acquire returns the current generation of h, with a reference held.
+func (h *Handle) acquire() *generation {
+	for {
+		g := h.f.Load().(*generation)
+		atomic.AddInt64(&g.refs, 1)
+		if h.f.Load().(*generation) == g {
+			return g
+		}
+		// Replaced in the meantime. g may already have been drained.
+		h.release(g)
+	}
+}
+
+// release drops a reference to g and drains it if that was the last.
+func (h *Handle) release(g *generation) {
+	if atomic.AddInt64(&g.refs, -1) == 0 && atomic.CompareAndSwapUint32(&g.drained, 0, 1) {
+		h.drain(g.f)
+	}
+}
+
+// Replace makes f the current filter of h and returns the old one.
+//
+// If the old filter has a Recorder and f has none, the Recorder is
+// transferred to f, so that statistics continue across replacements.
+//
+// If h has a drain function, the old filter is drained when calls
+// using it have returned; see SetDrainFunc.
+func (h *Handle) Replace(f *SyncFilter) (old *SyncFilter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	g := h.f.Load().(*generation)
+	old = g.f
+	if f.rec == nil {
+		f.rec = old.rec
+	}
+	h.f.Store(&generation{f: f, refs: 1})
+	if h.drain != nil {
+		h.release(g)
+	}
+	return old
+}
+
+// ReloadFrom loads a new filter from r, in the format produced by Dump,
+// and makes it the current filter of h. It returns the dump's comment.
+//
+// Queries continue to be served by the old filter while the new one loads.
+// If an error occurs, the old filter is kept.
+func (h *Handle) ReloadFrom(r io.Reader) (comment string, err error) {
+	l, err := NewLoader(r)
+	if err != nil {
+		return "", err
+	}
+	f, err := l.LoadSync(nil)
+	if err != nil {
+		return "", err
+	}
+	h.Replace(f)
+	return l.Comment, nil
+}
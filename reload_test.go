@@ -0,0 +1,173 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleReload(t *testing.T) {
+	const n = 1000
+	keys := randomU64(2*n, 0x4e10)
+	oldKeys, newKeys := keys[:n], keys[n:]
+
+	old := NewSync(1<<16, 6)
+	for _, x := range oldKeys {
+		old.Add(x)
+	}
+	var counters Counters
+	old.SetRecorder(&counters)
+	h := NewHandle(old)
+
+	fresh := New(1<<16, 6)
+	for _, x := range newKeys {
+		fresh.Add(x)
+	}
+	var dump bytes.Buffer
+	_, err := Dump(&dump, fresh, "v2")
+	require.NoError(t, err)
+
+	// Query concurrently with the reload.
+	var (
+		wg   sync.WaitGroup
+		stop uint32
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadUint32(&stop) == 0 {
+				for _, x := range oldKeys[:10] {
+					h.Has(x)
+				}
+			}
+		}()
+	}
+
+	comment, err := h.ReloadFrom(&dump)
+	atomic.StoreUint32(&stop, 1)
+	wg.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, "v2", comment)
+
+	for _, x := range newKeys {
+		assert.True(t, h.Has(x))
+	}
+	assert.NotSame(t, old, h.Filter())
+	assert.Same(t, old.rec, h.Filter().rec)
+
+	// A failed reload keeps the current filter.
+	cur := h.Filter()
+	_, err = h.ReloadFrom(bytes.NewReader([]byte("garbage")))
+	assert.Error(t, err)
+	assert.Same(t, cur, h.Filter())
+}
+
+func TestHandleReplace(t *testing.T) {
+	f, g := NewSync(BlockBits, 2), NewSync(BlockBits, 2)
+	h := NewHandle(f)
+	h.Add(1)
+	assert.True(t, f.Has(1))
+
+	assert.Same(t, f, h.Replace(g))
+	assert.False(t, h.Has(1))
+}
+
+func TestHandleDrain(t *testing.T) {
+	const nfilters = 50
+
+	var (
+		mu      sync.Mutex
+		drained = make(map[*SyncFilter]int)
+	)
+	newFilter := func() *SyncFilter {
+		f := NewSync(BlockBits, 2)
+		f.SetRecorder(RecorderFuncs{Has: func(uint64, bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			if drained[f] != 0 {
+				t.Error("drained filter used")
+			}
+		}})
+		return f
+	}
+
+	h := NewHandle(newFilter())
+	h.SetDrainFunc(func(old *SyncFilter) {
+		mu.Lock()
+		defer mu.Unlock()
+		drained[old]++
+	})
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for x := uint64(0); ; x++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				h.Add(x)
+				h.Has(x)
+			}
+		}()
+	}
+
+	var replaced []*SyncFilter
+	for i := 0; i < nfilters; i++ {
+		replaced = append(replaced, h.Replace(newFilter()))
+	}
+	close(stop)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, drained, nfilters)
+	for _, f := range replaced {
+		assert.Equal(t, 1, drained[f])
+	}
+	assert.Zero(t, drained[h.Filter()])
+}
+
+func TestHandleReloadSeeded(t *testing.T) {
+	keys := randomU64(1000, 0x4e5d)
+
+	f := NewOptimized(Config{Capacity: 1000, FPRate: 1e-4, Seed: 0x4e5d, Probing: TripleHashing})
+	for _, x := range keys {
+		f.Add(x)
+	}
+	var dump bytes.Buffer
+	_, err := Dump(&dump, f, "")
+	require.NoError(t, err)
+
+	h := NewHandle(NewSync(1<<10, 2))
+	_, err = h.ReloadFrom(&dump)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0x4e5d, h.Filter().Seed())
+	assert.Equal(t, TripleHashing, h.Filter().Probing())
+	for _, x := range keys {
+		assert.True(t, h.Has(x))
+	}
+}
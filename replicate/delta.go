@@ -0,0 +1,204 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replicate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/greatroar/blobloom"
+)
+
+// A Delta holds the blocks of a Leader's filter that changed between
+// two positions, or all blocks.
+type Delta struct {
+	From, To  Position
+	Full      bool // Holds all blocks. From is the zero Position.
+	NumBlocks int  // Shape of the filter.
+	NumHashes int
+	Seed      uint64           // See blobloom.Filter.SetSeed.
+	Probing   blobloom.Probing // See blobloom.Filter.SetProbing.
+
+	// Indexes of the blocks in Data, in increasing order. Empty if Full.
+	Blocks []uint32
+	// Block contents, BlockBytes per block, as encoded by AppendBlock.
+	Data []byte
+}
+
+func (d *Delta) check() error {
+	n := len(d.Blocks)
+	if d.Full {
+		n = d.NumBlocks
+		if len(d.Blocks) != 0 {
+			return errors.New("replicate: full delta with block list")
+		}
+	}
+	switch {
+	case d.NumBlocks < 1 || uint64(d.NumBlocks) > blobloom.MaxBits/blobloom.BlockBits:
+		return fmt.Errorf("replicate: invalid number of blocks %d", d.NumBlocks)
+	case d.NumHashes < 2:
+		return fmt.Errorf("replicate: invalid number of hashes %d", d.NumHashes)
+	case d.Probing != blobloom.DoubleHashing && d.Probing != blobloom.TripleHashing:
+		return fmt.Errorf("replicate: invalid probing scheme %d", d.Probing)
+	case len(d.Data) != n*blobloom.BlockBytes:
+		return errors.New("replicate: delta has wrong amount of data")
+	}
+	for _, idx := range d.Blocks {
+		if int64(idx) >= int64(d.NumBlocks) {
+			return fmt.Errorf("replicate: block index %d out of range", idx)
+		}
+	}
+	return nil
+}
+
+// Wire format of a Delta, all integers little-endian:
+//
+//	magic "BLRD", version byte (0 or 1), flags byte (1 = full), two zero bytes
+//	From.Epoch, From.Seq, To.Epoch, To.Seq: 64 bits each
+//	NumBlocks, NumHashes, number of blocks in the delta: 32 bits each
+//	version 1 only: Seed, 64 bits; Probing, 32 bits; four zero bytes
+//	block indexes, 32 bits each, absent if full
+//	block contents
+//
+// Version 0 is written when Seed and Probing have their default values.
+const (
+	magic        = "BLRD"
+	headerSize   = 8 + 4*8 + 3*4
+	headerSizeV1 = headerSize + 16
+	flagFull     = 1
+)
+
+func (d *Delta) version() byte {
+	if d.Seed != 0 || d.Probing != blobloom.DoubleHashing {
+		return 1
+	}
+	return 0
+}
+
+// WriteTo writes d to w in a binary format that ReadDelta accepts.
+func (d *Delta) WriteTo(w io.Writer) (int64, error) {
+	if err := d.check(); err != nil {
+		return 0, err
+	}
+
+	hsize := headerSize
+	if d.version() > 0 {
+		hsize = headerSizeV1
+	}
+	buf := make([]byte, hsize+4*len(d.Blocks))
+	copy(buf, magic)
+	buf[4] = d.version()
+	n := len(d.Blocks)
+	if d.Full {
+		buf[5] = flagFull
+		n = d.NumBlocks
+	}
+	binary.LittleEndian.PutUint64(buf[8:], d.From.Epoch)
+	binary.LittleEndian.PutUint64(buf[16:], d.From.Seq)
+	binary.LittleEndian.PutUint64(buf[24:], d.To.Epoch)
+	binary.LittleEndian.PutUint64(buf[32:], d.To.Seq)
+	binary.LittleEndian.PutUint32(buf[40:], uint32(d.NumBlocks))
+	binary.LittleEndian.PutUint32(buf[44:], uint32(d.NumHashes))
+	binary.LittleEndian.PutUint32(buf[48:], uint32(n))
+	if hsize > headerSize {
+		binary.LittleEndian.PutUint64(buf[52:], d.Seed)
+		binary.LittleEndian.PutUint32(buf[60:], uint32(d.Probing))
+	}
+	for i, idx := range d.Blocks {
+		binary.LittleEndian.PutUint32(buf[hsize+4*i:], idx)
+	}
+
+	k, err := w.Write(buf)
+	if err != nil {
+		return int64(k), err
+	}
+	m, err := w.Write(d.Data)
+	return int64(k + m), err
+}
+
+// ReadDelta reads a Delta written by Delta.WriteTo from r.
+func ReadDelta(r io.Reader) (*Delta, error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	switch {
+	case string(hdr[:4]) != magic:
+		return nil, errors.New("replicate: not a delta")
+	case hdr[4] > 1:
+		return nil, errors.New("replicate: unsupported delta version")
+	}
+
+	d := &Delta{
+		From:      Position{binary.LittleEndian.Uint64(hdr[8:]), binary.LittleEndian.Uint64(hdr[16:])},
+		To:        Position{binary.LittleEndian.Uint64(hdr[24:]), binary.LittleEndian.Uint64(hdr[32:])},
+		Full:      hdr[5]&flagFull != 0,
+		NumBlocks: int(binary.LittleEndian.Uint32(hdr[40:])),
+		NumHashes: int(binary.LittleEndian.Uint32(hdr[44:])),
+	}
+	n := int(binary.LittleEndian.Uint32(hdr[48:]))
+	if n < 0 || n > d.NumBlocks || (d.Full && n != d.NumBlocks) {
+		return nil, errors.New("replicate: invalid number of blocks in delta")
+	}
+
+	if hdr[4] == 1 {
+		var ext [headerSizeV1 - headerSize]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, noEOF(err)
+		}
+		if binary.LittleEndian.Uint32(ext[12:]) != 0 {
+			return nil, errors.New("replicate: corrupt delta header")
+		}
+		d.Seed = binary.LittleEndian.Uint64(ext[:])
+		d.Probing = blobloom.Probing(binary.LittleEndian.Uint32(ext[8:]))
+	}
+
+	if !d.Full {
+		p, err := readFull(r, 4*int64(n))
+		if err != nil {
+			return nil, err
+		}
+		d.Blocks = make([]uint32, n)
+		for i := range d.Blocks {
+			d.Blocks[i] = binary.LittleEndian.Uint32(p[4*i:])
+		}
+	}
+	var err error
+	if d.Data, err = readFull(r, int64(n)*blobloom.BlockBytes); err != nil {
+		return nil, err
+	}
+	return d, d.check()
+}
+
+// readFull reads n bytes from r. The header says how large n is,
+// but it is not trusted: the buffer grows as data arrives, so a corrupt
+// or truncated delta cannot make us allocate much more than it contains.
+func readFull(r io.Reader, n int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, n); err != nil {
+		return nil, noEOF(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,268 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replicate implements a resumable replication protocol for Bloom
+// filters, independent of the transport.
+//
+// A Leader owns the authoritative filter. Keys are added through the Leader,
+// which tracks the blocks they modify. Calling Commit periodically closes
+// a batch of modifications and assigns it a sequence number.
+//
+// A Follower keeps a replica. It sends its Position to the Leader, e.g., when
+// (re)connecting, and the Leader replies with a Delta holding the blocks
+// changed since that position, or a full snapshot if the position is too
+// old or belongs to a previous incarnation of the Leader. Applying a Delta
+// advances the Follower's position, so a Follower resumes where it left off
+// after a disconnect.
+//
+// Since Bloom filters only grow, deltas are applied by union. Applying
+// a Delta twice is harmless, and a Delta may reflect modifications made
+// after its sequence number was assigned. The Leader's filter must not be
+// cleared or intersected.
+package replicate
+
+import (
+	"errors"
+	"math/bits"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/greatroar/blobloom"
+)
+
+// A Position identifies a state of a Leader's filter.
+type Position struct {
+	Epoch uint64 // Identifies an incarnation of the Leader.
+	Seq   uint64 // Sequence number of the last batch.
+}
+
+// A Leader tracks modifications to a filter for replication.
+//
+// A Leader is safe for concurrent use by multiple goroutines.
+type Leader struct {
+	f     *blobloom.SyncFilter
+	epoch uint64
+	dirty []uint32 // Bitmap of modified blocks. Accessed atomically.
+
+	mu         sync.Mutex
+	seq        uint64
+	history    []batch // Most recent last.
+	historyLen int     // Total number of blocks in history.
+	maxHistory int
+}
+
+type batch struct {
+	seq    uint64
+	blocks []uint32
+}
+
+// NewLeader returns a Leader for f.
+//
+// The Leader remembers the blocks modified by recent batches, up to
+// a total of maxHistory blocks. Followers that are further behind
+// receive a full snapshot.
+func NewLeader(f *blobloom.SyncFilter, maxHistory int) *Leader {
+	nblocks := f.NumBits() / blobloom.BlockBits
+	return &Leader{
+		f:          f,
+		epoch:      newEpoch(),
+		dirty:      make([]uint32, (nblocks+31)/32),
+		maxHistory: maxHistory,
+	}
+}
+
+func newEpoch() uint64 {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for {
+		if e := r.Uint64(); e != 0 {
+			return e
+		}
+	}
+}
+
+// Filter returns the Leader's filter. Keys added to it directly,
+// not through the Leader, are not replicated.
+func (l *Leader) Filter() *blobloom.SyncFilter { return l.f }
+
+// Add adds the key with hash value h to the filter.
+func (l *Leader) Add(h uint64) {
+	l.f.Add(h)
+
+	i := l.f.BlockIndex(h)
+	p, bit := &l.dirty[i/32], uint32(1)<<(i%32)
+	for {
+		old := atomic.LoadUint32(p)
+		if old&bit != 0 || atomic.CompareAndSwapUint32(p, old, old|bit) {
+			return
+		}
+	}
+}
+
+// Commit closes the current batch of modifications and returns the
+// position after it. If there were no modifications, the position is
+// unchanged.
+func (l *Leader) Commit() Position {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var blocks []uint32
+	for w := range l.dirty {
+		x := atomic.SwapUint32(&l.dirty[w], 0)
+		for ; x != 0; x &= x - 1 {
+			blocks = append(blocks, uint32(32*w+bits.TrailingZeros32(x)))
+		}
+	}
+	if len(blocks) == 0 {
+		return Position{l.epoch, l.seq}
+	}
+
+	l.seq++
+	l.history = append(l.history, batch{l.seq, blocks})
+	l.historyLen += len(blocks)
+	for l.historyLen > l.maxHistory && len(l.history) > 0 {
+		l.historyLen -= len(l.history[0].blocks)
+		l.history[0] = batch{}
+		l.history = l.history[1:]
+	}
+	return Position{l.epoch, l.seq}
+}
+
+// Position returns the position after the last committed batch.
+func (l *Leader) Position() Position {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Position{l.epoch, l.seq}
+}
+
+// Delta returns the changes since the follower position from.
+// The zero Position always produces a full snapshot.
+func (l *Leader) Delta(from Position) *Delta {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d := &Delta{
+		From:      from,
+		To:        Position{l.epoch, l.seq},
+		NumBlocks: int(l.f.NumBits() / blobloom.BlockBits),
+		NumHashes: l.f.NumHashes(),
+		Seed:      l.f.Seed(),
+		Probing:   l.f.Probing(),
+	}
+
+	// Find the first batch after from.
+	i := sort.Search(len(l.history), func(i int) bool {
+		return l.history[i].seq > from.Seq
+	})
+	var oldest uint64 // Oldest seq we can serve a delta from.
+	if len(l.history) > 0 {
+		oldest = l.history[0].seq - 1
+	} else {
+		oldest = l.seq
+	}
+
+	if from.Epoch != l.epoch || from.Seq < oldest || from.Seq > l.seq {
+		d.Full = true
+		d.From = Position{}
+		d.Data = make([]byte, 0, d.NumBlocks*blobloom.BlockBytes)
+		for b := 0; b < d.NumBlocks; b++ {
+			d.Data = l.f.AppendBlock(d.Data, b)
+		}
+		return d
+	}
+
+	seen := make(map[uint32]bool)
+	for _, b := range l.history[i:] {
+		for _, idx := range b.blocks {
+			if !seen[idx] {
+				seen[idx] = true
+				d.Blocks = append(d.Blocks, idx)
+			}
+		}
+	}
+	sort.Slice(d.Blocks, func(i, j int) bool { return d.Blocks[i] < d.Blocks[j] })
+	d.Data = make([]byte, 0, len(d.Blocks)*blobloom.BlockBytes)
+	for _, idx := range d.Blocks {
+		d.Data = l.f.AppendBlock(d.Data, int(idx))
+	}
+	return d
+}
+
+// A Follower maintains a replica of a Leader's filter.
+//
+// A Follower is safe for concurrent use by multiple goroutines.
+type Follower struct {
+	mu  sync.Mutex
+	pos Position
+	f   *blobloom.SyncFilter
+}
+
+// ErrGap is returned by Follower.Apply for an incremental Delta that does
+// not start at the Follower's position. The Follower should request a new
+// Delta from its current position.
+var ErrGap = errors.New("replicate: delta does not start at follower's position")
+
+// Position returns the Follower's position, to be sent to the Leader.
+func (fl *Follower) Position() Position {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return fl.pos
+}
+
+// Filter returns the replica, or nil if no full snapshot has been applied
+// yet. The replica may be queried concurrently with Apply. Applying a full
+// snapshot replaces it, so Filter should be called again after Apply.
+func (fl *Follower) Filter() *blobloom.SyncFilter {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return fl.f
+}
+
+// Apply applies d to the replica and advances the Follower's position.
+func (fl *Follower) Apply(d *Delta) error {
+	if err := d.check(); err != nil {
+		return err
+	}
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	if d.Full {
+		// Replace the replica: a new epoch may have different contents.
+		f := blobloom.NewSync(uint64(d.NumBlocks)*blobloom.BlockBits, d.NumHashes)
+		f.SetSeed(d.Seed)
+		f.SetProbing(d.Probing)
+		for b := 0; b < d.NumBlocks; b++ {
+			f.UnionBlock(b, d.Data[b*blobloom.BlockBytes:])
+		}
+		fl.f = f
+		fl.pos = d.To
+		return nil
+	}
+
+	if fl.f == nil || d.From != fl.pos {
+		return ErrGap
+	}
+	if fl.f.NumBits() != uint64(d.NumBlocks)*blobloom.BlockBits || fl.f.NumHashes() != d.NumHashes ||
+		fl.f.Seed() != d.Seed || fl.f.Probing() != d.Probing {
+		return errors.New("replicate: delta does not match replica's shape")
+	}
+	for i, idx := range d.Blocks {
+		fl.f.UnionBlock(int(idx), d.Data[i*blobloom.BlockBytes:])
+	}
+	fl.pos = d.To
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replicate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transfer sends d through its wire format.
+func transfer(t *testing.T, d *Delta) *Delta {
+	t.Helper()
+	var buf bytes.Buffer
+	_, err := d.WriteTo(&buf)
+	require.NoError(t, err)
+	d, err = ReadDelta(&buf)
+	require.NoError(t, err)
+	assert.Zero(t, buf.Len())
+	return d
+}
+
+func TestReplicate(t *testing.T) {
+	r := rand.New(rand.NewSource(0x5eed))
+	l := NewLeader(blobloom.NewSync(1000*blobloom.BlockBits, 5), 100)
+
+	var fl Follower
+	assert.Nil(t, fl.Filter())
+
+	addKeys := func(n int) {
+		for i := 0; i < n; i++ {
+			l.Add(r.Uint64())
+		}
+	}
+
+	addKeys(100)
+	pos := l.Commit()
+	assert.EqualValues(t, 1, pos.Seq)
+	assert.Equal(t, pos, l.Commit()) // Nothing changed.
+
+	d := transfer(t, l.Delta(fl.Position()))
+	assert.True(t, d.Full)
+	require.NoError(t, fl.Apply(d))
+	assert.Equal(t, pos, fl.Position())
+	assert.True(t, l.Filter().Equals(fl.Filter()))
+
+	// Incremental deltas.
+	for i := 0; i < 3; i++ {
+		addKeys(10)
+		l.Commit()
+	}
+	d = transfer(t, l.Delta(fl.Position()))
+	assert.False(t, d.Full)
+	assert.LessOrEqual(t, len(d.Blocks), 30)
+	assert.Len(t, d.Data, len(d.Blocks)*blobloom.BlockBytes)
+
+	require.NoError(t, fl.Apply(d))
+	assert.Equal(t, l.Position(), fl.Position())
+	assert.True(t, l.Filter().Equals(fl.Filter()))
+
+	// The delta is now stale.
+	assert.Equal(t, ErrGap, fl.Apply(d))
+
+	// Up to date: empty delta.
+	d = l.Delta(fl.Position())
+	assert.False(t, d.Full)
+	assert.Empty(t, d.Blocks)
+	require.NoError(t, fl.Apply(transfer(t, d)))
+
+	// Falling behind the history produces a full snapshot.
+	for i := 0; i < 20; i++ {
+		addKeys(10)
+		l.Commit()
+	}
+	d = l.Delta(fl.Position())
+	assert.True(t, d.Full)
+	require.NoError(t, fl.Apply(transfer(t, d)))
+	assert.True(t, l.Filter().Equals(fl.Filter()))
+
+	// A new Leader incarnation has a new epoch.
+	l2 := NewLeader(l.Filter(), 100)
+	d = l2.Delta(fl.Position())
+	assert.True(t, d.Full)
+}
+
+func TestReplicateSeed(t *testing.T) {
+	f := blobloom.NewSync(100*blobloom.BlockBits, 5)
+	f.SetSeed(0xfeed)
+	f.SetProbing(blobloom.TripleHashing)
+	l := NewLeader(f, 100)
+
+	r := rand.New(rand.NewSource(0xfeed))
+	keys := make([]uint64, 200)
+	for i := range keys {
+		keys[i] = r.Uint64()
+	}
+
+	for _, h := range keys[:100] {
+		l.Add(h)
+	}
+	l.Commit()
+
+	var fl Follower
+	require.NoError(t, fl.Apply(transfer(t, l.Delta(Position{}))))
+	assert.EqualValues(t, 0xfeed, fl.Filter().Seed())
+	assert.Equal(t, blobloom.TripleHashing, fl.Filter().Probing())
+
+	for _, h := range keys[100:] {
+		l.Add(h)
+	}
+	l.Commit()
+	d := transfer(t, l.Delta(fl.Position()))
+	assert.False(t, d.Full)
+	require.NoError(t, fl.Apply(d))
+	assert.True(t, l.Filter().Equals(fl.Filter()))
+	for _, h := range keys {
+		assert.True(t, fl.Filter().Has(h))
+	}
+
+	// Deltas for a differently seeded filter don't apply.
+	l.Add(r.Uint64())
+	l.Commit()
+	d = l.Delta(fl.Position())
+	d.Seed++
+	assert.Error(t, fl.Apply(d))
+}
+
+func TestReadDeltaInvalid(t *testing.T) {
+	l := NewLeader(blobloom.NewSync(10*blobloom.BlockBits, 3), 100)
+	l.Add(1)
+	l.Commit()
+
+	var buf bytes.Buffer
+	_, err := l.Delta(Position{}).WriteTo(&buf)
+	require.NoError(t, err)
+	p := buf.Bytes()
+
+	for i := 0; i < len(p); i += 7 {
+		_, err := ReadDelta(bytes.NewReader(p[:i]))
+		assert.Error(t, err)
+	}
+
+	bad := append([]byte(nil), p...)
+	bad[0] = 'X'
+	_, err = ReadDelta(bytes.NewReader(bad))
+	assert.Error(t, err)
+
+	bad = append([]byte(nil), p...)
+	bad[44] = 1 // One hash.
+	_, err = ReadDelta(bytes.NewReader(bad))
+	assert.Error(t, err)
+}
+
+func TestReadDeltaHuge(t *testing.T) {
+	// A header that claims 128GiB of data must not make ReadDelta
+	// allocate that much before finding out the data isn't there.
+	const nblocks = 1<<31 - 1
+
+	p := make([]byte, headerSize+blobloom.BlockBytes)
+	copy(p, magic)
+	p[5] = flagFull
+	binary.LittleEndian.PutUint32(p[40:], nblocks)
+	binary.LittleEndian.PutUint32(p[44:], 3)
+	binary.LittleEndian.PutUint32(p[48:], nblocks)
+
+	_, err := ReadDelta(bytes.NewReader(p))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
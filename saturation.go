@@ -0,0 +1,99 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "sync/atomic"
+
+// SetSaturationFunc arranges for fn to be called when the CurrentFPRate of f
+// first exceeds maxFPR, so that a long-running service learns that f is
+// overfull before false positives become frequent. A nil fn disables the
+// check, which is the default.
+//
+// Add, AddMany and AddIfNotHas estimate the rate after every
+// NumBits()/BlockBits keys, so fn may be called somewhat late.
+// It is called at most once, until Clear.
+func (f *Filter) SetSaturationFunc(maxFPR float64, fn func()) {
+	f.sat = newSaturation(maxFPR, fn)
+}
+
+// SetSaturationFunc arranges for fn to be called when the CurrentFPRate of f
+// first exceeds maxFPR. See Filter.SetSaturationFunc for details.
+//
+// fn is called by the goroutine whose addition triggered the check.
+// SetSaturationFunc must not be called concurrently with other methods of f.
+func (f *SyncFilter) SetSaturationFunc(maxFPR float64, fn func()) {
+	f.sat = newSaturation(maxFPR, fn)
+}
+
+// Saturated reports whether the CurrentFPRate of f exceeds maxFPR.
+func (f *Filter) Saturated(maxFPR float64) bool {
+	return f.CurrentFPRate() > maxFPR
+}
+
+// Saturated reports whether the CurrentFPRate of f exceeds maxFPR.
+func (f *SyncFilter) Saturated(maxFPR float64) bool {
+	return f.CurrentFPRate() > maxFPR
+}
+
+func (f *Filter) checkSaturation(nkeys int) {
+	if f.sat.due(uint64(nkeys), len(f.b)) {
+		f.sat.check(f.CurrentFPRate())
+	}
+}
+
+func (f *SyncFilter) checkSaturation(nkeys int) {
+	if f.sat.due(uint64(nkeys), len(f.b)) {
+		f.sat.check(f.CurrentFPRate())
+	}
+}
+
+// A saturation is the state of a saturation check.
+// Its methods are safe for concurrent use.
+type saturation struct {
+	adds  uint64 // Accessed atomically. First for alignment on 32-bit.
+	fired uint32 // Accessed atomically.
+
+	maxFPR float64
+	fn     func()
+}
+
+func newSaturation(maxFPR float64, fn func()) *saturation {
+	if fn == nil {
+		return nil
+	}
+	return &saturation{maxFPR: maxFPR, fn: fn}
+}
+
+// due counts nkeys added keys and reports whether the FPR should be
+// estimated: once per nblocks keys, until fn has been called.
+func (s *saturation) due(nkeys uint64, nblocks int) bool {
+	if atomic.LoadUint32(&s.fired) != 0 {
+		return false
+	}
+	n := uint64(nblocks)
+	adds := atomic.AddUint64(&s.adds, nkeys)
+	return adds/n != (adds-nkeys)/n
+}
+
+func (s *saturation) check(fpr float64) {
+	if fpr > s.maxFPR && atomic.CompareAndSwapUint32(&s.fired, 0, 1) {
+		s.fn()
+	}
+}
+
+func (s *saturation) reset() {
+	atomic.StoreUint64(&s.adds, 0)
+	atomic.StoreUint32(&s.fired, 0)
+}
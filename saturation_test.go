@@ -0,0 +1,92 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaturation(t *testing.T) {
+	const capacity = 1000
+	keys := randomU64(4*capacity, 0x5a7)
+
+	var ncalls int
+	f := NewOptimized(Config{
+		Capacity:     capacity,
+		FPRate:       .01,
+		OnSaturation: func() { ncalls++ },
+	})
+
+	for _, h := range keys[:capacity] {
+		f.Add(h)
+	}
+	assert.False(t, f.Saturated(.01))
+	assert.Zero(t, ncalls)
+
+	f.AddMany(keys[capacity : 2*capacity])
+	for _, h := range keys[2*capacity:] {
+		f.AddIfNotHas(h)
+	}
+	assert.True(t, f.Saturated(.01))
+	assert.Equal(t, 1, ncalls)
+
+	f.Clear()
+	for _, h := range keys {
+		f.Add(h)
+	}
+	assert.Equal(t, 2, ncalls)
+
+	g := f.Clone()
+	g.Clear()
+	for _, h := range keys {
+		g.Add(h)
+	}
+	assert.Equal(t, 2, ncalls)
+
+	f.SetSaturationFunc(.01, nil)
+	f.Clear()
+	for _, h := range keys {
+		f.Add(h)
+	}
+	assert.Equal(t, 2, ncalls)
+}
+
+func TestSaturationSync(t *testing.T) {
+	const ngoroutines = 8
+	keys := randomU64(4000, 0x5a75)
+
+	var ncalls uint32
+	f := NewSync(1<<14, 7)
+	f.SetSaturationFunc(.05, func() { atomic.AddUint32(&ncalls, 1) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < ngoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, h := range keys[i*len(keys)/ngoroutines : (i+1)*len(keys)/ngoroutines] {
+				f.Add(h)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.True(t, f.Saturated(.05))
+	assert.EqualValues(t, 1, ncalls)
+}
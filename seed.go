@@ -0,0 +1,71 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+// Seed returns the seed of f, as set by SetSeed or Config.Seed.
+// Zero means that f uses hash values as given.
+func (f *Filter) Seed() uint64 { return f.probeSeed }
+
+// SetSeed sets the seed that f mixes into the hash values passed to its
+// methods, before they are used to select blocks and bits. The default
+// seed is zero, which means no mixing.
+//
+// An attacker who knows the hash function used with a Filter can choose
+// keys whose hashes all map to the same block, saturating that block and
+// driving up the false positive rate for other keys in it. With a secret,
+// random seed, the attacker cannot tell which keys share a block. Keys
+// with identical hash values still collide; protection against that
+// requires a keyed hash function, such as SipHash.
+//
+// The seed must be set before any keys are added. Dump, MarshalBinary and
+// WriteTo record it, and a Loader, UnmarshalBinary and ReadFrom restore it.
+// Union and Intersect panic for filters with different seeds.
+// Recorders receive hash values before mixing.
+func (f *Filter) SetSeed(seed uint64) { f.probeSeed = seed }
+
+// Seed returns the seed of f, as set by SetSeed or Config.Seed.
+// Zero means that f uses hash values as given.
+func (f *SyncFilter) Seed() uint64 { return f.probeSeed }
+
+// SetSeed sets the seed that f mixes into hash values.
+// See Filter.SetSeed for details.
+//
+// SetSeed must not be called concurrently with other methods of f.
+func (f *SyncFilter) SetSeed(seed uint64) { f.probeSeed = seed }
+
+// Seed returns the seed of f, as set by SetSeed.
+// Zero means that f uses hash values as given.
+func (f *StorageFilter) Seed() uint64 { return f.probeSeed }
+
+// SetSeed sets the seed that f mixes into hash values.
+// See Filter.SetSeed for details. The seed is not kept in f's storage.
+func (f *StorageFilter) SetSeed(seed uint64) { f.probeSeed = seed }
+
+// mix scrambles the hash value h with seed, unless seed is zero.
+func mix(h, seed uint64) uint64 {
+	if seed == 0 {
+		return h
+	}
+
+	// Finalizer from MurmurHash3, which is a bijection, so distinct hash
+	// values remain distinct.
+	h ^= seed
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeedAdversarial(t *testing.T) {
+	const nblocks = 64
+
+	// Hash values that all map to block 0 without a seed.
+	keys := make([]uint64, 1000)
+	for i := range keys {
+		keys[i] = uint64(i) << 32
+	}
+
+	f, g := New(nblocks*BlockBits, 5), New(nblocks*BlockBits, 5)
+	g.SetSeed(0xfeedbeef)
+	assert.Equal(t, uint64(0xfeedbeef), g.Seed())
+
+	used := make(map[int]bool)
+	for _, h := range keys {
+		f.Add(h)
+		g.Add(h)
+		assert.Equal(t, 0, f.BlockIndex(h))
+		used[g.BlockIndex(h)] = true
+	}
+	assert.Greater(t, f.BlockStats().Max, 500)
+	assert.Len(t, used, nblocks)
+	assert.Less(t, g.BlockStats().Max, 200)
+
+	for _, h := range keys {
+		assert.True(t, g.Has(h))
+	}
+}
+
+func TestSeedConsistent(t *testing.T) {
+	const seed uint64 = 0x5eed5eed5eed
+
+	keys := randomU64(2000, 0x1e2d)
+
+	f, s, batch := New(1<<16, 6), NewSync(1<<16, 6), New(1<<16, 6)
+	f.SetSeed(seed)
+	s.SetSeed(seed)
+	batch.SetSeed(seed)
+
+	for _, h := range keys[:1000] {
+		f.Add(h)
+		s.AddIfNotHas(h)
+	}
+	batch.AddMany(keys[:1000])
+	assert.True(t, f.Equals(batch))
+	for i := range f.b {
+		assert.Equal(t, f.b[i], s.b[i])
+	}
+
+	res := make([]bool, len(keys))
+	f.HasMany(keys, res)
+	for i, h := range keys {
+		assert.Equal(t, f.Has(h), res[i])
+		assert.Equal(t, f.Has(h), s.Has(h))
+
+		pos := f.Positions(h)
+		assert.Equal(t, pos, s.Positions(h))
+		for _, p := range pos {
+			assert.EqualValues(t, f.BlockIndex(h), p/BlockBits)
+		}
+	}
+
+	// Seeds are kept by Clone and Fold and checked by set operations.
+	assert.True(t, f.Clone().Equals(f))
+	assert.Equal(t, seed, s.Clone().Seed())
+	folded := f.Fold(4 * BlockBits)
+	assert.Equal(t, seed, folded.Seed())
+	for _, h := range keys[:1000] {
+		assert.True(t, folded.Has(h))
+	}
+
+	unseeded := New(1<<16, 6)
+	assert.False(t, f.Equals(unseeded))
+	assert.Panics(t, func() { f.Union(unseeded) })
+	assert.Panics(t, func() { f.Intersect(unseeded) })
+	assert.Panics(t, func() { New(BlockBits, 6).UnionFold(f) })
+
+	assert.Equal(t, seed, NewSyncOptimized(Config{
+		Capacity: 100, FPRate: .01, Seed: seed,
+	}).Seed())
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package semijoin provides Bloom filter semi-join reducers for query
+// engines.
+//
+// A hash join can skip most probe-side rows without a match by first
+// testing them against a Bloom filter of the build side's join keys
+// (a "Bloom join"). The filter is small enough to be pushed down to scans
+// or shipped to other nodes, which then only return rows that may join.
+//
+// Keys are passed as 64-bit hashes, which the engine typically has already
+// computed for its hash table.
+package semijoin
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+
+	"github.com/greatroar/blobloom"
+)
+
+// A Reducer is a Bloom filter of the join keys of a relation.
+//
+// AddBatch may be called concurrently by multiple goroutines, e.g., one per
+// build-side partition. Probe may be called concurrently with other calls
+// to Probe, but only reflects batches whose AddBatch calls have returned.
+type Reducer struct {
+	probes, hits uint64 // Accessed atomically. First for alignment on 32-bit.
+
+	f *blobloom.SyncFilter
+}
+
+// NewReducer constructs a Reducer. Set config.Capacity to the expected
+// number of distinct keys on the build side.
+func NewReducer(config blobloom.Config) *Reducer {
+	return &Reducer{f: blobloom.NewSyncOptimized(config)}
+}
+
+// NewReducerFrom constructs a Reducer from an existing filter,
+// e.g., one received from another node.
+func NewReducerFrom(f *blobloom.SyncFilter) *Reducer {
+	return &Reducer{f: f}
+}
+
+// AddBatch adds a batch of key hashes to r.
+func (r *Reducer) AddBatch(hs []uint64) {
+	for _, h := range hs {
+		r.f.Add(h)
+	}
+}
+
+// Filter returns r's underlying filter, for serialization with
+// blobloom.DumpSync.
+func (r *Reducer) Filter() *blobloom.SyncFilter { return r.f }
+
+// Probe tests a batch of key hashes against r. It sets bit i of the
+// selection bitmap if row i may have a match and returns the bitmap,
+// along with the number of rows selected.
+//
+// Probe reuses sel's storage if it has sufficient capacity.
+func (r *Reducer) Probe(hs []uint64, sel Bitmap) (Bitmap, int) {
+	sel = sel.reset(len(hs))
+
+	n := 0
+	for i := 0; i < len(hs); i += 64 {
+		batch := hs[i:]
+		if len(batch) > 64 {
+			batch = batch[:64]
+		}
+		var word uint64
+		for j, h := range batch {
+			if r.f.Has(h) {
+				word |= 1 << uint(j)
+			}
+		}
+		sel[i/64] = word
+		n += bits.OnesCount64(word)
+	}
+
+	atomic.AddUint64(&r.probes, uint64(len(hs)))
+	atomic.AddUint64(&r.hits, uint64(n))
+	return sel, n
+}
+
+// Counts returns the number of keys probed and the number selected
+// by calls to Probe.
+func (r *Reducer) Counts() (probes, hits uint64) {
+	return atomic.LoadUint64(&r.probes), atomic.LoadUint64(&r.hits)
+}
+
+// FPRate returns the estimated false positive rate of r, based on the
+// number of keys estimated from its fill ratio.
+func (r *Reducer) FPRate() float64 {
+	nkeys := r.f.Cardinality()
+	if math.IsInf(nkeys, 0) || nkeys >= math.MaxUint64 {
+		return 1
+	}
+	return r.f.FPRate(uint64(math.Round(nkeys)))
+}
+
+// Number of probes at which observations weigh as much as the prior.
+const priorWeight = 1000
+
+// Selectivity estimates the selectivity of the predicate "key IN build
+// side", i.e., the fraction of probe-side keys that have a match.
+// Query planners can use it to cost the semi-join reduction and the join.
+//
+// The prior is the planner's estimate of the selectivity before any probes,
+// e.g., from distinct value counts. It is combined with the hit rate
+// observed by Probe, which takes over as more keys are probed. False
+// positives are accounted for using FPRate.
+func (r *Reducer) Selectivity(prior float64) float64 {
+	fpr := r.FPRate()
+	if fpr >= 1 {
+		// Every key hits, so hits say nothing about the selectivity.
+		return prior
+	}
+
+	// Expected hit rate: true matches plus false positives on the rest.
+	priorHits := prior + (1-prior)*fpr
+	probes, hits := r.Counts()
+	hitRate := (float64(hits) + priorWeight*priorHits) / (float64(probes) + priorWeight)
+
+	s := (hitRate - fpr) / (1 - fpr)
+	return math.Max(0, math.Min(1, s))
+}
+
+// A Bitmap is a selection vector with one bit per row.
+// Bit i is stored in word i/64, at position i%64.
+type Bitmap []uint64
+
+// Get reports whether bit i is set.
+func (b Bitmap) Get(i int) bool {
+	return b[i/64]&(1<<uint(i%64)) != 0
+}
+
+// Selected appends the indices of the set bits in b to dst and returns
+// the extended slice.
+func (b Bitmap) Selected(dst []int) []int {
+	for i, w := range b {
+		for w != 0 {
+			dst = append(dst, 64*i+bits.TrailingZeros64(w))
+			w &= w - 1
+		}
+	}
+	return dst
+}
+
+func (b Bitmap) reset(n int) Bitmap {
+	nwords := (n + 63) / 64
+	if cap(b) < nwords {
+		return make(Bitmap, nwords)
+	}
+	return b[:nwords]
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semijoin
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReducer(t *testing.T) {
+	const nbuild = 10000
+	r := NewReducer(blobloom.Config{Capacity: nbuild, FPRate: 1e-3})
+
+	// Build side: keys 0, 2, 4, ...; four partitions.
+	var wg sync.WaitGroup
+	for p := 0; p < 4; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			batch := make([]uint64, 0, 100)
+			for i := p; i < nbuild; i += 4 {
+				batch = append(batch, hash(uint64(2*i)))
+				if len(batch) == cap(batch) {
+					r.AddBatch(batch)
+					batch = batch[:0]
+				}
+			}
+			r.AddBatch(batch)
+		}(p)
+	}
+	wg.Wait()
+
+	// Probe side: keys 0, 1, 2, ..., in batches of odd size.
+	var sel Bitmap
+	var selected []int
+	total, fp := 0, 0
+	for start := 0; start < 2*nbuild; start += 1000 {
+		hs := make([]uint64, 1000)
+		for i := range hs {
+			hs[i] = hash(uint64(start + i))
+		}
+		hs = hs[:999]
+
+		var n int
+		sel, n = r.Probe(hs, sel)
+		assert.Len(t, sel, 16)
+		total += n
+
+		selected = sel.Selected(selected[:0])
+		assert.Len(t, selected, n)
+		for i := range hs {
+			even := (start+i)%2 == 0
+			if even {
+				assert.True(t, sel.Get(i))
+			} else if sel.Get(i) {
+				fp++
+			}
+		}
+	}
+	assert.Less(t, fp, 50)
+	assert.Equal(t, nbuild+fp, total)
+}
+
+// Finalizer from MurmurHash3.
+func hash(i uint64) uint64 {
+	i ^= i >> 33
+	i *= 0xff51afd7ed558ccd
+	i ^= i >> 33
+	i *= 0xc4ceb9fe1a85ec53
+	i ^= i >> 33
+	return i
+}
+
+func TestSelectivity(t *testing.T) {
+	r := NewReducer(blobloom.Config{Capacity: 1000, FPRate: .01})
+	for i := uint64(0); i < 1000; i++ {
+		r.AddBatch([]uint64{hash(i)})
+	}
+	fpr := r.FPRate()
+	assert.InDelta(t, .01, fpr, .01)
+
+	// Before probing, the prior is returned.
+	assert.InDelta(t, .5, r.Selectivity(.5), 1e-9)
+
+	// One in ten probe keys has a match.
+	hs := make([]uint64, 100000)
+	for i := range hs {
+		hs[i] = hash(uint64(i % 10000))
+	}
+	_, n := r.Probe(hs, nil)
+	probes, hits := r.Counts()
+	assert.EqualValues(t, len(hs), probes)
+	assert.EqualValues(t, n, hits)
+
+	assert.InDelta(t, .1, r.Selectivity(.5), .01)
+	assert.InDelta(t, .1, r.Selectivity(0), .01)
+
+	// Full filter: no information beyond the prior.
+	r = NewReducer(blobloom.Config{Capacity: 1, FPRate: .5})
+	for i := uint64(0); i < 10000; i++ {
+		r.AddBatch([]uint64{hash(i)})
+	}
+	assert.Equal(t, 1.0, r.FPRate())
+	assert.Equal(t, .3, r.Selectivity(.3))
+}
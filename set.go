@@ -0,0 +1,53 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package blobloom
+
+// A Hasher computes 64-bit hash values for keys of type K.
+//
+// A Hasher should distribute its outputs uniformly over all 64 bits,
+// since Filter uses both halves of the hash value.
+type Hasher[K any] func(key K) uint64
+
+// A Set is a Filter for keys of type K, which are hashed by a Hasher.
+type Set[K any] struct {
+	f    *Filter
+	hash Hasher[K]
+}
+
+// NewSet constructs a Set with given numbers of bits and hash functions,
+// as New does, that hashes its keys with hash.
+func NewSet[K any](nbits uint64, nhashes int, hash Hasher[K]) *Set[K] {
+	return &Set[K]{f: New(nbits, nhashes), hash: hash}
+}
+
+// NewSetOptimized is like NewSet, but picks the size of the Set
+// as NewOptimized does.
+func NewSetOptimized[K any](config Config, hash Hasher[K]) *Set[K] {
+	return &Set[K]{f: NewOptimized(config), hash: hash}
+}
+
+// Add inserts key into s.
+func (s *Set[K]) Add(key K) { s.f.Add(s.hash(key)) }
+
+// Has reports whether key has been added to s.
+// It may return a false positive.
+func (s *Set[K]) Has(key K) bool { return s.f.Has(s.hash(key)) }
+
+// Filter returns the Filter underlying s, e.g., to Dump it or to compute
+// the Union with another Set. Sets must use the same Hasher for this.
+func (s *Set[K]) Filter() *Filter { return s.f }
@@ -0,0 +1,60 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package blobloom
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUser struct {
+	ID   uint32
+	Name string
+}
+
+func hashUser(u testUser) uint64 {
+	h := fnv.New64a()
+	var id [4]byte
+	binary.LittleEndian.PutUint32(id[:], u.ID)
+	h.Write(id[:])
+	h.Write([]byte(u.Name))
+	return h.Sum64()
+}
+
+func TestSet(t *testing.T) {
+	users := []testUser{{1, "alice"}, {2, "bob"}, {3, "carol"}}
+
+	s := NewSetOptimized(Config{Capacity: 100, FPRate: 1e-4}, hashUser)
+	for _, u := range users[:2] {
+		s.Add(u)
+	}
+	assert.True(t, s.Has(users[0]))
+	assert.True(t, s.Has(users[1]))
+	assert.False(t, s.Has(users[2]))
+	assert.False(t, s.Has(testUser{1, "bob"}))
+
+	other := NewSet(s.Filter().NumBits(), s.Filter().NumHashes(), hashUser)
+	other.Add(users[2])
+	s.Filter().Union(other.Filter())
+	for _, u := range users {
+		assert.True(t, s.Has(u))
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "sync"
+
+// A ShardedFilter is a Bloom filter that can be accessed and updated
+// by multiple goroutines concurrently, like a SyncFilter.
+//
+// A ShardedFilter splits its bits across a number of independently locked
+// Filters, the shards, selected by the upper bits of the hash. When many
+// goroutines add keys at the same time, a ShardedFilter avoids the
+// contention that a SyncFilter suffers on frequently updated blocks,
+// at the expense of slower single-threaded operation.
+//
+// All shards use the same number of hashes, so the false positive rate of
+// a ShardedFilter is that of a Filter with the same total number of bits.
+type ShardedFilter struct {
+	shards []shard
+}
+
+type shard struct {
+	mu sync.RWMutex
+	f  Filter
+	_  [64]byte // Avoid false sharing between shards.
+}
+
+// NewSharded constructs a ShardedFilter with given numbers of bits,
+// hash functions and shards.
+//
+// The bits are divided evenly across the shards, each of which gets
+// at least BlockBits bits. The number of shards is silently increased
+// to one if a lower value is given. A good value is a small multiple of
+// the number of goroutines expected to use the filter concurrently.
+func NewSharded(nbits uint64, nhashes, nshards int) *ShardedFilter {
+	if nshards < 1 {
+		nshards = 1
+	}
+	perShard := (nbits + uint64(nshards) - 1) / uint64(nshards)
+
+	f := &ShardedFilter{shards: make([]shard, nshards)}
+	for i := range f.shards {
+		f.shards[i].f = *New(perShard, nhashes)
+	}
+	return f
+}
+
+// getshard returns the shard for the hash value h.
+//
+// The shard is selected using the upper bits of h1 = h>>32. The block is
+// selected by h2, the lower half of h. Probing does start from h1, but
+// bit positions are taken modulo BlockBits, so only the low bits of h1
+// influence which bits are set within the block.
+func (f *ShardedFilter) getshard(h uint64) *shard {
+	return &f.shards[reducerange(uint32(h>>32), uint32(len(f.shards)))]
+}
+
+// Add inserts a key with hash value h into f.
+func (f *ShardedFilter) Add(h uint64) {
+	s := f.getshard(h)
+	s.mu.Lock()
+	s.f.add(h)
+	s.mu.Unlock()
+}
+
+// AddIfNotHas inserts a key with hash value h into f and reports whether
+// it was new: whether Has(h) would have returned false before the call.
+// Unlike calling Has followed by Add, AddIfNotHas is atomic: when multiple
+// goroutines concurrently add the same new key, exactly one of them
+// gets true.
+func (f *ShardedFilter) AddIfNotHas(h uint64) (added bool) {
+	s := f.getshard(h)
+	s.mu.Lock()
+	added = s.f.AddIfNotHas(h)
+	s.mu.Unlock()
+	return added
+}
+
+// Cardinality estimates the number of distinct keys added to f.
+// See Filter.Cardinality for details.
+//
+// Cardinality locks each shard in turn, so the estimate may not reflect
+// a single point in time if other goroutines are concurrently adding keys.
+func (f *ShardedFilter) Cardinality() (n float64) {
+	for i := range f.shards {
+		s := &f.shards[i]
+		s.mu.RLock()
+		n += s.f.Cardinality()
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Clear resets f to its empty state.
+func (f *ShardedFilter) Clear() {
+	for i := range f.shards {
+		s := &f.shards[i]
+		s.mu.Lock()
+		s.f.Clear()
+		s.mu.Unlock()
+	}
+}
+
+// FillRatio returns the fraction of bits in f that are set.
+func (f *ShardedFilter) FillRatio() float64 {
+	var r float64
+	for i := range f.shards {
+		s := &f.shards[i]
+		s.mu.RLock()
+		r += s.f.FillRatio()
+		s.mu.RUnlock()
+	}
+	return r / float64(len(f.shards))
+}
+
+// FPRate computes an estimate of f's false positive rate after nkeys distinct
+// keys have been added.
+func (f *ShardedFilter) FPRate(nkeys uint64) float64 {
+	return FPRate(nkeys, f.NumBits(), f.NumHashes())
+}
+
+// Has reports whether a key with hash value h has been added.
+// It may return a false positive.
+func (f *ShardedFilter) Has(h uint64) bool {
+	s := f.getshard(h)
+	s.mu.RLock()
+	found := s.f.has(h)
+	s.mu.RUnlock()
+	return found
+}
+
+// NumBits returns the number of bits of f.
+func (f *ShardedFilter) NumBits() uint64 {
+	return uint64(len(f.shards)) * f.shards[0].f.NumBits()
+}
+
+// NumHashes returns the number of hash functions of f.
+func (f *ShardedFilter) NumHashes() int { return f.shards[0].f.k }
+
+// NumShards returns the number of shards of f.
+func (f *ShardedFilter) NumShards() int { return len(f.shards) }
@@ -0,0 +1,38 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.16
+// +build go1.16
+
+package sharded
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// FS returns a read-only Storage that reads files from fsys,
+// for use with Load.
+func FS(fsys fs.FS) Storage { return fsStorage{fsys} }
+
+type fsStorage struct{ fsys fs.FS }
+
+func (s fsStorage) Open(name string) (io.ReadCloser, error) {
+	return s.fsys.Open(name)
+}
+
+func (s fsStorage) Create(name string) (io.WriteCloser, error) {
+	return nil, &fs.PathError{Op: "create", Path: name, Err: errors.New("read-only file system")}
+}
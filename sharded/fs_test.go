@@ -0,0 +1,69 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.16
+// +build go1.16
+
+package sharded
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFS(t *testing.T) {
+	f := blobloom.New(10*blobloom.BlockBits, 3)
+	f.Add(42)
+
+	mem := fstest.MapFS{}
+	m, err := Save(mapStorage(mem), f, 64+4*64, "")
+	require.NoError(t, err)
+	assert.Len(t, m.Shards, 3)
+	require.NoError(t, fstest.TestFS(mem, ManifestName, m.Shards[0].Name))
+
+	g, _, err := Load(FS(mem))
+	require.NoError(t, err)
+	assert.True(t, f.Equals(g))
+
+	_, err = Save(FS(mem), f, 1<<20, "")
+	assert.Error(t, err)
+}
+
+// mapStorage writes to a MapFS.
+type mapStorage fstest.MapFS
+
+func (s mapStorage) Open(name string) (io.ReadCloser, error) {
+	return fstest.MapFS(s).Open(name)
+}
+
+func (s mapStorage) Create(name string) (io.WriteCloser, error) {
+	return &mapFile{s: s, name: name}, nil
+}
+
+type mapFile struct {
+	bytes.Buffer
+	s    mapStorage
+	name string
+}
+
+func (f *mapFile) Close() error {
+	f.s[f.name] = &fstest.MapFile{Data: f.Bytes()}
+	return nil
+}
@@ -0,0 +1,353 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sharded stores a Bloom filter as multiple files, called shards,
+// each holding a range of the filter's blocks, plus a manifest.
+//
+// Sharding allows filters to exceed the maximum file or object size of
+// a file system or object store. The manifest records the filter's shape
+// and the size and checksum of each shard, so that a filter is only loaded
+// if all its shards are present and intact.
+package sharded
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/internal/atomicfile"
+	"github.com/greatroar/blobloom/internal/dirstore"
+)
+
+// ManifestName is the name of the manifest in a Storage.
+const ManifestName = "manifest.json"
+
+// A Manifest describes a sharded filter.
+type Manifest struct {
+	NumBits   uint64  `json:"num_bits"`
+	NumHashes int     `json:"num_hashes"`
+	Comment   string  `json:"comment,omitempty"`
+	Shards    []Shard `json:"shards"`
+}
+
+// A Shard describes a shard of a filter, which holds the blocks
+// FirstBlock through FirstBlock+NumBlocks-1 in the format written by
+// blobloom.DumpRange.
+type Shard struct {
+	Name       string `json:"name"`
+	FirstBlock int    `json:"first_block"`
+	NumBlocks  int    `json:"num_blocks"`
+	Size       int64  `json:"size"`   // In bytes.
+	CRC32C     uint32 `json:"crc32c"` // Checksum of the entire shard.
+}
+
+// A Storage stores the manifest and shards of a filter. Create must
+// replace a file atomically when its writer is closed.
+type Storage = dirstore.Storage
+
+// A GCStorage is a Storage that can list and remove files.
+// Save removes unreferenced shards from a GCStorage.
+type GCStorage = dirstore.ListStorage
+
+// Dir returns a GCStorage that stores files in the directory path.
+// Names of files, including the shard names in a manifest, must consist
+// of ASCII letters, digits, '.', '-' and '_', and must not start with a dot.
+func Dir(path string) Storage {
+	return dirstore.Dir{Path: path, ErrInvalidName: errInvalidName}
+}
+
+var errInvalidName = errors.New("sharded: invalid file name")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+const (
+	blockBytes  = blobloom.BlockBits / 8
+	headerBytes = 64
+)
+
+// Save stores f in s, as shards of at most maxShardSize bytes,
+// followed by a manifest. The comment is stored in the manifest.
+//
+// The shards get names that are unique to the call and the manifest is
+// written last, so a Save that fails does not replace a previously saved
+// filter, but it may leave stale shards behind. When s is a GCStorage,
+// a successful Save removes all shards that its manifest does not
+// reference, including stale ones. Concurrent Saves to the same Storage
+// are not allowed.
+func Save(s Storage, f *blobloom.Filter, maxShardSize int64, comment string) (*Manifest, error) {
+	return SaveParallel(s, f, maxShardSize, comment, 1)
+}
+
+// SaveParallel is like Save, but writes up to parallelism shards
+// concurrently. The Storage must be safe for concurrent use.
+// f must not be modified during the call.
+func SaveParallel(s Storage, f *blobloom.Filter, maxShardSize int64, comment string, parallelism int) (*Manifest, error) {
+	perShard := (maxShardSize - headerBytes) / blockBytes
+	if perShard < 1 {
+		return nil, fmt.Errorf("sharded: shard size %d too small", maxShardSize)
+	}
+
+	gen, err := newGeneration()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		NumBits:   f.NumBits(),
+		NumHashes: f.NumHashes(),
+		Comment:   comment,
+	}
+	nblocks := int(f.NumBits() / blobloom.BlockBits)
+	for start := 0; start < nblocks; start += int(perShard) {
+		end := start + int(perShard)
+		if end > nblocks {
+			end = nblocks
+		}
+		m.Shards = append(m.Shards, Shard{
+			Name:       fmt.Sprintf("%s%s-%05d%s", shardPrefix, gen, len(m.Shards), shardSuffix),
+			FirstBlock: start,
+			NumBlocks:  end - start,
+		})
+	}
+
+	err = forEach(len(m.Shards), parallelism, func(i int) error {
+		return saveShard(s, f, &m.Shards[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := s.Create(ManifestName)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	err = atomicfile.Finish(w, enc.Encode(m))
+	if err != nil {
+		return nil, err
+	}
+
+	if gc, ok := s.(GCStorage); ok {
+		removeUnreferenced(gc, m)
+	}
+	return m, nil
+}
+
+// Shard names are shardPrefix, a generation, a dash, an index
+// and shardSuffix.
+const (
+	shardPrefix = "shard-"
+	shardSuffix = ".blobloom"
+)
+
+// newGeneration returns a random string that identifies a call to Save.
+func newGeneration() (string, error) {
+	var p [8]byte
+	if _, err := rand.Read(p[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(p[:]), nil
+}
+
+// removeUnreferenced removes the shards in s that m does not reference.
+// Failures are ignored: they only leave files behind.
+func removeUnreferenced(s GCStorage, m *Manifest) {
+	names, err := s.List()
+	if err != nil {
+		return
+	}
+	keep := make(map[string]bool, len(m.Shards))
+	for _, sh := range m.Shards {
+		keep[sh.Name] = true
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, shardPrefix) && strings.HasSuffix(name, shardSuffix) && !keep[name] {
+			s.Remove(name)
+		}
+	}
+}
+
+// saveShard writes sh and fills in its size and checksum.
+func saveShard(s Storage, f *blobloom.Filter, sh *Shard) error {
+	w, err := s.Create(sh.Name)
+	if err != nil {
+		return err
+	}
+
+	crc := crc32.New(castagnoli)
+	n, err := blobloom.DumpRange(io.MultiWriter(w, crc), f,
+		sh.FirstBlock, sh.FirstBlock+sh.NumBlocks, "")
+	err = atomicfile.Finish(w, err)
+	sh.Size, sh.CRC32C = n, crc.Sum32()
+	return err
+}
+
+// ReadManifest reads the manifest from s.
+func ReadManifest(s Storage) (*Manifest, error) {
+	r, err := s.Open(ManifestName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	m := new(Manifest)
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("sharded: invalid manifest: %w", err)
+	}
+	return m, m.check()
+}
+
+// check verifies that the shards of m cover the filter exactly.
+func (m *Manifest) check() error {
+	if m.NumBits == 0 || m.NumBits%blobloom.BlockBits != 0 || m.NumBits > blobloom.MaxBits {
+		return fmt.Errorf("sharded: invalid number of bits %d", m.NumBits)
+	}
+	if m.NumHashes < 2 {
+		return fmt.Errorf("sharded: invalid number of hashes %d", m.NumHashes)
+	}
+
+	next := 0
+	for _, sh := range m.Shards {
+		if sh.FirstBlock != next || sh.NumBlocks < 1 {
+			return errors.New("sharded: shards do not cover the filter")
+		}
+		if sh.Size != headerBytes+blockBytes*int64(sh.NumBlocks) {
+			return fmt.Errorf("sharded: wrong size for shard %s", sh.Name)
+		}
+		next += sh.NumBlocks
+	}
+	if uint64(next) != m.NumBits/blobloom.BlockBits {
+		return errors.New("sharded: shards do not cover the filter")
+	}
+	return nil
+}
+
+// Load loads a filter from s, verifying the checksums of its shards.
+func Load(s Storage) (*blobloom.Filter, *Manifest, error) {
+	return LoadParallel(s, 1)
+}
+
+// LoadParallel is like Load, but reads up to parallelism shards
+// concurrently. The Storage must be safe for concurrent use.
+func LoadParallel(s Storage, parallelism int) (*blobloom.Filter, *Manifest, error) {
+	m, err := ReadManifest(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Shards cover disjoint block ranges, so they can be loaded concurrently.
+	f := blobloom.New(m.NumBits, m.NumHashes)
+	err = forEach(len(m.Shards), parallelism, func(i int) error {
+		return loadShard(s, f, m.Shards[i])
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, m, nil
+}
+
+// forEach calls fn(i) for i in [0,n), with up to parallelism calls running
+// concurrently. After the first error, it starts no more calls and returns
+// that error once the running calls have finished.
+func forEach(n, parallelism int, fn func(i int) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	if parallelism > n {
+		parallelism = n
+	}
+
+	var (
+		mu    sync.Mutex
+		next  int
+		first error
+		wg    sync.WaitGroup
+	)
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				i := next
+				next++
+				stop := i >= n || first != nil
+				mu.Unlock()
+				if stop {
+					return
+				}
+
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return first
+}
+
+func loadShard(s Storage, f *blobloom.Filter, sh Shard) error {
+	r, err := s.Open(sh.Name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cr := &checksumReader{r: r, crc: crc32.New(castagnoli)}
+	l, err := blobloom.NewLoader(cr)
+	if err == nil {
+		err = l.LoadRange(f, sh.FirstBlock)
+	}
+	if err == nil {
+		// Consume trailing garbage, so the checks below fail.
+		_, err = io.Copy(ioutil.Discard, cr)
+	}
+	switch {
+	case err != nil:
+		return fmt.Errorf("sharded: shard %s: %w", sh.Name, err)
+	case cr.n != sh.Size:
+		return fmt.Errorf("sharded: shard %s has size %d, expected %d", sh.Name, cr.n, sh.Size)
+	case cr.crc.Sum32() != sh.CRC32C:
+		return fmt.Errorf("sharded: checksum mismatch for shard %s", sh.Name)
+	}
+	return nil
+}
+
+type checksumReader struct {
+	r   io.Reader
+	crc hash.Hash32
+	n   int64
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.crc.Write(p[:n])
+	r.n += int64(n)
+	return n, err
+}
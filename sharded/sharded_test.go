@@ -0,0 +1,174 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharded
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLoad(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "blobloom-sharded")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	f := blobloom.New(1000*blobloom.BlockBits, 5)
+	r := rand.New(rand.NewSource(0x5eed))
+	for i := 0; i < 10000; i++ {
+		f.Add(r.Uint64())
+	}
+
+	s := Dir(tmp)
+	m, err := Save(s, f, 64+300*64, "sharded")
+	require.NoError(t, err)
+	require.Len(t, m.Shards, 4)
+	assert.Equal(t, 900, m.Shards[3].FirstBlock)
+	assert.Equal(t, 100, m.Shards[3].NumBlocks)
+	assert.EqualValues(t, 64+100*64, m.Shards[3].Size)
+
+	g, m2, err := Load(s)
+	require.NoError(t, err)
+	assert.Equal(t, m, m2)
+	assert.True(t, f.Equals(g))
+
+	m, err = SaveParallel(s, f, 64+64*64, "sharded", 4)
+	require.NoError(t, err)
+	require.Len(t, m.Shards, 16)
+	for _, parallelism := range []int{0, 3, 100} {
+		g, m2, err = LoadParallel(s, parallelism)
+		require.NoError(t, err)
+		assert.Equal(t, m, m2)
+		assert.True(t, f.Equals(g))
+	}
+
+	// Corrupt a shard.
+	path := filepath.Join(tmp, m.Shards[2].Name)
+	p, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	p[1000] ^= 1
+	require.NoError(t, ioutil.WriteFile(path, p, 0644))
+	_, _, err = Load(s)
+	assert.Error(t, err)
+	_, _, err = LoadParallel(s, 4)
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, append(p, 0), 0644))
+	_, _, err = Load(s)
+	assert.Error(t, err)
+
+	require.NoError(t, os.Remove(path))
+	_, _, err = Load(s)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	_, err = Save(s, f, 100, "")
+	assert.Error(t, err)
+
+	// Shard names from a manifest cannot escape the directory.
+	m.Shards[0].Name = "../" + m.Shards[0].Name
+	p, err = json.Marshal(m)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, ManifestName), p, 0644))
+	_, _, err = Load(s)
+	assert.True(t, errors.Is(err, errInvalidName))
+}
+
+// failStorage fails to create files after n have been created.
+type failStorage struct {
+	Storage
+	n int
+}
+
+func (s *failStorage) Create(name string) (io.WriteCloser, error) {
+	if s.n == 0 {
+		return nil, errors.New("storage failure")
+	}
+	s.n--
+	return s.Storage.Create(name)
+}
+
+func TestSaveFailure(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "blobloom-sharded")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	r := rand.New(rand.NewSource(0xfa11))
+	f := blobloom.New(100*blobloom.BlockBits, 4)
+	g := blobloom.New(100*blobloom.BlockBits, 4)
+	for i := 0; i < 1000; i++ {
+		f.Add(r.Uint64())
+		g.Add(r.Uint64())
+	}
+
+	s := Dir(tmp)
+	_, err = Save(s, f, 64+30*64, "f")
+	require.NoError(t, err)
+
+	// Fail after writing two of the four shards of g.
+	_, err = Save(&failStorage{s, 2}, g, 64+30*64, "g")
+	require.Error(t, err)
+
+	h, m, err := Load(s)
+	require.NoError(t, err)
+	assert.Equal(t, "f", m.Comment)
+	assert.True(t, f.Equals(h))
+
+	// A successful Save removes the shards of f and the stale ones of g.
+	m, err = Save(s, g, 64+30*64, "g")
+	require.NoError(t, err)
+	h, _, err = Load(s)
+	require.NoError(t, err)
+	assert.True(t, g.Equals(h))
+
+	names, err := s.(GCStorage).List()
+	require.NoError(t, err)
+	expect := []string{ManifestName}
+	for _, sh := range m.Shards {
+		expect = append(expect, sh.Name)
+	}
+	assert.ElementsMatch(t, expect, names)
+}
+
+func TestManifestCheck(t *testing.T) {
+	m := Manifest{
+		NumBits:   10 * blobloom.BlockBits,
+		NumHashes: 3,
+		Shards: []Shard{
+			{FirstBlock: 0, NumBlocks: 4, Size: 64 + 4*64},
+			{FirstBlock: 4, NumBlocks: 6, Size: 64 + 6*64},
+		},
+	}
+	assert.NoError(t, m.check())
+
+	m.Shards[1].FirstBlock = 5
+	assert.Error(t, m.check())
+	m.Shards[1].FirstBlock = 4
+
+	m.Shards[1].Size++
+	assert.Error(t, m.check())
+	m.Shards[1].Size--
+
+	m.NumBits += blobloom.BlockBits
+	assert.Error(t, m.check())
+}
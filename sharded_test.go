@@ -0,0 +1,91 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharded(t *testing.T) {
+	const nkeys, nhashes = 10000, 8
+
+	f := NewSharded(nkeys*10, nhashes, 7)
+	assert.Equal(t, 7, f.NumShards())
+	assert.Equal(t, nhashes, f.NumHashes())
+	assert.GreaterOrEqual(t, f.NumBits(), uint64(nkeys*10))
+	assert.Zero(t, f.NumBits()%BlockBits)
+
+	keys := randomU64(nkeys, 0x54a2d)
+	for _, h := range keys[:nkeys/2] {
+		f.Add(h)
+	}
+	for _, h := range keys[nkeys/2:] {
+		assert.True(t, f.AddIfNotHas(h) || f.Has(h))
+		assert.False(t, f.AddIfNotHas(h))
+	}
+	for _, h := range keys {
+		assert.True(t, f.Has(h))
+	}
+
+	assert.InDelta(t, nkeys, f.Cardinality(), nkeys/50)
+	assert.Greater(t, f.FillRatio(), 0.)
+	assert.Less(t, f.FPRate(nkeys), .01)
+
+	// All shards receive keys.
+	for i := range f.shards {
+		assert.False(t, f.shards[i].f.Empty())
+	}
+
+	f.Clear()
+	for _, h := range keys {
+		assert.False(t, f.Has(h))
+	}
+	assert.Zero(t, f.FillRatio())
+}
+
+func TestShardedConcurrent(t *testing.T) {
+	const ngoroutines = 8
+
+	keys := randomU64(4000, 0x5a5a)
+	f := NewSharded(1<<18, 6, 16)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		added = make([]int, len(keys))
+	)
+	for g := 0; g < ngoroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, h := range keys {
+				if f.AddIfNotHas(h) {
+					mu.Lock()
+					added[i]++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, h := range keys {
+		assert.True(t, f.Has(h))
+		assert.LessOrEqual(t, added[i], 1)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "math/bits"
+
+// MaxShiftClasses is the maximum number of classes of a ShiftingFilter.
+const MaxShiftClasses = 64
+
+// A ShiftingFilter is a shifting Bloom filter (ShBF) that records, for each
+// key, one of a small number of classes, such as which of several sets the
+// key belongs to, or how recently it was seen. A single ShiftingFilter
+// answers "which class" queries that would otherwise take one filter
+// per class and one lookup in each.
+//
+// A key in class c sets the bits that Filter.Add would set, shifted by c
+// times an offset derived from the key's hash. All probes for a key,
+// in every class, fall in the same block, so Classes costs a single cache
+// miss. Since the bits of all classes share the same array, a ShiftingFilter
+// needs about as many bits as a Filter holding all the keys.
+//
+// See Yang et al., A Shifting Bloom Filter Framework for Set Queries,
+// https://arxiv.org/abs/1510.03019.
+type ShiftingFilter struct {
+	b        []block // Shards.
+	k        int     // Number of hash functions required.
+	nclasses int
+}
+
+// NewShifting constructs a ShiftingFilter with the given numbers of bits,
+// hash functions and classes.
+//
+// The number of bits and hashes are increased silently in the same way
+// as New does. NewShifting panics if nclasses is not between one and
+// MaxShiftClasses.
+func NewShifting(nbits uint64, nhashes, nclasses int) *ShiftingFilter {
+	if nclasses < 1 || nclasses > MaxShiftClasses {
+		panic("blobloom: number of classes out of range")
+	}
+	nbits, nhashes = fixBitsAndHashes(nbits, nhashes)
+
+	return &ShiftingFilter{
+		b:        make([]block, nbits/BlockBits),
+		k:        nhashes,
+		nclasses: nclasses,
+	}
+}
+
+// Add inserts a key with hash value h into f, in the given class.
+// A key may be added in more than one class.
+func (f *ShiftingFilter) Add(h uint64, class int) {
+	if class < 0 || class >= f.nclasses {
+		panic("blobloom: class out of range")
+	}
+
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := getblock(f.b, h2)
+	off := uint32(class) * shiftOffset(h)
+
+	for i := 1; i < f.k; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		b.setbit(h1 + off)
+	}
+}
+
+// Classes returns the set of classes that a key with hash value h may have
+// been added in, as a bitmask: bit c is set if the key may be in class c.
+// The result may contain false positives, but no false negatives.
+func (f *ShiftingFilter) Classes(h uint64) uint64 {
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := getblock(f.b, h2)
+	shift := shiftOffset(h)
+
+	mask := ^uint64(0) >> (MaxShiftClasses - f.nclasses)
+	for i := 1; i < f.k && mask != 0; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		for m := mask; m != 0; m &= m - 1 {
+			c := bits.TrailingZeros64(m)
+			if !b.getbit(h1 + uint32(c)*shift) {
+				mask &^= 1 << uint(c)
+			}
+		}
+	}
+	return mask
+}
+
+// Has reports whether a key with hash value h has been added in the given
+// class. It may return a false positive.
+func (f *ShiftingFilter) Has(h uint64, class int) bool {
+	if class < 0 || class >= f.nclasses {
+		return false
+	}
+
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := getblock(f.b, h2)
+	off := uint32(class) * shiftOffset(h)
+
+	for i := 1; i < f.k; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		if !b.getbit(h1 + off) {
+			return false
+		}
+	}
+	return true
+}
+
+// shiftOffset returns the offset between the probes of successive classes
+// for a key with hash h. The offset is odd, so the probes of different
+// classes never coincide modulo BlockBits.
+func shiftOffset(h uint64) uint32 {
+	return uint32(bits.RotateLeft64(h, 21)) | 1
+}
+
+// Clear resets f to its empty state.
+func (f *ShiftingFilter) Clear() {
+	for i := range f.b {
+		f.b[i] = block{}
+	}
+}
+
+// FillRatio returns the fraction of bits in f that are set.
+func (f *ShiftingFilter) FillRatio() float64 {
+	return fillRatio(f.b, onescount)
+}
+
+// NumBits returns the number of bits of f.
+func (f *ShiftingFilter) NumBits() uint64 {
+	return BlockBits * uint64(len(f.b))
+}
+
+// NumClasses returns the number of classes of f.
+func (f *ShiftingFilter) NumClasses() int { return f.nclasses }
+
+// NumHashes returns the number of hash functions of f.
+func (f *ShiftingFilter) NumHashes() int { return f.k }
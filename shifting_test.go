@@ -0,0 +1,86 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShifting(t *testing.T) {
+	const n = 5000
+
+	f := NewShifting(1<<19, 6, 2)
+	assert.Equal(t, 2, f.NumClasses())
+	assert.Equal(t, 6, f.NumHashes())
+
+	// Two sets with some overlap.
+	keys := randomU64(3*n, 0x5b1f)
+	only0, only1, both := keys[:n], keys[n:2*n], keys[2*n:]
+	for _, h := range only0 {
+		f.Add(h, 0)
+	}
+	for _, h := range only1 {
+		f.Add(h, 1)
+	}
+	for _, h := range both {
+		f.Add(h, 0)
+		f.Add(h, 1)
+	}
+
+	var wrong int
+	for want, hs := range [][]uint64{nil, only0, only1, both} {
+		for _, h := range hs {
+			got := f.Classes(h)
+			assert.Equal(t, uint64(want), got&uint64(want))
+			if got != uint64(want) {
+				wrong++
+			}
+			if want&1 != 0 {
+				assert.True(t, f.Has(h, 0))
+			}
+		}
+	}
+	assert.Less(t, wrong, 3*n/1000)
+
+	var fp int
+	for _, h := range randomU64(n, 0xabc) {
+		if f.Classes(h) != 0 {
+			fp++
+		}
+	}
+	assert.Less(t, fp, n/1000)
+
+	assert.False(t, f.Has(both[0], 2))
+	assert.Panics(t, func() { f.Add(1, 2) })
+
+	f.Clear()
+	assert.Zero(t, f.FillRatio())
+	assert.Zero(t, f.Classes(both[0]))
+}
+
+func TestShiftingClasses(t *testing.T) {
+	f := NewShifting(BlockBits, 4, MaxShiftClasses)
+	for c := 0; c < MaxShiftClasses; c += 7 {
+		f.Add(uint64(c), c)
+	}
+	for c := 0; c < MaxShiftClasses; c += 7 {
+		assert.NotZero(t, f.Classes(uint64(c))&(1<<uint(c)))
+	}
+
+	assert.Panics(t, func() { NewShifting(BlockBits, 2, 0) })
+	assert.Panics(t, func() { NewShifting(BlockBits, 2, MaxShiftClasses+1) })
+}
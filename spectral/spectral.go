@@ -0,0 +1,152 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spectral implements spectral Bloom filters, which estimate
+// the multiplicity of keys.
+//
+// A spectral Bloom filter is a counting Bloom filter that uses the minimal
+// increase rule: adding a key only increments the key's smallest counters.
+// Its estimates are never too low and are much closer to the true counts
+// than those of a plain counting Bloom filter of the same size, which
+// increments all of a key's counters.
+//
+// Like the filters in package blobloom, a Filter is divided into blocks that
+// each fill a cache line, so that all the counters for a key are in one block.
+//
+// See Cohen and Matias, Spectral Bloom Filters, SIGMOD 2003,
+// https://doi.org/10.1145/872757.872787.
+package spectral
+
+import "math"
+
+const (
+	counters = 32 // Counters per block.
+
+	// MaxCount is the largest count a Filter can represent.
+	// Counters saturate at MaxCount.
+	MaxCount = 1<<16 - 1
+)
+
+// A block fills a cache line.
+type block [counters]uint16
+
+// A Filter is a spectral Bloom filter.
+//
+// A Filter is not safe for concurrent use.
+type Filter struct {
+	b []block
+	k int // Number of counters per key.
+}
+
+// New constructs a Filter with the given numbers of counters and hash
+// functions. Each counter takes two bytes. As in package blobloom,
+// one hash function selects a block, so each key has nhashes-1 counters.
+//
+// The number of counters is rounded up to a multiple of 32 and the number
+// of hashes is silently increased to two if a lower value is given.
+func New(ncounters uint64, nhashes int) *Filter {
+	nblocks := (ncounters + counters - 1) / counters
+	if nblocks < 1 {
+		nblocks = 1
+	}
+	if nblocks > math.MaxUint32 {
+		panic("spectral: too many counters")
+	}
+	if nhashes < 2 {
+		nhashes = 2
+	}
+	return &Filter{b: make([]block, nblocks), k: nhashes}
+}
+
+// Add records an occurrence of the key with hash value h.
+func (f *Filter) Add(h uint64) {
+	est := f.Count(h)
+	if est == MaxCount {
+		return
+	}
+
+	// Minimal increase: only raise counters to the new estimate.
+	est++
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := f.block(h2)
+	for i := 1; i < f.k; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		if c := &b[h1%counters]; *c < est {
+			*c = est
+		}
+	}
+}
+
+// Count returns an estimate of the number of times the key with hash value h
+// has been added, since the last Clear and taking Decay into account.
+// The estimate is never lower than the true count, unless that exceeds
+// MaxCount.
+func (f *Filter) Count(h uint64) uint16 {
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := f.block(h2)
+
+	est := uint16(MaxCount)
+	for i := 1; i < f.k; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		est = min16(est, b[h1%counters])
+	}
+	return est
+}
+
+// Decay halves all counts, rounding down, so that old occurrences count
+// for less than recent ones. Calling Decay periodically turns a Filter into
+// a sketch of recent frequencies.
+func (f *Filter) Decay() {
+	for i := range f.b {
+		for j := range f.b[i] {
+			f.b[i][j] >>= 1
+		}
+	}
+}
+
+// Clear resets all counts to zero.
+func (f *Filter) Clear() {
+	for i := range f.b {
+		f.b[i] = block{}
+	}
+}
+
+// NumCounters returns the number of counters of f.
+func (f *Filter) NumCounters() uint64 { return counters * uint64(len(f.b)) }
+
+// NumHashes returns the number of hash functions of f.
+func (f *Filter) NumHashes() int { return f.k }
+
+func (f *Filter) block(h2 uint32) *block {
+	return &f.b[reducerange(h2, uint32(len(f.b)))]
+}
+
+func min16(a, b uint16) uint16 {
+	if b < a {
+		return b
+	}
+	return a
+}
+
+// The following match their counterparts in package blobloom.
+
+func doublehash(h1, h2 uint32, i int) (uint32, uint32) {
+	h1 = h1 + h2
+	h2 = h2 + uint32(i)
+	return h1, h2
+}
+
+func reducerange(i, n uint32) uint32 {
+	return uint32((uint64(i) * uint64(n)) >> 32)
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spectral
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpectral(t *testing.T) {
+	const nkeys = 2000
+
+	f := New(16*nkeys, 4)
+	assert.Equal(t, uint64(16*nkeys), f.NumCounters())
+	assert.Equal(t, 4, f.NumHashes())
+
+	// Zipf-distributed counts, plus a plain counting filter to compare with.
+	r := rand.New(rand.NewSource(0x5bec))
+	z := rand.NewZipf(r, 1.2, 1, nkeys-1)
+	counts := make(map[uint64]int)
+	plain := make([]block, len(f.b))
+	for i := 0; i < 50000; i++ {
+		h := key(z.Uint64())
+		counts[h]++
+		f.Add(h)
+
+		h1, h2 := uint32(h>>32), uint32(h)
+		b := &plain[reducerange(h2, uint32(len(plain)))]
+		for i := 1; i < f.k; i++ {
+			h1, h2 = doublehash(h1, h2, i)
+			b[h1%counters]++
+		}
+	}
+
+	var errSpectral, errPlain int
+	for h, n := range counts {
+		est := int(f.Count(h))
+		assert.GreaterOrEqual(t, est, n)
+		errSpectral += est - n
+
+		h1, h2 := uint32(h>>32), uint32(h)
+		b := &plain[reducerange(h2, uint32(len(plain)))]
+		min := uint16(MaxCount)
+		for i := 1; i < f.k; i++ {
+			h1, h2 = doublehash(h1, h2, i)
+			min = min16(min, b[h1%counters])
+		}
+		errPlain += int(min) - n
+	}
+	assert.Less(t, errSpectral, errPlain)
+
+	f.Decay()
+	for h, n := range counts {
+		assert.GreaterOrEqual(t, int(f.Count(h)), n/2)
+	}
+
+	f.Clear()
+	for h := range counts {
+		assert.Zero(t, f.Count(h))
+	}
+}
+
+func TestSaturate(t *testing.T) {
+	f := New(0, 0)
+	assert.Equal(t, uint64(counters), f.NumCounters())
+	assert.Equal(t, 2, f.NumHashes())
+
+	for i := 0; i < MaxCount+10; i++ {
+		f.Add(key(1))
+	}
+	assert.EqualValues(t, MaxCount, f.Count(key(1)))
+}
+
+func key(i uint64) uint64 { return (i + 1) * 0x9e3779b97f4a7c15 }
@@ -0,0 +1,152 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "hash/maphash"
+
+// A Partition is a part of a Filter, as returned by SplitByPrefix.
+// It holds a contiguous range of the Filter's blocks, which are the blocks
+// selected by a range of hash values. A Partition is not safe for
+// concurrent use.
+//
+// Add and Has must only be called with hash values that the Partition
+// Owns. Use Route to find the Partition for a hash value.
+type Partition struct {
+	b         []block
+	k         int
+	seed      maphash.Seed // Of the original Filter, for AddString and friends.
+	probeSeed uint64
+	probing   Probing
+	index     int
+	nparts    int
+	nblocks   uint32 // Number of blocks in the original Filter.
+}
+
+// SplitByPrefix splits f into n Partitions, each holding a copy of one n-th
+// of its blocks. Partition i holds the keys whose hash values fall in the
+// i-th of n equal ranges of the low 32 bits, which select the block, after
+// mixing in the seed of f.
+//
+// The Partitions can be filled independently, e.g., by worker goroutines
+// or processes that each handle one range of hash values, and then be
+// recombined with MergePartitions. Adding a key to its Partition has the
+// same effect as adding it to f.
+//
+// SplitByPrefix panics if n is less than one or does not divide the number
+// of blocks in f.
+func (f *Filter) SplitByPrefix(n int) []*Partition {
+	if n < 1 || len(f.b)%n != 0 {
+		panic("number of partitions does not divide number of blocks")
+	}
+
+	m := len(f.b) / n
+	parts := make([]*Partition, n)
+	for i := range parts {
+		b := make([]block, m)
+		copy(b, f.b[i*m:])
+		parts[i] = &Partition{
+			b:         b,
+			k:         f.k,
+			seed:      f.seed,
+			probeSeed: f.probeSeed,
+			probing:   f.probing,
+			index:     i,
+			nparts:    n,
+			nblocks:   uint32(len(f.b)),
+		}
+	}
+	return parts
+}
+
+// MergePartitions reassembles the Partitions returned by a call to
+// SplitByPrefix into a Filter. The Partitions must be given in order.
+//
+// MergePartitions panics if parts is not a complete, ordered set of
+// Partitions from a single split.
+func MergePartitions(parts ...*Partition) *Filter {
+	if len(parts) == 0 {
+		panic("no partitions to merge")
+	}
+	p0 := parts[0]
+	f := &Filter{b: make([]block, 0, p0.nblocks), k: p0.k, seed: p0.seed,
+		probeSeed: p0.probeSeed, probing: p0.probing}
+	for i, p := range parts {
+		if p.index != i || p.nparts != len(parts) || p.nblocks != p0.nblocks ||
+			p.k != p0.k || p.seed != p0.seed || p.probeSeed != p0.probeSeed ||
+			p.probing != p0.probing {
+			panic("partitions do not form a Bloom filter")
+		}
+		f.b = append(f.b, p.b...)
+	}
+	return f
+}
+
+// Index returns the index of p in the result of SplitByPrefix.
+func (p *Partition) Index() int { return p.index }
+
+// Owns reports whether the hash value h belongs in p.
+func (p *Partition) Owns(h uint64) bool { return p.Route(h) == p.index }
+
+// Route returns the index of the Partition that h belongs in, among
+// those that p was split with.
+func (p *Partition) Route(h uint64) int {
+	return int(reducerange(uint32(mix(h, p.probeSeed)), uint32(p.nparts)))
+}
+
+// Add inserts a key with hash value h into p.
+// It panics if p does not own h.
+func (p *Partition) Add(h uint64) {
+	h = mix(h, p.probeSeed)
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := p.getblock(h2)
+	h3 := thirdhash(p.probing, h1)
+
+	for i := 1; i < p.k; i++ {
+		h1, h2 = probehash(h1, h2, h3, i)
+		b.setbit(h1)
+	}
+}
+
+// Has reports whether a key with hash value h has been added.
+// It may return a false positive. It panics if p does not own h.
+func (p *Partition) Has(h uint64) bool {
+	h = mix(h, p.probeSeed)
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := p.getblock(h2)
+
+	if useVector(p.k) && p.probing == DoubleHashing {
+		return hasVector(b, h1, h2, p.k)
+	}
+	h3 := thirdhash(p.probing, h1)
+	for i := 1; i < p.k; i++ {
+		h1, h2 = probehash(h1, h2, h3, i)
+		if !b.getbit(h1) {
+			return false
+		}
+	}
+	return true
+}
+
+// getblock returns the block that the original Filter would select for h2.
+func (p *Partition) getblock(h2 uint32) *block {
+	i := int(reducerange(h2, p.nblocks)) - p.index*len(p.b)
+	if i < 0 || i >= len(p.b) {
+		panic("hash value does not belong in partition")
+	}
+	return &p.b[i]
+}
+
+// NumBits returns the number of bits of p.
+func (p *Partition) NumBits() uint64 { return BlockBits * uint64(len(p.b)) }
@@ -0,0 +1,73 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitByPrefix(t *testing.T) {
+	const nparts = 4
+
+	for _, seed := range []uint64{0, 0x5eed} {
+		keys := randomU64(4000, 0x5b1)
+		f := New(12*BlockBits, 5)
+		f.SetSeed(seed)
+		want := f.Clone()
+		for _, h := range keys {
+			want.Add(h)
+		}
+
+		for _, h := range keys[:1000] {
+			f.Add(h)
+		}
+		f.AddString("before split")
+		want.AddString("before split")
+		parts := f.SplitByPrefix(nparts)
+		require.Len(t, parts, nparts)
+
+		for j, h := range keys[1000:] {
+			i := parts[0].Route(h)
+			p := parts[i]
+			assert.True(t, p.Owns(h))
+			assert.False(t, parts[(i+1)%nparts].Owns(h))
+			p.Add(h)
+			assert.True(t, p.Has(h))
+			if j < 10 {
+				assert.Panics(t, func() { parts[(i+1)%nparts].Add(h) })
+			}
+		}
+		for i, p := range parts {
+			assert.Equal(t, i, p.Index())
+			assert.EqualValues(t, 3*BlockBits, p.NumBits())
+		}
+
+		g := MergePartitions(parts...)
+		assert.True(t, want.Equals(g))
+		for _, h := range keys {
+			assert.True(t, g.Has(h))
+		}
+		assert.True(t, g.HasString("before split"))
+
+		assert.Panics(t, func() { MergePartitions(parts[1:]...) })
+		assert.Panics(t, func() { MergePartitions(parts[1], parts[0], parts[2], parts[3]) })
+	}
+
+	assert.Panics(t, func() { New(12*BlockBits, 5).SplitByPrefix(5) })
+	assert.Panics(t, func() { New(12*BlockBits, 5).SplitByPrefix(0) })
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "sync/atomic"
+
+// Stats holds the number of operations performed on a filter.
+type Stats struct {
+	Adds    uint64 // Keys added, by Add, AddMany and AddIfNotHas.
+	Lookups uint64 // Calls to Has and AddIfNotHas.
+	Hits    uint64 // Lookups that found the key.
+
+	Batches   uint64 // Calls to AddMany.
+	BatchAdds uint64 // Keys added by AddMany.
+
+	Verified       uint64 // Hits checked by Counters.Verify.
+	FalsePositives uint64 // Verified hits for keys that were not added.
+}
+
+// Counters is a Recorder that counts operations using atomic counters.
+// Set it on a filter with SetRecorder to enable the filter's Stats method,
+// which exporters such as bloomprom, bloomexpvar and bloomotel read.
+//
+// The zero Counters is ready to use. To measure the actual false positive
+// rate, set Verify before setting the Counters on a filter.
+type Counters struct {
+	adds, lookups, hits, batches, batchAdds uint64 // Accessed atomically.
+	verified, falsePositives                uint64 // Accessed atomically.
+
+	// Verify, if not nil, is called with the hash value of a sample of
+	// the hits, to check whether the key was actually added. It typically
+	// consults the authoritative store that the filter fronts. Verify must
+	// be safe for concurrent use if the filter is a SyncFilter.
+	Verify func(h uint64) bool
+
+	// VerifyEvery is the sampling interval of Verify: every VerifyEvery-th
+	// hit is checked. Zero means every hit.
+	VerifyEvery uint64
+}
+
+// RecordAdd counts a call to Add.
+func (c *Counters) RecordAdd(h uint64) { atomic.AddUint64(&c.adds, 1) }
+
+// RecordBatch counts a call to AddMany.
+func (c *Counters) RecordBatch(hs []uint64) {
+	n := uint64(len(hs))
+	atomic.AddUint64(&c.adds, n)
+	atomic.AddUint64(&c.batches, 1)
+	atomic.AddUint64(&c.batchAdds, n)
+}
+
+// RecordHas counts a call to Has. If found and the hit is sampled,
+// it also calls c.Verify.
+func (c *Counters) RecordHas(h uint64, found bool) {
+	atomic.AddUint64(&c.lookups, 1)
+	if !found {
+		return
+	}
+	nhits := atomic.AddUint64(&c.hits, 1)
+	if c.Verify == nil || c.VerifyEvery > 1 && nhits%c.VerifyEvery != 0 {
+		return
+	}
+	atomic.AddUint64(&c.verified, 1)
+	if !c.Verify(h) {
+		atomic.AddUint64(&c.falsePositives, 1)
+	}
+}
+
+// Stats returns the current counts.
+func (c *Counters) Stats() Stats {
+	return Stats{
+		Adds:      atomic.LoadUint64(&c.adds),
+		Lookups:   atomic.LoadUint64(&c.lookups),
+		Hits:      atomic.LoadUint64(&c.hits),
+		Batches:   atomic.LoadUint64(&c.batches),
+		BatchAdds: atomic.LoadUint64(&c.batchAdds),
+
+		Verified:       atomic.LoadUint64(&c.verified),
+		FalsePositives: atomic.LoadUint64(&c.falsePositives),
+	}
+}
+
+// A BatchRecorder is a Recorder that is notified of calls to AddMany as
+// a whole. AddMany calls RecordAdd for each key on Recorders that do not
+// implement this interface.
+type BatchRecorder interface {
+	Recorder
+	RecordBatch(hs []uint64)
+}
+
+// A statser is a Recorder that keeps Stats, such as *Counters.
+type statser interface {
+	Stats() Stats
+}
+
+// Stats returns the operation counts of f. These are only maintained when
+// f has a Recorder with a method Stats() Stats, such as a *Counters;
+// otherwise, Stats returns zero counts.
+func (f *Filter) Stats() Stats { return stats(f.rec) }
+
+// Stats returns the operation counts of f. These are only maintained when
+// f has a Recorder with a method Stats() Stats, such as a *Counters;
+// otherwise, Stats returns zero counts.
+func (f *SyncFilter) Stats() Stats { return stats(f.rec) }
+
+func stats(r Recorder) Stats {
+	if s, ok := r.(statser); ok {
+		return s.Stats()
+	}
+	return Stats{}
+}
+
+// AddMany adds the keys with hash values hs to f.
+//
+// For filters larger than the CPU caches, AddMany is faster than a loop
+// around Add, because it fetches the blocks for several keys from memory
+// in parallel.
+func (f *Filter) AddMany(hs []uint64) {
+	f.addBatch(hs)
+	recordBatch(f.rec, hs)
+	if f.sat != nil {
+		f.checkSaturation(len(hs))
+	}
+}
+
+// AddMany adds the keys with hash values hs to f.
+// See Filter.AddMany for its performance characteristics.
+func (f *SyncFilter) AddMany(hs []uint64) {
+	f.addBatch(hs)
+	if f.dirty != nil {
+		for _, h := range hs {
+			f.markDirty(h)
+		}
+	}
+	recordBatch(f.rec, hs)
+	if f.sat != nil {
+		f.checkSaturation(len(hs))
+	}
+}
+
+func recordBatch(r Recorder, hs []uint64) {
+	switch r := r.(type) {
+	case nil:
+	case BatchRecorder:
+		r.RecordBatch(hs)
+	default:
+		for _, h := range hs {
+			r.RecordAdd(h)
+		}
+	}
+}
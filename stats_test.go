@@ -0,0 +1,92 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	for _, f := range []interface {
+		Add(uint64)
+		AddMany([]uint64)
+		Has(uint64) bool
+		SetRecorder(Recorder)
+		Stats() Stats
+	}{
+		New(1<<12, 4), NewSync(1<<12, 4),
+	} {
+		f.Add(1)
+		assert.Equal(t, Stats{}, f.Stats())
+
+		f.SetRecorder(new(Counters))
+		f.Add(2)
+		f.AddMany([]uint64{3, 4, 5})
+		f.AddMany(nil)
+		f.Has(1)
+		f.Has(5)
+		f.Has(0xffffffffffff)
+
+		assert.Equal(t, Stats{
+			Adds:      4,
+			Lookups:   3,
+			Hits:      2,
+			Batches:   2,
+			BatchAdds: 3,
+		}, f.Stats())
+	}
+}
+
+func TestCountersVerify(t *testing.T) {
+	keys := randomU64(2000, 0x7e71f)
+	added := make(map[uint64]bool)
+	for _, h := range keys[:1000] {
+		added[h] = true
+	}
+
+	f := New(1<<13, 4)
+	c := &Counters{
+		Verify:      func(h uint64) bool { return added[h] },
+		VerifyEvery: 2,
+	}
+	f.SetRecorder(c)
+	f.AddMany(keys[:1000])
+
+	var fp uint64
+	for _, h := range keys {
+		if f.Has(h) && !added[h] {
+			fp++
+		}
+	}
+
+	st := f.Stats()
+	assert.Greater(t, fp, uint64(0))
+	assert.Equal(t, st.Hits/2, st.Verified)
+	assert.Greater(t, st.FalsePositives, uint64(0))
+	assert.LessOrEqual(t, st.FalsePositives, fp)
+}
+
+func TestAddManyRecorder(t *testing.T) {
+	f := New(1<<12, 4)
+	var r recording
+	f.SetRecorder(&r)
+	f.AddMany([]uint64{1, 2, 3})
+	assert.Equal(t, []uint64{1, 2, 3}, r.adds)
+	for h := uint64(1); h <= 3; h++ {
+		assert.True(t, f.Has(h))
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "encoding/binary"
+
+// A BlockStorage stores the blocks of a StorageFilter. Implementations can
+// keep the blocks in a remote cache, in disk pages, in a compressed store
+// or anywhere else.
+//
+// Blocks are encoded as by AppendBlock: BlockBytes bytes holding sixteen
+// little-endian 32-bit words.
+type BlockStorage interface {
+	// ReadBlock reads block i into p, which has length BlockBytes.
+	// Blocks that have never been written must read as all zeros.
+	ReadBlock(i int, p []byte) error
+
+	// WriteBlock sets block i to the contents of p, which has length
+	// BlockBytes. The storage must not retain p.
+	WriteBlock(i int, p []byte) error
+
+	// Flush persists all blocks written so far.
+	Flush() error
+}
+
+// A StorageFilter is a blocked Bloom filter whose blocks are kept in
+// a BlockStorage. It uses the same probes as a Filter, so a StorageFilter
+// and a Filter of the same shape, seed and probing scheme hold the same
+// bits for the same keys.
+//
+// Each Add or Has reads one block from the storage, and each Add that
+// changes a block writes it back. Errors from the storage are returned
+// to the caller.
+//
+// A StorageFilter is not safe for concurrent use, unless its storage is
+// and concurrent Adds of keys in the same block are acceptable to lose.
+type StorageFilter struct {
+	st        BlockStorage
+	nblocks   uint32
+	k         int
+	probeSeed uint64 // See SetSeed.
+	probing   Probing
+}
+
+// NewStorageFilter constructs a StorageFilter with given numbers of bits
+// and hash functions, with blocks stored in st. The numbers of bits and
+// hashes are adjusted as by New. st must hold nbits/BlockBits blocks.
+func NewStorageFilter(st BlockStorage, nbits uint64, nhashes int) *StorageFilter {
+	nbits, nhashes = fixBitsAndHashes(nbits, nhashes)
+	return &StorageFilter{
+		st:      st,
+		nblocks: uint32(nbits / BlockBits),
+		k:       nhashes,
+	}
+}
+
+// Add inserts a key with hash value h into f.
+func (f *StorageFilter) Add(h uint64) error {
+	var buf [BlockBytes]byte
+	i, b, err := f.read(h, &buf)
+	if err != nil {
+		return err
+	}
+
+	old := b
+	h = mix(h, f.probeSeed)
+	h1, h2 := uint32(h>>32), uint32(h)
+	h3 := thirdhash(f.probing, h1)
+	for j := 1; j < f.k; j++ {
+		h1, h2 = probehash(h1, h2, h3, j)
+		b.setbit(h1)
+	}
+	if b == old {
+		return nil
+	}
+
+	for j := range b {
+		binary.LittleEndian.PutUint32(buf[4*j:], b[j])
+	}
+	return f.st.WriteBlock(i, buf[:])
+}
+
+// Has reports whether a key with hash value h has been added.
+// It may return a false positive.
+func (f *StorageFilter) Has(h uint64) (bool, error) {
+	var buf [BlockBytes]byte
+	_, b, err := f.read(h, &buf)
+	if err != nil {
+		return false, err
+	}
+
+	h = mix(h, f.probeSeed)
+	h1, h2 := uint32(h>>32), uint32(h)
+	h3 := thirdhash(f.probing, h1)
+	for j := 1; j < f.k; j++ {
+		h1, h2 = probehash(h1, h2, h3, j)
+		if !b.getbit(h1) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// read reads the block for h.
+func (f *StorageFilter) read(h uint64, buf *[BlockBytes]byte) (i int, b block, err error) {
+	i = f.BlockIndex(h)
+	if err = f.st.ReadBlock(i, buf[:]); err != nil {
+		return i, b, err
+	}
+	for j := range b {
+		b[j] = binary.LittleEndian.Uint32(buf[4*j:])
+	}
+	return i, b, nil
+}
+
+// BlockIndex returns the index of the block that a key with hash value h
+// maps to. All bits set by Add(h) are in that block.
+func (f *StorageFilter) BlockIndex(h uint64) int {
+	return int(reducerange(uint32(mix(h, f.probeSeed)), f.nblocks))
+}
+
+// Flush calls Flush on f's storage.
+func (f *StorageFilter) Flush() error { return f.st.Flush() }
+
+// NumBits returns the number of bits of f.
+func (f *StorageFilter) NumBits() uint64 { return BlockBits * uint64(f.nblocks) }
+
+// NumHashes returns the number of hash functions of f.
+func (f *StorageFilter) NumHashes() int { return f.k }
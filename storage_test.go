@@ -0,0 +1,128 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memStorage struct {
+	blocks map[int][]byte
+	writes int
+	err    error
+}
+
+func (s *memStorage) ReadBlock(i int, p []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	b, ok := s.blocks[i]
+	if !ok {
+		b = make([]byte, BlockBytes)
+	}
+	copy(p, b)
+	return nil
+}
+
+func (s *memStorage) WriteBlock(i int, p []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.writes++
+	s.blocks[i] = append([]byte(nil), p...)
+	return nil
+}
+
+func (s *memStorage) Flush() error { return s.err }
+
+func TestStorageFilter(t *testing.T) {
+	const nbits, nhashes = 30 * BlockBits, 6
+
+	st := &memStorage{blocks: make(map[int][]byte)}
+	sf := NewStorageFilter(st, nbits, nhashes)
+	f := New(nbits, nhashes)
+	assert.Equal(t, f.NumBits(), sf.NumBits())
+	assert.Equal(t, f.NumHashes(), sf.NumHashes())
+
+	r := rand.New(rand.NewSource(0x5707))
+	keys := make([]uint64, 200)
+	for i := range keys {
+		keys[i] = r.Uint64()
+		assert.NoError(t, sf.Add(keys[i]))
+		f.Add(keys[i])
+		assert.Equal(t, f.BlockIndex(keys[i]), sf.BlockIndex(keys[i]))
+	}
+	assert.NoError(t, sf.Flush())
+
+	for i, p := range st.blocks {
+		assert.Equal(t, f.AppendBlock(nil, i), p)
+	}
+	for _, h := range keys {
+		found, err := sf.Has(h)
+		assert.NoError(t, err)
+		assert.True(t, found)
+	}
+	for i := 0; i < 1000; i++ {
+		h := r.Uint64()
+		found, err := sf.Has(h)
+		assert.NoError(t, err)
+		assert.Equal(t, f.Has(h), found)
+	}
+
+	// Re-adding keys doesn't write.
+	writes := st.writes
+	for _, h := range keys {
+		assert.NoError(t, sf.Add(h))
+	}
+	assert.Equal(t, writes, st.writes)
+
+	st.err = errors.New("storage failure")
+	assert.Equal(t, st.err, sf.Add(keys[0]))
+	_, err := sf.Has(keys[0])
+	assert.Equal(t, st.err, err)
+	assert.Equal(t, st.err, sf.Flush())
+}
+
+func TestStorageFilterSeed(t *testing.T) {
+	const nbits, nhashes = 30 * BlockBits, 6
+
+	for _, p := range []Probing{DoubleHashing, TripleHashing} {
+		st := &memStorage{blocks: make(map[int][]byte)}
+		sf := NewStorageFilter(st, nbits, nhashes)
+		sf.SetSeed(0x5eed)
+		sf.SetProbing(p)
+		assert.EqualValues(t, 0x5eed, sf.Seed())
+		assert.Equal(t, p, sf.Probing())
+
+		f := New(nbits, nhashes)
+		f.SetSeed(0x5eed)
+		f.SetProbing(p)
+
+		r := rand.New(rand.NewSource(0x5eed))
+		for i := 0; i < 200; i++ {
+			h := r.Uint64()
+			assert.NoError(t, sf.Add(h))
+			f.Add(h)
+			assert.Equal(t, f.BlockIndex(h), sf.BlockIndex(h))
+		}
+		for i, b := range st.blocks {
+			assert.Equal(t, f.AppendBlock(nil, i), b)
+		}
+	}
+}
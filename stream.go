@@ -0,0 +1,59 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "io"
+
+// streamChunk is the maximum number of blocks that Dump and ReadFrom
+// pass to a single Write or Read call.
+const streamChunk = 512 // 32KiB.
+
+// WriteTo writes f to w in the format written by Dump, with an empty
+// comment. The blocks are written in chunks, so unlike MarshalBinary,
+// WriteTo does not make a copy of f in memory.
+func (f *Filter) WriteTo(w io.Writer) (n int64, err error) {
+	return dump(w, f.b, f.k, f.probeSeed, f.probing, "")
+}
+
+// ReadFrom sets f to the filter read from r, which must be in the format
+// written by Dump. It reads no further than the end of the filter.
+//
+// On error, f is left unchanged. A Recorder set on f is retained.
+func (f *Filter) ReadFrom(r io.Reader) (n int64, err error) {
+	cr := &countingReader{r: r}
+	l, err := NewLoader(cr)
+	if err != nil {
+		return cr.n, err
+	}
+	b, err := readBlocks(cr, l.nblocks)
+	if err != nil {
+		return cr.n, err
+	}
+
+	f.b, f.k, f.seed = b, l.nhashes, ensureSeed(f.seed)
+	f.probeSeed, f.probing = l.probeSeed, l.probing
+	return cr.n, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ io.WriterTo   = (*Filter)(nil)
+	_ io.ReaderFrom = (*Filter)(nil)
+)
+
+func TestWriteToReadFrom(t *testing.T) {
+	// More blocks than fit in a chunk.
+	f := New((2*streamChunk+3)*BlockBits, 5)
+	for _, h := range randomU64(5000, 0x57ea) {
+		f.Add(h)
+	}
+
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, buf.Len(), n)
+
+	p, err := f.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, p, buf.Bytes())
+
+	// ReadFrom stops at the end of the filter.
+	buf.WriteString("trailer")
+	g := New(1, 2)
+	n, err = g.ReadFrom(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(p), n)
+	assert.True(t, f.Equals(g))
+	assert.Equal(t, "trailer", buf.String())
+
+	n, err = g.ReadFrom(bytes.NewReader(p[:len(p)-1]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.EqualValues(t, len(p)-1, n)
+	assert.True(t, f.Equals(g))
+
+	_, err = new(Filter).WriteTo(&buf)
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "hash/maphash"
+
+// AddString inserts the string s into f.
+//
+// AddString, AddBytes, HasString and HasBytes hash their keys with
+// hash/maphash, using a random seed chosen when f is constructed.
+// The hash values differ between filters and between runs of a program,
+// so string keys are only found in the filter they were added to, or in
+// its Clones. In particular, they are lost by Dump and Load, and Union
+// and Intersect do not work for them, except between a filter and its
+// Clones. For persistent filters of strings, hash the strings with
+// a fixed hash function and use Add and Has.
+func (f *Filter) AddString(s string) { f.Add(hashString(f.seed, s)) }
+
+// AddBytes inserts the byte slice p into f. See AddString for details.
+func (f *Filter) AddBytes(p []byte) { f.Add(hashBytes(f.seed, p)) }
+
+// HasString reports whether the string s has been added to f by AddString
+// or AddBytes. It may return a false positive.
+func (f *Filter) HasString(s string) bool { return f.Has(hashString(f.seed, s)) }
+
+// HasBytes reports whether the byte slice p has been added to f by AddBytes
+// or AddString. It may return a false positive.
+func (f *Filter) HasBytes(p []byte) bool { return f.Has(hashBytes(f.seed, p)) }
+
+// AddString inserts the string s into f.
+// See Filter.AddString for how s is hashed.
+func (f *SyncFilter) AddString(s string) { f.Add(hashString(f.seed, s)) }
+
+// AddBytes inserts the byte slice p into f.
+// See Filter.AddString for how p is hashed.
+func (f *SyncFilter) AddBytes(p []byte) { f.Add(hashBytes(f.seed, p)) }
+
+// HasString reports whether the string s has been added to f by AddString
+// or AddBytes. It may return a false positive.
+func (f *SyncFilter) HasString(s string) bool { return f.Has(hashString(f.seed, s)) }
+
+// HasBytes reports whether the byte slice p has been added to f by AddBytes
+// or AddString. It may return a false positive.
+func (f *SyncFilter) HasBytes(p []byte) bool { return f.Has(hashBytes(f.seed, p)) }
+
+func hashString(seed maphash.Seed, s string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	h.WriteString(s)
+	return h.Sum64()
+}
+
+func hashBytes(seed maphash.Seed, p []byte) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	h.Write(p)
+	return h.Sum64()
+}
+
+// ensureSeed returns seed if it has been set, else a new random seed.
+func ensureSeed(seed maphash.Seed) maphash.Seed {
+	if seed == (maphash.Seed{}) {
+		seed = maphash.MakeSeed()
+	}
+	return seed
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrings(t *testing.T) {
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, fmt.Sprint("key", i))
+	}
+
+	f, s := New(1<<15, 6), NewSync(1<<15, 6)
+	for i, k := range keys[:500] {
+		if i%2 == 0 {
+			f.AddString(k)
+			s.AddString(k)
+		} else {
+			f.AddBytes([]byte(k))
+			s.AddBytes([]byte(k))
+		}
+	}
+
+	fp := 0
+	for i, k := range keys {
+		if i < 500 {
+			assert.True(t, f.HasString(k))
+			assert.True(t, f.HasBytes([]byte(k)))
+			assert.True(t, s.HasString(k))
+			assert.True(t, s.HasBytes([]byte(k)))
+		} else if f.HasString(k) {
+			fp++
+		}
+	}
+	assert.Less(t, fp, 10)
+
+	// Clones share the seed.
+	g := f.Clone()
+	for _, k := range keys[:500] {
+		assert.True(t, g.HasString(k))
+	}
+	assert.True(t, s.Clone().HasString(keys[0]))
+
+	// Each filter gets its own seed.
+	assert.NotEqual(t, hashString(f.seed, keys[0]), hashString(New(1<<15, 6).seed, keys[0]))
+
+	// Zero Filters become usable for strings when decoded into.
+	p, err := f.MarshalBinary()
+	require.NoError(t, err)
+
+	var h Filter
+	require.NoError(t, h.UnmarshalBinary(p))
+	h.AddString("foo")
+	assert.True(t, h.HasString("foo"))
+
+	var r Filter
+	_, err = r.ReadFrom(bytes.NewReader(p))
+	require.NoError(t, err)
+	r.AddString("foo")
+	assert.True(t, r.HasString("foo"))
+
+	var q SyncFilter
+	require.NoError(t, q.UnmarshalBinary(p))
+	q.AddString("foo")
+	assert.True(t, q.HasString("foo"))
+
+	// Decoding keeps a seed that was already set.
+	seed := g.seed
+	require.NoError(t, g.UnmarshalBinary(p))
+	assert.Equal(t, seed, g.seed)
+}
+
+func TestStringsNoAlloc(t *testing.T) {
+	f := New(1<<15, 6)
+	key := []byte("some key")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		f.AddBytes(key)
+		f.AddString("some key")
+		f.HasBytes(key)
+		f.HasString("some key")
+	})
+	assert.Zero(t, allocs)
+}
@@ -16,6 +16,7 @@ package blobloom
 
 import (
 	"encoding/binary"
+	"hash/maphash"
 	"io"
 	"sync/atomic"
 )
@@ -34,8 +35,15 @@ import (
 // but is implemented much more efficiently.
 // See the method descriptions for exceptions to the previous rule.
 type SyncFilter struct {
-	b []block // Shards.
-	k int     // Number of hash functions required.
+	b   []block  // Shards.
+	k   int      // Number of hash functions required.
+	rec Recorder // Optional.
+
+	seed      maphash.Seed // For AddString and friends.
+	probeSeed uint64       // Mixed into hash values, see SetSeed.
+	probing   Probing
+	sat       *saturation // Optional, see SetSaturationFunc.
+	dirty     dirtySet    // Optional, see TrackDirty.
 }
 
 // NewSync constructs a Bloom filter with given numbers of bits and hash functions.
@@ -50,8 +58,9 @@ func NewSync(nbits uint64, nhashes int) *SyncFilter {
 	nbits, nhashes = fixBitsAndHashes(nbits, nhashes)
 
 	return &SyncFilter{
-		b: make([]block, nbits/BlockBits),
-		k: nhashes,
+		b:    make([]block, nbits/BlockBits),
+		k:    nhashes,
+		seed: maphash.MakeSeed(),
 	}
 
 }
@@ -84,18 +93,33 @@ func ReadSync(stream io.Reader) (*SyncFilter, error) {
 		b = append(b, block)
 	}
 	return &SyncFilter{
-		k: int(k),
-		b: b,
+		k:    int(k),
+		b:    b,
+		seed: maphash.MakeSeed(),
 	}, nil
 }
 
 // Add insert a key with hash value h into f.
 func (f *SyncFilter) Add(h uint64) {
+	f.add(mix(h, f.probeSeed))
+	if f.dirty != nil {
+		f.markDirty(h)
+	}
+	if f.rec != nil {
+		f.rec.RecordAdd(h)
+	}
+	if f.sat != nil {
+		f.checkSaturation(1)
+	}
+}
+
+func (f *SyncFilter) add(h uint64) {
 	h1, h2 := uint32(h>>32), uint32(h)
 	b := getblock(f.b, h2)
+	h3 := thirdhash(f.probing, h1)
 
 	for i := 1; i < f.k; i++ {
-		h1, h2 = doublehash(h1, h2, i)
+		h1, h2 = probehash(h1, h2, h3, i)
 		setbitAtomic(b, h1)
 	}
 }
@@ -118,6 +142,52 @@ func (f *SyncFilter) Cardinality() float64 {
 	return cardinality(f.k, f.b, onescountAtomic)
 }
 
+// Clear resets f to its empty state, reusing its memory.
+//
+// If other goroutines are concurrently adding keys,
+// some of their additions may survive the Clear.
+func (f *SyncFilter) Clear() {
+	for i := 0; i < len(f.b); i++ {
+		for j := 0; j < blockWords; j++ {
+			atomic.StoreUint32(&f.b[i][j], 0)
+		}
+	}
+	if f.sat != nil {
+		f.sat.reset()
+	}
+}
+
+// Clone returns a deep copy of f. The copy has no Recorder or saturation
+// function set.
+//
+// If other goroutines are concurrently adding keys,
+// their additions may or may not be reflected in the copy.
+func (f *SyncFilter) Clone() *SyncFilter {
+	b := make([]block, len(f.b))
+	for i := range b {
+		for j := 0; j < blockWords; j++ {
+			b[i][j] = atomic.LoadUint32(&f.b[i][j])
+		}
+	}
+	return &SyncFilter{b: b, k: f.k, seed: f.seed, probeSeed: f.probeSeed, probing: f.probing}
+}
+
+// Snapshot returns a copy of f as a Filter, e.g., to serialize a filter
+// that other goroutines keep adding keys to, without pausing them.
+// The copy has no Recorder or saturation function set.
+//
+// Since Add only ever sets bits, the copy is consistent in the sense that
+// matters for a Bloom filter: it has all keys whose Add returned before
+// Snapshot was called. Keys being added concurrently may be absent, or
+// present as false positives. Snapshot must not be called concurrently
+// with Clear.
+//
+// DumpSync and MarshalBinary make the same guarantee without copying f.
+func (f *SyncFilter) Snapshot() *Filter {
+	g := f.Clone()
+	return &Filter{b: g.b, k: g.k, seed: g.seed, probeSeed: g.probeSeed, probing: g.probing}
+}
+
 // Empty reports whether f contains no keys.
 //
 // If other goroutines are concurrently adding keys,
@@ -143,14 +213,46 @@ func (f *SyncFilter) Fill() {
 	}
 }
 
+// FillRatio returns the fraction of bits in f that are set.
+//
+// If other goroutines are concurrently adding keys,
+// the result may lag behind the actual state of f.
+func (f *SyncFilter) FillRatio() float64 {
+	return fillRatio(f.b, onescountAtomic)
+}
+
+// FPRate computes an estimate of f's false positive rate after nkeys distinct
+// keys have been added.
+func (f *SyncFilter) FPRate(nkeys uint64) float64 {
+	return FPRate(nkeys, f.NumBits(), f.k)
+}
+
+// CurrentFPRate estimates f's false positive rate from its current contents.
+// See Filter.CurrentFPRate for details.
+//
+// If other goroutines are concurrently adding keys,
+// the result may lag behind the actual state of f.
+func (f *SyncFilter) CurrentFPRate() float64 {
+	return currentFPRate(f.b, f.k, onescountAtomic)
+}
+
 // Has reports whether a key with hash value h has been added.
 // It may return a false positive.
 func (f *SyncFilter) Has(h uint64) bool {
+	found := f.has(mix(h, f.probeSeed))
+	if f.rec != nil {
+		f.rec.RecordHas(h, found)
+	}
+	return found
+}
+
+func (f *SyncFilter) has(h uint64) bool {
 	h1, h2 := uint32(h>>32), uint32(h)
 	b := getblock(f.b, h2)
+	h3 := thirdhash(f.probing, h1)
 
 	for i := 1; i < f.k; i++ {
-		h1, h2 = doublehash(h1, h2, i)
+		h1, h2 = probehash(h1, h2, h3, i)
 		if !getbitAtomic(b, h1) {
 			return false
 		}
@@ -158,6 +260,14 @@ func (f *SyncFilter) Has(h uint64) bool {
 	return true
 }
 
+// NumBits returns the number of bits of f.
+func (f *SyncFilter) NumBits() uint64 {
+	return BlockBits * uint64(len(f.b))
+}
+
+// NumHashes returns the number of hash functions of f.
+func (f *SyncFilter) NumHashes() int { return f.k }
+
 // getbitAtomic reports whether bit (i modulo BlockBits) is set.
 func getbitAtomic(b *block, i uint32) bool {
 	bit := uint32(1) << (i % wordSize)
@@ -211,7 +321,7 @@ func (f *SyncFilter) Write(stream io.Writer) error {
 // If other goroutines are concurrently adding keys,
 // Equals may return an incorrect response
 func (f *SyncFilter) Equals(f1 *SyncFilter) bool {
-	if f1.k != f.k || len(f1.b) != len(f.b) {
+	if f1.k != f.k || len(f1.b) != len(f.b) || f1.probeSeed != f.probeSeed || f1.probing != f.probing {
 		return false
 	}
 	for i := range f1.b {
@@ -59,6 +59,8 @@ func TestSync(t *testing.T) {
 			assert.Equal(t, ref.Has(h), f.Has(h))
 		}
 		assert.Equal(t, card, f.Cardinality())
+		assert.Equal(t, ref.FillRatio(), f.FillRatio())
+		assert.Equal(t, ref.NumBits(), f.NumBits())
 
 		// Write the filter
 		var b bytes.Buffer
@@ -125,3 +127,61 @@ func TestSync(t *testing.T) {
 		check(f)
 	})
 }
+
+func TestSyncClear(t *testing.T) {
+	keys := randomU64(1000, 0xc1ea7)
+
+	f := NewSync(1<<14, 5)
+	b := f.b
+	for _, h := range keys {
+		f.Add(h)
+	}
+	require.False(t, f.Empty())
+
+	f.Clear()
+	assert.True(t, f.Empty())
+	assert.Zero(t, f.FillRatio())
+	assert.Equal(t, &b[0], &f.b[0]) // Memory is reused.
+	for _, h := range keys {
+		assert.False(t, f.Has(h))
+	}
+}
+
+func TestSyncClone(t *testing.T) {
+	f := NewSync(1<<14, 5)
+	for _, h := range randomU64(1000, 0x5c10) {
+		f.Add(h)
+	}
+
+	g := f.Clone()
+	assert.True(t, f.Equals(g))
+
+	for _, h := range randomU64(1000, 0x5c11) {
+		g.Add(h)
+	}
+	assert.False(t, f.Equals(g))
+}
+
+func TestSnapshot(t *testing.T) {
+	const nkeys = 20000
+
+	keys := randomU64(2*nkeys, 0x5a95)
+	f := NewSync(1<<18, 6)
+	f.AddMany(keys[:nkeys])
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.AddMany(keys[nkeys:])
+	}()
+	s := f.Snapshot()
+	for _, h := range keys[:nkeys] {
+		assert.True(t, s.Has(h))
+	}
+	<-done
+
+	assert.Equal(t, f.NumBits(), s.NumBits())
+	assert.Equal(t, f.NumHashes(), s.NumHashes())
+	s.AddMany(keys[nkeys:])
+	assert.Equal(t, f.b, s.b)
+}
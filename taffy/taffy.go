@@ -0,0 +1,293 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package taffy implements an expandable cuckoo filter, the taffy cuckoo
+// filter of Apple (2022), https://arxiv.org/abs/2109.02748.
+//
+// Like other cuckoo filters, a taffy filter supports deletion, but unlike
+// them, it does not need to know the number of keys in advance. When it
+// fills up, it doubles its table of buckets instead of failing or chaining
+// a second filter. Each stored entry carries a variable-length "tail" of
+// extra hash bits, one of which moves into the bucket index on every
+// growth. The false positive rate therefore increases slowly as the filter
+// grows, while keys added after growing get full-length tails again.
+//
+// A Filter is not safe for concurrent use.
+package taffy
+
+import "math/bits"
+
+const (
+	slotsPerBucket = 4
+	maxKicks       = 500
+
+	// A slot holds, from the least significant bit: the side (one bit),
+	// the fingerprint (fpBits) and the tail, preceded by a one bit
+	// marking its length. Zero means empty.
+	fpBits  = 16
+	maxTail = 32 - 1 - fpBits - 1
+)
+
+// A Filter is a taffy cuckoo filter.
+//
+// Each key has two candidate buckets, one for each of two hash functions
+// ("sides"). Each side maps the low logb+fpBits bits of a key's hash
+// to a bucket index and a fingerprint, invertibly, so the hash bits
+// can be recovered from an entry's position and fingerprint.
+// This allows moving entries to their other side and rehashing them
+// into a larger table.
+//
+// Each slot takes four bytes. The false positive rate is about
+// 2*slotsPerBucket/2^(fpBits+t) = 2^(-13-t) for keys with t tail bits;
+// keys are added with 14 tail bits. After 14 growths, keys that have
+// run out of tail bits are stored in both buckets that they might
+// belong in. From then on, the false positive rate is about 1e-4.
+type Filter struct {
+	slots []uint32
+	logb  uint // Log2 of number of buckets.
+	count int
+	rng   uint64
+}
+
+// An entry is the known part of a hash value: its lowest k bits.
+type entry struct {
+	x uint64
+	k uint
+}
+
+// New constructs a Filter for about capacity keys before it first grows.
+func New(capacity int) *Filter {
+	nbuckets := (capacity + slotsPerBucket - 1) / slotsPerBucket
+	if nbuckets < 1 {
+		nbuckets = 1
+	}
+	logb := uint(bits.Len(uint(nbuckets - 1)))
+
+	return &Filter{
+		slots: make([]uint32, slotsPerBucket<<logb),
+		logb:  logb,
+		rng:   0x2545f4914f6cdd1d,
+	}
+}
+
+// Add inserts a key with hash value h into f, growing f if necessary.
+// Adding a key more than once stores it more than once.
+func (f *Filter) Add(h uint64) {
+	k := f.width() + maxTail
+	if k > 64 {
+		k = 64
+	}
+	f.count++
+	f.add(entry{x: h & mask(k), k: k})
+}
+
+// Delete removes one copy of a key with hash value h from f.
+// It reports whether a matching entry was found.
+//
+// Only keys that have been added may be deleted. Deleting other keys
+// may remove the entry of a key that shares its fingerprint, causing
+// false negatives.
+func (f *Filter) Delete(h uint64) bool {
+	i := f.find(h)
+	if i < 0 {
+		return false
+	}
+	f.slots[i] = 0
+	f.count--
+	return true
+}
+
+// Has reports whether a key with hash value h has been added.
+// It may return a false positive.
+func (f *Filter) Has(h uint64) bool {
+	return f.find(h) >= 0
+}
+
+// Len returns the number of keys in f.
+func (f *Filter) Len() int { return f.count }
+
+// NumBuckets returns the number of buckets of f. Each bucket has four slots.
+func (f *Filter) NumBuckets() int { return 1 << f.logb }
+
+// find returns the index of a slot that matches h, or -1.
+func (f *Filter) find(h uint64) int {
+	w := f.width()
+	const low = 1<<(1+fpBits) - 1
+
+	for side := uint32(0); side < 2; side++ {
+		b, want := f.place(entry{x: h & mask(w), k: w}, side)
+		for i, s := range f.bucket(b) {
+			if s == 0 || s&low != want&low {
+				continue
+			}
+			t, tail := decodeTail(s)
+			if tail == (h>>w)&mask(t) {
+				return int(b)*slotsPerBucket + i
+			}
+		}
+	}
+	return -1
+}
+
+// width returns the number of hash bits that determine an entry's bucket
+// and fingerprint.
+func (f *Filter) width() uint { return f.logb + fpBits }
+
+// place returns the bucket and slot value for e on the given side.
+func (f *Filter) place(e entry, side uint32) (b uint64, s uint32) {
+	w := f.width()
+	q := e.x & mask(w)
+
+	var fp uint64
+	if side == 0 {
+		b, fp = q&mask(f.logb), q>>f.logb
+	} else {
+		// Take the bucket from the high bits of the product,
+		// which depend on all bits of q.
+		q = (q*permMul + permAdd) & mask(w)
+		b, fp = q>>fpBits, q&mask(fpBits)
+	}
+
+	t := e.k - w
+	tail := (e.x >> w) & mask(t)
+	return b, uint32(1<<t|tail)<<(1+fpBits) | uint32(fp)<<1 | side
+}
+
+// entry is the inverse of place.
+func (f *Filter) entry(b uint64, s uint32) (e entry, side uint32) {
+	w := f.width()
+	side = s & 1
+	fp := uint64(s>>1) & mask(fpBits)
+
+	var q uint64
+	if side == 0 {
+		q = fp<<f.logb | b
+	} else {
+		q = ((b<<fpBits | fp) - permAdd) * permMulInv & mask(w)
+	}
+
+	t, tail := decodeTail(s)
+	return entry{x: q | tail<<w, k: w + t}, side
+}
+
+func decodeTail(s uint32) (t uint, tail uint64) {
+	s >>= 1 + fpBits
+	t = uint(bits.Len32(s) - 1)
+	return t, uint64(s &^ (1 << t))
+}
+
+func (f *Filter) add(es ...entry) {
+	var homeless []entry
+	for _, e := range es {
+		if e, ok := f.insert(e); !ok {
+			homeless = append(homeless, e)
+		}
+	}
+	if len(homeless) > 0 {
+		f.grow(homeless)
+	}
+}
+
+// insert inserts e into one of its buckets, kicking out other entries
+// as needed. If it fails, it returns the entry that is left homeless.
+func (f *Filter) insert(e entry) (entry, bool) {
+	b, s := f.place(e, 0)
+	if f.put(b, s) {
+		return entry{}, true
+	}
+	b, s = f.place(e, 1)
+
+	for n := 0; n < maxKicks; n++ {
+		if f.put(b, s) {
+			return entry{}, true
+		}
+		i := b*slotsPerBucket + f.random()%slotsPerBucket
+		s, f.slots[i] = f.slots[i], s
+
+		e, side := f.entry(b, s)
+		b, s = f.place(e, side^1)
+	}
+
+	e, _ = f.entry(b, s)
+	return e, false
+}
+
+func (f *Filter) put(b uint64, s uint32) bool {
+	bucket := f.bucket(b)
+	for i := range bucket {
+		if bucket[i] == 0 {
+			bucket[i] = s
+			return true
+		}
+	}
+	return false
+}
+
+// grow doubles the number of buckets and reinserts all entries,
+// plus the homeless entries in pending.
+//
+// Entries whose tails are exhausted are missing the hash bit that
+// now determines their bucket. They are stored twice, once for each
+// value of that bit.
+func (f *Filter) grow(pending []entry) {
+	es := pending
+	for b := uint64(0); b < 1<<f.logb; b++ {
+		for _, s := range f.bucket(b) {
+			if s != 0 {
+				e, _ := f.entry(b, s)
+				es = append(es, e)
+			}
+		}
+	}
+
+	f.logb++
+	f.slots = make([]uint32, slotsPerBucket<<f.logb)
+
+	w := f.width()
+	for i, n := 0, len(es); i < n; i++ {
+		if e := es[i]; e.k < w {
+			es[i] = entry{x: e.x, k: w}
+			es = append(es, entry{x: e.x | 1<<(w-1), k: w})
+		}
+	}
+	f.add(es...)
+}
+
+func (f *Filter) bucket(b uint64) []uint32 {
+	return f.slots[b*slotsPerBucket : (b+1)*slotsPerBucket]
+}
+
+// random returns a pseudo-random number (xorshift64*).
+func (f *Filter) random() uint64 {
+	f.rng ^= f.rng >> 12
+	f.rng ^= f.rng << 25
+	f.rng ^= f.rng >> 27
+	return f.rng * 0x2545f4914f6cdd1d
+}
+
+func mask(n uint) uint64 { return 1<<n - 1 }
+
+const (
+	permMul = 0x9e3779b97f4a7c15
+	permAdd = 0xd6e8feb86659fd93
+)
+
+// Multiplicative inverse of permMul modulo 2^64, by Newton's method.
+var permMulInv = func() uint64 {
+	inv := uint64(permMul)
+	for i := 0; i < 5; i++ {
+		inv *= 2 - permMul*inv
+	}
+	return inv
+}()
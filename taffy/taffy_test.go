@@ -0,0 +1,111 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taffy
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hashes(n int, seed int64) []uint64 {
+	r := rand.New(rand.NewSource(seed))
+	hs := make([]uint64, n)
+	for i := range hs {
+		hs[i] = r.Uint64()
+	}
+	return hs
+}
+
+func TestGrow(t *testing.T) {
+	const n = 100000
+
+	f := New(100)
+	assert.Equal(t, 32, f.NumBuckets())
+
+	keys := hashes(n, 0x7aff1)
+	for _, h := range keys {
+		f.Add(h)
+	}
+	assert.Equal(t, n, f.Len())
+	assert.Greater(t, f.NumBuckets(), n/slotsPerBucket)
+
+	for _, h := range keys {
+		assert.True(t, f.Has(h))
+	}
+
+	var fp int
+	for _, h := range hashes(n, 0xfa15e) {
+		if f.Has(h) {
+			fp++
+		}
+	}
+	assert.Less(t, fp, 10)
+
+	for _, h := range keys[:n/2] {
+		assert.True(t, f.Delete(h))
+	}
+	assert.Equal(t, n/2, f.Len())
+	for _, h := range keys[n/2:] {
+		assert.True(t, f.Has(h))
+	}
+	var deleted int
+	for _, h := range keys[:n/2] {
+		if !f.Has(h) {
+			deleted++
+		}
+	}
+	assert.Greater(t, deleted, n/2-10)
+}
+
+func TestExhaustedTails(t *testing.T) {
+	const n = 200000
+
+	// Growing a filter many times exhausts the tails of the first keys.
+	f := New(1)
+	keys := hashes(n, 1)
+	for _, h := range keys {
+		f.Add(h)
+	}
+	assert.Greater(t, f.NumBuckets(), 1<<maxTail)
+
+	for _, h := range keys {
+		assert.True(t, f.Has(h))
+	}
+
+	var fp int
+	for _, h := range hashes(n, 2) {
+		if f.Has(h) {
+			fp++
+		}
+	}
+	assert.Less(t, fp, n/1000)
+}
+
+func TestPlace(t *testing.T) {
+	f := New(1000)
+	for _, h := range hashes(1000, 3) {
+		for side := uint32(0); side < 2; side++ {
+			e := entry{x: h & mask(f.width()+5), k: f.width() + 5}
+			b, s := f.place(e, side)
+			got, gotSide := f.entry(b, s)
+			assert.Equal(t, e, got)
+			assert.Equal(t, side, gotSide)
+		}
+	}
+
+	assert.Equal(t, uint64(1), permMul*permMulInv)
+}
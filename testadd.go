@@ -0,0 +1,107 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import "sync/atomic"
+
+// AddIfNotHas inserts a key with hash value h into f and reports whether
+// it was new: whether Has(h) would have returned false before the call.
+// It is equivalent to, but faster than, calling Has followed by Add.
+//
+// Since Has may return false positives, AddIfNotHas may report a new key
+// as already present.
+func (f *Filter) AddIfNotHas(h uint64) (added bool) {
+	m := mix(h, f.probeSeed)
+	h1, h2 := uint32(m>>32), uint32(m)
+	b := getblock(f.b, h2)
+	h3 := thirdhash(f.probing, h1)
+
+	for i := 1; i < f.k; i++ {
+		h1, h2 = probehash(h1, h2, h3, i)
+		if b.testAndSetbit(h1) {
+			added = true
+		}
+	}
+
+	if f.rec != nil {
+		f.rec.RecordHas(h, !added)
+		f.rec.RecordAdd(h)
+	}
+	if f.sat != nil {
+		f.checkSaturation(1)
+	}
+	return added
+}
+
+// AddIfNotHas inserts a key with hash value h into f and reports whether
+// it was new: whether Has(h) would have returned false before the call.
+// Unlike calling Has followed by Add, AddIfNotHas is atomic: when multiple
+// goroutines concurrently add the same new key, at least one of them
+// gets true, though more than one may.
+//
+// Since Has may return false positives, AddIfNotHas may report a new key
+// as already present.
+func (f *SyncFilter) AddIfNotHas(h uint64) (added bool) {
+	m := mix(h, f.probeSeed)
+	h1, h2 := uint32(m>>32), uint32(m)
+	b := getblock(f.b, h2)
+	h3 := thirdhash(f.probing, h1)
+
+	for i := 1; i < f.k; i++ {
+		h1, h2 = probehash(h1, h2, h3, i)
+		if testAndSetbitAtomic(b, h1) {
+			added = true
+		}
+	}
+
+	if f.dirty != nil && added {
+		f.markDirty(h)
+	}
+	if f.rec != nil {
+		f.rec.RecordHas(h, !added)
+		f.rec.RecordAdd(h)
+	}
+	if f.sat != nil {
+		f.checkSaturation(1)
+	}
+	return added
+}
+
+// testAndSetbit sets bit (i modulo BlockBits) of b and reports whether
+// it was previously unset.
+func (b *block) testAndSetbit(i uint32) bool {
+	bit := uint32(1) << (i % wordSize)
+	p := &(*b)[(i/wordSize)%blockWords]
+	old := *p
+	*p |= bit
+	return old&bit == 0
+}
+
+// testAndSetbitAtomic is like testAndSetbit, but sets the bit atomically.
+// Of multiple goroutines setting the same bit, only one gets true.
+func testAndSetbitAtomic(b *block, i uint32) bool {
+	bit := uint32(1) << (i % wordSize)
+	p := &(*b)[(i/wordSize)%blockWords]
+
+	for {
+		old := atomic.LoadUint32(p)
+		if old&bit != 0 {
+			return false
+		}
+		if atomic.CompareAndSwapUint32(p, old, old|bit) {
+			return true
+		}
+	}
+}
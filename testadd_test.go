@@ -0,0 +1,88 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddIfNotHas(t *testing.T) {
+	const nbits, nhashes = 1 << 16, 7
+
+	f, ref := New(nbits, nhashes), New(nbits, nhashes)
+	s := NewSync(nbits, nhashes)
+	var c Counters
+	f.SetRecorder(&c)
+
+	nnew := 0
+	for _, h := range randomU64(1000, 0x7e57) {
+		want := !ref.Has(h)
+		if want {
+			nnew++
+		}
+		ref.Add(h)
+		assert.Equal(t, want, f.AddIfNotHas(h))
+		assert.Equal(t, want, s.AddIfNotHas(h))
+
+		assert.False(t, f.AddIfNotHas(h))
+		assert.False(t, s.AddIfNotHas(h))
+	}
+	assert.True(t, ref.Equals(f))
+	for i := range f.b {
+		assert.Equal(t, f.b[i], s.b[i])
+	}
+
+	st := c.Stats()
+	assert.EqualValues(t, 2000, st.Adds)
+	assert.EqualValues(t, 2000, st.Lookups)
+	assert.EqualValues(t, nnew, st.Lookups-st.Hits)
+}
+
+func TestAddIfNotHasConcurrent(t *testing.T) {
+	const ngoroutines = 8
+
+	keys := randomU64(2000, 0xc0c)
+	f := NewSync(1<<18, 6)
+	added := make([]int32, len(keys))
+
+	var wg sync.WaitGroup
+	for g := 0; g < ngoroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, h := range keys {
+				if f.AddIfNotHas(h) {
+					atomic.AddInt32(&added[i], 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every key that no single goroutine found present was reported
+	// as new at least once.
+	g := New(1<<18, 6)
+	for i, h := range keys {
+		if !g.Has(h) {
+			assert.NotZero(t, added[i])
+		}
+		g.Add(h)
+		assert.True(t, f.Has(h))
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package timebucket maintains Bloom filters for consecutive time buckets,
+// for questions like "was this key seen last week?".
+//
+// A Set keeps one filter per bucket, such as an hour or a day. Keys are
+// added with a timestamp, and range queries consult only the buckets that
+// overlap the range. Old buckets can be expired and each bucket is
+// persisted separately, so a long history can be kept on disk cheaply.
+package timebucket
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/internal/atomicfile"
+)
+
+// A Config holds parameters for New.
+type Config struct {
+	// Trigger the "contains filtered or unexported fields" message for
+	// forward compatibility and force the caller to use named fields.
+	_ struct{}
+
+	// Bucket is the width of a bucket. It must be a positive multiple
+	// of a second. Buckets are aligned to the Unix epoch, so a bucket
+	// of 24 hours starts at midnight UTC.
+	Bucket time.Duration
+
+	// Retention is how long buckets are kept by Expire.
+	// Zero means buckets never expire.
+	Retention time.Duration
+
+	// Capacity is the number of distinct keys per bucket.
+	Capacity uint64
+
+	// FPRate is the false positive rate of each bucket's filter
+	// when it is filled to capacity.
+	FPRate float64
+
+	// Seed and Probing are set on the filters of new buckets. See
+	// blobloom.Filter.SetSeed and SetProbing. Buckets loaded by Load keep
+	// the seed and probing scheme that they were saved with.
+	Seed    uint64
+	Probing blobloom.Probing
+
+	// If not nil, Logger receives events for the construction, loading,
+	// saving and expiry of buckets, and a warning when a bucket is saved
+	// that holds more keys than Capacity.
+	Logger blobloom.Logger
+}
+
+// A Set holds a filter per time bucket.
+//
+// A Set is safe for concurrent use by multiple goroutines.
+type Set struct {
+	config  Config
+	nbits   uint64
+	nhashes int
+
+	mu      sync.RWMutex
+	buckets map[int64]*bucket // By index.
+	removed map[int64]bool    // Buckets expired since the last Save.
+}
+
+type bucket struct {
+	dirty     uint32 // Accessed atomically.
+	saturated uint32 // Accessed atomically.
+	f         *blobloom.SyncFilter
+}
+
+// New constructs an empty Set with the given configuration.
+// It panics when config.Bucket or config.FPRate is invalid.
+func New(config Config) *Set {
+	if config.Bucket < time.Second || config.Bucket%time.Second != 0 {
+		panic("timebucket: bucket width must be a positive number of seconds")
+	}
+	nbits, nhashes := blobloom.Optimize(blobloom.Config{
+		Capacity: config.Capacity,
+		FPRate:   config.FPRate,
+	})
+	s := &Set{
+		config:  config,
+		nbits:   nbits,
+		nhashes: nhashes,
+		buckets: make(map[int64]*bucket),
+		removed: make(map[int64]bool),
+	}
+	if l := config.Logger; l != nil {
+		l.Info("timebucket: set created", "bucket", config.Bucket,
+			"retention", config.Retention, "bits_per_bucket", nbits)
+	}
+	return s
+}
+
+// index returns the index of the bucket containing t.
+func (s *Set) index(t time.Time) int64 {
+	w := int64(s.config.Bucket / time.Second)
+	sec := t.Unix()
+	i := sec / w
+	if sec%w < 0 {
+		i--
+	}
+	return i
+}
+
+// start returns the start time of the bucket with index i.
+func (s *Set) start(i int64) time.Time {
+	return time.Unix(i*int64(s.config.Bucket/time.Second), 0).UTC()
+}
+
+// Add adds a key with hash value h, seen at time t.
+func (s *Set) Add(h uint64, t time.Time) {
+	i := s.index(t)
+
+	s.mu.RLock()
+	b := s.buckets[i]
+	s.mu.RUnlock()
+
+	if b == nil {
+		s.mu.Lock()
+		if b = s.buckets[i]; b == nil {
+			f := blobloom.NewSync(s.nbits, s.nhashes)
+			f.SetSeed(s.config.Seed)
+			f.SetProbing(s.config.Probing)
+			b = &bucket{f: f}
+			s.buckets[i] = b
+			delete(s.removed, i)
+		}
+		s.mu.Unlock()
+	}
+
+	b.f.Add(h)
+	atomic.StoreUint32(&b.dirty, 1)
+}
+
+// HasBetween reports whether a key with hash value h was seen in any bucket
+// that overlaps the time range [from, to]. It may return a false positive.
+//
+// Since buckets are the unit of time, keys seen shortly before from or
+// shortly after to may also be reported.
+func (s *Set) HasBetween(h uint64, from, to time.Time) bool {
+	lo, hi := s.index(from), s.index(to)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i, b := range s.buckets {
+		if lo <= i && i <= hi && b.f.Has(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// SeenBetween returns the start times of the buckets overlapping [from, to]
+// that report a key with hash value h, in chronological order.
+// Each may be a false positive.
+func (s *Set) SeenBetween(h uint64, from, to time.Time) []time.Time {
+	lo, hi := s.index(from), s.index(to)
+
+	s.mu.RLock()
+	var idx []int64
+	for i, b := range s.buckets {
+		if lo <= i && i <= hi && b.f.Has(h) {
+			idx = append(idx, i)
+		}
+	}
+	s.mu.RUnlock()
+
+	return s.times(idx)
+}
+
+// Buckets returns the start times of the buckets in s, in chronological order.
+func (s *Set) Buckets() []time.Time {
+	s.mu.RLock()
+	idx := make([]int64, 0, len(s.buckets))
+	for i := range s.buckets {
+		idx = append(idx, i)
+	}
+	s.mu.RUnlock()
+
+	return s.times(idx)
+}
+
+func (s *Set) times(idx []int64) []time.Time {
+	sort.Slice(idx, func(i, j int) bool { return idx[i] < idx[j] })
+	ts := make([]time.Time, len(idx))
+	for j, i := range idx {
+		ts[j] = s.start(i)
+	}
+	return ts
+}
+
+// Expire removes the buckets that ended more than the configured Retention
+// before now and returns their start times. It does nothing if Retention
+// is zero.
+//
+// Expired buckets are removed from storage by the next call to Save.
+func (s *Set) Expire(now time.Time) []time.Time {
+	if s.config.Retention <= 0 {
+		return nil
+	}
+	// A bucket expires when its end, the start of bucket i+1,
+	// is before cutoff.
+	cutoff := s.index(now.Add(-s.config.Retention))
+
+	s.mu.Lock()
+	var idx []int64
+	for i := range s.buckets {
+		if i+1 <= cutoff {
+			idx = append(idx, i)
+			delete(s.buckets, i)
+			s.removed[i] = true
+		}
+	}
+	s.mu.Unlock()
+
+	if l := s.config.Logger; l != nil && len(idx) > 0 {
+		l.Info("timebucket: buckets expired", "count", len(idx))
+	}
+	return s.times(idx)
+}
+
+// Name format of buckets in Storage.
+const nameLayout = "20060102T150405Z"
+
+// Save writes the buckets that were modified since the last Save or Load
+// to st and removes expired buckets from st.
+//
+// Keys added concurrently with Save may or may not be included,
+// but are saved by the next call to Save.
+func (s *Set) Save(st Storage) error {
+	s.mu.RLock()
+	idx := make([]int64, 0, len(s.buckets))
+	for i := range s.buckets {
+		idx = append(idx, i)
+	}
+	var removed []int64
+	for i := range s.removed {
+		removed = append(removed, i)
+	}
+	s.mu.RUnlock()
+
+	comment := s.comment()
+	var stored int
+	for _, i := range idx {
+		s.mu.RLock()
+		b := s.buckets[i]
+		s.mu.RUnlock()
+		if b == nil || !atomic.CompareAndSwapUint32(&b.dirty, 1, 0) {
+			continue
+		}
+		name := s.start(i).Format(nameLayout)
+		if err := store(st, name, b.f, comment); err != nil {
+			atomic.StoreUint32(&b.dirty, 1)
+			return err
+		}
+		stored++
+		s.checkSaturation(name, b)
+	}
+
+	for _, i := range removed {
+		err := st.Remove(s.start(i).Format(nameLayout))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		s.mu.Lock()
+		delete(s.removed, i)
+		s.mu.Unlock()
+	}
+
+	if l := s.config.Logger; l != nil {
+		l.Info("timebucket: saved", "stored", stored, "removed", len(removed))
+	}
+	return nil
+}
+
+// checkSaturation logs a warning when b's estimated number of keys
+// first exceeds the configured capacity.
+func (s *Set) checkSaturation(name string, b *bucket) {
+	l := s.config.Logger
+	if l == nil || s.config.Capacity == 0 || atomic.LoadUint32(&b.saturated) != 0 {
+		return
+	}
+	nkeys := b.f.Cardinality()
+	if nkeys > float64(s.config.Capacity) && atomic.CompareAndSwapUint32(&b.saturated, 0, 1) {
+		l.Warn("timebucket: bucket exceeds capacity", "bucket", name,
+			"estimated_keys", nkeys, "capacity", s.config.Capacity)
+	}
+}
+
+func store(st Storage, name string, f *blobloom.SyncFilter, comment string) error {
+	w, err := st.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = blobloom.DumpSync(w, f, comment)
+	return atomicfile.Finish(w, err)
+}
+
+// comment records the bucket width in dumps.
+func (s *Set) comment() string {
+	return fmt.Sprintf("timebucket %d", s.config.Bucket/time.Second)
+}
+
+// Load constructs a Set with the given configuration from the buckets
+// stored in st. Buckets must have been saved with the same bucket width.
+func Load(st Storage, config Config) (*Set, error) {
+	s := New(config)
+
+	names, err := st.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		t, err := time.Parse(nameLayout, name)
+		if err != nil {
+			continue // Not a bucket.
+		}
+		i := s.index(t)
+		if !s.start(i).Equal(t) {
+			return nil, fmt.Errorf("timebucket: %s is not a bucket boundary", name)
+		}
+
+		f, err := load(st, name, s.comment())
+		if err != nil {
+			return nil, err
+		}
+		s.buckets[i] = &bucket{f: f}
+	}
+
+	if l := config.Logger; l != nil {
+		l.Info("timebucket: loaded", "buckets", len(s.buckets))
+	}
+	return s, nil
+}
+
+func load(st Storage, name, comment string) (*blobloom.SyncFilter, error) {
+	r, err := st.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	l, err := blobloom.NewLoader(r)
+	if err != nil {
+		return nil, err
+	}
+	if l.Comment != comment {
+		return nil, fmt.Errorf("timebucket: bucket %s has comment %q, want %q",
+			name, l.Comment, comment)
+	}
+	return l.LoadSync(nil)
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timebucket
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = Config{
+	Bucket:    time.Hour,
+	Retention: 24 * time.Hour,
+	Capacity:  1000,
+	FPRate:    1e-4,
+}
+
+var t0 = time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+func TestHasBetween(t *testing.T) {
+	s := New(testConfig)
+	s.Add(1, t0.Add(30*time.Minute))
+	s.Add(2, t0.Add(5*time.Hour))
+
+	assert.True(t, s.HasBetween(1, t0, t0))
+	assert.True(t, s.HasBetween(1, t0.Add(59*time.Minute), t0.Add(2*time.Hour)))
+	assert.False(t, s.HasBetween(1, t0.Add(time.Hour), t0.Add(10*time.Hour)))
+	assert.False(t, s.HasBetween(2, t0, t0.Add(4*time.Hour)))
+	assert.True(t, s.HasBetween(2, t0, t0.Add(5*time.Hour)))
+	assert.False(t, s.HasBetween(3, t0, t0.Add(24*time.Hour)))
+
+	s.Add(1, t0.Add(5*time.Hour+time.Second))
+	assert.Equal(t, []time.Time{t0, t0.Add(5 * time.Hour)},
+		s.SeenBetween(1, t0.Add(-time.Hour), t0.Add(time.Hour*6)))
+	assert.Equal(t, []time.Time{t0, t0.Add(5 * time.Hour)}, s.Buckets())
+}
+
+func TestIndexNegative(t *testing.T) {
+	s := New(Config{Bucket: 24 * time.Hour, Capacity: 10, FPRate: .01})
+	before := time.Date(1969, 12, 31, 23, 0, 0, 0, time.UTC)
+	assert.EqualValues(t, -1, s.index(before))
+	assert.Equal(t, time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), s.start(-1))
+}
+
+func TestExpire(t *testing.T) {
+	s := New(testConfig)
+	for i := 0; i < 48; i++ {
+		s.Add(uint64(i), t0.Add(time.Duration(i)*time.Hour))
+	}
+
+	expired := s.Expire(t0.Add(48 * time.Hour))
+	assert.Len(t, expired, 24)
+	assert.Equal(t, t0, expired[0])
+	assert.Len(t, s.Buckets(), 24)
+	assert.False(t, s.HasBetween(0, t0, t0.Add(time.Hour)))
+	assert.True(t, s.HasBetween(47, t0, t0.Add(48*time.Hour)))
+
+	assert.Nil(t, New(Config{Bucket: time.Hour, Capacity: 1, FPRate: .1}).
+		Expire(t0))
+}
+
+func TestSaveLoad(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "timebucket")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	st := Dir(tmp)
+
+	s := New(testConfig)
+	for i := 0; i < 30; i++ {
+		s.Add(uint64(i), t0.Add(time.Duration(i)*time.Hour))
+	}
+	require.NoError(t, s.Save(st))
+	names, err := st.List()
+	require.NoError(t, err)
+	assert.Len(t, names, 30)
+	assert.Contains(t, names, "20230501T050000Z")
+
+	s.Expire(t0.Add(30 * time.Hour))
+	s.Add(100, t0.Add(29*time.Hour))
+	require.NoError(t, s.Save(st))
+	names, _ = st.List()
+	assert.Len(t, names, 24)
+
+	// Unrelated files are ignored.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "README"), nil, 0644))
+
+	l, err := Load(st, testConfig)
+	require.NoError(t, err)
+	assert.Equal(t, s.Buckets(), l.Buckets())
+	assert.True(t, l.HasBetween(100, t0, t0.Add(30*time.Hour)))
+	assert.True(t, l.HasBetween(29, t0.Add(29*time.Hour), t0.Add(29*time.Hour)))
+	assert.False(t, l.HasBetween(0, t0, t0.Add(30*time.Hour)))
+
+	// Loaded buckets are clean.
+	require.NoError(t, l.Save(failStorage{st}))
+
+	_, err = Load(st, Config{Bucket: 2 * time.Hour, Capacity: 1000, FPRate: 1e-4})
+	assert.Error(t, err)
+}
+
+func TestSaveLoadSeeded(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "timebucket")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	st := Dir(tmp)
+
+	config := testConfig
+	config.Seed = 0x7b
+	config.Probing = blobloom.TripleHashing
+
+	s := New(config)
+	for i := uint64(0); i < 1000; i++ {
+		s.Add(i*0x9e3779b97f4a7c15, t0)
+	}
+	require.NoError(t, s.Save(st))
+
+	l, err := Load(st, config)
+	require.NoError(t, err)
+	for i := uint64(0); i < 1000; i++ {
+		assert.True(t, l.HasBetween(i*0x9e3779b97f4a7c15, t0, t0))
+	}
+}
+
+func TestDirInvalidName(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "timebucket")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	st := Dir(tmp)
+
+	for _, name := range []string{"", ".hidden", "a/b", "../x"} {
+		_, err = st.Open(name)
+		assert.Equal(t, errInvalidName, err, name)
+		_, err = st.Create(name)
+		assert.Equal(t, errInvalidName, err, name)
+		assert.Equal(t, errInvalidName, st.Remove(name), name)
+	}
+}
+
+type failStorage struct{ Storage }
+
+func (failStorage) Create(string) (io.WriteCloser, error) {
+	return nil, errors.New("unexpected Create")
+}
@@ -0,0 +1,225 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tuner recommends filter configurations for observed workloads.
+//
+// A Tuner is a Recorder that is set on a live filter, or fed the keys of
+// a sample workload. It estimates the number of distinct keys with a
+// HyperLogLog sketch and counts adds and lookups, then recommends the
+// size, number of hashes and false positive rate of a filter for the
+// workload, within a memory limit.
+package tuner
+
+import (
+	"expvar"
+	"math"
+	"math/bits"
+	"sync/atomic"
+
+	"github.com/greatroar/blobloom"
+)
+
+// A Config holds parameters for a Tuner.
+type Config struct {
+	// Trigger the "contains filtered or unexported fields" message for
+	// forward compatibility and force the caller to use named fields.
+	_ struct{}
+
+	// Desired false positive rate. Zero means DefaultFPRate.
+	FPRate float64
+
+	// Maximum size of the filter in bytes. Zero means no limit.
+	MemoryLimit uint64
+
+	// Factor by which to multiply the observed number of distinct keys
+	// to get the capacity, to allow for growth. Zero means DefaultHeadroom.
+	Headroom float64
+}
+
+const (
+	// DefaultFPRate is the default Config.FPRate.
+	DefaultFPRate = .01
+	// DefaultHeadroom is the default Config.Headroom.
+	DefaultHeadroom = 2
+
+	// Lookups per add above which a workload counts as read-heavy.
+	readHeavy = 100
+)
+
+// A Recommendation is a filter configuration recommended by a Tuner.
+type Recommendation struct {
+	// Config for blobloom.NewOptimized or NewSyncOptimized.
+	Config blobloom.Config
+
+	NumBits   uint64  // As returned by blobloom.Optimize(Config).
+	NumHashes int     // As returned by blobloom.Optimize(Config).
+	FPRate    float64 // Expected false positive rate at capacity.
+
+	// The block size is fixed at blobloom.BlockBits. It is reported
+	// so that consumers of recommendations need not hardcode it.
+	BlockBits int
+
+	DistinctKeys float64 // Estimated number of distinct keys added.
+	ReadRatio    float64 // Lookups per add.
+}
+
+// HyperLogLog parameters.
+const (
+	precision  = 14
+	nregisters = 1 << precision
+)
+
+// A Tuner observes a workload and recommends a filter configuration for it.
+//
+// A Tuner is a blobloom.Recorder: set it on a filter with SetRecorder.
+// It embeds a blobloom.Counters, so the filter's Stats method and the
+// exporters that read it keep working.
+//
+// A Tuner is safe for concurrent use by multiple goroutines.
+type Tuner struct {
+	blobloom.Counters
+
+	config    Config
+	registers [nregisters]uint32 // HyperLogLog. Accessed atomically.
+}
+
+// New constructs a Tuner.
+func New(config Config) *Tuner {
+	if config.FPRate == 0 {
+		config.FPRate = DefaultFPRate
+	}
+	if config.Headroom == 0 {
+		config.Headroom = DefaultHeadroom
+	}
+	return &Tuner{config: config}
+}
+
+// RecordAdd records a call to Add.
+func (t *Tuner) RecordAdd(h uint64) {
+	t.observe(h)
+	t.Counters.RecordAdd(h)
+}
+
+// RecordBatch records a call to AddMany.
+func (t *Tuner) RecordBatch(hs []uint64) {
+	for _, h := range hs {
+		t.observe(h)
+	}
+	t.Counters.RecordBatch(hs)
+}
+
+func (t *Tuner) observe(h uint64) {
+	i := h >> (64 - precision)
+	rank := uint32(bits.LeadingZeros64(h<<precision|1<<(precision-1))) + 1
+
+	r := &t.registers[i]
+	for {
+		old := atomic.LoadUint32(r)
+		if rank <= old || atomic.CompareAndSwapUint32(r, old, rank) {
+			return
+		}
+	}
+}
+
+// DistinctKeys returns an estimate of the number of distinct keys added.
+// Its standard error is about 0.8%.
+func (t *Tuner) DistinctKeys() float64 {
+	const m = nregisters
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	var sum float64
+	var zeros int
+	for i := range t.registers {
+		r := atomic.LoadUint32(&t.registers[i])
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	est := alpha * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		// Linear counting for small cardinalities.
+		est = m * math.Log(m/float64(zeros))
+	}
+	return est
+}
+
+// Recommend returns a recommendation for the workload observed so far.
+//
+// The capacity is the estimated number of distinct keys times the headroom.
+// If a filter of that capacity does not fit in the memory limit at the
+// desired false positive rate, the recommendation is the largest filter that
+// fits, with a correspondingly higher FPRate. If the workload is read-heavy
+// and a memory limit is set, a single-probe filter is recommended when it
+// fits, since it answers lookups fastest.
+func (t *Tuner) Recommend() Recommendation {
+	stats := t.Stats()
+	r := Recommendation{
+		BlockBits:    blobloom.BlockBits,
+		DistinctKeys: t.DistinctKeys(),
+	}
+	if stats.Adds > 0 {
+		r.ReadRatio = float64(stats.Lookups) / float64(stats.Adds)
+	}
+
+	capacity := uint64(math.Ceil(r.DistinctKeys * t.config.Headroom))
+	if capacity == 0 {
+		capacity = 1
+	}
+	r.Config = blobloom.Config{
+		Capacity: capacity,
+		FPRate:   t.config.FPRate,
+	}
+	if limit := t.config.MemoryLimit; limit > 0 {
+		r.Config.MaxBits = 8 * limit
+		if r.Config.MaxBits/8 != limit || r.Config.MaxBits > blobloom.MaxBits {
+			r.Config.MaxBits = blobloom.MaxBits
+		}
+	}
+
+	if r.ReadRatio >= readHeavy && r.Config.MaxBits > 0 {
+		single := r.Config
+		single.SingleProbe = true
+		nbits, nhashes := blobloom.Optimize(single)
+		fpr := -math.Expm1(-float64(capacity) / float64(nbits))
+		if fpr <= single.FPRate*1.01 {
+			r.Config = single
+			r.NumBits, r.NumHashes, r.FPRate = nbits, nhashes, fpr
+			return r
+		}
+	}
+
+	r.NumBits, r.NumHashes = blobloom.Optimize(r.Config)
+	r.FPRate = blobloom.FPRate(capacity, r.NumBits, r.NumHashes)
+	return r
+}
+
+// Var returns an expvar.Var that reports t's current recommendation
+// as a JSON object. It can be published with expvar.Publish.
+func (t *Tuner) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		r := t.Recommend()
+		return map[string]interface{}{
+			"capacity":      r.Config.Capacity,
+			"fpr":           r.FPRate,
+			"bits":          r.NumBits,
+			"hashes":        r.NumHashes,
+			"block_bits":    r.BlockBits,
+			"single_probe":  r.Config.SingleProbe,
+			"distinct_keys": r.DistinctKeys,
+			"read_ratio":    r.ReadRatio,
+		}
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuner
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashes(n int, seed int64) []uint64 {
+	r := rand.New(rand.NewSource(seed))
+	hs := make([]uint64, n)
+	for i := range hs {
+		hs[i] = r.Uint64()
+	}
+	return hs
+}
+
+func TestDistinctKeys(t *testing.T) {
+	tu := New(Config{})
+	assert.Zero(t, tu.DistinctKeys())
+
+	for _, n := range []int{100, 10000, 1000000} {
+		tu := New(Config{})
+		keys := hashes(n, int64(n))
+		for i := 0; i < 3; i++ {
+			tu.RecordBatch(keys)
+		}
+		assert.InEpsilon(t, n, tu.DistinctKeys(), .03)
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	const n = 50000
+
+	tu := New(Config{FPRate: .001})
+	f := blobloom.NewSync(1<<20, 4)
+	f.SetRecorder(tu)
+	for _, h := range hashes(n, 1) {
+		f.Add(h)
+		f.Has(h)
+	}
+	assert.Equal(t, uint64(n), f.Stats().Adds)
+
+	r := tu.Recommend()
+	assert.InEpsilon(t, 2*n, r.Config.Capacity, .03)
+	assert.Equal(t, .001, r.Config.FPRate)
+	assert.False(t, r.Config.SingleProbe)
+	assert.InDelta(t, 1, r.ReadRatio, 1e-9)
+	assert.Equal(t, blobloom.BlockBits, r.BlockBits)
+	assert.LessOrEqual(t, r.FPRate, .0012)
+
+	nbits, nhashes := blobloom.Optimize(r.Config)
+	assert.Equal(t, nbits, r.NumBits)
+	assert.Equal(t, nhashes, r.NumHashes)
+
+	// With a memory limit, the FPR goes up.
+	tu.config.MemoryLimit = r.NumBits / 8 / 4
+	r = tu.Recommend()
+	assert.LessOrEqual(t, r.NumBits, 8*tu.config.MemoryLimit)
+	assert.Greater(t, r.FPRate, .01)
+}
+
+func TestRecommendReadHeavy(t *testing.T) {
+	const n = 1000
+
+	tu := New(Config{MemoryLimit: 1 << 20})
+	for _, h := range hashes(n, 2) {
+		tu.RecordAdd(h)
+		for i := 0; i < 200; i++ {
+			tu.RecordHas(h, true)
+		}
+	}
+
+	r := tu.Recommend()
+	assert.True(t, r.Config.SingleProbe)
+	assert.Equal(t, 2, r.NumHashes)
+	assert.LessOrEqual(t, r.FPRate, DefaultFPRate)
+
+	// Without room for a single-probe filter, recommend the usual one.
+	tu.config.MemoryLimit = 4096
+	r = tu.Recommend()
+	assert.False(t, r.Config.SingleProbe)
+
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(tu.Var().String()), &m))
+	assert.Equal(t, false, m["single_probe"])
+	assert.EqualValues(t, r.NumBits, m["bits"])
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"errors"
+	"io"
+)
+
+// Number of blocks that UnionDumps processes at a time.
+const unionRangeBlocks = 1024
+
+// UnionDumps reads filters in the format produced by Dump from rs and writes
+// their union to w, in the same format and with the given comment.
+// It returns the number of bytes written to w.
+//
+// UnionDumps streams through its inputs, one range of blocks at a time, so it
+// needs only two ranges of memory, regardless of the number of filters and
+// their size. This makes it suitable for compacting many large dumps on disk.
+// The readers should be buffered, since each is read in small pieces.
+//
+// All filters must have the same numbers of blocks and hashes, seed and
+// probing scheme.
+func UnionDumps(w io.Writer, comment string, rs ...io.Reader) (n int64, err error) {
+	if len(rs) == 0 {
+		return 0, errors.New("blobloom: no dumps to union")
+	}
+
+	loaders := make([]*Loader, len(rs))
+	for i, r := range rs {
+		l, err := NewLoader(r)
+		if err != nil {
+			return 0, err
+		}
+		if i > 0 {
+			l0 := loaders[0]
+			err = l.checkShape(int(l0.nblocks), l0.nhashes, l0.probeSeed, l0.probing)
+			if err != nil {
+				return 0, err
+			}
+		}
+		loaders[i] = l
+	}
+
+	var header [64]byte
+	l0 := loaders[0]
+	k, err := writeHeader(w, &header, l0.nblocks, l0.nhashes, l0.probeSeed, l0.probing, comment)
+	n = int64(k)
+	if err != nil {
+		return n, err
+	}
+
+	nblocks := loaders[0].nblocks
+	size := nblocks
+	if size > unionRangeBlocks {
+		size = unionRangeBlocks
+	}
+	acc := make([]byte, size*BlockBits/8)
+	buf := make([]byte, len(acc))
+
+	for start := uint64(0); start < nblocks; start += unionRangeBlocks {
+		m := len(acc)
+		if rest := nblocks - start; rest < unionRangeBlocks {
+			m = int(rest * BlockBits / 8)
+		}
+		acc, buf := acc[:m], buf[:m]
+
+		for i := range acc {
+			acc[i] = 0
+		}
+		for _, l := range loaders {
+			if _, err := io.ReadFull(l.r, buf); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return n, err
+			}
+			for i := range acc {
+				acc[i] |= buf[i]
+			}
+		}
+
+		k, err := w.Write(acc)
+		n += int64(k)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
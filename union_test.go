@@ -0,0 +1,77 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnionDumps(t *testing.T) {
+	r := rand.New(rand.NewSource(0x5eed))
+
+	for _, nbits := range []uint64{BlockBits, 2500 * BlockBits} {
+		want := New(nbits, 5)
+
+		var dumps [][]byte
+		var rs []io.Reader
+		for i := 0; i < 10; i++ {
+			f := New(nbits, 5)
+			for j := 0; j < 1000; j++ {
+				f.Add(r.Uint64())
+			}
+			want.Union(f)
+
+			var buf bytes.Buffer
+			_, err := Dump(&buf, f, "")
+			require.NoError(t, err)
+			dumps = append(dumps, buf.Bytes())
+			rs = append(rs, bytes.NewReader(buf.Bytes()))
+		}
+
+		var out bytes.Buffer
+		n, err := UnionDumps(&out, "union", rs...)
+		require.NoError(t, err)
+		assert.EqualValues(t, len(dumps[0]), n)
+
+		l, err := NewLoader(&out)
+		require.NoError(t, err)
+		assert.Equal(t, "union", l.Comment)
+		got, err := l.Load(nil)
+		require.NoError(t, err)
+		assert.True(t, want.Equals(got))
+
+		_, err = UnionDumps(ioutil.Discard, "",
+			bytes.NewReader(dumps[0]), bytes.NewReader(dumps[1][:len(dumps[1])-1]))
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	}
+
+	var a, b bytes.Buffer
+	_, err := Dump(&a, New(BlockBits, 5), "")
+	require.NoError(t, err)
+	_, err = Dump(&b, New(BlockBits, 6), "")
+	require.NoError(t, err)
+	_, err = UnionDumps(ioutil.Discard, "", &a, &b)
+	assert.Error(t, err)
+
+	_, err = UnionDumps(ioutil.Discard, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,239 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package urlseen provides the "seen URL" set of a web crawler's frontier.
+//
+// URLs are canonicalized before they are hashed, so that trivially
+// different spellings of the same URL are recognized as duplicates.
+// The set grows as URLs are added, so its capacity need not be known
+// in advance.
+package urlseen
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/greatroar/blobloom"
+	"github.com/greatroar/blobloom/internal/keyhash"
+)
+
+// Canonicalize returns the canonical form of the absolute URL rawURL.
+//
+// Canonicalization lowercases the scheme and host, removes default ports,
+// a trailing dot from the host, trailing slashes from the path, empty
+// query parameters and the fragment, and sorts the query parameters.
+// An empty path becomes "/". Percent-encoding is preserved.
+func Canonicalize(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	switch {
+	case err != nil:
+		return "", err
+	case !u.IsAbs() || u.Opaque != "":
+		return "", errors.New("urlseen: not an absolute URL: " + rawURL)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host, port := u.Hostname(), u.Port()
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if strings.IndexByte(host, ':') != -1 {
+		host = "[" + host + "]" // IPv6.
+	}
+	if port != "" && port != defaultPorts[u.Scheme] {
+		host += ":" + port
+	}
+	u.Host = host
+
+	path := u.EscapedPath()
+	path = strings.TrimRight(path, "/")
+	if path == "" {
+		path = "/"
+	}
+	if u.Path, err = url.PathUnescape(path); err != nil {
+		return "", err
+	}
+	u.RawPath = path
+
+	params := strings.Split(u.RawQuery, "&")
+	j := 0
+	for _, p := range params {
+		if p != "" {
+			params[j] = p
+			j++
+		}
+	}
+	params = params[:j]
+	sort.Strings(params)
+	u.RawQuery = strings.Join(params, "&")
+	u.ForceQuery = false
+
+	u.Fragment, u.RawFragment = "", ""
+
+	return u.String(), nil
+}
+
+var defaultPorts = map[string]string{
+	"ftp":   "21",
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// A Set is a set of canonicalized URLs.
+//
+// A Set is a scalable Bloom filter (Almeida et al., Scalable Bloom Filters,
+// https://doi.org/10.1016/j.ipl.2006.10.007): when its current filter is
+// full, it adds a filter of twice the capacity and half the false positive
+// rate. The overall false positive rate stays below the configured FPRate.
+//
+// A Set is safe for concurrent use by multiple goroutines.
+type Set struct {
+	mu      sync.Mutex
+	filters []*blobloom.Filter // Oldest first.
+	count   uint64             // Number of URLs in the last filter.
+
+	capacity uint64  // Capacity of last filter.
+	fprate   float64 // FPR of last filter.
+
+	log blobloom.Logger
+}
+
+// New constructs a Set. Its initial capacity and FPRate are taken from
+// config. The Set's overall false positive rate stays below config.FPRate.
+//
+// New panics when config.FPRate is invalid.
+func New(config blobloom.Config) *Set {
+	if config.FPRate <= 0 || config.FPRate > 1 {
+		panic("false positive rate for a Bloom filter must be > 0, <= 1")
+	}
+	s := &Set{
+		capacity: config.Capacity / 2,
+		fprate:   config.FPRate, // Sum of FPRate/2, FPRate/4, ...
+	}
+	if s.capacity == 0 {
+		s.capacity = 1
+	}
+	s.grow()
+	return s
+}
+
+func (s *Set) grow() {
+	s.capacity *= 2
+	s.fprate /= 2
+	s.filters = append(s.filters, blobloom.NewOptimized(blobloom.Config{
+		Capacity: s.capacity,
+		FPRate:   s.fprate,
+	}))
+	s.count = 0
+
+	if s.log != nil {
+		s.log.Info("urlseen: set grown", "filters", len(s.filters),
+			"capacity", s.capacity, "fprate", s.fprate, "bits", s.numBits())
+	}
+}
+
+// SetLogger sets a Logger that receives an event whenever s grows.
+func (s *Set) SetLogger(l blobloom.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = l
+}
+
+// Seen reports whether rawURL has been added to s, then adds it.
+// It returns an error if rawURL cannot be canonicalized.
+func (s *Set) Seen(rawURL string) (bool, error) {
+	h, err := hash(rawURL)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.has(h) {
+		return true, nil
+	}
+	s.add(h)
+	return false, nil
+}
+
+// Has reports whether rawURL has been added to s.
+// It returns an error if rawURL cannot be canonicalized.
+func (s *Set) Has(rawURL string) (bool, error) {
+	h, err := hash(rawURL)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.has(h), nil
+}
+
+// Add adds rawURL to s.
+// It returns an error if rawURL cannot be canonicalized.
+func (s *Set) Add(rawURL string) error {
+	h, err := hash(rawURL)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(h)
+	return nil
+}
+
+func (s *Set) has(h uint64) bool {
+	for _, f := range s.filters {
+		if f.Has(h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Set) add(h uint64) {
+	if s.count >= s.capacity {
+		s.grow()
+	}
+	s.filters[len(s.filters)-1].Add(h)
+	s.count++
+}
+
+// NumBits returns the total number of bits in s's filters.
+func (s *Set) NumBits() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.numBits()
+}
+
+func (s *Set) numBits() (nbits uint64) {
+	for _, f := range s.filters {
+		nbits += f.NumBits()
+	}
+	return nbits
+}
+
+func hash(rawURL string) (uint64, error) {
+	c, err := Canonicalize(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	return keyhash.Sum64([]byte(c)), nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package urlseen
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/greatroar/blobloom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	for _, c := range []struct{ in, out string }{
+		{"http://example.com", "http://example.com/"},
+		{"HTTP://Example.COM:80/", "http://example.com/"},
+		{"https://example.com.:443/a/b/", "https://example.com/a/b"},
+		{"https://example.com:8443/a//", "https://example.com:8443/a"},
+		{"http://example.com/A?b=2&a=1&&c#frag", "http://example.com/A?a=1&b=2&c"},
+		{"http://example.com/?", "http://example.com/"},
+		{"http://example.com/a%2Fb/", "http://example.com/a%2Fb"},
+		{"http://user@[::1]:80/", "http://user@[::1]/"},
+		{"http://[::1]:8080", "http://[::1]:8080/"},
+	} {
+		out, err := Canonicalize(c.in)
+		require.NoError(t, err, c.in)
+		assert.Equal(t, c.out, out, c.in)
+	}
+
+	for _, in := range []string{"/relative", "mailto:x@example.com", "http://%zz"} {
+		_, err := Canonicalize(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestSet(t *testing.T) {
+	s := New(blobloom.Config{Capacity: 100, FPRate: 1e-4})
+	nbits := s.NumBits()
+
+	for i := 0; i < 1000; i++ {
+		seen, err := s.Seen(fmt.Sprintf("http://example.com/%d", i))
+		require.NoError(t, err)
+		assert.False(t, seen)
+	}
+	assert.Greater(t, s.NumBits(), 8*nbits)
+
+	for i := 0; i < 1000; i++ {
+		seen, err := s.Has(fmt.Sprintf("HTTP://EXAMPLE.COM:80/%d/#x", i))
+		require.NoError(t, err)
+		assert.True(t, seen)
+	}
+
+	fp := 0
+	for i := 1000; i < 100000; i++ {
+		if seen, _ := s.Has(fmt.Sprintf("http://example.com/%d", i)); seen {
+			fp++
+		}
+	}
+	assert.Less(t, fp, 20)
+
+	_, err := s.Seen("not a url")
+	assert.Error(t, err)
+}
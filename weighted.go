@@ -0,0 +1,116 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+// A WeightedFilter is a blocked Bloom filter in which the number of hash
+// functions used for a key depends on a weight class chosen by the caller.
+// Keys in a class with more hash functions get a lower false positive rate
+// at the cost of slower Adds and more bits set per key. A single
+// WeightedFilter can thus hold a few critical keys with a very low FPR
+// alongside many bulk keys with a cheaper representation.
+//
+// All classes share the same probe sequence: a key added in a class with
+// k hash functions sets the same bits as the first k probes of the other
+// classes. Has for a class reports true for a key added in that class or
+// in any class with at least as many hash functions.
+type WeightedFilter struct {
+	b []block // Shards.
+	k []int   // Number of hash functions per class.
+}
+
+// NewWeighted constructs a WeightedFilter with the given number of bits and,
+// for each weight class, the number of hash functions. Classes are numbered
+// from zero in the order given.
+//
+// The number of bits and hashes are increased silently in the same way
+// as New does. NewWeighted panics if no classes are given.
+func NewWeighted(nbits uint64, nhashes ...int) *WeightedFilter {
+	if len(nhashes) == 0 {
+		panic("blobloom: no weight classes given")
+	}
+
+	k := make([]int, len(nhashes))
+	for i, n := range nhashes {
+		_, k[i] = fixBitsAndHashes(BlockBits, n)
+	}
+	nbits, _ = fixBitsAndHashes(nbits, 2)
+
+	return &WeightedFilter{
+		b: make([]block, nbits/BlockBits),
+		k: k,
+	}
+}
+
+// Add inserts a key with hash value h into f, in the given weight class.
+func (f *WeightedFilter) Add(h uint64, class int) {
+	k := f.k[class]
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := getblock(f.b, h2)
+
+	for i := 1; i < k; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		b.setbit(h1)
+	}
+}
+
+// Has reports whether a key with hash value h has been added in the given
+// weight class or in a class with at least as many hash functions.
+// It may return a false positive.
+func (f *WeightedFilter) Has(h uint64, class int) bool {
+	k := f.k[class]
+	h1, h2 := uint32(h>>32), uint32(h)
+	b := getblock(f.b, h2)
+
+	for i := 1; i < k; i++ {
+		h1, h2 = doublehash(h1, h2, i)
+		if !b.getbit(h1) {
+			return false
+		}
+	}
+	return true
+}
+
+// Clear resets f to its empty state.
+func (f *WeightedFilter) Clear() {
+	for i := range f.b {
+		f.b[i] = block{}
+	}
+}
+
+// FillRatio returns the fraction of bits in f that are set.
+func (f *WeightedFilter) FillRatio() float64 {
+	return fillRatio(f.b, onescount)
+}
+
+// FPRate estimates the false positive rate of Has for the given weight
+// class, given the current contents of f.
+//
+// Unlike the FPRate function, which predicts the rate from the number
+// of keys, this method measures how full each block is, so it accounts
+// for the mix of classes that keys have been added in.
+func (f *WeightedFilter) FPRate(class int) float64 {
+	return currentFPRate(f.b, f.k[class], onescount)
+}
+
+// NumBits returns the number of bits of f.
+func (f *WeightedFilter) NumBits() uint64 {
+	return BlockBits * uint64(len(f.b))
+}
+
+// NumClasses returns the number of weight classes of f.
+func (f *WeightedFilter) NumClasses() int { return len(f.k) }
+
+// NumHashes returns the number of hash functions for the given weight class.
+func (f *WeightedFilter) NumHashes(class int) int { return f.k[class] }
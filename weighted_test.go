@@ -0,0 +1,78 @@
+// Copyright 2023 the Blobloom authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blobloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeighted(t *testing.T) {
+	const nbulk, ncritical = 20000, 100
+
+	f := NewWeighted(1<<18, 3, 12)
+	assert.Equal(t, 2, f.NumClasses())
+	assert.Equal(t, 3, f.NumHashes(0))
+	assert.Equal(t, 12, f.NumHashes(1))
+
+	keys := randomU64(nbulk+ncritical, 0x3e1)
+	bulk, critical := keys[:nbulk], keys[nbulk:]
+	for _, h := range bulk {
+		f.Add(h, 0)
+	}
+	for _, h := range critical {
+		f.Add(h, 1)
+	}
+
+	for _, h := range bulk {
+		assert.True(t, f.Has(h, 0))
+	}
+	for _, h := range critical {
+		assert.True(t, f.Has(h, 1))
+		assert.True(t, f.Has(h, 0)) // Shared probe sequence.
+	}
+
+	var fp [2]int
+	const ntests = 100000
+	for _, h := range randomU64(ntests, 0xfa15e) {
+		for class := range fp {
+			if f.Has(h, class) {
+				fp[class]++
+			}
+		}
+	}
+	assert.Less(t, fp[1], fp[0])
+
+	// The estimates should be in the right ballpark.
+	for class := range fp {
+		est := f.FPRate(class)
+		assert.InDelta(t, est, float64(fp[class])/ntests, est/2+1e-4)
+	}
+
+	f.Clear()
+	assert.Zero(t, f.FillRatio())
+	assert.False(t, f.Has(critical[0], 1))
+}
+
+func TestWeightedFix(t *testing.T) {
+	f := NewWeighted(0, 0, 1, 5)
+	assert.Equal(t, uint64(BlockBits), f.NumBits())
+	assert.Equal(t, 2, f.NumHashes(0))
+	assert.Equal(t, 2, f.NumHashes(1))
+	assert.Equal(t, 5, f.NumHashes(2))
+
+	assert.Panics(t, func() { NewWeighted(BlockBits) })
+}